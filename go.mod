@@ -7,4 +7,10 @@ toolchain go1.24.10
 require (
 	github.com/andybalholm/brotli v1.2.0
 	github.com/klauspost/compress v1.18.1
+	github.com/refraction-networking/utls v1.8.2
+)
+
+require (
+	golang.org/x/crypto v0.36.0 // indirect
+	golang.org/x/sys v0.31.0 // indirect
 )
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ghostlawless/xdl/internal/audit"
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+var altTextColumns = []string{"tweet_id", "file", "url", "alt_text"}
+
+func (p *Pipeline) altText(dir string, cp *downloader.Checkpoint) {
+	if !p.r0.AltText || p.r0.Pack != nil {
+		return
+	}
+	if err := writeAltText(dir, cp.DoneItems()); err != nil {
+		log.LogError("alt-text", err.Error())
+	}
+}
+
+func writeAltText(dir string, items []downloader.CheckpointItem) error {
+	fp := paths.AltText(dir)
+	rows := map[string][]string{}
+	if f, err := os.Open(fp); err == nil {
+		rs, err := csv.NewReader(f).ReadAll()
+		f.Close()
+		if err != nil {
+			return err
+		}
+		for i, r := range rs {
+			if i == 0 || len(r) != len(altTextColumns) {
+				continue
+			}
+			rows[r[2]] = r
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	n := 0
+	for _, it := range items {
+		if it.AltText == "" {
+			continue
+		}
+		rel := it.Path
+		if r, err := filepath.Rel(dir, it.Path); err == nil {
+			rel = filepath.ToSlash(r)
+		}
+		if _, ok := rows[it.URL]; !ok {
+			n++
+		}
+		rows[it.URL] = []string{it.TweetID, rel, it.URL, it.AltText}
+	}
+	if n == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(rows))
+	for k := range rows {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := rows[keys[i]], rows[keys[j]]
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		return a[2] < b[2]
+	})
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	_ = w.Write(altTextColumns)
+	for _, k := range keys {
+		_ = w.Write(rows[k])
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	if err := utils.SaveToFile(fp, b.Bytes()); err != nil {
+		return err
+	}
+	audit.File(audit.FileWrite, fp, "")
+	return nil
+}
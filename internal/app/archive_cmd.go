@@ -0,0 +1,143 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/archive"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+var importPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^(\d{10,20})_\d+\.`),
+	regexp.MustCompile(`\[(\d{10,20})\]`),
+	regexp.MustCompile(`status(?:es)?[_-](\d{10,20})`),
+	regexp.MustCompile(`(?:^|[^0-9])(\d{15,20})(?:[^0-9]|$)`),
+}
+
+var importExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".gif": true, ".mp4": true, ".m3u8": true, ".mov": true,
+}
+
+func runArchiveCommand(args []string, runID string, runSeed []byte) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Usage: xdl archive import --from <dir> [-dry-run]")
+	}
+	switch args[0] {
+	case "import":
+		return runArchiveImport(args[1:], runID, runSeed)
+	default:
+		return fmt.Errorf("Unknown archive command: %s", args[0])
+	}
+}
+
+func runArchiveImport(args []string, runID string, runSeed []byte) error {
+	var (
+		from string
+		dry  bool
+	)
+	r0, _, err := parseCommandArgs("archive", args, runID, runSeed, func(fs *flag.FlagSet) {
+		fs.StringVar(&from, "from", "", "Folder of previously downloaded media (e.g. a gallery-dl directory)")
+		fs.BoolVar(&dry, "dry-run", false, "Count what would be imported without writing")
+	})
+	if err != nil {
+		return err
+	}
+	if from = strings.TrimSpace(from); from == "" {
+		return fmt.Errorf("Missing --from folder.\n\nUsage:\n  xdl archive import --from ./gallery-dl/twitter")
+	}
+	if !utils.DirExists(from) {
+		return fmt.Errorf("Could not find folder %s", from)
+	}
+
+	st, err := archive.Open(archive.DefaultPath(r0.OutRoot))
+	if err != nil {
+		return fmt.Errorf("Could not open the archive: %w", err)
+	}
+
+	var files, added, known, unmatched int
+	tweets := map[string]struct{}{}
+	err = filepath.WalkDir(from, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !importExts[strings.ToLower(filepath.Ext(p))] {
+			return nil
+		}
+		files++
+		id := tweetIDFromName(d.Name())
+		if id == "" {
+			unmatched++
+			log.LogInfo("archive", "import: no tweet id in "+p)
+			return nil
+		}
+		tweets[id] = struct{}{}
+		if dry {
+			return nil
+		}
+		var sz int64
+		if fi, e := d.Info(); e == nil {
+			sz = fi.Size()
+		}
+		abs, e := filepath.Abs(p)
+		if e != nil {
+			abs = p
+		}
+		if st.Import(archive.ImportRecord{TweetID: id, Handle: importHandle(from, p), Path: abs, Size: sz}) {
+			added++
+		} else {
+			known++
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Could not scan %s: %w", from, err)
+	}
+	if !dry {
+		if err := st.Save(); err != nil {
+			return fmt.Errorf("Could not save the archive: %w", err)
+		}
+	}
+
+	log.LogInfo("archive", fmt.Sprintf("import from=%s files=%d tweets=%d added=%d known=%d unmatched=%d dry=%v", from, files, len(tweets), added, known, unmatched, dry))
+	if r0.Mode == ModeQuiet {
+		return nil
+	}
+	if dry {
+		utils.PrintInfo("Would import %d files from %d tweets (%d files without a tweet ID)", files-unmatched, len(tweets), unmatched)
+		return nil
+	}
+	utils.PrintSuccess("Imported %d files from %d tweets into %s", added, len(tweets), st.Path())
+	if known > 0 {
+		utils.PrintInfo("%d files were already imported", known)
+	}
+	if unmatched > 0 {
+		utils.PrintWarn("%d files had no tweet ID in their name and were skipped (see the log)", unmatched)
+	}
+	return nil
+}
+
+func tweetIDFromName(name string) string {
+	for _, re := range importPatterns {
+		m := re.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		if t := scraper.TweetTime(m[1]); !t.IsZero() && t.Before(time.Now().Add(24*time.Hour)) {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+func importHandle(root, p string) string {
+	rel, err := filepath.Rel(root, filepath.Dir(p))
+	if err != nil || rel == "." {
+		return filepath.Base(filepath.Clean(root))
+	}
+	return strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]
+}
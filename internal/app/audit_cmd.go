@@ -0,0 +1,160 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/archive"
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/errs"
+	"github.com/ghostlawless/xdl/internal/httpx"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+type auditFinding struct {
+	TweetID string `json:"tweet_id"`
+	State   string `json:"state"`
+	Path    string `json:"path,omitempty"`
+	URL     string `json:"url"`
+}
+
+func runAuditCommand(args []string, runID string, runSeed []byte) error {
+	var asJSON bool
+	r0, rest, err := parseCommandArgs("audit", args, runID, runSeed, func(fs *flag.FlagSet) {
+		fs.BoolVar(&asJSON, "json", false, "Print one JSON object per flagged file")
+	})
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 || strings.TrimSpace(rest[0]) == "" {
+		return fmt.Errorf("Usage: xdl audit [-json] <username>\n\nExample:\n  xdl audit nasa")
+	}
+	user := strings.TrimPrefix(strings.TrimSpace(rest[0]), "@")
+
+	st, err := archive.Open(archive.DefaultPath(r0.OutRoot))
+	if err != nil {
+		return fmt.Errorf("Could not open the archive: %w", err)
+	}
+	u, ms, ok := st.MediaSince(user, time.Time{})
+	if !ok || len(ms) == 0 {
+		return fmt.Errorf("No archived media for @%s in %s", user, st.Path())
+	}
+
+	c0, err := loadSession(r0)
+	if err != nil {
+		return err
+	}
+	h0 := httpx.NewAPIClient(c0.HTTPTimeout(), c0.HeaderOrder)
+
+	byTweet := map[string][]archive.MediaRecord{}
+	for _, m := range ms {
+		if m.TweetID != "" {
+			byTweet[m.TweetID] = append(byTweet[m.TweetID], m)
+		}
+	}
+	ids := make([]string, 0, len(byTweet))
+	for id := range byTweet {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	states := map[string]scraper.TweetState{}
+	if acct := auditAccountState(h0, c0, u.ID); acct != "" {
+		if !asJSON && r0.Mode != ModeQuiet {
+			utils.PrintWarn("@%s is %s; every archived file is now the only copy", u.Handle, acct)
+		}
+		for _, id := range ids {
+			states[id] = acct
+		}
+	} else {
+		l0 := newPipeline(r0, c0, h0, nil).limiter()
+		for i, p0 := 0, 1; i < len(ids); i, p0 = i+scraper.TweetBatchSize, p0+1 {
+			j := min(i+scraper.TweetBatchSize, len(ids))
+			l0.SleepBeforeRequest(context.Background(), "audit", p0, p0)
+			got, err := scraper.FetchTweetStates(h0, c0, ids[i:j])
+			if err != nil {
+				log.LogError("audit", err.Error())
+				if h := remediate(user, err); h != nil && r0.Mode != ModeDebug {
+					err = h
+				}
+				return err
+			}
+			for id, s := range got {
+				states[id] = s
+			}
+			if !asJSON && r0.Mode != ModeQuiet {
+				utils.PrintInfo("Checked %d/%d tweets", j, len(ids))
+			}
+		}
+	}
+
+	var out []auditFinding
+	counts := map[scraper.TweetState]int{}
+	for _, id := range ids {
+		s := states[id]
+		if s == "" || s == scraper.TweetLive {
+			continue
+		}
+		counts[s]++
+		for _, m := range byTweet[id] {
+			p := filepath.FromSlash(m.Path)
+			if p != "" && !filepath.IsAbs(p) {
+				p = filepath.Join(st.Root(), p)
+			}
+			out = append(out, auditFinding{TweetID: id, State: string(s), Path: p, URL: m.URL})
+		}
+	}
+	log.LogInfo("audit", fmt.Sprintf("user=%s tweets=%d media=%d flagged=%d", u.Handle, len(ids), len(ms), len(out)))
+
+	if asJSON {
+		enc := json.NewEncoder(utils.Stdout)
+		for _, f := range out {
+			_ = enc.Encode(f)
+		}
+		return nil
+	}
+	if r0.Mode == ModeQuiet {
+		return nil
+	}
+	for _, f := range out {
+		fmt.Fprintf(utils.Stdout, "%-11s %s  %s\n", f.State, f.TweetID, f.Path)
+	}
+	if len(out) == 0 {
+		utils.PrintSuccess("All %d archived tweets from @%s are still online", len(ids), u.Handle)
+		return nil
+	}
+	var parts []string
+	for _, s := range []scraper.TweetState{scraper.TweetDeleted, scraper.TweetProtected, scraper.TweetUnavailable} {
+		if counts[s] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[s], s))
+		}
+	}
+	utils.PrintWarn("%d files from @%s are no longer online (%s tweets of %d)", len(out), u.Handle, strings.Join(parts, ", "), len(ids))
+	return nil
+}
+
+func auditAccountState(h0 *http.Client, c0 *config.EssentialsConfig, id string) scraper.TweetState {
+	if id == "" {
+		return ""
+	}
+	_, err := scraper.FetchUserByRestID(h0, c0, id)
+	switch {
+	case errors.Is(err, errs.ErrUserSuspended):
+		return scraper.TweetUnavailable
+	case errors.Is(err, errs.ErrUserNotFound):
+		return scraper.TweetDeleted
+	case errors.Is(err, errs.ErrProtectedAccount):
+		return scraper.TweetProtected
+	}
+	return ""
+}
@@ -0,0 +1,191 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/errs"
+)
+
+const minBurstInterval = 30 * time.Second
+
+type burstRule struct {
+	Every time.Duration
+	For   time.Duration
+}
+
+type burstFlags map[string]burstRule
+
+func (b burstFlags) String() string {
+	ks := make([]string, 0, len(b))
+	for k, r := range b {
+		ks = append(ks, fmt.Sprintf("%s=%s/%s", k, r.Every, r.For))
+	}
+	sort.Strings(ks)
+	return strings.Join(ks, ",")
+}
+
+func (b burstFlags) Set(v string) error {
+	u, r, err := parseBurst(v)
+	if err != nil {
+		return err
+	}
+	b[u] = r
+	return nil
+}
+
+func parseBurst(v string) (string, burstRule, error) {
+	u, spec := "", strings.TrimSpace(v)
+	if i := strings.Index(spec, "="); i >= 0 {
+		u = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(spec[:i]), "@"))
+		spec = spec[i+1:]
+	}
+	every, dur, ok := strings.Cut(spec, "/")
+	if !ok {
+		return "", burstRule{}, fmt.Errorf("burst %q: want [user=]INTERVAL/DURATION, e.g. 2m/1h", v)
+	}
+	e, err := time.ParseDuration(strings.TrimSpace(every))
+	if err != nil {
+		return "", burstRule{}, fmt.Errorf("burst %q: %w", v, err)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(dur))
+	if err != nil {
+		return "", burstRule{}, fmt.Errorf("burst %q: %w", v, err)
+	}
+	if e < minBurstInterval || d <= 0 {
+		return "", burstRule{}, fmt.Errorf("burst %q: interval must be at least %s and duration positive", v, minBurstInterval)
+	}
+	return u, burstRule{Every: e, For: d}, nil
+}
+
+type watchState struct {
+	next       time.Time
+	interval   time.Duration
+	burstUntil time.Time
+	scanned    bool
+}
+
+type watchScheduler struct {
+	base    time.Duration
+	every   map[string]time.Duration
+	rules   burstFlags
+	users   []string
+	state   map[string]*watchState
+	nextIDs time.Time
+	hold    time.Time
+}
+
+func newWatchScheduler(r0 RunContext, now time.Time) *watchScheduler {
+	s := &watchScheduler{
+		base:  r0.Watch,
+		every: map[string]time.Duration{},
+		rules: r0.Bursts,
+		users: r0.Users,
+		state: make(map[string]*watchState, len(r0.Users)),
+	}
+	for u, o := range r0.Overrides {
+		if o.every > 0 {
+			s.every[u] = o.every
+		}
+	}
+	for _, u := range r0.Users {
+		s.state[strings.ToLower(u)] = &watchState{next: now, interval: s.baseFor(u)}
+	}
+	if r0.IDsFile != "" {
+		s.nextIDs = now
+	}
+	return s
+}
+
+func (s *watchScheduler) due(now time.Time) (users []string, ids bool) {
+	for _, u := range s.users {
+		if st := s.state[strings.ToLower(u)]; !now.Before(st.next) {
+			users = append(users, u)
+		}
+	}
+	ids = !s.nextIDs.IsZero() && !now.Before(s.nextIDs)
+	return users, ids
+}
+
+func (s *watchScheduler) baseFor(u string) time.Duration {
+	if d, ok := s.every[strings.ToLower(u)]; ok {
+		return d
+	}
+	return s.base
+}
+
+func (s *watchScheduler) rule(u string) (burstRule, bool) {
+	if r, ok := s.rules[strings.ToLower(u)]; ok {
+		return r, true
+	}
+	r, ok := s.rules[""]
+	return r, ok
+}
+
+func (s *watchScheduler) update(now time.Time, users []string, ids bool, rep *RunReport) []string {
+	got := map[string]targetReport{}
+	rep.mu.Lock()
+	for _, t := range rep.Targets {
+		got[strings.ToLower(t.User)] = t
+		if errors.Is(t.Err, errs.ErrRateLimited) {
+			h := now.Add(s.base)
+			if r := errs.ResetTime(t.Err); r.After(now) {
+				h = r
+			}
+			if h.After(s.hold) {
+				s.hold = h
+			}
+		}
+	}
+	rep.mu.Unlock()
+
+	var started []string
+	for _, u := range users {
+		st := s.state[strings.ToLower(u)]
+		r, ok := s.rule(u)
+		t := got[strings.ToLower(u)]
+		b := s.baseFor(u)
+		switch {
+		case !ok:
+			st.interval = b
+		case now.Before(s.hold):
+			st.interval = max(b, s.hold.Sub(now))
+			st.burstUntil = time.Time{}
+		case t.Downloaded > 0 && st.scanned:
+			if !now.Before(st.burstUntil) {
+				started = append(started, u)
+			}
+			st.burstUntil = now.Add(r.For)
+			st.interval = r.Every
+		case now.Before(st.burstUntil):
+			st.interval = r.Every
+		default:
+			st.interval = min(st.interval*2, b)
+		}
+		st.next = now.Add(st.interval)
+		st.scanned = true
+	}
+	if ids {
+		s.nextIDs = now.Add(s.base)
+	}
+	return started
+}
+
+func (s *watchScheduler) wait(now time.Time) time.Duration {
+	var next time.Time
+	for _, st := range s.state {
+		if next.IsZero() || st.next.Before(next) {
+			next = st.next
+		}
+	}
+	if !s.nextIDs.IsZero() && (next.IsZero() || s.nextIDs.Before(next)) {
+		next = s.nextIDs
+	}
+	if d := next.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
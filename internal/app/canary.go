@@ -0,0 +1,98 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/errs"
+	"github.com/ghostlawless/xdl/internal/httpx"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+const (
+	canaryUser   = "X"
+	canaryUserID = "783214"
+)
+
+type canaryStep struct {
+	Name   string
+	Err    error
+	Detail string
+}
+
+func canaryClass(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, errs.ErrShapeChanged):
+		return "response shape changed, update needed"
+	case errors.Is(err, errs.ErrAuthExpired):
+		return "auth error"
+	case errors.Is(err, errs.ErrRateLimited):
+		return "rate limited"
+	}
+	return "network error"
+}
+
+func runCanary(c0 *config.EssentialsConfig, h0 *http.Client) []canaryStep {
+	out := make([]canaryStep, 0, 2)
+
+	id, err := scraper.FetchUserID(h0, c0, canaryUser)
+	if err == nil && id != canaryUserID {
+		err = &errs.APIError{Op: "UserByScreenName", Message: fmt.Sprintf("expected rest_id %s, got %q", canaryUserID, id), Kind: errs.ErrShapeChanged}
+	}
+	if errors.Is(err, errs.ErrUserNotFound) {
+		err = &errs.APIError{Op: "UserByScreenName", Message: err.Error(), Kind: errs.ErrShapeChanged}
+	}
+	out = append(out, canaryStep{Name: "UserByScreenName", Err: err, Detail: "@" + canaryUser})
+
+	p, err := scraper.ProbeUserMedia(h0, c0, canaryUserID)
+	out = append(out, canaryStep{Name: "UserMedia", Err: err, Detail: fmt.Sprintf("media=%d cursor=%v", p.Media, p.Cursor)})
+
+	for _, s := range out {
+		if s.Err != nil {
+			log.LogError("canary", fmt.Sprintf("%s: %s: %v", s.Name, canaryClass(s.Err), s.Err))
+		} else {
+			log.LogInfo("canary", fmt.Sprintf("%s: ok (%s)", s.Name, s.Detail))
+		}
+	}
+	return out
+}
+
+func canaryErr(steps []canaryStep) error {
+	var es []error
+	for _, s := range steps {
+		if s.Err != nil {
+			es = append(es, fmt.Errorf("%s: %w", s.Name, s.Err))
+		}
+	}
+	return errors.Join(es...)
+}
+
+func runCanaryCommand(args []string, runID string, runSeed []byte) error {
+	r0, _, err := parseCommandArgs("canary", args, runID, runSeed, nil)
+	if err != nil {
+		return err
+	}
+	c0, err := loadSession(r0)
+	if err != nil {
+		return err
+	}
+	h0 := httpx.NewAPIClient(c0.HTTPTimeout(), c0.HeaderOrder)
+
+	steps := runCanary(c0, h0)
+	if r0.Mode != ModeQuiet {
+		for _, s := range steps {
+			if s.Err != nil {
+				utils.PrintError("%-18s %s: %v", s.Name, canaryClass(s.Err), s.Err)
+				continue
+			}
+			utils.PrintInfo("%-18s ok (%s)", s.Name, s.Detail)
+		}
+	}
+	return canaryErr(steps)
+}
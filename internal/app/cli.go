@@ -1,14 +1,21 @@
 package app
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/ghostlawless/xdl/internal/archive"
+	"github.com/ghostlawless/xdl/internal/downloader"
 	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/utils"
 )
 
 type RunContext struct {
@@ -22,6 +29,62 @@ type RunContext struct {
 	OutRoot           string
 	NoDownload        bool
 	DryRun            bool
+	Store             *archive.Store
+	Pack              *downloader.Pack
+	DownloadArchive   *archive.IDList
+	Layout            *paths.Layout
+	IDFallback        bool
+	WaitRateLimit     bool
+	JSON              bool
+	AbortOnPipe       bool
+	DumpRaw           bool
+	Watch             time.Duration
+	Grace             time.Duration
+	DebugAddr         string
+	ServeAddr         string
+	IDsFile           string
+	OTLP              string
+	Bursts            burstFlags
+	Audit             bool
+	PageSize          int
+	MaxPages          int
+	MaxTweets         int
+	Deep              bool
+	StopAfterDups     int
+	NewerThan         string
+	ArchiveOutput     string
+	IDListPath        string
+	ReportFile        string
+	Thumbs            bool
+	Sample            int
+	Takeout           string
+	NoAuth            bool
+	CacheTTL          time.Duration
+	Record            string
+	Replay            string
+	IPVersion         int
+	HAR               string
+	AllImageSizes     bool
+	AltText           bool
+	WriteText         string
+	GeoJSON           bool
+	IncludeCards      bool
+	IncludeRetweets   bool
+	Attr              string
+	TargetsFile       string
+	Overrides         map[string]*targetOptions
+	LockWait          time.Duration
+	Order             string
+	UserTimeout       time.Duration
+	StallTimeout      time.Duration
+	Preflight         bool
+	CheckSize         bool
+	StrictParse       bool
+	Confirm           bool
+	ProgressFile      string
+	Headless          bool
+
+	render renderer
 }
 
 type RunMode int
@@ -52,13 +115,33 @@ func RunWithArgs(args []string) error {
 }
 
 func RunWithArgsAndID(args []string, runID string, runSeed []byte) error {
+	if len(args) > 0 {
+		if c0, ok := commands[args[0]]; ok {
+			return c0(args[1:], runID, runSeed)
+		}
+	}
 	r0, e0 := parseArgs(args, runID, runSeed)
+	var h0 *helpRequest
+	if errors.As(e0, &h0) {
+		writeUsage(utils.Stdout, h0.flags)
+		return nil
+	}
 	if e0 != nil {
 		return e0
 	}
 	return runWithContext(r0)
 }
 
+func ipVersion(v4, v6 bool) int {
+	switch {
+	case v4:
+		return 4
+	case v6:
+		return 6
+	}
+	return 0
+}
+
 func parseArgs(a0 []string, p0 string, p1 []byte) (RunContext, error) {
 	a1 := make([]string, 0, len(a0))
 	for _, a2 := range a0 {
@@ -75,70 +158,395 @@ func parseArgs(a0 []string, p0 string, p1 []byte) (RunContext, error) {
 	var (
 		v0 bool
 		v1 bool
+		v2 time.Duration
+		v3 string
+		v4 time.Duration
+		v5 bool
+		v6 bool
+		v7 bool
+		v8 string
+		v9 bool
+		va string
+		vb string
+		vc string
+		vd = burstFlags{}
+		ve bool
+		vf int
+		vg int
+		vh int
+		vi bool
+		vj int
+		vk string
+		vl string
+		vm string
+		vn string
+		vo bool
+		vp bool
+		vq int
+		vr bool
+		vs time.Duration
+		vt string
+		vu string
+		vv bool
+		vw bool
+		vx string
+		vy bool
+		vz bool
+		w0 string
+		w1 bool
+		w2 bool
+		w3 bool
+		w4 string
+		w5 string
+		w6 string
+		w7 time.Duration
+		w8 string
+		w9 time.Duration
+		wa time.Duration
+		wb bool
+		wc bool
+		wd bool
+		we bool
+		wf string
+		wg string
+		wh bool
 	)
 
 	z0 := flag.NewFlagSet("xdl", flag.ContinueOnError)
 	z0.SetOutput(io.Discard)
-	z0.BoolVar(&v0, "q", false, "Quiet mode")
+	z0.BoolVar(&v0, "q", false, "Quiet mode: print one key=value summary line per user, errors on stderr")
 	z0.BoolVar(&v1, "d", false, "Debug mode")
+	z0.DurationVar(&v2, "watch", 0, "Re-run every interval until stopped")
+	z0.DurationVar(&v4, "grace", 0, "Shutdown grace period for in-flight downloads")
+	z0.BoolVar(&v5, "id-fallback", true, "Retry failed lookups by stored user ID")
+	z0.BoolVar(&v6, "wait-for-rate-limit", utils.IsTerminal(os.Stdout), "Block until a rate limit resets instead of failing")
+	z0.BoolVar(&v7, "json", false, "Print the run report as JSON")
+	z0.BoolVar(&wh, "headless", false, "For Docker and systemd: no keyboard controls, spinner or progress bars, JSON log lines on stdout, and flags and users read from XDL_* variables")
+	z0.StringVar(&wf, "color", utils.ColorAuto, "Color the xdl> and xdl! prefixes: auto (terminal and no NO_COLOR), always or never")
+	z0.StringVar(&v8, "on-broken-pipe", "continue", "What to do when stdout is closed: continue or abort")
+	z0.BoolVar(&v9, "dump-raw", false, "Save redacted GraphQL responses under logs/run_<id>/raw")
+	z0.StringVar(&va, "serve", "", "Serve the archive API on this address (e.g. "+defaultServeAddr+")")
+	z0.StringVar(&vb, "ids", "", "Download media for the tweet IDs listed in this file")
+	z0.StringVar(&vc, "otlp", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "Export OTLP/HTTP traces to this endpoint")
+	z0.Var(vd, "burst", "With -watch, scan [user=]every/for after new media, e.g. nasa=2m/1h (repeatable)")
+	z0.BoolVar(&ve, "audit", false, "Append every file and archive change to .xdl/audit.jsonl")
+	z0.IntVar(&vf, "page-size", 0, "Media per timeline request, 1-100 (default from essentials.json)")
+	z0.IntVar(&vg, "max-pages", 0, "Stop scanning a user after this many pages")
+	z0.IntVar(&vh, "max-tweets", 0, "Stop scanning a user after this many tweets with media")
+	z0.BoolVar(&vi, "deep", false, "After the media timeline ends, search older media by date window")
+	z0.IntVar(&vj, "stop-after-duplicates", 0, "Stop scanning a user after this many already-archived tweets in a row")
+	z0.StringVar(&vk, "newer-than", "", "Only scan tweets newer than this tweet ID")
+	z0.StringVar(&vm, "download-archive", "", "Skip tweets listed in this gallery-dl/yt-dlp style file and record new ones")
+	z0.StringVar(&vn, "report", "", "Append one row per user to this .csv (or .jsonl) file")
+	z0.StringVar(&wg, "progress-file", "", "Keep a JSON snapshot of the run's progress in this file, or write one line per update to it if it is a named pipe")
+	z0.BoolVar(&vy, "all-image-sizes", false, "Also save the small, medium, large and orig size of every photo under sizes/<tweet_id>/")
+	z0.BoolVar(&vz, "alt-text", false, "Collect the alt text of every downloaded file into alt_text.csv")
+	z0.StringVar(&w0, "write-text", "", "Save the text of every downloaded tweet: txt (text/<tweet_id>.txt) or ndjson (tweets.ndjson)")
+	z0.BoolVar(&w1, "geojson", false, "Write the location of geotagged media to geo.geojson in each user folder")
+	z0.BoolVar(&w2, "include-cards", false, "Also download link-preview card images and record poll results of tweets with media")
+	z0.BoolVar(&w3, "include-retweets", false, "Also download media the user retweeted or quoted")
+	z0.StringVar(&w4, "attr", attrRetweeter, "With -include-retweets, save retweeted media under the retweeter or the original author's folder")
+	z0.StringVar(&w6, "output", "", "Download root (default $"+paths.RootEnv+" or "+paths.DefaultRoot+"); ~ and $VARS are expanded")
+	z0.StringVar(&w6, "o", "", "Shorthand for -output")
+	z0.DurationVar(&w9, "user-timeout", 0, "Stop a user that is still running after this long (e.g. 2h) and move on")
+	z0.DurationVar(&wa, "stall-timeout", defaultStallTimeout, "Stop a user after this long without a new page or downloaded byte (0 = never)")
+	z0.StringVar(&w8, "order", orderAsGiven, "Start users in this order: "+strings.Join(targetOrders, ", "))
+	z0.DurationVar(&w7, "lock-wait", 0, "When another xdl run holds a user, wait this long for it instead of skipping the user")
+	z0.StringVar(&w5, "targets", "", "Read usernames and per-user options (max_pages, output, watch, ...) from this .yaml or .json file")
+	z0.BoolVar(&vo, "thumbs", false, "Save a poster frame for each video under thumbs/ (ffmpeg or the tweet preview)")
+	z0.BoolVar(&wb, "preflight", false, "HEAD every media URL of a page first, skip deleted ones (404) and count only live files; with -dry-run, size all of them")
+	z0.BoolVar(&wc, "check-size", false, "Before skipping a file that already exists, compare its size with the remote one and download it again if they differ")
+	z0.BoolVar(&wd, "strict-parse", false, "Fail a user as soon as a timeline entry has a shape xdl does not know, instead of skipping it and counting it in the summary")
+	z0.BoolVar(&we, "confirm", false, "Scan each user fully, show what was found and ask before downloading, with images and videos toggled separately")
+	z0.BoolVar(&vp, "dry-run", false, "Scan without downloading and estimate the total size")
+	z0.IntVar(&vq, "sample", downloader.DefaultEstimateSample, "With -dry-run, HEAD this many media per type to estimate size (0 = all)")
+	z0.BoolVar(&vr, "no-auth", false, "Skip cookies and read recent media from the public syndication timeline")
+	z0.DurationVar(&vs, "cache", 0, "Reuse identical API responses for this long, revalidating with ETags (e.g. 10m)")
+	z0.StringVar(&vt, "record", "", "Save every API request and response (secrets redacted) to this cassette file")
+	z0.StringVar(&vu, "replay", "", "Answer API requests from this cassette file instead of the network (needs -dry-run)")
+	z0.BoolVar(&vv, "force-ipv4", false, "Only connect over IPv4")
+	z0.BoolVar(&vw, "force-ipv6", false, "Only connect over IPv6")
+	z0.StringVar(&vx, "har", "", "Write all API traffic of the run to this HAR file (cookies redacted, bodies truncated)")
+	z0.StringVar(&vl, "archive-output", "", "Write media into one .tar, .tar.gz, .tar.zst or .zip file")
+	z0.StringVar(&v3, "debug-goroutines", "", "Serve goroutine dumps on this address")
 
-	if e0 := z0.Parse(a1); e0 != nil {
-		return RunContext{}, fmt.Errorf(
-			"Invalid arguments: %v\n\nUsage:\n  xdl [-q|-d] <username> [more_usernames...]\n\nExamples:\n  xdl google\n  xdl google nasa\n  xdl -d google",
-			e0,
-		)
+	if e0 := z0.Parse(a1); errors.Is(e0, flag.ErrHelp) {
+		return RunContext{}, &helpRequest{flags: z0}
+	} else if e0 != nil {
+		return RunContext{}, fmt.Errorf("Invalid arguments: %v\n\n%s", e0, usageText)
+	}
+	if wh = wh || headlessEnv(); wh {
+		if e0 := flagsFromEnv(z0); e0 != nil {
+			return RunContext{}, e0
+		}
 	}
 
-	u0 := make([]string, 0, len(z0.Args()))
-	for _, u1 := range z0.Args() {
-		u2 := strings.TrimSpace(u1)
-		if u2 == "" {
-			continue
+	switch v8 {
+	case "continue", "abort":
+	default:
+		return RunContext{}, fmt.Errorf("Invalid -on-broken-pipe value %q (use continue or abort)", v8)
+	}
+	if e0 := utils.SetColor(strings.ToLower(strings.TrimSpace(wf))); e0 != nil {
+		return RunContext{}, fmt.Errorf("Invalid -color value %q (use auto, always or never)", wf)
+	}
+
+	if vf < 0 || vf > 100 {
+		return RunContext{}, fmt.Errorf("Invalid -page-size %d (use 1-100)", vf)
+	}
+	if vg < 0 || vh < 0 {
+		return RunContext{}, fmt.Errorf("-max-pages and -max-tweets must be positive")
+	}
+	if vj < 0 {
+		return RunContext{}, fmt.Errorf("-stop-after-duplicates must be positive")
+	}
+	if vq < 0 {
+		return RunContext{}, fmt.Errorf("-sample must be positive")
+	}
+	if vs < 0 {
+		return RunContext{}, fmt.Errorf("-cache must be positive")
+	}
+	if w7 < 0 {
+		return RunContext{}, fmt.Errorf("-lock-wait must be positive")
+	}
+	if w9 < 0 || wa < 0 {
+		return RunContext{}, fmt.Errorf("-user-timeout and -stall-timeout must be positive")
+	}
+	if w8 = strings.ToLower(strings.TrimSpace(w8)); !validOrder(w8) {
+		return RunContext{}, fmt.Errorf("Invalid -order value %q (use %s)", w8, strings.Join(targetOrders, ", "))
+	}
+	switch w4 = strings.ToLower(strings.TrimSpace(w4)); w4 {
+	case attrRetweeter, attrOriginal:
+	default:
+		return RunContext{}, fmt.Errorf("Invalid -attr value %q (use original or retweeter)", w4)
+	}
+	switch w0 = strings.ToLower(strings.TrimSpace(w0)); w0 {
+	case "", textTxt, textNDJSON:
+	default:
+		return RunContext{}, fmt.Errorf("Invalid -write-text value %q (use txt or ndjson)", w0)
+	}
+	if vk = strings.TrimSpace(vk); vk != "" {
+		if _, e0 := strconv.ParseUint(vk, 10, 64); e0 != nil {
+			return RunContext{}, fmt.Errorf("Invalid -newer-than %q (use a numeric tweet ID)", vk)
 		}
-		u0 = append(u0, u2)
 	}
 
-	if len(u0) == 0 {
-		return RunContext{}, fmt.Errorf(
-			"Missing username.\n\nUsage:\n  xdl [-q|-d] <username> [more_usernames...]\n\nExamples:\n  xdl google\n  xdl google nasa\n  xdl -d google",
-		)
+	if vv && vw {
+		return RunContext{}, fmt.Errorf("-force-ipv4 and -force-ipv6 cannot be used together")
+	}
+	if vt != "" && vu != "" {
+		return RunContext{}, fmt.Errorf("-record and -replay cannot be used together")
+	}
+	if vu != "" && !vp {
+		return RunContext{}, fmt.Errorf("-replay only covers API calls; add -dry-run so no media is fetched")
+	}
+
+	if vr && vi {
+		return RunContext{}, fmt.Errorf("-deep needs a logged-in session and cannot be used with -no-auth")
+	}
+
+	if we && (vp || v2 > 0 || v7 || v0 || wh) {
+		return RunContext{}, fmt.Errorf("-confirm asks at the terminal and cannot be used with -q, -dry-run, -watch, -json or -headless")
+	}
+
+	if v0 && wh {
+		return RunContext{}, fmt.Errorf("-headless already prints JSON log lines on stdout and cannot be used with -q; use -json for a machine-readable report")
+	}
+
+	if len(vd) > 0 && v2 <= 0 {
+		return RunContext{}, fmt.Errorf("-burst needs -watch (e.g. -watch 30m -burst 2m/1h)")
+	}
+
+	u0 := z0.Args()
+	if wh && len(u0) == 0 {
+		u0 = usersFromEnv()
+	}
+	var o0 map[string]*targetOptions
+	if w5 = strings.TrimSpace(w5); w5 != "" {
+		u1, o1, e0 := loadTargetsFile(w5)
+		if e0 != nil {
+			return RunContext{}, fmt.Errorf("Invalid -targets file: %w", e0)
+		}
+		u0, o0 = append(u0, u1...), o1
+		for u, o := range o0 {
+			if o.every > 0 && v2 <= 0 {
+				return RunContext{}, fmt.Errorf("-targets sets watch for @%s; per-user intervals need -watch", u)
+			}
+		}
+	}
+	u0 = dedupeUsers(u0)
+
+	if len(u0) == 0 && strings.TrimSpace(vb) == "" && wh {
+		return RunContext{}, fmt.Errorf("Missing username: pass usernames or set XDL_USERS")
+	}
+	if len(u0) == 0 && strings.TrimSpace(vb) == "" {
+		return RunContext{}, fmt.Errorf("Missing username.\n\n%s", usageText)
 	}
 
 	r0 := RunContext{
-		Users:      u0,
-		Mode:       ModeVerbose,
-		RunID:      p0,
-		RunSeed:    p1,
-		OutRoot:    "xDownloads",
-		NoDownload: false,
-		DryRun:     false,
+		Users:           u0,
+		Mode:            ModeVerbose,
+		RunID:           p0,
+		RunSeed:         p1,
+		OutRoot:         paths.Root(w6),
+		NoDownload:      false,
+		DryRun:          vp,
+		IDFallback:      v5,
+		WaitRateLimit:   v6,
+		JSON:            v7,
+		AbortOnPipe:     v8 == "abort",
+		DumpRaw:         v9,
+		Watch:           v2,
+		Grace:           v4,
+		ServeAddr:       strings.TrimSpace(va),
+		IDsFile:         strings.TrimSpace(vb),
+		DebugAddr:       v3,
+		OTLP:            vc,
+		Bursts:          vd,
+		Audit:           ve,
+		PageSize:        vf,
+		MaxPages:        vg,
+		MaxTweets:       vh,
+		Deep:            vi,
+		StopAfterDups:   vj,
+		NewerThan:       vk,
+		ArchiveOutput:   strings.TrimSpace(vl),
+		IDListPath:      strings.TrimSpace(vm),
+		ReportFile:      strings.TrimSpace(vn),
+		Thumbs:          vo,
+		Sample:          vq,
+		NoAuth:          vr,
+		CacheTTL:        vs,
+		Record:          strings.TrimSpace(vt),
+		Replay:          strings.TrimSpace(vu),
+		IPVersion:       ipVersion(vv, vw),
+		HAR:             strings.TrimSpace(vx),
+		AllImageSizes:   vy,
+		AltText:         vz,
+		WriteText:       w0,
+		GeoJSON:         w1,
+		IncludeCards:    w2,
+		IncludeRetweets: w3,
+		Attr:            w4,
+		TargetsFile:     w5,
+		Overrides:       o0,
+		LockWait:        w7,
+		Order:           w8,
+		UserTimeout:     w9,
+		StallTimeout:    wa,
+		Preflight:       wb,
+		CheckSize:       wc,
+		StrictParse:     wd,
+		Confirm:         we,
+		ProgressFile:    strings.TrimSpace(wg),
+		Headless:        wh,
 	}
 
 	if v1 {
 		r0.Mode = ModeDebug
-	} else if v0 {
+	} else if v0 || v7 {
 		r0.Mode = ModeQuiet
 	}
 
+	m0 := "multi"
+	if len(r0.Users) == 0 {
+		m0 = "ids"
+	}
+	if len(r0.Users) == 1 && strings.TrimSpace(r0.Users[0]) != "" {
+		m0 = r0.Users[0]
+	}
+	if e1 := initRunLogging(&r0, m0); e1 != nil {
+		return RunContext{}, e1
+	}
+
+	return r0, nil
+}
+
+func initRunLogging(r0 *RunContext, label string) error {
 	if r0.RunID == "" {
 		r0.RunID = generateRunID()
 	}
 
-	if r0.Mode == ModeDebug {
-		m0 := "multi"
-		if len(r0.Users) == 1 && strings.TrimSpace(r0.Users[0]) != "" {
-			m0 = r0.Users[0]
+	if r0.Headless {
+		utils.SetLogged(true)
+		log.UseJSON(utils.Stdout)
+	}
+	if r0.Mode != ModeDebug {
+		if !r0.Headless {
+			log.Disable()
 		}
+		return nil
+	}
 
-		r0.LogPath = filepath.Join(p9(), "debug", "run_"+m0+"_"+r0.RunID)
-		if e1 := os.MkdirAll(r0.LogPath, 0o755); e1 != nil {
-			return RunContext{}, fmt.Errorf("Could not create debug folder: %w", e1)
-		}
-		log.Init(filepath.Join(r0.LogPath, "main.log"))
-		log.LogInfo("main", "Debug mode enabled; logs stored in "+r0.LogPath)
-	} else {
-		log.Disable()
+	r0.LogPath = filepath.Join(p9(), "debug", "run_"+label+"_"+r0.RunID)
+	if e1 := os.MkdirAll(r0.LogPath, 0o755); e1 != nil {
+		return fmt.Errorf("Could not create debug folder: %w", e1)
 	}
+	log.Init(filepath.Join(r0.LogPath, "main.log"))
+	log.LogInfo("main", "Debug mode enabled; logs stored in "+r0.LogPath)
+	return nil
+}
 
-	return r0, nil
+func parseCommandArgs(name string, a0 []string, p0 string, p1 []byte, bind func(*flag.FlagSet)) (RunContext, []string, error) {
+	var (
+		v0 bool
+		v1 bool
+		v2 string
+		v3 string
+	)
+
+	z0 := flag.NewFlagSet("xdl "+name, flag.ContinueOnError)
+	z0.SetOutput(io.Discard)
+	z0.BoolVar(&v0, "q", false, "Quiet mode")
+	z0.BoolVar(&v1, "d", false, "Debug mode")
+	z0.StringVar(&v3, "color", utils.ColorAuto, "Color the xdl> and xdl! prefixes: auto, always or never")
+	if bind != nil {
+		bind(z0)
+	}
+	z0.StringVar(&v2, "output", "", "Download root (default $"+paths.RootEnv+" or "+paths.DefaultRoot+")")
+	if z0.Lookup("o") == nil {
+		z0.StringVar(&v2, "o", "", "Shorthand for -output")
+	}
+
+	if e0 := z0.Parse(a0); e0 != nil {
+		return RunContext{}, nil, fmt.Errorf("Invalid arguments for %s: %v", name, e0)
+	}
+	if e0 := utils.SetColor(strings.ToLower(strings.TrimSpace(v3))); e0 != nil {
+		return RunContext{}, nil, fmt.Errorf("Invalid -color value %q (use auto, always or never)", v3)
+	}
+
+	r0 := RunContext{
+		Mode:       ModeVerbose,
+		RunID:      p0,
+		RunSeed:    p1,
+		OutRoot:    paths.Root(v2),
+		IDFallback: true,
+	}
+	if v1 {
+		r0.Mode = ModeDebug
+	} else if v0 {
+		r0.Mode = ModeQuiet
+	}
+
+	if e1 := initRunLogging(&r0, name); e1 != nil {
+		return RunContext{}, nil, e1
+	}
+	return r0, z0.Args(), nil
+}
+
+func dedupeUsers(in []string) []string {
+	out := make([]string, 0, len(in))
+	seen := make(map[string]struct{}, len(in))
+	for _, u := range in {
+		u = strings.TrimPrefix(strings.TrimSpace(u), "@")
+		if u == "" {
+			continue
+		}
+		k := strings.ToLower(u)
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		out = append(out, u)
+	}
+	return out
 }
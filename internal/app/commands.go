@@ -0,0 +1,21 @@
+package app
+
+type command func(args []string, runID string, runSeed []byte) error
+
+var commands = map[string]command{}
+
+func init() {
+	commands["archive"] = runArchiveCommand
+	commands["audit"] = runAuditCommand
+	commands["canary"] = runCanaryCommand
+	commands["gallery"] = runGalleryCommand
+	commands["help"] = runHelpCommand
+	commands["import-takeout"] = runTakeoutCommand
+	commands["config"] = runConfigCommand
+	commands["serve"] = runServeCommand
+	commands["parse"] = runParseCommand
+	commands["retry"] = runRetryCommand
+	commands["status"] = runStatusCommand
+	commands["verify"] = runVerifyCommand
+	commands["version"] = runVersionCommand
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/discovery"
+	"github.com/ghostlawless/xdl/internal/httpx"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+func runConfigCommand(args []string, runID string, runSeed []byte) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Usage: xdl config refresh-endpoints [-q|-d] [-o path] [-dry-run]")
+	}
+	switch args[0] {
+	case "refresh-endpoints":
+		return runRefreshEndpoints(args[1:], runID, runSeed)
+	default:
+		return fmt.Errorf("Unknown config command: %s", args[0])
+	}
+}
+
+func runRefreshEndpoints(args []string, runID string, runSeed []byte) error {
+	var (
+		out string
+		dry bool
+	)
+	r0, _, err := parseCommandArgs("config", args, runID, runSeed, func(fs *flag.FlagSet) {
+		fs.StringVar(&out, "o", "", "essentials.json to write")
+		fs.BoolVar(&dry, "dry-run", false, "Show changes without writing")
+	})
+	if err != nil {
+		return err
+	}
+
+	paths := essentialsPaths()
+	c0, err := config.LoadEssentialsWithFallback(paths)
+	if err != nil {
+		return err
+	}
+	if out = strings.TrimSpace(out); out == "" {
+		out = paths[0]
+		for _, p := range paths {
+			if _, e := os.Stat(p); e == nil {
+				out = p
+				break
+			}
+		}
+	}
+
+	// The file is saved as it was loaded; XDL_* overrides only shape the
+	// client used for discovery.
+	n0, err := config.LoadEssentialsWithFallback(paths)
+	if err != nil {
+		return err
+	}
+	if err := n0.ApplyEnv(); err != nil {
+		return fmt.Errorf("Invalid environment configuration: %w", err)
+	}
+	if err := setupTransport(r0, n0); err != nil {
+		return err
+	}
+
+	h0 := httpx.NewAPIClient(n0.HTTPTimeout(), n0.HeaderOrder)
+	res, err := discovery.Fetch(h0, n0)
+	if err != nil {
+		log.LogError("config", "endpoint discovery failed: "+err.Error())
+		return fmt.Errorf("Could not discover GraphQL endpoints: %w", err)
+	}
+
+	changes := discovery.Apply(c0, res)
+	if r0.Mode != ModeQuiet {
+		for k, n := range c0.OperationNames() {
+			if _, ok := res.Operations[n]; !ok {
+				utils.PrintWarn("%-20s %s not found in %d bundles", k, n, len(res.Bundles))
+			}
+		}
+		for _, ch := range changes {
+			utils.PrintInfo("%-20s %s: %s -> %s (+%d features)", ch.Key, ch.Name, ch.OldID, ch.NewID, len(ch.Features))
+		}
+		if len(changes) == 0 {
+			utils.PrintSuccess("Endpoints are up to date.")
+		}
+	}
+	if len(changes) == 0 || dry {
+		return nil
+	}
+
+	if err := config.SaveEssentials(c0, out); err != nil {
+		return err
+	}
+	if r0.Mode != ModeQuiet {
+		utils.PrintSuccess("Saved %d updated endpoints to %s", len(changes), out)
+	}
+	return nil
+}
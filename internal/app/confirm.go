@@ -0,0 +1,117 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+// confirmChunk is how many confirmed media are handed to the downloader at a
+// time, standing in for the timeline pages the scan did not download.
+const confirmChunk = 100
+
+// confirmMu keeps prompts of users scanned in parallel from interleaving.
+var confirmMu sync.Mutex
+
+// confirm shows what the scan of a user found and lets the user pick which
+// media types to download, or skip the user. It returns the media to get.
+func (p *Pipeline) confirm(user string, ms []scraper.Media) ([]scraper.Media, error) {
+	if len(ms) == 0 {
+		return nil, nil
+	}
+	var imgs, vids []scraper.Media
+	var first, last time.Time
+	for _, m := range ms {
+		if m.Type == "video" {
+			vids = append(vids, m)
+		} else {
+			imgs = append(imgs, m)
+		}
+		if t := scraper.TweetTime(m.TweetID); !t.IsZero() {
+			if first.IsZero() || t.Before(first) {
+				first = t
+			}
+			if t.After(last) {
+				last = t
+			}
+		}
+	}
+	ei, ev := p.estimate(user, imgs), p.estimate(user, vids)
+
+	defer globalWatchdog.sleep(user)()
+	confirmMu.Lock()
+	defer confirmMu.Unlock()
+	globalControl.asking.Store(true)
+	defer globalControl.asking.Store(false)
+
+	span := ""
+	if !first.IsZero() {
+		span = fmt.Sprintf(", tweets from %s to %s", first.Format("2006-01-02"), last.Format("2006-01-02"))
+	}
+	utils.PrintInfo("@%s: %d media found (%d images, %d videos)%s", user, len(ms), len(imgs), len(vids), span)
+
+	wantI, wantV := len(imgs) > 0, len(vids) > 0
+	for {
+		e := &downloader.Estimate{Exact: true}
+		if wantI {
+			addEstimate(e, ei)
+		}
+		if wantV {
+			addEstimate(e, ev)
+		}
+		utils.PrintInfo("Download [i]mages: %s, [v]ideos: %s, %d media, %s? [Y/n]", onOff(wantI), onOff(wantV), e.Media, formatEstimate(e))
+
+		k, ok := globalControl.readKey(func() bool { return p.interrupted(user) != nil })
+		if !ok {
+			if e0 := p.interrupted(user); e0 != nil {
+				return nil, e0
+			}
+			return nil, errStoppedByUser
+		}
+		switch k {
+		case 'i', 'I':
+			wantI = !wantI
+		case 'v', 'V':
+			wantV = !wantV
+		case 'n', 'N', 's', 'S':
+			utils.PrintInfo("Skipping @%s", user)
+			return nil, errSkippedByUser
+		case 'q', 'Q':
+			globalControl.setQuit()
+			return nil, errStoppedByUser
+		case 'y', 'Y', '\r', '\n':
+			var out []scraper.Media
+			if wantI {
+				out = append(out, imgs...)
+			}
+			if wantV {
+				out = append(out, vids...)
+			}
+			if len(out) == 0 {
+				utils.PrintInfo("Nothing selected; skipping @%s", user)
+				return nil, errSkippedByUser
+			}
+			return out, nil
+		}
+	}
+}
+
+func addEstimate(dst, e *downloader.Estimate) {
+	dst.Media += e.Media
+	dst.Sampled += e.Sampled
+	dst.Failed += e.Failed
+	dst.Bytes += e.Bytes
+	dst.Unknown += e.Unknown
+	dst.Exact = dst.Exact && e.Exact
+}
+
+func onOff(v bool) string {
+	if v {
+		return "on"
+	}
+	return "off"
+}
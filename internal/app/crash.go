@@ -0,0 +1,74 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	goruntime "runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+var errCrashed = errors.New("internal error")
+
+// recoverTarget turns a panic while handling one user into a failed target
+// with a crash report, so the rest of the batch keeps going.
+func (p *Pipeline) recoverTarget(u string, t0 time.Time, rep *targetReport) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	fp := p.crashReport("handling @"+u, u, v, debug.Stack())
+	*rep = newTargetReport(u, t0, scanResult{}, downloadStats{}, crashErr(u, fp))
+}
+
+func crashErr(u, fp string) error {
+	if fp == "" {
+		return withHint(errCrashed, "xdl hit an internal error on @%s and stopped that user; the others continue. Run with -d and report the log.", u)
+	}
+	return withHint(errCrashed, "xdl hit an internal error on @%s and stopped that user; the others continue. Please attach %s to a bug report.", u, fp)
+}
+
+// crashReport writes the panic, its stack and the config with secrets
+// removed under logs/ and returns the file, or "" if it could not be saved.
+func (p *Pipeline) crashReport(what, u string, v any, stack []byte) string {
+	log.LogError("crash", fmt.Sprintf("panic while %s: %v\n%s", what, v, stack))
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "xdl crash report\n\ntime:   %s\nrun:    %s\nuser:   %s\nwhile:  %s\npanic:  %v\ngo:     %s %s/%s\n\n",
+		time.Now().Format(time.RFC3339), p.r0.RunID, u, what, v, goruntime.Version(), goruntime.GOOS, goruntime.GOARCH)
+	b.Write(stack)
+	if p.c0 != nil {
+		if c, err := json.MarshalIndent(p.c0, "", "  "); err == nil {
+			b.WriteString("\nconfig (secrets removed):\n")
+			b.Write(redactProxy(scraper.Redact(p.c0, c), p.c0.Transport.Proxy))
+			b.WriteString("\n")
+		}
+	}
+	dir := p.r0.LogPath
+	if dir == "" {
+		dir = filepath.Join(p9(), "logs")
+	}
+	name := fmt.Sprintf("crash_%s_%s_%s.txt", p.r0.RunID, utils.SanitizeFilename(strings.ToLower(u)), time.Now().Format("20060102_150405"))
+	fp := filepath.Join(dir, name)
+	if err := utils.SaveToFile(fp, b.Bytes()); err != nil {
+		log.LogError("crash", "writing crash report failed: "+err.Error())
+		return ""
+	}
+	return fp
+}
+
+func redactProxy(b []byte, proxy string) []byte {
+	u, err := url.Parse(proxy)
+	if err != nil || u.User == nil {
+		return b
+	}
+	return bytes.ReplaceAll(b, []byte(u.User.String()+"@"), []byte("[REDACTED]@"))
+}
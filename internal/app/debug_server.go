@@ -0,0 +1,51 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"runtime/pprof"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/log"
+)
+
+func startDebugServer(addr string) (func(), error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/goroutines", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_ = pprof.Lookup("goroutine").WriteTo(w, 2)
+	})
+	mux.HandleFunc("/debug/stats", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(readRuntimeStats())
+	})
+
+	stop, bound, err := startHTTPServer(addr, mux, "debug")
+	if err != nil {
+		return nil, err
+	}
+	log.LogInfo("debug", "goroutine dumps at http://"+bound+"/debug/goroutines")
+	return stop, nil
+}
+
+func startHTTPServer(addr string, h http.Handler, tag string) (func(), string, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, "", err
+	}
+
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.LogError(tag, err.Error())
+		}
+	}()
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}, ln.Addr().String(), nil
+}
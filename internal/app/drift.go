@@ -0,0 +1,54 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ghostlawless/xdl/internal/scraper"
+)
+
+// driftCounts collects, per user, what the scraper skipped because it did not
+// recognise its shape.
+type driftCounts struct {
+	mu sync.Mutex
+	m  map[string]scraper.Drift
+}
+
+var schemaDrift = &driftCounts{m: make(map[string]scraper.Drift)}
+
+func (c *driftCounts) add(user string, d scraper.Drift) {
+	k := strings.ToLower(strings.TrimSpace(user))
+	c.mu.Lock()
+	s := c.m[k]
+	s.Add(d)
+	c.m[k] = s
+	c.mu.Unlock()
+}
+
+func (c *driftCounts) take(user string) scraper.Drift {
+	k := strings.ToLower(strings.TrimSpace(user))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d := c.m[k]
+	delete(c.m, k)
+	return d
+}
+
+func driftDetail(d scraper.Drift) string {
+	var p []string
+	if d.Entries > 0 {
+		p = append(p, fmt.Sprintf("%d unknown entry types", d.Entries))
+	}
+	if d.Fields > 0 {
+		p = append(p, fmt.Sprintf("%d missing media fields", d.Fields))
+	}
+	if d.Videos > 0 {
+		p = append(p, fmt.Sprintf("%d unrecognized video types", d.Videos))
+	}
+	return strings.Join(p, ", ")
+}
+
+func unparsedNote(user string, d scraper.Drift) string {
+	return fmt.Sprintf("%d entries for @%s could not be parsed (%s); X may have changed its format. Run with -d and report it.", d.Total(), user, driftDetail(d))
+}
@@ -0,0 +1,33 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/scraper"
+)
+
+func (p *Pipeline) estimate(user string, ms []scraper.Media) *downloader.Estimate {
+	if len(ms) == 0 {
+		return &downloader.Estimate{Exact: true}
+	}
+	p.r0.ui().info("Estimating download size for @%s (%d media)", user, len(ms))
+	e := downloader.EstimateSize(p.dl, p.c0, ms, p.r0.Sample, func() bool { return p.interrupted(user) != nil })
+	log.LogInfo("download", fmt.Sprintf(
+		"estimate user=%s media=%d sampled=%d failed=%d unknown=%d bytes=%d exact=%v",
+		user, e.Media, e.Sampled, e.Failed, e.Unknown, e.Bytes, e.Exact,
+	))
+	return &e
+}
+
+func formatEstimate(e *downloader.Estimate) string {
+	out := fmt.Sprintf("%.2f MB", float64(e.Bytes)/1024.0/1024.0)
+	if !e.Exact {
+		out = "~" + out
+	}
+	if e.Unknown > 0 {
+		out += fmt.Sprintf(" + %d of unknown size", e.Unknown)
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/errs"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+const (
+	ExitOK           = 0
+	ExitFailure      = 1
+	ExitAuth         = 2
+	ExitUserNotFound = 3
+	ExitRateLimited  = 4
+	ExitPartial      = 5
+	ExitShapeChanged = 6
+	ExitQuota        = 7
+	ExitLocked       = 8
+	ExitAborted      = 130
+)
+
+var (
+	ErrPartial       = errors.New("some downloads failed")
+	ErrQuotaReached  = errors.New("soft quota reached")
+	errStoppedByUser = fmt.Errorf("Stopped by user: %w", downloader.ErrAborted)
+	errSkippedByUser = errors.New("skipped by user")
+	errQuotaStop     = fmt.Errorf("Stopped at the storage soft quota; progress was saved and the next run resumes: %w", ErrQuotaReached)
+)
+
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, ErrQuotaReached):
+		return ExitQuota
+	case errors.Is(err, downloader.ErrAborted):
+		return ExitAborted
+	case errors.Is(err, errs.ErrShapeChanged):
+		return ExitShapeChanged
+	case errors.Is(err, errs.ErrAuthExpired), errors.Is(err, config.ErrCookieFileMissing):
+		return ExitAuth
+	case errors.Is(err, errs.ErrRateLimited):
+		return ExitRateLimited
+	case errors.Is(err, errs.ErrUserNotFound), errors.Is(err, errs.ErrUserSuspended), errors.Is(err, errs.ErrProtectedAccount):
+		return ExitUserNotFound
+	case errors.Is(err, utils.ErrLocked):
+		return ExitLocked
+	case errors.Is(err, ErrPartial):
+		return ExitPartial
+	}
+	return ExitFailure
+}
+
+type hintError struct {
+	msg string
+	err error
+}
+
+func (e *hintError) Error() string { return e.msg }
+func (e *hintError) Unwrap() error { return e.err }
+
+func withHint(err error, format string, args ...any) error {
+	return &hintError{msg: fmt.Sprintf(format, args...), err: err}
+}
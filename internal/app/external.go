@@ -0,0 +1,111 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/audit"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/scraper"
+)
+
+const externalTimeout = time.Hour
+
+func splitExternal(ms []scraper.Media) ([]scraper.Media, []scraper.Media) {
+	var ext []scraper.Media
+	out := ms[:0:0]
+	for _, m := range ms {
+		if m.Type == scraper.MediaExternal {
+			ext = append(ext, m)
+		} else {
+			out = append(out, m)
+		}
+	}
+	if ext == nil {
+		return ms, nil
+	}
+	return out, ext
+}
+
+func (p *Pipeline) handoff(user, dir string, ext []scraper.Media) {
+	if len(ext) == 0 || p.r0.DryRun || p.r0.Pack != nil {
+		return
+	}
+	fp := paths.External(dir)
+	seen := map[string]bool{}
+	if f, err := os.Open(fp); err == nil {
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			seen[strings.TrimSpace(sc.Text())] = true
+		}
+		f.Close()
+	}
+	var fresh []scraper.Media
+	for _, m := range ext {
+		if !seen[m.URL] {
+			seen[m.URL] = true
+			fresh = append(fresh, m)
+		}
+	}
+	if len(fresh) == 0 {
+		return
+	}
+	f, err := os.OpenFile(fp, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		log.LogError("external", err.Error())
+		return
+	}
+	for _, m := range fresh {
+		fmt.Fprintln(f, m.URL)
+	}
+	f.Close()
+	audit.File(audit.FileWrite, fp, "")
+	log.LogInfo("external", fmt.Sprintf("@%s: %d external video links saved to %s", user, len(fresh), fp))
+
+	cmd := p.c0.External.Command
+	if len(cmd) == 0 {
+		return
+	}
+	for _, m := range fresh {
+		if shouldStopDownloads() {
+			return
+		}
+		if err := runHandoff(cmd, dir, m); err != nil {
+			log.LogWarn("external", fmt.Sprintf("%s: %v", m.URL, err))
+		}
+	}
+}
+
+func runHandoff(cmd []string, dir string, m scraper.Media) error {
+	r := strings.NewReplacer("{url}", m.URL, "{dir}", dir, "{tweet_id}", m.TweetID)
+	args := make([]string, len(cmd))
+	for i, a := range cmd {
+		args[i] = r.Replace(a)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), externalTimeout)
+	defer cancel()
+	c := exec.CommandContext(ctx, args[0], args[1:]...)
+	c.Dir = dir
+	out, err := c.CombinedOutput()
+	if err != nil {
+		if s := strings.TrimSpace(string(out)); s != "" {
+			return fmt.Errorf("%w: %s", err, lastLine(s))
+		}
+		return err
+	}
+	log.LogInfo("external", fmt.Sprintf("%s -> %s", m.URL, args[0]))
+	return nil
+}
+
+func lastLine(s string) string {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
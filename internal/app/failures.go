@@ -0,0 +1,154 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+const failedDir = "failed"
+
+type failedMedia struct {
+	URL      string                  `json:"url"`
+	Type     string                  `json:"type"`
+	TweetID  string                  `json:"tweet_id,omitempty"`
+	AltText  string                  `json:"alt_text,omitempty"`
+	Author   string                  `json:"author,omitempty"`
+	UID      string                  `json:"user_id,omitempty"`
+	Handle   string                  `json:"handle,omitempty"`
+	Dir      string                  `json:"dir"`
+	Class    downloader.FailureClass `json:"class"`
+	Error    string                  `json:"error"`
+	RunID    string                  `json:"run_id,omitempty"`
+	First    time.Time               `json:"first_failed"`
+	Last     time.Time               `json:"last_failed"`
+	Attempts int                     `json:"attempts"`
+}
+
+type failureLog struct {
+	User  string        `json:"user"`
+	Items []failedMedia `json:"items"`
+}
+
+func failuresPath(l *paths.Layout, u string) string {
+	return l.StateFile(filepath.Join(failedDir, utils.SanitizeFilename(strings.ToLower(u))+".json"))
+}
+
+func readFailures(fp string) (*failureLog, error) {
+	f := &failureLog{}
+	b, err := os.ReadFile(fp)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// updateFailures rewrites a user's failure log under a lock; the file is
+// removed once nothing is left to retry.
+func updateFailures(fp, user string, fn func(f *failureLog)) error {
+	lk, err := utils.LockExclusive(fp)
+	if err != nil {
+		return err
+	}
+	defer lk.Unlock()
+	f, err := readFailures(fp)
+	if err != nil {
+		return err
+	}
+	fn(f)
+	if len(f.Items) == 0 {
+		if err := os.Remove(fp); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	f.User = user
+	sort.SliceStable(f.Items, func(i, j int) bool { return f.Items[i].Last.Before(f.Items[j].Last) })
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.SaveToFile(fp, b)
+}
+
+// merge drops the URLs the checkpoint got this time and adds the ones that
+// failed. Items failed only because the run was stopped carry no class and
+// are left for the next scan to pick up.
+func (f *failureLog) merge(uid, user, dir, runID string, cp *downloader.Checkpoint, now time.Time) {
+	at := make(map[string]int, len(f.Items))
+	for i, m := range f.Items {
+		at[m.URL] = i
+	}
+	drop := map[string]bool{}
+	for _, it := range cp.Items {
+		i, seen := at[it.URL]
+		switch {
+		case it.Status == downloader.CheckpointDone || it.Status == downloader.CheckpointSkipped:
+			if seen {
+				drop[it.URL] = true
+			}
+		case it.Status == downloader.CheckpointFailed && it.Class != "":
+			if !seen {
+				f.Items = append(f.Items, failedMedia{URL: it.URL, First: now})
+				i = len(f.Items) - 1
+				at[it.URL] = i
+			}
+			m := &f.Items[i]
+			m.Type, m.TweetID, m.AltText, m.Author = it.Type, it.TweetID, it.AltText, it.Author
+			m.UID, m.Handle, m.Dir, m.RunID = uid, user, dir, runID
+			m.Class, m.Error, m.Last = it.Class, it.Error, now
+			m.Attempts++
+		}
+	}
+	if len(drop) == 0 {
+		return
+	}
+	kept := f.Items[:0]
+	for _, m := range f.Items {
+		if !drop[m.URL] {
+			kept = append(kept, m)
+		}
+	}
+	f.Items = kept
+}
+
+func (p *Pipeline) recordFailures(uid, user, dir string, cp *downloader.Checkpoint) {
+	if p.r0.Layout == nil || p.r0.DryRun || cp == nil {
+		return
+	}
+	who := p.who
+	if who == "" {
+		who = user
+	}
+	fp := failuresPath(p.r0.Layout, who)
+	if len(cp.FailedItems()) == 0 {
+		if _, err := os.Stat(fp); err != nil {
+			return
+		}
+	}
+	err := updateFailures(fp, who, func(f *failureLog) {
+		f.merge(uid, user, dir, p.r0.RunID, cp, time.Now().UTC())
+	})
+	if err != nil {
+		log.LogError("failures", err.Error())
+	}
+}
+
+func (m failedMedia) media() scraper.Media {
+	return scraper.Media{URL: m.URL, Type: m.Type, TweetID: m.TweetID, AltText: m.AltText, Author: m.Author}
+}
@@ -0,0 +1,145 @@
+package app
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/archive"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+const (
+	feedFile  = "feed.xml"
+	feedItems = 50
+)
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Updated     string    `xml:"lastBuildDate"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link,omitempty"`
+	GUID        rssGUID       `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+	Description string        `xml:"description"`
+	Enclosure   *rssEnclosure `xml:"enclosure,omitempty"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+func buildFeed(u archive.UserRecord, items []archive.MediaRecord, root string) ([]byte, error) {
+	if len(items) > feedItems {
+		items = items[len(items)-feedItems:]
+	}
+	ch := rssChannel{
+		Title:       "xdl: @" + u.Handle,
+		Link:        "https://x.com/" + url.PathEscape(u.Handle),
+		Description: "Media archived by xdl for @" + u.Handle,
+		Updated:     time.Now().UTC().Format(time.RFC1123Z),
+	}
+	for i := len(items) - 1; i >= 0; i-- {
+		m := items[i]
+		it := rssItem{
+			Title:   fmt.Sprintf("New %s from @%s", feedKind(m.Type), u.Handle),
+			GUID:    rssGUID{Value: m.URL},
+			PubDate: m.AddedAt.UTC().Format(time.RFC1123Z),
+		}
+		var desc []string
+		if m.TweetID != "" {
+			it.Link = "https://x.com/" + url.PathEscape(u.Handle) + "/status/" + m.TweetID
+			desc = append(desc, fmt.Sprintf(`<a href="%s">Original tweet</a>`, html.EscapeString(it.Link)))
+		}
+		if m.Path != "" {
+			f := feedFileURL(root, m.Path)
+			it.Enclosure = &rssEnclosure{URL: f, Length: m.Size, Type: m.MIME}
+			if m.Type != "video" {
+				desc = append(desc, fmt.Sprintf(`<img src="%s">`, html.EscapeString(f)))
+			}
+			desc = append(desc, fmt.Sprintf(`<a href="%s">Local file</a>`, html.EscapeString(f)))
+		}
+		it.Description = strings.Join(desc, "<br>")
+		ch.Items = append(ch.Items, it)
+	}
+	b, err := xml.MarshalIndent(rssFeed{Version: "2.0", Channel: ch}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+func feedKind(t string) string {
+	if t == "video" {
+		return "video"
+	}
+	return "image"
+}
+
+func feedFileURL(root, p string) string {
+	p = filepath.FromSlash(p)
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, p)
+	}
+	if a, err := filepath.Abs(p); err == nil {
+		p = a
+	}
+	p = filepath.ToSlash(p)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
+
+func (p *Pipeline) writeFeeds(rep *RunReport) {
+	st := p.r0.Store
+	if st == nil || p.r0.DryRun {
+		return
+	}
+	rep.mu.Lock()
+	ts := append([]targetReport(nil), rep.Targets...)
+	rep.mu.Unlock()
+
+	for _, t := range ts {
+		out := filepath.Join(p.r0.Layout.UserDir(t.User), feedFile)
+		if _, err := os.Stat(out); t.Downloaded == 0 && err == nil {
+			continue
+		}
+		u, items, ok := st.MediaSince(t.User, time.Time{})
+		if !ok || len(items) == 0 {
+			continue
+		}
+		b, err := buildFeed(u, items, st.Root())
+		if err == nil {
+			err = utils.SaveToFile(out, b)
+		}
+		if err != nil {
+			log.LogError("feed", fmt.Sprintf("feed for @%s: %v", t.User, err))
+		}
+	}
+}
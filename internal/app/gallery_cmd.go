@@ -0,0 +1,244 @@
+package app
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/archive"
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+type galleryItem struct {
+	Src     string
+	Thumb   string
+	Video   bool
+	TweetID string
+	Link    string
+	Date    string
+	Text    string
+	at      time.Time
+}
+
+type galleryPage struct {
+	Title     string
+	Generated string
+	Items     []galleryItem
+	Images    int
+	Videos    int
+}
+
+func runGalleryCommand(args []string, runID string, runSeed []byte) error {
+	var out string
+	r0, rest, err := parseCommandArgs("gallery", args, runID, runSeed, func(fs *flag.FlagSet) {
+		fs.StringVar(&out, "o", "", "HTML file to write (default <runDir>/index.html)")
+	})
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 || strings.TrimSpace(rest[0]) == "" {
+		return fmt.Errorf("Usage: xdl gallery [-o index.html] <runDir>\n\nExample:\n  xdl gallery xDownloads/nasa")
+	}
+	dir := filepath.Clean(rest[0])
+	if !utils.DirExists(dir) {
+		return fmt.Errorf("Could not find folder %s", dir)
+	}
+	if out == "" {
+		out = filepath.Join(dir, "index.html")
+	}
+
+	items, err := galleryItems(dir, filepath.Dir(out))
+	if err != nil {
+		return fmt.Errorf("Could not read %s: %w", dir, err)
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("No media found in %s", dir)
+	}
+
+	pg := galleryPage{Title: filepath.Base(dir), Generated: time.Now().Format("2006-01-02 15:04"), Items: items}
+	for _, it := range items {
+		if it.Video {
+			pg.Videos++
+		} else {
+			pg.Images++
+		}
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return fmt.Errorf("Could not write %s: %w", out, err)
+	}
+	defer f.Close()
+	if err := galleryTemplate.Execute(f, pg); err != nil {
+		return fmt.Errorf("Could not write %s: %w", out, err)
+	}
+	if r0.Mode != ModeQuiet {
+		utils.PrintSuccess("Gallery with %d images and %d videos: %s", pg.Images, pg.Videos, out)
+	}
+	return nil
+}
+
+func galleryItems(dir, base string) ([]galleryItem, error) {
+	byPath := map[string]archive.MediaRecord{}
+	if st, err := archive.Open(archive.DefaultPath(filepath.Dir(dir))); err == nil {
+		root := st.Root()
+		for _, m := range st.AllMedia() {
+			if m.Path == "" {
+				continue
+			}
+			p := filepath.FromSlash(m.Path)
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(root, p)
+			}
+			if a, err := filepath.Abs(p); err == nil {
+				byPath[a] = m
+			}
+		}
+	}
+
+	var out []galleryItem
+	for _, sub := range []string{paths.ImagesDir, paths.VideosDir} {
+		es, err := os.ReadDir(filepath.Join(dir, sub))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range es {
+			if e.IsDir() || strings.Contains(e.Name(), ".tmp-") || filepath.Ext(e.Name()) == ".json" || filepath.Ext(e.Name()) == ".txt" {
+				continue
+			}
+			p := filepath.Join(dir, sub, e.Name())
+			it := galleryItem{Src: galleryRel(base, p), Thumb: galleryRel(base, p), Video: sub == paths.VideosDir}
+			if th := paths.Thumb(dir, p); fileExists(th) {
+				it.Thumb = galleryRel(base, th)
+			}
+			if a, err := filepath.Abs(p); err == nil {
+				it.TweetID = byPath[a].TweetID
+			}
+			it.Text, it.TweetID = gallerySidecar(p, it.TweetID)
+			if it.TweetID != "" {
+				it.Link = "https://x.com/i/status/" + it.TweetID
+				it.at = scraper.TweetTime(it.TweetID)
+			}
+			if it.at.IsZero() {
+				if fi, err := e.Info(); err == nil {
+					it.at = fi.ModTime()
+				}
+			}
+			it.Date = it.at.Local().Format("2006-01-02 15:04")
+			out = append(out, it)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].at.After(out[j].at) })
+	return out, nil
+}
+
+func gallerySidecar(media, id string) (string, string) {
+	if b, err := os.ReadFile(paths.Sidecar(media, "json")); err == nil {
+		var m struct {
+			Text    string `json:"text"`
+			TweetID string `json:"tweet_id"`
+		}
+		if json.Unmarshal(b, &m) == nil {
+			if id == "" {
+				id = m.TweetID
+			}
+			if m.Text != "" {
+				return m.Text, id
+			}
+		}
+	}
+	if b, err := os.ReadFile(paths.Sidecar(media, "txt")); err == nil {
+		return strings.TrimSpace(string(b)), id
+	}
+	if id != "" {
+		if b, err := os.ReadFile(paths.TweetText(filepath.Dir(filepath.Dir(media)), id)); err == nil {
+			return strings.TrimSpace(string(b)), id
+		}
+	}
+	return "", id
+}
+
+func galleryRel(base, p string) string {
+	if r, err := filepath.Rel(base, p); err == nil {
+		return filepath.ToSlash(r)
+	}
+	return filepath.ToSlash(p)
+}
+
+func fileExists(p string) bool {
+	st, err := os.Stat(p)
+	return err == nil && !st.IsDir()
+}
+
+var galleryTemplate = template.Must(template.New("gallery").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>@{{.Title}} — xdl</title>
+<style>
+body{margin:0;font:14px/1.4 system-ui,sans-serif;background:#111;color:#ddd}
+header{padding:16px 20px;border-bottom:1px solid #222}
+header h1{margin:0;font-size:20px}
+header p{margin:4px 0 0;color:#888}
+main{display:grid;grid-template-columns:repeat(auto-fill,minmax(200px,1fr));gap:8px;padding:12px}
+figure{margin:0;background:#1b1b1b;border-radius:6px;overflow:hidden;cursor:pointer}
+figure img,figure video{display:block;width:100%;height:200px;object-fit:cover;background:#000}
+figcaption{padding:6px 8px;font-size:12px;color:#999;white-space:nowrap;overflow:hidden;text-overflow:ellipsis}
+#lb{position:fixed;inset:0;background:rgba(0,0,0,.92);display:none;flex-direction:column;align-items:center;justify-content:center;padding:20px;box-sizing:border-box}
+#lb.on{display:flex}
+#lb img,#lb video{max-width:100%;max-height:80vh}
+#lb p{max-width:800px;white-space:pre-wrap;text-align:center}
+#lb a{color:#8ab4f8}
+#lb button{position:absolute;top:50%;background:none;border:0;color:#fff;font-size:40px;cursor:pointer}
+#prev{left:10px}#next{right:10px}
+</style>
+</head>
+<body>
+<header><h1>@{{.Title}}</h1><p>{{.Images}} images · {{.Videos}} videos · generated {{.Generated}}</p></header>
+<main>
+{{range $i, $m := .Items}}<figure data-i="{{$i}}">{{if and $m.Video (eq $m.Thumb $m.Src)}}<video src="{{$m.Src}}" preload="metadata" muted></video>{{else}}<img src="{{$m.Thumb}}" loading="lazy" alt="">{{end}}<figcaption>{{$m.Date}}{{if $m.Text}} · {{$m.Text}}{{end}}</figcaption></figure>
+{{end}}</main>
+<div id="lb"><button id="prev">‹</button><div id="view"></div><p id="cap"></p><button id="next">›</button></div>
+<script>
+const items = [{{range .Items}}{src:{{.Src}},video:{{.Video}},text:{{.Text}},link:{{.Link}},date:{{.Date}}},{{end}}];
+const lb = document.getElementById('lb'), view = document.getElementById('view'), cap = document.getElementById('cap');
+let cur = -1;
+function show(i) {
+  if (i < 0 || i >= items.length) return;
+  cur = i;
+  const m = items[i];
+  view.replaceChildren();
+  const el = document.createElement(m.video ? 'video' : 'img');
+  el.src = m.src;
+  if (m.video) { el.controls = true; el.autoplay = true; }
+  view.appendChild(el);
+  cap.replaceChildren(document.createTextNode(m.date + (m.text ? '\n' + m.text : '')));
+  if (m.link) { const a = document.createElement('a'); a.href = m.link; a.textContent = ' open tweet'; a.target = '_blank'; cap.appendChild(a); }
+  lb.classList.add('on');
+}
+function hide() { lb.classList.remove('on'); view.replaceChildren(); cur = -1; }
+document.querySelector('main').addEventListener('click', e => { const f = e.target.closest('figure'); if (f) show(+f.dataset.i); });
+document.getElementById('prev').onclick = e => { e.stopPropagation(); show(cur - 1); };
+document.getElementById('next').onclick = e => { e.stopPropagation(); show(cur + 1); };
+lb.addEventListener('click', e => { if (e.target === lb) hide(); });
+document.addEventListener('keydown', e => {
+  if (cur < 0) return;
+  if (e.key === 'Escape') hide();
+  if (e.key === 'ArrowLeft') show(cur - 1);
+  if (e.key === 'ArrowRight') show(cur + 1);
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ghostlawless/xdl/internal/audit"
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+type geoFeature struct {
+	Type     string      `json:"type"`
+	Geometry geoPoint    `json:"geometry"`
+	Props    geoFeatProp `json:"properties"`
+}
+
+type geoPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type geoFeatProp struct {
+	TweetID string `json:"tweet_id,omitempty"`
+	File    string `json:"file,omitempty"`
+	URL     string `json:"url"`
+	Place   string `json:"place,omitempty"`
+	Country string `json:"country,omitempty"`
+	Exact   bool   `json:"exact"`
+}
+
+type geoCollection struct {
+	Type     string       `json:"type"`
+	Features []geoFeature `json:"features"`
+}
+
+func (p *Pipeline) geoJSON(dir string, cp *downloader.Checkpoint) {
+	if !p.r0.GeoJSON || p.r0.Pack != nil {
+		return
+	}
+	if err := writeGeoJSON(dir, cp.DoneItems()); err != nil {
+		log.LogError("geo", err.Error())
+	}
+}
+
+func writeGeoJSON(dir string, items []downloader.CheckpointItem) error {
+	fp := paths.GeoJSON(dir)
+	byURL := map[string]geoFeature{}
+	if b, err := os.ReadFile(fp); err == nil {
+		var fc geoCollection
+		if err := json.Unmarshal(b, &fc); err != nil {
+			return err
+		}
+		for _, f := range fc.Features {
+			byURL[f.Props.URL] = f
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	n := 0
+	for _, it := range items {
+		g := it.Geo
+		if g == nil || (g.Lat == 0 && g.Lon == 0) {
+			continue
+		}
+		if _, ok := byURL[it.URL]; ok {
+			continue
+		}
+		rel := it.Path
+		if r, err := filepath.Rel(dir, it.Path); err == nil {
+			rel = filepath.ToSlash(r)
+		}
+		byURL[it.URL] = geoFeature{
+			Type:     "Feature",
+			Geometry: geoPoint{Type: "Point", Coordinates: [2]float64{g.Lon, g.Lat}},
+			Props:    geoFeatProp{TweetID: it.TweetID, File: rel, URL: it.URL, Place: g.Place, Country: g.Country, Exact: g.Exact},
+		}
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	fc := geoCollection{Type: "FeatureCollection", Features: make([]geoFeature, 0, len(byURL))}
+	for _, f := range byURL {
+		fc.Features = append(fc.Features, f)
+	}
+	sort.Slice(fc.Features, func(i, j int) bool {
+		a, b := fc.Features[i].Props, fc.Features[j].Props
+		if a.TweetID != b.TweetID {
+			return a.TweetID < b.TweetID
+		}
+		return a.URL < b.URL
+	})
+	b, err := json.MarshalIndent(fc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := utils.SaveToFile(fp, b); err != nil {
+		return err
+	}
+	audit.File(audit.FileWrite, fp, "")
+	return nil
+}
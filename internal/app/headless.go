@@ -0,0 +1,52 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// headlessEnv reports whether XDL_HEADLESS turns on -headless, for images
+// whose entrypoint cannot take extra flags.
+func headlessEnv() bool {
+	v, _ := strconv.ParseBool(strings.TrimSpace(os.Getenv("XDL_HEADLESS")))
+	return v
+}
+
+// flagEnv names the variable a -headless run reads a flag from, e.g.
+// XDL_MAX_PAGES for -max-pages.
+func flagEnv(name string) string {
+	return "XDL_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// flagsFromEnv sets every flag that was not given on the command line from
+// its XDL_* variable. One-letter shorthands are left out.
+func flagsFromEnv(fs *flag.FlagSet) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] || len(f.Name) < 2 {
+			return
+		}
+		n := flagEnv(f.Name)
+		v, ok := os.LookupEnv(n)
+		if !ok {
+			return
+		}
+		if e0 := fs.Set(f.Name, strings.TrimSpace(v)); e0 != nil {
+			err = fmt.Errorf("Invalid %s=%q: %v", n, v, e0)
+		}
+	})
+	return err
+}
+
+// usersFromEnv reads XDL_USERS, separated by commas or spaces.
+func usersFromEnv() []string {
+	return strings.FieldsFunc(os.Getenv("XDL_USERS"), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
@@ -0,0 +1,290 @@
+package app
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+const usageText = `Usage:
+  xdl [-q|-d] [-json] [-o dir] [-watch 30m] <username> [more_usernames...]
+  xdl [-q|-d] -ids ids.txt
+  xdl [-q|-d] -targets targets.yaml
+  xdl <command> [flags] [args]
+  xdl help [topic | -man]
+
+Examples:
+  xdl google
+  xdl google nasa
+  xdl -d google`
+
+type helpTopic struct {
+	Summary string
+	Flags   []string
+	Text    string
+}
+
+var helpTopics = map[string]helpTopic{}
+
+var commandSummaries = map[string]string{
+	"archive":        "import existing downloads into the archive",
+	"audit":          "flag archived media whose tweets were deleted, protected or withheld",
+	"canary":         "check that X's responses still match the parser",
+	"config":         "refresh-endpoints: rediscover GraphQL queryIds",
+	"gallery":        "build a static HTML gallery of a download",
+	"help":           "show this help, a topic, or a man page",
+	"import-takeout": "download the media of your X data export",
+	"parse":          "re-parse responses saved with -dump-raw, offline",
+	"retry":          "retry the downloads that failed for a user",
+	"serve":          "serve the archive API",
+	"status":         "show when each user last ran and how it went",
+	"verify":         "re-hash a download folder against its SHA256SUMS",
+	"version":        "print build info; -check probes every GraphQL operation",
+}
+
+func init() {
+	helpTopics["auth"] = helpTopic{
+		Summary: "where the session comes from and what to do when X rejects it",
+		Flags:   []string{"no-auth", "id-fallback", "wait-for-rate-limit"},
+		Text: `xdl reads X with the session of your logged-in browser. It tries these
+sources in order and prints the one in use at startup:
+
+  1. XDL_AUTH_TOKEN, XDL_CT0 and XDL_GUEST_ID, or a whole cookie export
+     in XDL_COOKIES (each also as <NAME>_FILE)
+  2. cookies.json or cookies.txt next to the binary
+  3. an accounts/ folder (or config/accounts/) with one cookie export
+     per account
+  4. a guest token, as a logged-out visitor: public accounts only, fewer
+     tweets and earlier rate limits
+
+To export cookies, log in to x.com, export the cookies as JSON with an
+extension such as Cookie-Editor, and save the file as cookies.json next
+to xdl. The file is only read locally.
+
+-no-auth skips the session and reads the public embed timeline, which
+only reaches the most recent tweets.
+
+A 401 or 403 usually means expired cookies: export them again. When X
+rotates the ct0 cookie, xdl picks up the new value itself. Run
+"xdl version -check" to tell cookies, network and stale queryIds apart.`,
+	}
+
+	helpTopics["filters"] = helpTopic{
+		Summary: "which tweets are scanned and which media are saved",
+		Flags: []string{
+			"page-size", "max-pages", "max-tweets", "newer-than", "stop-after-duplicates", "deep",
+			"include-retweets", "attr", "include-cards", "all-image-sizes", "download-archive", "dry-run", "confirm",
+		},
+		Text: `A user's media timeline is read newest first, 100 items per request
+for up to 200 pages. The media of a pinned tweet comes first, however old
+the tweet is.
+
+-max-pages and -max-tweets stop early. -newer-than stops at the first
+tweet at or below that ID, and -stop-after-duplicates stops after that many
+already-archived tweets in a row; both are meant for scheduled runs and
+turn off -deep. -deep continues past the roughly 3,200 tweets the timeline
+returns by searching in 90-day windows; -max-pages and -max-tweets count
+its pages and tweets too.
+
+Media the user retweeted or quoted is skipped unless -include-retweets is
+given. -download-archive skips tweets listed in a gallery-dl or yt-dlp
+archive file and adds the new ones.
+
+-page-size, -include-cards, -download-archive, -dry-run and -confirm apply
+to the whole run. The other options here can be set per user in a -targets
+file, e.g.
+
+  nasa:
+    max_pages: 3
+    include_retweets: true`,
+	}
+
+	helpTopics["templates"] = helpTopic{
+		Summary: "output layout and the placeholders xdl fills in",
+		Flags:   []string{"output", "archive-output", "write-text", "targets"},
+		Text: `File names are not templated. Every user gets a fixed layout under the
+download root (-o, $XDL_OUTPUT_DIR or xDownloads/):
+
+  <root>/<user>/images/    photos
+  <root>/<user>/videos/    videos and GIFs
+  <root>/.xdl/             checkpoints, history, locks and failure logs
+
+-archive-output keeps the same <user>/images/... paths inside one .tar,
+.tar.gz, .tar.zst or .zip file.
+
+The one place with placeholders is external.command in essentials.json,
+run for every link to YouTube, Vimeo and similar hosts:
+
+  "external": { "command": ["yt-dlp", "-P", "{dir}/external", "{url}"] }
+
+  {url}        the linked URL
+  {dir}        the user folder
+  {tweet_id}   the tweet that linked it`,
+	}
+
+	helpTopics["watch"] = helpTopic{
+		Summary: "re-running on a schedule, bursts and shutting down cleanly",
+		Flags:   []string{"watch", "burst", "grace", "lock-wait", "stall-timeout", "user-timeout", "report"},
+		Text: `-watch 30m scans every user, waits, and scans again until stopped. A
+-targets file can give a user its own interval with watch: 10m; it needs
+-watch, which stays the interval for everyone else. At startup a watch
+run checks that X's responses still match the parser (see "xdl canary").
+
+-burst nasa=2m/1h checks @nasa every 2 minutes for an hour after a scan
+finds new media, then backs off to the normal interval. Leave out user=
+to apply it to every target.
+
+After each cycle xdl updates .xdl/history.json, which "xdl status"
+reads, and <user>/feed.xml, an RSS feed of the newest files.
+
+Ctrl+C lets in-flight downloads finish for up to -grace and saves
+checkpoints; a second Ctrl+C quits at once. Each user is locked while it
+runs, so a cron job and a watch run never download the same user at the
+same time; -lock-wait waits for the lock instead of skipping the user.`,
+	}
+}
+
+// helpRequest is returned by parseArgs for -h, carrying the flag set so
+// usage and the man page are generated from the flags actually defined.
+type helpRequest struct {
+	flags *flag.FlagSet
+}
+
+func (*helpRequest) Error() string { return "help requested" }
+
+func mainFlags() *flag.FlagSet {
+	_, err := parseArgs([]string{"-h"}, "", nil)
+	var h *helpRequest
+	if errors.As(err, &h) {
+		return h.flags
+	}
+	return flag.NewFlagSet("xdl", flag.ContinueOnError)
+}
+
+func runHelpCommand(args []string, runID string, runSeed []byte) error {
+	var man bool
+	_, rest, err := parseCommandArgs("help", args, runID, runSeed, func(fs *flag.FlagSet) {
+		fs.BoolVar(&man, "man", false, "Print a man page (troff) to stdout")
+	})
+	if err != nil {
+		return err
+	}
+	fs := mainFlags()
+	if man {
+		writeManPage(utils.Stdout, fs)
+		return nil
+	}
+	if len(rest) == 0 {
+		writeUsage(utils.Stdout, fs)
+		return nil
+	}
+	name := strings.ToLower(rest[0])
+	t, ok := helpTopics[name]
+	if !ok {
+		return fmt.Errorf("Unknown help topic %q (use %s)", rest[0], strings.Join(topicNames(), ", "))
+	}
+	fmt.Fprintf(utils.Stdout, "%s — %s\n\n%s\n", name, t.Summary, t.Text)
+	if len(t.Flags) > 0 {
+		fmt.Fprintln(utils.Stdout, "\nFlags:")
+		for _, n := range t.Flags {
+			if f := fs.Lookup(n); f != nil {
+				writeFlag(utils.Stdout, f)
+			}
+		}
+	}
+	return nil
+}
+
+func topicNames() []string {
+	ns := make([]string, 0, len(helpTopics))
+	for n := range helpTopics {
+		ns = append(ns, n)
+	}
+	sort.Strings(ns)
+	return ns
+}
+
+func commandNames() []string {
+	ns := make([]string, 0, len(commands))
+	for n := range commands {
+		ns = append(ns, n)
+	}
+	sort.Strings(ns)
+	return ns
+}
+
+func writeUsage(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "%s\n\nCommands:\n", usageText)
+	for _, n := range commandNames() {
+		fmt.Fprintf(w, "  %-16s %s\n", n, commandSummaries[n])
+	}
+	fmt.Fprintln(w, "\nHelp topics (xdl help <topic>):")
+	for _, n := range topicNames() {
+		fmt.Fprintf(w, "  %-16s %s\n", n, helpTopics[n].Summary)
+	}
+	fmt.Fprintln(w, "\nFlags:")
+	fs.VisitAll(func(f *flag.Flag) { writeFlag(w, f) })
+}
+
+func flagSyntax(f *flag.Flag) (string, string) {
+	arg, usage := flag.UnquoteUsage(f)
+	s := "-" + f.Name
+	if arg != "" {
+		s += " " + arg
+	}
+	if d := f.DefValue; d != "" && d != "false" && d != "0" && d != "0s" && d != "[]" {
+		usage += fmt.Sprintf(" (default %s)", d)
+	}
+	return s, usage
+}
+
+func writeFlag(w io.Writer, f *flag.Flag) {
+	s, usage := flagSyntax(f)
+	fmt.Fprintf(w, "  %s\n      %s\n", s, usage)
+}
+
+func writeManPage(w io.Writer, fs *flag.FlagSet) {
+	b := readBuildInfo()
+	fmt.Fprintf(w, ".TH XDL 1 %q %q \"User Commands\"\n", time.Now().Format("2006-01-02"), "xdl "+b.Version)
+	fmt.Fprintln(w, ".SH NAME\nxdl \\- download images and videos from X (Twitter) profiles")
+	fmt.Fprintln(w, ".SH SYNOPSIS")
+	for _, l := range strings.Split(usageText, "\n")[1:6] {
+		fmt.Fprintf(w, ".br\n%s\n", manEscape(strings.TrimSpace(l)))
+	}
+	fmt.Fprintln(w, ".SH DESCRIPTION")
+	fmt.Fprintln(w, "xdl downloads the media of the X profiles that your logged\\-in session can see, keeps an archive of what it has downloaded, and resumes where it stopped.")
+	fmt.Fprintln(w, ".SH OPTIONS")
+	fs.VisitAll(func(f *flag.Flag) {
+		s, usage := flagSyntax(f)
+		fmt.Fprintf(w, ".TP\n.B %s\n%s\n", manEscape(s), manEscape(usage))
+	})
+	fmt.Fprintln(w, ".SH COMMANDS")
+	for _, n := range commandNames() {
+		fmt.Fprintf(w, ".TP\n.B xdl %s\n%s\n", manEscape(n), manEscape(commandSummaries[n]))
+	}
+	for _, n := range topicNames() {
+		t := helpTopics[n]
+		fmt.Fprintf(w, ".SH %s\n", strings.ToUpper(n))
+		fmt.Fprintln(w, ".nf")
+		for _, l := range strings.Split(t.Text, "\n") {
+			fmt.Fprintln(w, manEscape(l))
+		}
+		fmt.Fprintln(w, ".fi")
+	}
+	fmt.Fprintln(w, ".SH SEE ALSO\nyt\\-dlp(1), gallery\\-dl(1)")
+}
+
+func manEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+const (
+	historyFile = "history.json"
+	historyRuns = 10
+)
+
+type historyRun struct {
+	At         time.Time `json:"at"`
+	RunID      string    `json:"run_id,omitempty"`
+	Status     string    `json:"status"`
+	Found      int       `json:"found"`
+	Downloaded int       `json:"downloaded"`
+	Failed     int       `json:"failed,omitempty"`
+	Bytes      int64     `json:"bytes,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type historyUser struct {
+	User        string       `json:"user"`
+	LastRun     time.Time    `json:"last_run"`
+	LastSuccess time.Time    `json:"last_success,omitzero"`
+	Failures    int          `json:"consecutive_failures,omitempty"`
+	Next        time.Time    `json:"next_run,omitzero"`
+	Runs        []historyRun `json:"runs"`
+}
+
+type runHistory struct {
+	Updated  time.Time               `json:"updated"`
+	WatchPID int                     `json:"watch_pid,omitempty"`
+	Users    map[string]*historyUser `json:"users"`
+}
+
+func readHistory(fp string) (*runHistory, error) {
+	h := &runHistory{Users: map[string]*historyUser{}}
+	b, err := os.ReadFile(fp)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, h); err != nil {
+		return nil, err
+	}
+	if h.Users == nil {
+		h.Users = map[string]*historyUser{}
+	}
+	return h, nil
+}
+
+func updateHistory(fp string, fn func(h *runHistory)) error {
+	lk, err := utils.LockExclusive(fp)
+	if err != nil {
+		return err
+	}
+	defer lk.Unlock()
+	h, err := readHistory(fp)
+	if err != nil {
+		return err
+	}
+	fn(h)
+	h.Updated = time.Now().UTC()
+	b, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.SaveToFile(fp, b)
+}
+
+func (h *runHistory) user(u string) *historyUser {
+	k := strings.ToLower(u)
+	e := h.Users[k]
+	if e == nil {
+		e = &historyUser{User: u}
+		h.Users[k] = e
+	}
+	return e
+}
+
+func (p *Pipeline) recordHistory(rep *RunReport) {
+	if p.r0.Layout == nil || p.r0.DryRun {
+		return
+	}
+	now := time.Now().UTC()
+	rep.mu.Lock()
+	ts := append([]targetReport(nil), rep.Targets...)
+	rep.mu.Unlock()
+	if len(ts) == 0 {
+		return
+	}
+	err := updateHistory(p.r0.Layout.StateFile(historyFile), func(h *runHistory) {
+		for _, t := range ts {
+			if t.Status == targetSkipped {
+				continue
+			}
+			j := t.json()
+			e := h.user(t.User)
+			e.LastRun = now
+			if t.Status == targetFailed {
+				e.Failures++
+			} else {
+				e.LastSuccess, e.Failures = now, 0
+			}
+			e.Runs = append(e.Runs, historyRun{
+				At: now, RunID: p.r0.RunID, Status: j.Status, Found: j.Found,
+				Downloaded: j.Downloaded, Failed: j.Failed, Bytes: j.Bytes, Error: j.Error,
+			})
+			if n := len(e.Runs); n > historyRuns {
+				e.Runs = e.Runs[n-historyRuns:]
+			}
+		}
+	})
+	if err != nil {
+		log.LogError("history", err.Error())
+	}
+}
+
+// recordSchedule stores the next watch cycle of every user; a nil scheduler
+// clears them when the watch loop exits.
+func (p *Pipeline) recordSchedule(ws *watchScheduler) {
+	if p.r0.Layout == nil || p.r0.DryRun {
+		return
+	}
+	err := updateHistory(p.r0.Layout.StateFile(historyFile), func(h *runHistory) {
+		if ws == nil {
+			h.WatchPID = 0
+			for _, e := range h.Users {
+				e.Next = time.Time{}
+			}
+			return
+		}
+		h.WatchPID = os.Getpid()
+		for _, u := range ws.users {
+			if st := ws.state[strings.ToLower(u)]; st != nil {
+				h.user(u).Next = st.next.UTC()
+			}
+		}
+	})
+	if err != nil {
+		log.LogError("history", err.Error())
+	}
+}
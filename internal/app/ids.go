@@ -0,0 +1,137 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/errs"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/scraper"
+)
+
+var reTweetID = regexp.MustCompile(`(?:status(?:es)?/)?(\d{5,20})`)
+
+func readTweetIDs(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []string
+	seen := map[string]struct{}{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, tok := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			m := reTweetID.FindStringSubmatch(tok)
+			if m == nil {
+				continue
+			}
+			if _, dup := seen[m[1]]; dup {
+				continue
+			}
+			seen[m[1]] = struct{}{}
+			out = append(out, m[1])
+		}
+	}
+	return out, sc.Err()
+}
+
+func (p *Pipeline) runIDs() targetReport {
+	r0 := p.r0
+	label := "ids"
+	t0 := time.Now()
+	defer globalControl.begin(label)()
+
+	ids, err := readTweetIDs(r0.IDsFile)
+	if err != nil {
+		return newTargetReport(label, t0, scanResult{}, downloadStats{}, fmt.Errorf("Could not read tweet IDs from %s: %w", r0.IDsFile, err))
+	}
+	if len(ids) == 0 {
+		return newTargetReport(label, t0, scanResult{}, downloadStats{}, fmt.Errorf("No tweet IDs found in %s", r0.IDsFile))
+	}
+	if l0 := r0.DownloadArchive; l0 != nil {
+		n := len(ids)
+		ids = slices.DeleteFunc(ids, l0.Has)
+		if n > len(ids) {
+			r0.ui().info("Skipping %d tweet IDs already in %s", n-len(ids), l0.Path())
+		}
+		if len(ids) == 0 {
+			r0.ui().targetDone(label, t0, scanResult{}, downloadStats{})
+			return newTargetReport(label, t0, scanResult{}, downloadStats{}, nil)
+		}
+	}
+	r0.ui().info("Hydrating %d tweet IDs from %s", len(ids), r0.IDsFile)
+
+	d0, err := prepareRunOutputDir(r0, p.c0, label, nil)
+	if err != nil {
+		return newTargetReport(label, t0, scanResult{}, downloadStats{}, err)
+	}
+
+	l0 := p.limiter()
+	a0 := newScanAccumulator(len(ids))
+	s0 := downloadStats{}
+
+	for i, p0 := 0, 1; i < len(ids); i, p0 = i+scraper.TweetBatchSize, p0+1 {
+		if err := p.interrupted(label); err != nil {
+			return newTargetReport(label, t0, a0.Result(), s0, err)
+		}
+		j := min(i+scraper.TweetBatchSize, len(ids))
+		l0.SleepBeforeRequest(context.Background(), label, p0, p0)
+
+		ms, err := p.fetchTweets(ids[i:j])
+		if err != nil {
+			log.LogError("tweets", err.Error())
+			if h := remediate(label, err); h != nil && r0.Mode != ModeDebug {
+				err = h
+			}
+			return newTargetReport(label, t0, a0.Result(), s0, err)
+		}
+		ms, x0 := splitExternal(ms)
+		p.handoff(label, d0, x0)
+		r0.ui().info("Batch %d: %d/%d IDs, %d media", p0, j, len(ids), len(ms))
+		if len(ms) == 0 {
+			continue
+		}
+		a0.Add(ms)
+
+		sum, err := p.download("", label, d0, p0, ms)
+		s0.add(sum)
+		if err != nil {
+			return newTargetReport(label, t0, a0.Result(), s0, err)
+		}
+	}
+
+	r0.ui().targetDone(label, t0, a0.Result(), s0)
+	return newTargetReport(label, t0, a0.Result(), s0, nil)
+}
+
+func (p *Pipeline) fetchTweets(ids []string) ([]scraper.Media, error) {
+	if !p.r0.NoAuth {
+		return scraper.FetchTweetsByIDs(p.api, p.c0, ids)
+	}
+	var out []scraper.Media
+	for _, id := range ids {
+		ms, err := scraper.FetchSyndicationTweet(p.api, id)
+		if errors.Is(err, errs.ErrUserNotFound) {
+			log.LogWarn("tweets", "tweet "+id+" is not public or was deleted")
+			continue
+		}
+		if err != nil {
+			return out, err
+		}
+		out = append(out, ms...)
+	}
+	return out, nil
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/scraper"
+)
+
+type lookupEntry struct {
+	done    chan struct{}
+	id      string
+	pinned  []string
+	media   int64
+	profile scraper.UserProfile
+	at      time.Time
+	err     error
+}
+
+type userLookupCache struct {
+	mu sync.Mutex
+	m  map[string]*lookupEntry
+}
+
+func newUserLookupCache() *userLookupCache {
+	return &userLookupCache{m: make(map[string]*lookupEntry)}
+}
+
+func (c *userLookupCache) Resolve(h *http.Client, cf *config.EssentialsConfig, user string) (string, error) {
+	k := strings.ToLower(strings.TrimSpace(user))
+
+	c.mu.Lock()
+	if e, ok := c.m[k]; ok {
+		c.mu.Unlock()
+		<-e.done
+		return e.id, e.err
+	}
+	e := &lookupEntry{done: make(chan struct{})}
+	c.m[k] = e
+	c.mu.Unlock()
+
+	pf, err := scraper.FetchUserProfile(h, cf, user)
+	e.id, e.pinned, e.media, e.profile, e.err = pf.ID, pf.Pinned, pf.MediaCount, pf, err
+	e.at = time.Now()
+	close(e.done)
+
+	if e.err != nil {
+		c.mu.Lock()
+		if c.m[k] == e {
+			delete(c.m, k)
+		}
+		c.mu.Unlock()
+	}
+	return e.id, e.err
+}
+
+func (c *userLookupCache) done(user string) *lookupEntry {
+	k := strings.ToLower(strings.TrimSpace(user))
+	c.mu.Lock()
+	e, ok := c.m[k]
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	select {
+	case <-e.done:
+		return e
+	default:
+		return nil
+	}
+}
+
+func (c *userLookupCache) Pinned(user string) []string {
+	if e := c.done(user); e != nil {
+		return e.pinned
+	}
+	return nil
+}
+
+func (c *userLookupCache) MediaCount(user string) (int64, bool) {
+	if e := c.done(user); e != nil && e.err == nil {
+		return e.media, true
+	}
+	return 0, false
+}
+
+// Profile returns the profile a lookup fetched at or after since, so a watch
+// cycle does not reuse one from an earlier cycle.
+func (c *userLookupCache) Profile(user string, since time.Time) (scraper.UserProfile, bool) {
+	if e := c.done(user); e != nil && e.err == nil && !e.at.Before(since) {
+		return e.profile, true
+	}
+	return scraper.UserProfile{}, false
+}
+
+var userLookups = newUserLookupCache()
@@ -2,19 +2,19 @@ package app
 
 import (
 	"fmt"
-	"net/http"
+	"path/filepath"
 	"time"
 
-	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/archive"
 	"github.com/ghostlawless/xdl/internal/downloader"
 	"github.com/ghostlawless/xdl/internal/log"
-	"github.com/ghostlawless/xdl/internal/runtime"
+	"github.com/ghostlawless/xdl/internal/paths"
 	"github.com/ghostlawless/xdl/internal/scraper"
-	"github.com/ghostlawless/xdl/internal/utils"
 )
 
 type scanAccumulator struct {
 	media      []scraper.Media
+	seen       map[string]struct{}
 	mediaCount int
 	imageCount int
 	videoCount int
@@ -26,6 +26,7 @@ func newScanAccumulator(initialCapacity int) *scanAccumulator {
 	}
 	return &scanAccumulator{
 		media: make([]scraper.Media, 0, initialCapacity),
+		seen:  make(map[string]struct{}, initialCapacity),
 	}
 }
 
@@ -45,7 +46,38 @@ func (a *scanAccumulator) Add(medias []scraper.Media) {
 	}
 
 	a.media = append(a.media, medias...)
+	for _, m := range medias {
+		a.seen[m.URL] = struct{}{}
+	}
+
+}
+
+func (a *scanAccumulator) Fresh(medias []scraper.Media) []scraper.Media {
+	out := make([]scraper.Media, 0, len(medias))
+	batch := make(map[string]struct{}, len(medias))
+	for _, m := range medias {
+		_, old := a.seen[m.URL]
+		_, dup := batch[m.URL]
+		if old || dup {
+			continue
+		}
+		batch[m.URL] = struct{}{}
+		out = append(out, m)
+	}
+	return out
+}
 
+func (a *scanAccumulator) Oldest() time.Time {
+	var t time.Time
+	for _, m := range a.media {
+		if m.Pinned {
+			continue
+		}
+		if tt := scraper.TweetTime(m.TweetID); !tt.IsZero() && (t.IsZero() || tt.Before(t)) {
+			t = tt
+		}
+	}
+	return t
 }
 
 func (a *scanAccumulator) Result() scanResult {
@@ -62,6 +94,7 @@ type scanResult struct {
 	TotalMedia  int
 	TotalImages int
 	TotalVideos int
+	Drift       scraper.Drift
 }
 
 type downloadStats struct {
@@ -69,221 +102,181 @@ type downloadStats struct {
 	Skipped    int
 	Failed     int
 	Bytes      int64
+	Estimate   *downloader.Estimate
 }
 
-func newPageProgressCallback(
-	r0 RunContext,
-	u0 string,
-	p0 int,
-	n0 int,
-) func(downloader.ProgressEvent) {
-	if n0 <= 0 {
-		return nil
-	}
-
-	type x1 struct {
-		a int
-		b int
-		c int
-		d int64
-		e int
-	}
-	x0 := &x1{}
-
-	switch r0.Mode {
-	case ModeVerbose:
-		return func(ev downloader.ProgressEvent) {
-			if globalControl.ShouldQuit() {
-				return
-			}
-
-			switch ev.Kind {
-			case downloader.ProgressKindDownloaded:
-				x0.a++
-				x0.d += ev.Size
-			case downloader.ProgressKindSkipped:
-				x0.b++
-			case downloader.ProgressKindFailed:
-				x0.c++
-			}
-
-			k0 := x0.a + x0.b + x0.c
-			if k0 <= 0 {
-				return
-			}
+func (s *downloadStats) add(sum downloader.Summary) {
+	s.Downloaded += sum.Downloaded
+	s.Skipped += sum.Skipped
+	s.Failed += sum.Failed
+	s.Bytes += sum.TotalBytes
+}
 
-			f0 := float64(k0) / float64(n0)
-			if f0 < 0 {
-				f0 = 0
-			}
-			if f0 > 1 {
-				f0 = 1
-			}
+type pageTally struct {
+	ok, skip, fail int
+	bytes          int64
+	events         int
+	inflight       map[string][2]int64
+	start          time.Time
+}
 
-			pct := f0 * 100.0
-			bar := buildProgressBar(30, f0)
+func (x *pageTally) add(ev downloader.ProgressEvent) {
+	switch ev.Kind {
+	case downloader.ProgressKindBytes:
+		x.inflight[ev.URL] = [2]int64{ev.Done, ev.Total}
+	case downloader.ProgressKindDownloaded:
+		x.ok++
+		x.bytes += ev.Size
+		delete(x.inflight, ev.URL)
+	case downloader.ProgressKindSkipped:
+		x.skip++
+		delete(x.inflight, ev.URL)
+	case downloader.ProgressKindFailed:
+		x.fail++
+		delete(x.inflight, ev.URL)
+	}
+}
 
-			sfx := ""
-			if globalControl.ShouldPause() {
-				sfx = " (paused)"
-			}
+func (x *pageTally) done() int { return x.ok + x.skip + x.fail }
 
-			termMu.Lock()
-			defer termMu.Unlock()
+func pageFraction(k0, n0 int) float64 {
+	return min(max(float64(k0)/float64(n0), 0), 1)
+}
 
-			fmt.Printf(
-				"\rxdl @%s%s  page %d  [%s] %3.0f%%  %d/%d  (ok:%d skip:%d fail:%d)",
-				u0, sfx, p0, bar, pct, k0, n0,
-				x0.a, x0.b, x0.c,
-			)
+func newBarProgress(u0 string, p0, n0 int) func(downloader.ProgressEvent) {
+	if n0 <= 0 {
+		return nil
+	}
+	x0 := &pageTally{inflight: map[string][2]int64{}}
+	return func(ev downloader.ProgressEvent) {
+		if globalControl.ShouldQuit() {
+			return
 		}
 
-	case ModeDebug:
-		return func(ev downloader.ProgressEvent) {
-			switch ev.Kind {
-			case downloader.ProgressKindDownloaded:
-				x0.a++
-				x0.d += ev.Size
-			case downloader.ProgressKindSkipped:
-				x0.b++
-			case downloader.ProgressKindFailed:
-				x0.c++
-			}
-
-			k0 := x0.a + x0.b + x0.c
-			if k0 <= 0 {
-				return
+		n1 := time.Now()
+		if x0.start.IsZero() {
+			x0.start = n1
+		}
+		x0.add(ev)
+
+		k0 := x0.done()
+		b1 := x0.bytes
+		var g0 [2]int64
+		for _, v := range x0.inflight {
+			b1 += v[0]
+			if v[1] > g0[1] {
+				g0 = v
 			}
+		}
+		if k0 <= 0 && b1 <= 0 {
+			return
+		}
 
-			x0.e++
-			emit := false
-			if n0 <= 50 {
-				emit = true
-			} else if x0.e%10 == 0 || k0 == n0 {
-				emit = true
-			}
-			if !emit {
-				return
-			}
+		f0 := pageFraction(k0, n0)
+		bar := buildProgressBar(30, f0)
 
-			f0 := float64(k0) / float64(n0)
-			if f0 < 0 {
-				f0 = 0
-			}
-			if f0 > 1 {
-				f0 = 1
-			}
-			pct := int(f0*100 + 0.5)
-
-			msg := fmt.Sprintf(
-				"progress user=%s page=%d done=%d/%d (%d%%) ok=%d skip=%d fail=%d bytes=%d",
-				u0,
-				p0,
-				k0,
-				n0,
-				pct,
-				x0.a,
-				x0.b,
-				x0.c,
-				x0.d,
-			)
-			log.LogInfo("download", msg)
+		sfx := ""
+		if globalControl.ShouldPause() {
+			sfx = " (paused)"
 		}
 
-	default:
-		return nil
+		globalProgress.Update(u0, fmt.Sprintf(
+			"xdl @%s%s  page %d  [%s] %3.0f%%  %d/%d  (ok:%d skip:%d fail:%d)%s",
+			u0, sfx, p0, bar, f0*100.0, k0, n0,
+			x0.ok, x0.skip, x0.fail, formatSpeedETA(b1, k0, n0, n1.Sub(x0.start))+formatInFlight(len(x0.inflight), g0),
+		), progressStats{OK: x0.ok, Skip: x0.skip, Fail: x0.fail, Bytes: b1})
 	}
-
 }
 
-func scanAndDownloadUserMedia(
-	r0 RunContext,
-	c0 *config.EssentialsConfig,
-	h0, h1 *http.Client,
-	u0 string,
-	u1 string,
-	d0 string,
-	l0 *runtime.Limiter,
-) (scanResult, downloadStats, error) {
-	a0 := newScanAccumulator(256)
-	s0 := downloadStats{}
-
-	v0 := r0.Mode == ModeVerbose && len(r0.Users) == 1
-
-	f0 := func(p0 int, _ string, m0 []scraper.Media) error {
-		if globalControl.ShouldQuit() {
-			return fmt.Errorf("Stopped by user.")
-		}
-
-		if len(m0) == 0 {
-			return nil
-		}
-
-		a0.Add(m0)
-
-		e0 := scraper.EnrichMediaWithTweetDetail(h0, c0, u1, m0, l0, v0)
-		if len(e0) == 0 {
-			return nil
+func newLogProgress(u0 string, p0, n0 int) func(downloader.ProgressEvent) {
+	if n0 <= 0 {
+		return nil
+	}
+	x0 := &pageTally{inflight: map[string][2]int64{}}
+	return func(ev downloader.ProgressEvent) {
+		if ev.Kind == downloader.ProgressKindBytes {
+			return
 		}
+		x0.add(ev)
 
-		cb := newPageProgressCallback(r0, u1, p0, len(e0))
-
-		sum, err := downloader.DownloadAllCycles(h1, c0, e0, downloader.Options{
-			RunDir:            d0,
-			User:              u1,
-			MediaMaxBytes:     0,
-			DryRun:            r0.DryRun,
-			Attempts:          3,
-			PerAttemptTimeout: 2 * time.Minute,
-			Progress:          cb,
-			ShouldPause:       globalControl.ShouldPause,
-			ShouldQuit:        globalControl.ShouldQuit,
-		})
-		if err != nil {
-			log.LogError("download", err.Error())
-			return fmt.Errorf("Download failed for @%s. Try again, or run with -d to generate logs.", u1)
+		k0 := x0.done()
+		if k0 <= 0 {
+			return
 		}
 
-		s0.Downloaded += sum.Downloaded
-		s0.Skipped += sum.Skipped
-		s0.Failed += sum.Failed
-		s0.Bytes += sum.TotalBytes
-
-		if r0.Mode == ModeDebug {
-			log.LogInfo("download", fmt.Sprintf(
-				"page=%d user=%s ok=%d skip=%d fail=%d bytes=%d cycles=%d",
-				p0, u1,
-				sum.Downloaded,
-				sum.Skipped,
-				sum.Failed,
-				sum.TotalBytes,
-				sum.Cycles,
-			))
+		x0.events++
+		if n0 > 50 && x0.events%10 != 0 && k0 != n0 {
+			return
 		}
 
-		if globalControl.ShouldQuit() {
-			if r0.Mode == ModeVerbose {
-				termMu.Lock()
-				fmt.Print("\n")
-				termMu.Unlock()
-				utils.PrintWarn("Stopped by user for @%s", u1)
-			}
-			return fmt.Errorf("Stopped by user.")
-		}
+		log.LogInfo("download", fmt.Sprintf(
+			"progress user=%s page=%d done=%d/%d (%d%%) ok=%d skip=%d fail=%d bytes=%d",
+			u0, p0, k0, n0, int(pageFraction(k0, n0)*100+0.5), x0.ok, x0.skip, x0.fail, x0.bytes,
+		))
+	}
+}
 
-		if r0.Mode == ModeVerbose && cb != nil {
-			termMu.Lock()
-			fmt.Print("\n")
-			termMu.Unlock()
-		}
+func formatSpeedETA(bytes int64, done, total int, el time.Duration) string {
+	if el < time.Second {
+		return ""
+	}
+	out := fmt.Sprintf("  %.1f MB/s", float64(bytes)/1024/1024/el.Seconds())
+	if done > 0 && done < total {
+		eta := time.Duration(float64(el) / float64(done) * float64(total-done))
+		out += "  ETA " + formatETA(eta)
+	}
+	return out
+}
 
-		return nil
+func formatInFlight(n int, big [2]int64) string {
+	if n == 0 || big[1] <= 0 {
+		return ""
 	}
+	return fmt.Sprintf("  [%d active, %.1f/%.1f MB]", n, float64(big[0])/1024/1024, float64(big[1])/1024/1024)
+}
 
-	if err := scraper.WalkUserMediaPages(h0, c0, u0, u1, v0, l0, f0); err != nil {
-		return a0.Result(), s0, err
+func formatETA(d time.Duration) string {
+	d = d.Round(time.Second)
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
 	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
 
-	return a0.Result(), s0, nil
+func recordArchivedMedia(st *archive.Store, uid, user string, cp *downloader.Checkpoint) {
+	if st == nil {
+		return
+	}
+	root := st.Root()
+	for _, it := range cp.DoneItems() {
+		p := it.Path
+		if rel, err := filepath.Rel(root, p); err == nil && p != "" {
+			p = filepath.ToSlash(rel)
+		}
+		st.AddMedia(archive.MediaRecord{
+			UserID:  uid,
+			Handle:  user,
+			TweetID: it.TweetID,
+			URL:     it.URL,
+			Type:    it.Type,
+			Path:    p,
+			SHA256:  it.SHA256,
+			Size:    it.Size,
+			MIME:    it.MIME,
+			AltText: it.AltText,
+		})
+	}
+}
 
+func saveCheckpoint(dir string, cp *downloader.Checkpoint) {
+	if cp == nil || dir == "" {
+		return
+	}
+	p := paths.Checkpoint(dir)
+	if err := cp.Save(p); err != nil {
+		log.LogError("download", "checkpoint save failed: "+err.Error())
+		return
+	}
+	log.LogInfo("download", "checkpoint saved: "+p)
 }
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/log"
+)
+
+const (
+	orderAsGiven = "as-given"
+	orderAlpha   = "alphabetical"
+	orderSize    = "size"
+	orderRecent  = "recent-first"
+	orderStale   = "stale"
+)
+
+var targetOrders = []string{orderAsGiven, orderAlpha, orderSize, orderRecent, orderStale}
+
+func validOrder(o string) bool {
+	for _, v := range targetOrders {
+		if o == v {
+			return true
+		}
+	}
+	return false
+}
+
+// order sorts targets by their targets-file priority (highest first) and then
+// by -order. Ties keep the order they were given in.
+func (p *Pipeline) order(ts []target) []target {
+	if len(ts) < 2 {
+		return ts
+	}
+	prio := func(t target) int {
+		if o := p.r0.Overrides[strings.ToLower(t.User)]; o != nil && o.Priority != nil {
+			return *o.Priority
+		}
+		return 0
+	}
+	var less func(a, b target) bool
+	switch p.r0.Order {
+	case orderAlpha:
+		less = func(a, b target) bool { return strings.ToLower(a.User) < strings.ToLower(b.User) }
+	case orderSize:
+		// Accounts the lookup could not size go last.
+		less = func(a, b target) bool {
+			na, oka := userLookups.MediaCount(a.User)
+			nb, okb := userLookups.MediaCount(b.User)
+			if oka != okb {
+				return oka
+			}
+			return na < nb
+		}
+	case orderRecent, orderStale:
+		h := p.history()
+		at := func(t target) time.Time {
+			e := h.Users[strings.ToLower(t.User)]
+			if e == nil {
+				return time.Time{}
+			}
+			if p.r0.Order == orderStale {
+				return e.LastSuccess
+			}
+			for i := len(e.Runs) - 1; i >= 0; i-- {
+				if e.Runs[i].Downloaded > 0 {
+					return e.Runs[i].At
+				}
+			}
+			return time.Time{}
+		}
+		if p.r0.Order == orderStale {
+			less = func(a, b target) bool { return at(a).Before(at(b)) }
+		} else {
+			less = func(a, b target) bool { return at(a).After(at(b)) }
+		}
+	default:
+		less = func(a, b target) bool { return false }
+	}
+	out := append([]target(nil), ts...)
+	sort.SliceStable(out, func(i, j int) bool {
+		if pi, pj := prio(out[i]), prio(out[j]); pi != pj {
+			return pi > pj
+		}
+		return less(out[i], out[j])
+	})
+	us := make([]string, len(out))
+	for i, t := range out {
+		us[i] = "@" + t.User
+	}
+	log.LogInfo("main", fmt.Sprintf("target order (%s): %s", p.r0.Order, strings.Join(us, ", ")))
+	return out
+}
+
+func (p *Pipeline) history() *runHistory {
+	if p.r0.Layout != nil {
+		if h, err := readHistory(p.r0.Layout.StateFile(historyFile)); err == nil {
+			return h
+		}
+	}
+	return &runHistory{Users: map[string]*historyUser{}}
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+func runParseCommand(args []string, runID string, runSeed []byte) error {
+	var (
+		from   string
+		asJSON bool
+	)
+	r0, rest, err := parseCommandArgs("parse", args, runID, runSeed, func(fs *flag.FlagSet) {
+		fs.StringVar(&from, "from", "", "Folder or file with captured raw responses")
+		fs.BoolVar(&asJSON, "json", false, "Print media as JSON instead of one URL per line")
+	})
+	if err != nil {
+		return err
+	}
+	if from == "" && len(rest) > 0 {
+		from = rest[0]
+	}
+	if strings.TrimSpace(from) == "" {
+		return fmt.Errorf("Usage: xdl parse --from logs/run_<id>/raw/ [-json]")
+	}
+
+	files, err := rawFiles(from)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("No .json files found in %s", from)
+	}
+
+	var (
+		all    []scraper.Media
+		seen   = map[string]struct{}{}
+		parsed int
+	)
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			utils.PrintWarn("%s: %v", f, err)
+			continue
+		}
+		ms, cur, err := scraper.ParseMediaPage(b)
+		if err != nil {
+			utils.PrintWarn("%s: %v", f, err)
+			continue
+		}
+		parsed++
+		n := 0
+		for _, m := range ms {
+			if _, dup := seen[m.URL]; dup {
+				continue
+			}
+			seen[m.URL] = struct{}{}
+			all = append(all, m)
+			n++
+		}
+		if r0.Mode == ModeDebug {
+			utils.PrintInfo("%s: +%d media, cursor=%v", filepath.Base(f), n, cur != "")
+		}
+	}
+	if parsed == 0 {
+		return fmt.Errorf("None of the %d files in %s could be parsed", len(files), from)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(utils.Stdout)
+		enc.SetIndent("", "  ")
+		if all == nil {
+			all = []scraper.Media{}
+		}
+		_ = enc.Encode(all)
+	} else {
+		for _, m := range all {
+			fmt.Fprintln(utils.Stdout, m.URL)
+		}
+	}
+	if r0.Mode != ModeQuiet {
+		utils.PrintWarn("parsed %d/%d files, %d media", parsed, len(files), len(all))
+	}
+	return nil
+}
+
+func rawFiles(from string) ([]string, error) {
+	st, err := os.Stat(from)
+	if err != nil {
+		return nil, err
+	}
+	if !st.IsDir() {
+		return []string{from}, nil
+	}
+	m, err := filepath.Glob(filepath.Join(from, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(m)
+	return m, nil
+}
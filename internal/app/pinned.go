@@ -0,0 +1,48 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/scraper"
+)
+
+func (p *Pipeline) pinned(user string, st *stopAt) []scraper.Media {
+	if p.r0.NoAuth {
+		return nil
+	}
+	ids := userLookups.Pinned(user)
+	if st != nil && st.newer > 0 {
+		keep := ids[:0:0]
+		for _, id := range ids {
+			if n, err := strconv.ParseUint(id, 10, 64); err == nil && n > st.newer {
+				keep = append(keep, id)
+			}
+		}
+		ids = keep
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	ms, err := scraper.FetchTweetsByIDs(p.api, p.c0, ids)
+	if err != nil {
+		log.LogWarn("media", fmt.Sprintf("pinned tweet of @%s: %v", user, err))
+		return nil
+	}
+	want := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+	out := ms[:0]
+	for _, m := range ms {
+		if want[m.TweetID] {
+			m.Pinned = true
+			out = append(out, m)
+		}
+	}
+	if len(out) > 0 {
+		log.LogInfo("media", fmt.Sprintf("@%s: %d media from the pinned tweet", user, len(out)))
+	}
+	return out
+}
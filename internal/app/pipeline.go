@@ -0,0 +1,453 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/runtime"
+	"github.com/ghostlawless/xdl/internal/scraper"
+)
+
+const maxParallelTargets = 4
+
+type Pipeline struct {
+	r0   RunContext
+	c0   *config.EssentialsConfig
+	api  *http.Client
+	dl   *http.Client
+	pace *runtime.Pacing
+	who  string
+}
+
+func newPipeline(r0 RunContext, c0 *config.EssentialsConfig, h0, h1 *http.Client) *Pipeline {
+	return &Pipeline{r0: r0, c0: c0, api: h0, dl: h1, pace: runtime.NewPacing(c0.PacingBounds())}
+}
+
+func (p *Pipeline) with(r0 RunContext) *Pipeline {
+	q := *p
+	q.r0 = r0
+	return &q
+}
+
+func (p *Pipeline) Run() error {
+	if p.r0.Watch > 0 {
+		return p.watch()
+	}
+	return p.collect().Err()
+}
+
+func (p *Pipeline) collect() *RunReport {
+	rep := &RunReport{}
+	if p.r0.IDsFile != "" {
+		rep.Add(p.runIDs())
+	}
+	if p.r0.Takeout != "" {
+		rep.Add(p.runTakeout())
+	}
+	p.runTargets(rep, p.targets())
+	log.LogInfo("pacing", fmt.Sprintf("request delays scaled x%.2f by observed rate-limit headroom", p.pace.Scale()))
+	saveArchive(p.r0.Store)
+	p.r0.ui().batchDone(rep)
+	p.recordHistory(rep)
+	if err := appendReportFile(p.r0, rep); err != nil {
+		log.LogError("report", err.Error())
+		p.r0.ui().warn("Could not write report %s: %v", p.r0.ReportFile, err)
+	}
+	return rep
+}
+
+func (p *Pipeline) targets() []target {
+	switch len(p.r0.Users) {
+	case 0:
+		return nil
+	case 1:
+		return []target{{User: p.r0.Users[0]}}
+	}
+	if p.r0.NoAuth {
+		return p.order(singleTargets(p.r0.Users))
+	}
+	return p.order(mergeTargetsByID(p.r0, p.c0, p.api, p.r0.Users, min(len(p.r0.Users), maxParallelTargets)))
+}
+
+func (p *Pipeline) runTargets(rep *RunReport, ts []target) {
+	s0 := make(chan struct{}, maxParallelTargets)
+	var w0 sync.WaitGroup
+	for _, t := range ts {
+		w0.Add(1)
+		s0 <- struct{}{}
+		go func() {
+			defer w0.Done()
+			defer func() { <-s0 }()
+
+			if e0 := p.interrupted(""); e0 != nil {
+				rep.Add(newTargetReport(t.User, time.Now(), scanResult{}, downloadStats{}, e0))
+				return
+			}
+			rep.Add(p.runTarget(t))
+		}()
+	}
+	w0.Wait()
+}
+
+func (p *Pipeline) runTarget(t target) (rep targetReport) {
+	p = p.with(p.r0.forUser(t.User))
+	p.who = t.User
+	u0 := t.User
+	t0 := time.Now()
+	defer p.recoverTarget(u0, t0, &rep)
+	defer globalControl.begin(u0)()
+	defer p.r0.ui().drop(u0)
+
+	p.r0.ui().targetStart(u0, t.Aliases)
+
+	unlock, e2 := p.lockUser(u0)
+	if e2 != nil {
+		return newTargetReport(u0, t0, scanResult{}, downloadStats{}, e2)
+	}
+	defer unlock()
+	defer globalWatchdog.begin(u0, p.r0.UserTimeout, p.r0.StallTimeout)()
+
+	s0 := newSpinnerForUser(p.r0, u0)
+	if s0 != nil {
+		defer stopSpinner(s0)
+	}
+
+	d0, i0, e0 := p.resolve(u0, s0)
+	if e0 != nil {
+		return newTargetReport(u0, t0, scanResult{}, downloadStats{}, e0)
+	}
+
+	a0, b0, e1 := p.scan(i0, u0, d0)
+	a0.Drift = schemaDrift.take(u0)
+	if h0 := remediate(u0, e1); h0 != nil && p.r0.Mode != ModeDebug {
+		e1 = h0
+	}
+	if e1 != nil {
+		return newTargetReport(u0, t0, a0, b0, e1)
+	}
+
+	p.r0.ui().targetDone(u0, t0, a0, b0)
+	return newTargetReport(u0, t0, a0, b0, nil)
+}
+
+func (p *Pipeline) resolve(u0 string, s0 *spinner) (dir, id string, err error) {
+	if dir, err = prepareRunOutputDir(p.r0, p.c0, u0, s0); err != nil {
+		return "", "", err
+	}
+	if p.r0.NoAuth {
+		if r, ok := p.r0.Store.LookupHandle(u0); ok {
+			id = r.ID
+		}
+		return dir, id, nil
+	}
+	t0 := time.Now()
+	if id, err = resolveUserID(p.r0, p.c0, p.api, u0, s0); err != nil {
+		return "", "", err
+	}
+	if p.r0.Watch > 0 && !p.r0.DryRun {
+		trackProfile(p.r0, p.c0, p.api, p.dl, u0, t0)
+	}
+	return dir, id, nil
+}
+
+func (p *Pipeline) scan(uid, user, dir string) (scanResult, downloadStats, error) {
+	a0 := newScanAccumulator(256)
+	s0 := downloadStats{}
+	l0 := p.limiter()
+	st := newStopAt(p.r0)
+	last := 0
+	var dry, ext []scraper.Media
+	defer func() { p.handoff(user, dir, ext) }()
+	dirs := &authorDirs{dirs: map[string]string{}}
+	defer dirs.release()
+
+	get := func(pg int, m1 []scraper.Media) error {
+		for _, g := range p.byAuthor(uid, user, dir, m1, dirs) {
+			sum, e1 := p.download(g.uid, g.user, g.dir, pg, g.media)
+			s0.add(sum)
+			if e1 != nil && !errors.Is(e1, errStoppedByUser) && !errors.Is(e1, errSkippedByUser) && !errors.Is(e1, ErrQuotaReached) && !errors.Is(e1, errUserTimeout) && !errors.Is(e1, errStalled) && !errors.Is(e1, errCrashed) {
+				return fmt.Errorf("Download failed for @%s. Try again, or run with -d to generate logs.", user)
+			}
+			if e1 != nil {
+				return e1
+			}
+		}
+		return nil
+	}
+
+	f0 := func(pg int, _ string, m0 []scraper.Media) error {
+		last = pg
+		globalWatchdog.touch(user, fmt.Sprintf("scanning page %d", pg))
+		if e0 := p.interrupted(user); e0 != nil {
+			return e0
+		}
+		m0, x0 := splitExternal(m0)
+		ext = append(ext, x0...)
+		m0 = p.ownMedia(uid, user, m0)
+		m0, done := st.page(p.r0.Store, m0)
+		var stop error
+		if done {
+			stop = errStopCondition
+		}
+		m0 = p.unlisted(a0.Fresh(m0))
+		if len(m0) == 0 {
+			return stop
+		}
+		a0.Add(m0)
+
+		m1 := p.filter(user, m0, l0)
+		if len(m1) == 0 {
+			return stop
+		}
+		if p.r0.DryRun || p.r0.Confirm {
+			dry = append(dry, m1...)
+			return stop
+		}
+		if e1 := get(pg, m1); e1 != nil {
+			return e1
+		}
+		return stop
+	}
+
+	var err error
+	if pin := p.pinned(user, st); len(pin) > 0 {
+		if err = f0(0, "", pin); err != nil && !errors.Is(err, errStopCondition) {
+			return a0.Result(), s0, err
+		}
+	}
+	if p.r0.NoAuth {
+		err = scraper.WalkSyndicationTimeline(p.api, user, f0)
+	} else {
+		mt := p.r0.MaxTweets
+		if mt <= 0 {
+			mt = p.c0.Runtime.MaxTweets
+		}
+		err = scraper.WalkUserMediaPagesLimited(p.api, p.c0, uid, user, p.r0.ui().chatty(), l0, p.r0.MaxPages, mt, f0)
+	}
+	if err == nil && p.r0.Deep && !st.incremental() {
+		p.r0.ui().info("Searching older media for @%s (deep mode)", user)
+		err = scraper.WalkSearchMediaWindows(p.api, p.c0, user, a0.Oldest(), last, l0, f0)
+	}
+	if errors.Is(err, errStopCondition) {
+		log.LogInfo("media", fmt.Sprintf("stop condition %s reached for @%s at page %d", st.why, user, last))
+		err = nil
+	}
+	if err == nil && p.r0.Confirm && !p.r0.DryRun {
+		var m1 []scraper.Media
+		if m1, err = p.confirm(user, dry); err == nil {
+			for i := 0; i < len(m1) && err == nil; i += confirmChunk {
+				err = get(i/confirmChunk+1, m1[i:min(i+confirmChunk, len(m1))])
+			}
+		}
+	}
+	if err == nil && p.r0.DryRun {
+		if p.r0.Preflight {
+			s0.Estimate = p.preflightEstimate(user, dry)
+		} else {
+			s0.Estimate = p.estimate(user, dry)
+		}
+	}
+	return a0.Result(), s0, err
+}
+
+func (p *Pipeline) filter(user string, m0 []scraper.Media, l0 *runtime.Limiter) []scraper.Media {
+	if p.r0.NoAuth {
+		return m0
+	}
+	return scraper.EnrichMediaWithTweetDetail(p.api, p.c0, user, m0, l0, p.r0.ui().chatty())
+}
+
+func (p *Pipeline) download(uid, user, dir string, pg int, ms []scraper.Media) (downloader.Summary, error) {
+	if ms = p.preflight(uid, user, dir, ms); len(ms) == 0 {
+		return downloader.Summary{}, nil
+	}
+	cb := p.r0.ui().pageProgress(user, pg, len(ms))
+	progress := func(ev downloader.ProgressEvent) {
+		globalWatchdog.touch(p.who, "downloading "+ev.URL)
+		if cb != nil {
+			cb(ev)
+		}
+	}
+	quit := func() bool {
+		return shouldStopDownloads() || globalControl.Skipped(user) || globalWatchdog.reason(p.who) != nil
+	}
+	var (
+		crashMu sync.Mutex
+		crashed error
+	)
+	onPanic := func(u string, v any, stack []byte) {
+		fp := p.crashReport("downloading "+u, p.who, v, stack)
+		crashMu.Lock()
+		if crashed == nil {
+			crashed = crashErr(p.who, fp)
+		}
+		crashMu.Unlock()
+	}
+	cp := downloader.NewCheckpoint(user, p.r0.RunID, ms)
+
+	sum, err := downloader.DownloadAllCycles(p.dl, p.c0, ms, downloader.Options{
+		RunDir:      dir,
+		User:        user,
+		DryRun:      p.r0.DryRun,
+		Progress:    progress,
+		ShouldPause: globalControl.ShouldPause,
+		ShouldQuit:  quit,
+		Checkpoint:  cp,
+		Written:     globalQuota.add,
+		Sink:        p.sink(dir),
+		Concurrency: p.c0.Concurrency(),
+		CheckSize:   p.r0.CheckSize,
+		OnPanic:     onPanic,
+	})
+	p.recordFailures(uid, user, dir, cp)
+	if err != nil {
+		log.LogError("download", err.Error())
+		if errors.Is(err, downloader.ErrAborted) {
+			saveCheckpoint(dir, cp)
+			return sum, p.stopReason(user)
+		}
+		return sum, err
+	}
+
+	if !p.r0.DryRun && uid != "" {
+		recordArchivedMedia(p.r0.Store, uid, user, cp)
+	}
+	if !p.r0.DryRun {
+		p.listTweets(cp)
+		p.checksums(dir, cp)
+		p.altText(dir, cp)
+		p.tweetTexts(dir, ms, cp)
+		p.geoJSON(dir, cp)
+	}
+	p.thumbnails(dir, ms, cp)
+	p.imageSizes(dir, cp)
+	p.packMetadata(dir, pg, cp.DoneItems())
+
+	p.r0.ui().debug("download", fmt.Sprintf(
+		"page=%d user=%s ok=%d skip=%d fail=%d bytes=%d cycles=%d",
+		pg, user, sum.Downloaded, sum.Skipped, sum.Failed, sum.TotalBytes, sum.Cycles,
+	))
+
+	if globalControl.ShouldQuit() {
+		saveCheckpoint(dir, cp)
+		p.r0.ui().commit(user)
+		p.r0.ui().warn("Stopped by user for @%s", user)
+		return sum, errStoppedByUser
+	}
+	if cb != nil {
+		p.r0.ui().commit(user)
+	}
+	return sum, crashed
+}
+
+func (p *Pipeline) unlisted(ms []scraper.Media) []scraper.Media {
+	l0, s0 := p.r0.DownloadArchive, p.r0.Store
+	if l0 == nil && s0 == nil {
+		return ms
+	}
+	out := ms[:0:0]
+	for _, m := range ms {
+		if !l0.Has(m.TweetID) && !s0.ImportedTweet(m.TweetID) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func (p *Pipeline) listTweets(cp *downloader.Checkpoint) {
+	l0 := p.r0.DownloadArchive
+	if l0 == nil {
+		return
+	}
+	ok := map[string]bool{}
+	for _, it := range cp.Items {
+		if it.TweetID == "" {
+			continue
+		}
+		d := it.Status == downloader.CheckpointDone || it.Status == downloader.CheckpointSkipped
+		if v, seen := ok[it.TweetID]; !seen || v {
+			ok[it.TweetID] = d
+		}
+	}
+	for id, d := range ok {
+		if !d {
+			continue
+		}
+		if err := l0.Add(id); err != nil {
+			log.LogError("archive", "download archive write failed: "+err.Error())
+			return
+		}
+	}
+}
+
+func (p *Pipeline) sink(dir string) downloader.Sink {
+	if p.r0.Pack == nil {
+		return downloader.DirSink{Root: dir}
+	}
+	return p.r0.Pack.Sub(p.packPrefix(dir))
+}
+
+func (p *Pipeline) packPrefix(dir string) string {
+	if rel, err := filepath.Rel(p.r0.OutRoot, dir); err == nil {
+		return rel
+	}
+	return filepath.Base(dir)
+}
+
+func (p *Pipeline) packMetadata(dir string, pg int, items []downloader.CheckpointItem) {
+	if p.r0.Pack == nil || len(items) == 0 {
+		return
+	}
+	b, err := json.MarshalIndent(items, "", "  ")
+	if err == nil {
+		err = p.r0.Pack.Add(path.Join(filepath.ToSlash(p.packPrefix(dir)), "meta", fmt.Sprintf("%s_p%03d.json", p.r0.RunID, pg)), b)
+	}
+	if err != nil {
+		log.LogError("archive", "metadata sidecar failed: "+err.Error())
+	}
+}
+
+func (p *Pipeline) interrupted(user string) error {
+	if e0 := globalWatchdog.reason(user); e0 != nil {
+		return e0
+	}
+	switch {
+	case globalControl.ShouldQuit(), globalShutdown.Draining():
+		return errStoppedByUser
+	case globalQuota.Halted():
+		return errQuotaStop
+	case user != "" && globalControl.Skipped(user):
+		return errSkippedByUser
+	}
+	return nil
+}
+
+func (p *Pipeline) stopReason(user string) error {
+	if e0 := globalWatchdog.reason(p.who); e0 != nil {
+		return e0
+	}
+	switch {
+	case globalControl.Skipped(user):
+		p.r0.ui().commit(user)
+		return errSkippedByUser
+	case globalQuota.Halted():
+		return errQuotaStop
+	}
+	return errStoppedByUser
+}
+
+func (p *Pipeline) limiter() *runtime.Limiter {
+	l := runtime.NewLimiterWith(p.r0.RunSeed, []byte(strings.TrimSpace(p.c0.Runtime.LimiterSecret)))
+	l.SetPacing(p.pace)
+	return l
+}
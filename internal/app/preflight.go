@@ -0,0 +1,55 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/scraper"
+)
+
+// preflight drops media whose every URL answers 404 before the page starts
+// downloading, so the progress bar counts only files that can arrive. The
+// dropped ones go into the failure log as not_found.
+func (p *Pipeline) preflight(uid, user, dir string, ms []scraper.Media) []scraper.Media {
+	if !p.r0.Preflight || len(ms) == 0 {
+		return ms
+	}
+	r := downloader.CheckURLs(p.dl, p.c0, ms, p.c0.Concurrency(), func() bool { return p.interrupted(user) != nil })
+	log.LogInfo("download", fmt.Sprintf(
+		"preflight user=%s media=%d dead=%d unknown=%d bytes=%d",
+		user, len(ms), len(r.Dead), r.Unknown, r.Bytes,
+	))
+	if len(r.Dead) == 0 {
+		return r.Live
+	}
+	p.r0.ui().info("Skipping %d deleted media for @%s (404 before download)", len(r.Dead), user)
+	cp := downloader.NewCheckpoint(user, p.r0.RunID, r.Dead)
+	for _, m := range r.Dead {
+		cp.MarkByURL(m.URL, downloader.CheckpointFailed, 0)
+		cp.SetFailure(m.URL, downloader.FailNotFound, "preflight: HEAD returned 404")
+	}
+	p.recordFailures(uid, user, dir, cp)
+	return r.Live
+}
+
+// preflightEstimate is the -dry-run estimate from a full HEAD pass: exact
+// for every file that answered, and without the ones already gone.
+func (p *Pipeline) preflightEstimate(user string, ms []scraper.Media) *downloader.Estimate {
+	p.r0.ui().info("Checking %d media URLs for @%s", len(ms), user)
+	r := downloader.CheckURLs(p.dl, p.c0, ms, p.c0.Concurrency(), func() bool { return p.interrupted(user) != nil })
+	log.LogInfo("download", fmt.Sprintf(
+		"preflight user=%s media=%d dead=%d unknown=%d bytes=%d",
+		user, len(ms), len(r.Dead), r.Unknown, r.Bytes,
+	))
+	if len(r.Dead) > 0 {
+		p.r0.ui().info("%d of them are deleted (404) and would be skipped", len(r.Dead))
+	}
+	return &downloader.Estimate{
+		Media:   len(r.Live),
+		Sampled: len(r.Live) - r.Unknown,
+		Bytes:   r.Bytes,
+		Exact:   r.Unknown == 0,
+		Unknown: r.Unknown,
+	}
+}
@@ -0,0 +1,205 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/archive"
+	"github.com/ghostlawless/xdl/internal/audit"
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/httpx"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+const maxProfileImageBytes = 16 << 20
+
+type profileEntry struct {
+	At          time.Time `json:"at"`
+	UserID      string    `json:"user_id"`
+	ScreenName  string    `json:"screen_name"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Location    string    `json:"location"`
+	Followers   int64     `json:"followers"`
+	Following   int64     `json:"following"`
+	Changed     []string  `json:"changed,omitempty"`
+}
+
+func trackProfile(r0 RunContext, c0 *config.EssentialsConfig, h0, h1 *http.Client, u0 string, since time.Time) {
+	if r0.Layout == nil {
+		return
+	}
+	p0, ok := userLookups.Profile(u0, since)
+	if !ok {
+		var e0 error
+		if p0, e0 = scraper.FetchUserProfile(h0, c0, u0); e0 != nil {
+			log.LogError("profile", fmt.Sprintf("@%s: %v", u0, e0))
+			return
+		}
+	}
+	d0 := r0.Layout.UserDir(u0)
+	recordProfileMetadata(r0, u0, d0, p0)
+	if !r0.NoDownload {
+		trackProfileImages(r0, c0, h1, u0, d0, p0)
+	}
+}
+
+func recordProfileMetadata(r0 RunContext, u0, d0 string, p0 scraper.UserProfile) {
+	if p0.ID == "" {
+		return
+	}
+	f0 := paths.ProfileHistory(d0)
+	n0 := profileEntry{
+		At:          time.Now().UTC(),
+		UserID:      p0.ID,
+		ScreenName:  p0.ScreenName,
+		Name:        p0.Name,
+		Description: p0.Description,
+		Location:    p0.Location,
+		Followers:   p0.Followers,
+		Following:   p0.Following,
+	}
+	if l0, ok := lastProfileEntry(f0); ok {
+		n0.Changed = profileDiff(l0, n0)
+		if len(n0.Changed) == 0 {
+			return
+		}
+	}
+	b0, e0 := json.Marshal(n0)
+	if e0 != nil {
+		return
+	}
+	if e1 := utils.EnsureDir(filepath.Dir(f0)); e1 != nil {
+		log.LogError("profile", e1.Error())
+		return
+	}
+	fh, e2 := os.OpenFile(f0, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if e2 != nil {
+		log.LogError("profile", e2.Error())
+		return
+	}
+	defer fh.Close()
+	if _, e3 := fh.Write(append(b0, '\n')); e3 != nil {
+		log.LogError("profile", e3.Error())
+		return
+	}
+	audit.Record(audit.Event{Action: audit.FileAppend, Path: f0, User: u0, Detail: strings.Join(n0.Changed, ",")})
+	if len(n0.Changed) > 0 {
+		log.LogInfo("profile", fmt.Sprintf("@%s changed: %s", u0, strings.Join(n0.Changed, ", ")))
+		if !countsOnly(n0.Changed) {
+			r0.ui().info("@%s updated their profile (%s)", u0, strings.Join(n0.Changed, ", "))
+		}
+	}
+}
+
+func lastProfileEntry(f0 string) (profileEntry, bool) {
+	b0, e0 := os.ReadFile(f0)
+	if e0 != nil {
+		return profileEntry{}, false
+	}
+	ls := strings.Split(strings.TrimSpace(string(b0)), "\n")
+	for i := len(ls) - 1; i >= 0; i-- {
+		var p profileEntry
+		if json.Unmarshal([]byte(ls[i]), &p) == nil && p.UserID != "" {
+			return p, true
+		}
+	}
+	return profileEntry{}, false
+}
+
+func profileDiff(a, b profileEntry) []string {
+	var out []string
+	if a.ScreenName != b.ScreenName {
+		out = append(out, "screen_name")
+	}
+	if a.Name != b.Name {
+		out = append(out, "name")
+	}
+	if a.Description != b.Description {
+		out = append(out, "description")
+	}
+	if a.Location != b.Location {
+		out = append(out, "location")
+	}
+	if a.Followers != b.Followers {
+		out = append(out, "followers")
+	}
+	if a.Following != b.Following {
+		out = append(out, "following")
+	}
+	return out
+}
+
+func countsOnly(changed []string) bool {
+	for _, c := range changed {
+		if c != "followers" && c != "following" {
+			return false
+		}
+	}
+	return true
+}
+
+func trackProfileImages(r0 RunContext, c0 *config.EssentialsConfig, h1 *http.Client, u0, d0 string, p0 scraper.UserProfile) {
+	if r0.Store == nil {
+		return
+	}
+	for _, k := range []struct{ kind, url string }{
+		{"avatar", p0.AvatarURL},
+		{"banner", p0.BannerURL},
+	} {
+		if k.url == "" {
+			continue
+		}
+		if last, ok := r0.Store.LatestProfileImage(p0.ID, k.kind); ok && last.URL == k.url {
+			continue
+		}
+		t0 := time.Now()
+		f0, e1 := downloadProfileImage(c0, h1, k.url, paths.ProfileImage(d0, k.kind, t0, profileImageExt(k.url)))
+		if e1 != nil {
+			log.LogError("profile", fmt.Sprintf("@%s %s: %v", u0, k.kind, e1))
+			continue
+		}
+		if rel, err := filepath.Rel(r0.Store.Root(), f0); err == nil {
+			f0 = filepath.ToSlash(rel)
+		}
+		r0.Store.AddProfileImage(p0.ID, k.kind, archive.ProfileImage{URL: k.url, Path: f0, SeenAt: t0.UTC()})
+		log.LogInfo("profile", fmt.Sprintf("@%s %s changed: %s", u0, k.kind, k.url))
+		r0.ui().info("@%s changed their %s (saved %s)", u0, k.kind, filepath.Base(f0))
+	}
+}
+
+func downloadProfileImage(c0 *config.EssentialsConfig, h1 *http.Client, u, dst string) (string, error) {
+	if err := utils.EnsureDir(filepath.Dir(dst)); err != nil {
+		return "", err
+	}
+	rq, err := http.NewRequest(http.MethodGet, c0.MediaURL(u), nil)
+	if err != nil {
+		return "", err
+	}
+	c0.BuildRequestHeaders(rq, c0.X.Network)
+	rq.Header.Set("Accept", "image/*")
+	if _, st, err := httpx.DownloadToFile(h1, rq, dst, maxProfileImageBytes); err != nil {
+		return "", fmt.Errorf("status %d: %w", st, err)
+	}
+	audit.File(audit.FileWrite, dst, u)
+	return dst, nil
+}
+
+func profileImageExt(u string) string {
+	if p, err := url.Parse(u); err == nil {
+		if ext := path.Ext(p.Path); ext != "" && len(ext) <= 5 {
+			return ext
+		}
+	}
+	return "jpg"
+}
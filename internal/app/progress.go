@@ -0,0 +1,260 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+type progressStats struct {
+	OK    int
+	Skip  int
+	Fail  int
+	Bytes int64
+}
+
+func (a progressStats) add(b progressStats) progressStats {
+	return progressStats{OK: a.OK + b.OK, Skip: a.Skip + b.Skip, Fail: a.Fail + b.Fail, Bytes: a.Bytes + b.Bytes}
+}
+
+type progressLine struct {
+	text  string
+	stats progressStats
+	spin  bool
+}
+
+type progressRenderer struct {
+	every time.Duration
+	ansi  bool
+	start time.Time
+
+	mu    sync.Mutex
+	lines map[string]progressLine
+	order []string
+	last  string
+	total progressStats
+	drawn int
+	dirty bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+var globalProgress *progressRenderer
+
+func startProgressRenderer(every time.Duration) func() {
+	if every <= 0 {
+		every = 100 * time.Millisecond
+	}
+	p := &progressRenderer{
+		every: every,
+		ansi:  utils.ANSIEnabled(),
+		start: time.Now(),
+		lines: map[string]progressLine{},
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	globalProgress = p
+	utils.SetPrintGuard(p.above)
+	go p.loop()
+	return func() {
+		close(p.stop)
+		<-p.done
+		utils.SetPrintGuard(nil)
+	}
+}
+
+func (p *progressRenderer) loop() {
+	defer close(p.done)
+	t := time.NewTicker(p.every)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			p.flush(false)
+		case <-p.stop:
+			p.flush(true)
+			return
+		}
+	}
+}
+
+func (p *progressRenderer) Update(key, text string, st progressStats) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.setLocked(key, progressLine{text: text, stats: st})
+}
+
+func (p *progressRenderer) Spin(key, text string) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if l, ok := p.lines[key]; ok && !l.spin {
+		return true
+	}
+	p.setLocked(key, progressLine{text: text, spin: true})
+	return true
+}
+
+func (p *progressRenderer) setLocked(key string, l progressLine) {
+	if _, ok := p.lines[key]; !ok {
+		p.order = append(p.order, key)
+	}
+	p.lines[key] = l
+	p.last = key
+	p.dirty = true
+}
+
+func (p *progressRenderer) Unspin(key string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if l, ok := p.lines[key]; ok && l.spin {
+		p.removeLocked(key)
+		p.dirty = true
+	}
+}
+
+func (p *progressRenderer) Commit(key string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.lines[key]
+	if !ok || l.spin {
+		return
+	}
+	p.removeLocked(key)
+	p.total = p.total.add(l.stats)
+
+	termMu.Lock()
+	defer termMu.Unlock()
+	p.writeLocked(l.text + "\n")
+}
+
+func (p *progressRenderer) Drop(key string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.lines[key]; ok {
+		p.removeLocked(key)
+		p.dirty = true
+	}
+}
+
+func (p *progressRenderer) above(write func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	termMu.Lock()
+	defer termMu.Unlock()
+	if p.drawn > 0 {
+		fmt.Fprint(utils.Stdout, p.clearSeq())
+		p.drawn = 0
+	}
+	write()
+	p.dirty = true
+}
+
+func (p *progressRenderer) removeLocked(key string) {
+	delete(p.lines, key)
+	for i, k := range p.order {
+		if k == key {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+	if p.last == key {
+		p.last = ""
+	}
+}
+
+func (p *progressRenderer) flush(final bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.dirty && !final {
+		return
+	}
+	termMu.Lock()
+	defer termMu.Unlock()
+	p.writeLocked("")
+	if final && p.drawn > 0 {
+		fmt.Fprint(utils.Stdout, "\n")
+		p.drawn = 0
+	}
+}
+
+func (p *progressRenderer) writeLocked(prefix string) {
+	p.dirty = false
+	if utils.StdoutClosed() {
+		p.drawn = 0
+		return
+	}
+	ls := p.frameLocked()
+	fmt.Fprint(utils.Stdout, p.clearSeq()+prefix+strings.Join(ls, "\n"))
+	p.drawn = len(ls)
+}
+
+func (p *progressRenderer) clearSeq() string {
+	if p.drawn == 0 {
+		return ""
+	}
+	if !p.ansi {
+		return "\r"
+	}
+	s := ""
+	if p.drawn > 1 {
+		s = fmt.Sprintf("\x1b[%dA", p.drawn-1)
+	}
+	return s + "\r\x1b[J"
+}
+
+func (p *progressRenderer) frameLocked() []string {
+	if len(p.order) == 0 {
+		return nil
+	}
+	if !p.ansi {
+		k := p.last
+		if k == "" {
+			k = p.order[len(p.order)-1]
+		}
+		return []string{p.lines[k].text}
+	}
+	ls := make([]string, 0, len(p.order)+1)
+	t := p.total
+	numbered := len(p.order) > 1 && globalControl.live.Load()
+	for _, k := range p.order {
+		l := p.lines[k]
+		if n := globalControl.slot(k); numbered && n > 0 && n < 10 {
+			ls = append(ls, fmt.Sprintf("%d %s", n, l.text))
+		} else {
+			ls = append(ls, l.text)
+		}
+		t = t.add(l.stats)
+	}
+	if len(p.order) > 1 {
+		ls = append(ls, formatTotals(len(p.order), t, time.Since(p.start)))
+	}
+	return ls
+}
+
+func formatTotals(active int, t progressStats, el time.Duration) string {
+	out := fmt.Sprintf("xdl total  %d active  ok:%d skip:%d fail:%d  %.1f MB",
+		active, t.OK, t.Skip, t.Fail, float64(t.Bytes)/1024/1024)
+	if el >= time.Second {
+		out += fmt.Sprintf("  %.1f MB/s", float64(t.Bytes)/1024/1024/el.Seconds())
+	}
+	return out
+}
@@ -0,0 +1,301 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+// progressFileEvery debounces -progress-file: at most one snapshot per interval.
+const progressFileEvery = 500 * time.Millisecond
+
+type progressUser struct {
+	User      string `json:"user"`
+	Phase     string `json:"phase"`
+	Pages     int    `json:"pages"`
+	Tweets    int    `json:"tweets"`
+	Media     int    `json:"media"`
+	Expected  int    `json:"expected,omitempty"`
+	Page      int    `json:"page,omitempty"`
+	PageDone  int    `json:"page_done,omitempty"`
+	PageTotal int    `json:"page_total,omitempty"`
+	OK        int    `json:"ok"`
+	Skip      int    `json:"skip"`
+	Fail      int    `json:"fail"`
+	Bytes     int64  `json:"bytes"`
+	Status    string `json:"status,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type progressSnapshot struct {
+	RunID   string         `json:"run_id"`
+	PID     int            `json:"pid"`
+	State   string         `json:"state"`
+	Started time.Time      `json:"started"`
+	Updated time.Time      `json:"updated"`
+	Active  int            `json:"active"`
+	Done    int            `json:"done"`
+	OK      int            `json:"ok"`
+	Skip    int            `json:"skip"`
+	Fail    int            `json:"fail"`
+	Bytes   int64          `json:"bytes"`
+	Users   []progressUser `json:"users"`
+}
+
+// progressFile writes JSON snapshots of the run for other programs. A
+// regular file is replaced as a whole; a named pipe gets one line per
+// snapshot while a reader has it open.
+type progressFile struct {
+	path  string
+	pipe  bool
+	runID string
+	start time.Time
+
+	mu     sync.Mutex
+	users  map[string]*progressUser
+	order  []string
+	dirty  bool
+	paused bool
+	out    *os.File
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+var globalProgressFile *progressFile
+
+func startProgressFile(path, runID string) (func(), error) {
+	f := &progressFile{
+		path:  path,
+		runID: runID,
+		start: time.Now(),
+		users: map[string]*progressUser{},
+		dirty: true,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if st, err := os.Stat(path); err == nil {
+		f.pipe = st.Mode()&os.ModeNamedPipe != 0
+		if st.IsDir() {
+			return nil, errors.New("is a directory")
+		}
+	}
+	if !f.pipe {
+		if err := f.write(f.snapshot("running")); err != nil {
+			return nil, err
+		}
+	}
+	globalProgressFile = f
+	go f.loop()
+	return func() {
+		close(f.stop)
+		<-f.done
+		globalProgressFile = nil
+	}, nil
+}
+
+func (f *progressFile) loop() {
+	defer close(f.done)
+	t := time.NewTicker(progressFileEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			f.mu.Lock()
+			if pz := globalControl.ShouldPause(); pz != f.paused {
+				f.paused, f.dirty = pz, true
+			}
+			if !f.dirty {
+				f.mu.Unlock()
+				continue
+			}
+			f.dirty = false
+			st := "running"
+			if f.paused {
+				st = "paused"
+			}
+			s := f.snapshot(st)
+			f.mu.Unlock()
+			f.report(f.write(s))
+		case <-f.stop:
+			f.mu.Lock()
+			s := f.snapshot("done")
+			f.mu.Unlock()
+			f.report(f.write(s))
+			if f.out != nil {
+				_ = f.out.Close()
+			}
+			return
+		}
+	}
+}
+
+func (f *progressFile) report(err error) {
+	if err != nil {
+		log.LogWarn("progress", f.path+": "+err.Error())
+	}
+}
+
+// snapshot must be called with f.mu held.
+func (f *progressFile) snapshot(state string) progressSnapshot {
+	s := progressSnapshot{
+		RunID:   f.runID,
+		PID:     os.Getpid(),
+		State:   state,
+		Started: f.start.UTC(),
+		Updated: time.Now().UTC(),
+		Users:   make([]progressUser, 0, len(f.order)),
+	}
+	for _, k := range f.order {
+		u := *f.users[k]
+		if u.Phase == "done" {
+			s.Done++
+		} else {
+			s.Active++
+		}
+		s.OK += u.OK
+		s.Skip += u.Skip
+		s.Fail += u.Fail
+		s.Bytes += u.Bytes
+		s.Users = append(s.Users, u)
+	}
+	return s
+}
+
+func (f *progressFile) write(s progressSnapshot) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if !f.pipe {
+		return utils.SaveToFile(f.path, b)
+	}
+	if f.out == nil {
+		// Opening a pipe without a reader fails with ENXIO instead of
+		// blocking; nobody is listening, so the snapshot is dropped.
+		o, err := os.OpenFile(f.path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+		if errors.Is(err, syscall.ENXIO) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		f.out = o
+	}
+	_ = f.out.SetWriteDeadline(time.Now().Add(progressFileEvery))
+	if _, err := f.out.Write(b); err != nil {
+		_ = f.out.Close()
+		f.out = nil
+		if errors.Is(err, syscall.EPIPE) || errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (f *progressFile) update(user string, fn func(u *progressUser)) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[user]
+	if !ok {
+		u = &progressUser{User: user}
+		f.users[user] = u
+		f.order = append(f.order, user)
+	}
+	fn(u)
+	f.dirty = true
+}
+
+func (f *progressFile) known(user string) bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.users[user]
+	return ok
+}
+
+// progressFileRenderer feeds -progress-file from the renderer calls, on top
+// of whatever the terminal shows.
+type progressFileRenderer struct {
+	renderer
+}
+
+func (r progressFileRenderer) targetStart(user string, aliases []string) {
+	r.renderer.targetStart(user, aliases)
+	globalProgressFile.update(user, func(u *progressUser) {
+		*u = progressUser{User: user, Phase: "scanning"}
+	})
+}
+
+func (r progressFileRenderer) scanProgress(ev scraper.ScanEvent) {
+	r.renderer.scanProgress(ev)
+	globalProgressFile.update(ev.User, func(u *progressUser) {
+		u.Phase = "scanning"
+		u.Pages, u.Tweets, u.Media, u.Expected = ev.Pages, ev.Tweets, ev.Media, ev.Expected
+	})
+}
+
+func (r progressFileRenderer) pageProgress(user string, page, total int) func(downloader.ProgressEvent) {
+	cb := r.renderer.pageProgress(user, page, total)
+	globalProgressFile.update(user, func(u *progressUser) {
+		u.Phase = "downloading"
+		u.Page, u.PageDone, u.PageTotal = page, 0, total
+	})
+	return func(ev downloader.ProgressEvent) {
+		if cb != nil {
+			cb(ev)
+		}
+		if ev.Kind == downloader.ProgressKindBytes {
+			return
+		}
+		globalProgressFile.update(user, func(u *progressUser) {
+			u.PageDone++
+			switch ev.Kind {
+			case downloader.ProgressKindDownloaded:
+				u.OK++
+				u.Bytes += ev.Size
+			case downloader.ProgressKindSkipped:
+				u.Skip++
+			case downloader.ProgressKindFailed:
+				u.Fail++
+			}
+		})
+	}
+}
+
+func (r progressFileRenderer) drop(key string) {
+	r.renderer.drop(key)
+	if globalProgressFile.known(key) {
+		globalProgressFile.update(key, func(u *progressUser) { u.Phase = "done" })
+	}
+}
+
+func (r progressFileRenderer) batchDone(rep *RunReport) {
+	rep.mu.Lock()
+	for _, t := range rep.Targets {
+		globalProgressFile.update(t.User, func(u *progressUser) {
+			u.Phase, u.Status, u.Error = "done", string(t.Status), ""
+			if t.Err != nil {
+				u.Error = strings.SplitN(t.Err.Error(), "\n", 2)[0]
+			}
+		})
+	}
+	rep.mu.Unlock()
+	r.renderer.batchDone(rep)
+}
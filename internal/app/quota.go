@@ -0,0 +1,123 @@
+package app
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+type quotaGuard struct {
+	root  string
+	limit int64
+	warn  []int
+
+	mu       sync.Mutex
+	used     int64
+	warned   int
+	full     bool
+	override bool
+}
+
+var globalQuota *quotaGuard
+
+func startQuota(root string, c0 *config.EssentialsConfig) *quotaGuard {
+	n := c0.SoftQuota()
+	if n <= 0 {
+		return nil
+	}
+	w := append([]int(nil), c0.QuotaWarnAt()...)
+	sort.Ints(w)
+	q := &quotaGuard{root: root, limit: n, warn: w}
+	q.mu.Lock()
+	q.used = diskUsage(root)
+	q.checkLocked()
+	q.mu.Unlock()
+	return q
+}
+
+func diskUsage(root string) int64 {
+	var n int64
+	_ = filepath.WalkDir(root, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if fi, e := d.Info(); e == nil {
+			n += fi.Size()
+		}
+		return nil
+	})
+	return n
+}
+
+func (q *quotaGuard) add(n int64) {
+	if q == nil || n <= 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.used += n
+	q.checkLocked()
+}
+
+func (q *quotaGuard) checkLocked() {
+	pct := int(q.used * 100 / q.limit)
+	for _, w := range q.warn {
+		if w > q.warned && w < 100 && pct >= w {
+			q.warned = w
+			msg := fmt.Sprintf("Output folder at %d%% of its soft quota (%s of %s)", pct, formatMB(q.used), formatMB(q.limit))
+			log.LogWarn("quota", msg)
+			utils.PrintWarn("%s", msg)
+		}
+	}
+	if q.full || q.override || q.used < q.limit {
+		return
+	}
+	q.full = true
+	log.LogWarn("quota", fmt.Sprintf("soft quota reached: %d of %d bytes in %s", q.used, q.limit, q.root))
+	if globalControl.live.Load() {
+		globalControl.setPaused(true)
+		utils.PrintWarn("Soft quota of %s reached. Free some space, then press p to resume (or q to stop)", formatMB(q.limit))
+		return
+	}
+	utils.PrintWarn("Soft quota of %s reached; stopping new downloads", formatMB(q.limit))
+}
+
+func (q *quotaGuard) resumed() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.full {
+		return
+	}
+	q.used = diskUsage(q.root)
+	q.full = false
+	if q.used >= q.limit {
+		q.override = true
+		utils.PrintWarn("Still over the soft quota (%s); continuing without pausing again", formatMB(q.used))
+	}
+}
+
+func (q *quotaGuard) Halted() bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.full && !globalControl.live.Load()
+}
+
+func shouldStopDownloads() bool {
+	return globalControl.ShouldQuit() || globalQuota.Halted()
+}
+
+func formatMB(n int64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/1024/1024)
+}
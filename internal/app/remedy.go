@@ -0,0 +1,33 @@
+package app
+
+import (
+	"errors"
+
+	"github.com/ghostlawless/xdl/internal/errs"
+	"github.com/ghostlawless/xdl/internal/scraper"
+)
+
+func remediate(u string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, errs.ErrUserNotFound):
+		return withHint(err, "@%s was not found.\n\nFix:\n  Check the spelling of the handle; the account may have been renamed or deleted.", u)
+	case errors.Is(err, errs.ErrUserSuspended):
+		return withHint(err, "@%s is suspended or deactivated; X no longer serves its media.", u)
+	case errors.Is(err, errs.ErrProtectedAccount):
+		return withHint(err, "@%s: account is protected; you must follow them.\n\nFix:\n  Follow the account from the logged-in session (and wait for approval), then run xdl again.", u)
+	case errors.Is(err, errs.ErrRateLimited):
+		if rs := errs.ResetTime(err); !rs.IsZero() {
+			return withHint(err, "X is rate-limiting this session while loading @%s.\n\nFix:\n  Wait until %s and run xdl again.", u, rs.Local().Format("15:04:05"))
+		}
+		return withHint(err, "X is rate-limiting this session while loading @%s.\n\nFix:\n  Wait about 15 minutes and run xdl again.", u)
+	case errors.Is(err, errs.ErrShapeChanged) && scraper.StrictParse:
+		return withHint(err, "X returned a timeline for @%s in a shape xdl does not understand (-strict-parse).\n\nFix:\n  Run with -d to save the raw pages and report it, or drop -strict-parse to skip those entries.", u)
+	case errors.Is(err, errs.ErrShapeChanged):
+		return withHint(err, "X changed the format of its responses while loading @%s, and this version of xdl cannot read them.\n\nFix:\n  Update xdl, or run with -d to save the raw responses and report it.", u)
+	case errors.Is(err, errs.ErrAuthExpired):
+		return withHint(err, "X rejected the session while loading @%s.\n\nFix:\n  1) Log in to x.com in your browser\n  2) Export fresh cookies as JSON (cookies.json next to the binary)\n  3) Run xdl again", u)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ghostlawless/xdl/internal/errs"
+	"github.com/ghostlawless/xdl/internal/scraper"
+)
+
+func TestRemediateShapeChanged(t *testing.T) {
+	err := &errs.APIError{Op: "SyndicationTimeline", Message: "no timeline data in page", Kind: errs.ErrShapeChanged}
+	for _, strict := range []bool{false, true} {
+		scraper.StrictParse = strict
+		h := remediate("nasa", err)
+		if h == nil {
+			t.Fatalf("strict=%v: no hint", strict)
+		}
+		if got := strings.Contains(h.Error(), "-strict-parse"); got != strict {
+			t.Errorf("strict=%v: hint mentions -strict-parse = %v:\n%s", strict, got, h)
+		}
+	}
+	scraper.StrictParse = false
+}
@@ -0,0 +1,219 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+type renderer interface {
+	banner()
+	startProgress(every time.Duration) func()
+	chatty() bool
+
+	info(format string, args ...any)
+	warn(format string, args ...any)
+	debug(tag, msg string)
+
+	targetStart(user string, aliases []string)
+	scanProgress(ev scraper.ScanEvent)
+	targetDone(user string, t0 time.Time, s scanResult, d downloadStats)
+	pageProgress(user string, page, total int) func(downloader.ProgressEvent)
+	commit(key string)
+	drop(key string)
+	batchDone(rep *RunReport)
+}
+
+func newRenderer(r0 RunContext) renderer {
+	var r renderer
+	switch {
+	case r0.Mode == ModeDebug, r0.Headless:
+		r = logRenderer{runID: r0.RunID}
+	case r0.Mode == ModeQuiet:
+		r = summaryRenderer{}
+		if r0.JSON {
+			r = quietRenderer{}
+		}
+	default:
+		r = cliRenderer{single: len(r0.Users) == 1}
+	}
+	if r0.JSON {
+		r = jsonRenderer{renderer: r, r0: r0}
+	}
+	if r0.ProgressFile != "" {
+		r = progressFileRenderer{renderer: r}
+	}
+	return r
+}
+
+func (r0 RunContext) ui() renderer {
+	if r0.render != nil {
+		return r0.render
+	}
+	return newRenderer(r0)
+}
+
+type quietRenderer struct{}
+
+func (quietRenderer) banner()                            {}
+func (quietRenderer) startProgress(time.Duration) func() { return func() {} }
+func (quietRenderer) chatty() bool                       { return false }
+func (quietRenderer) info(string, ...any)                {}
+func (quietRenderer) warn(string, ...any)                {}
+func (quietRenderer) debug(string, string)               {}
+func (quietRenderer) targetStart(string, []string)       {}
+func (quietRenderer) scanProgress(scraper.ScanEvent)     {}
+func (quietRenderer) commit(string)                      {}
+func (quietRenderer) drop(string)                        {}
+func (quietRenderer) batchDone(*RunReport)               {}
+func (quietRenderer) pageProgress(string, int, int) func(downloader.ProgressEvent) {
+	return nil
+}
+func (quietRenderer) targetDone(string, time.Time, scanResult, downloadStats) {}
+
+// summaryRenderer is -q: nothing while running, then one line per user.
+type summaryRenderer struct {
+	quietRenderer
+}
+
+func (summaryRenderer) batchDone(rep *RunReport) { printSummaryLines(rep) }
+
+type cliRenderer struct {
+	quietRenderer
+	single bool
+}
+
+func (cliRenderer) banner() { utils.PrintBanner() }
+func (cliRenderer) startProgress(every time.Duration) func() {
+	if !utils.IsTerminal(os.Stdout) {
+		return func() {}
+	}
+	return startProgressRenderer(every)
+}
+func (r cliRenderer) chatty() bool                  { return r.single }
+func (cliRenderer) info(format string, args ...any) { utils.PrintInfo(format, args...) }
+func (cliRenderer) warn(format string, args ...any) { utils.PrintWarn(format, args...) }
+func (cliRenderer) commit(key string)               { globalProgress.Commit(key) }
+func (cliRenderer) drop(key string)                 { globalProgress.Drop(key) }
+func (cliRenderer) batchDone(rep *RunReport)        { printRunReport(rep) }
+
+func (cliRenderer) targetStart(user string, _ []string) {
+	utils.PrintInfo("Loading target profile: @%s", user)
+}
+
+func (cliRenderer) targetDone(user string, t0 time.Time, s scanResult, d downloadStats) {
+	if d.Estimate != nil {
+		utils.PrintSuccess(
+			"Dry run @%s — %d media, %s to download (%d sampled, %.2fs)",
+			user, s.TotalMedia, formatEstimate(d.Estimate), d.Estimate.Sampled, time.Since(t0).Seconds(),
+		)
+		return
+	}
+	utils.PrintSuccess(
+		"Done @%s — ok:%d skip:%d fail:%d (%.2f MB, %.2fs)",
+		user, d.Downloaded, d.Skipped, d.Failed, float64(d.Bytes)/1024.0/1024.0, time.Since(t0).Seconds(),
+	)
+	if s.Drift.Total() > 0 {
+		utils.PrintWarn("%s", unparsedNote(user, s.Drift))
+	}
+}
+
+func (cliRenderer) scanProgress(ev scraper.ScanEvent) {
+	globalProgress.Update(ev.User, formatScan(ev), progressStats{})
+}
+
+func (cliRenderer) pageProgress(user string, page, total int) func(downloader.ProgressEvent) {
+	return newBarProgress(user, page, total)
+}
+
+type logRenderer struct {
+	quietRenderer
+	runID string
+}
+
+func (logRenderer) info(format string, args ...any) {
+	log.LogInfo("main", fmt.Sprintf(format, args...))
+}
+func (logRenderer) warn(format string, args ...any) {
+	log.LogWarn("main", fmt.Sprintf(format, args...))
+}
+func (logRenderer) debug(tag, msg string) { log.LogInfo(tag, msg) }
+
+func (r logRenderer) targetStart(user string, aliases []string) {
+	log.LogInfo("main", fmt.Sprintf("xdl start | run_id=%s | target=%s", r.runID, user))
+	if len(aliases) > 0 {
+		log.LogInfo("main", "aliases: @"+strings.Join(aliases, ", @"))
+	}
+}
+
+func (r logRenderer) targetDone(user string, t0 time.Time, s scanResult, d downloadStats) {
+	log.LogInfo("media", fmt.Sprintf(
+		"media found: %d (images:%d videos:%d)",
+		s.TotalMedia, s.TotalImages, s.TotalVideos,
+	))
+	log.LogInfo("download", fmt.Sprintf(
+		"done: ok=%d skipped=%d failed=%d bytes=%d",
+		d.Downloaded, d.Skipped, d.Failed, d.Bytes,
+	))
+	if d.Estimate != nil {
+		log.LogInfo("download", "dry run estimate: "+formatEstimate(d.Estimate))
+	}
+	if s.Drift.Total() > 0 {
+		log.LogWarn("media", unparsedNote(user, s.Drift))
+	}
+	log.LogInfo("main", fmt.Sprintf(
+		"xdl[%s] exit [%.2fs] user=%s",
+		r.runID, time.Since(t0).Seconds(), user,
+	))
+}
+
+func (logRenderer) scanProgress(ev scraper.ScanEvent) {
+	log.LogInfo("media", fmt.Sprintf(
+		"scan user=%s pages=%d tweets=%d media=%d expected=%d images=%d videos=%d oldest=%s",
+		ev.User, ev.Pages, ev.Tweets, ev.Media, ev.Expected, ev.Images, ev.Videos, formatScanDate(ev.Oldest),
+	))
+}
+
+func (logRenderer) pageProgress(user string, page, total int) func(downloader.ProgressEvent) {
+	return newLogProgress(user, page, total)
+}
+
+func (logRenderer) batchDone(rep *RunReport) {
+	ok, partial, failed := rep.Counts()
+	log.LogInfo("main", fmt.Sprintf("batch done: targets=%d success=%d partial=%d failed=%d", len(rep.Targets), ok, partial, failed))
+}
+
+type jsonRenderer struct {
+	renderer
+	r0 RunContext
+}
+
+func (r jsonRenderer) batchDone(rep *RunReport) {
+	r.renderer.batchDone(rep)
+	writeJSONReport(r.r0, rep)
+}
+
+func formatScan(ev scraper.ScanEvent) string {
+	out := fmt.Sprintf("xdl @%s  scanning  page %d  tweets %d  media %d", ev.User, ev.Pages, ev.Tweets, ev.Media)
+	if ev.Expected > 0 {
+		out += fmt.Sprintf("/%d", ev.Expected)
+	}
+	out += fmt.Sprintf(" (img:%d vid:%d)", ev.Images, ev.Videos)
+	if !ev.Oldest.IsZero() {
+		out += "  back to " + formatScanDate(ev.Oldest)
+	}
+	return out
+}
+
+func formatScanDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02")
+}
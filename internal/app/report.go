@@ -0,0 +1,333 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/errs"
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+type targetStatus string
+
+const (
+	targetSuccess targetStatus = "success"
+	targetPartial targetStatus = "partial"
+	targetFailed  targetStatus = "failed"
+	targetSkipped targetStatus = "skipped"
+)
+
+type targetReport struct {
+	User       string
+	Status     targetStatus
+	Found      int
+	Downloaded int
+	Skipped    int
+	Failed     int
+	Drift      scraper.Drift
+	Bytes      int64
+	Estimate   *downloader.Estimate
+	Duration   time.Duration
+	Err        error
+}
+
+func newTargetReport(u string, t0 time.Time, s scanResult, d downloadStats, err error) targetReport {
+	r := targetReport{
+		User:       u,
+		Found:      s.TotalMedia,
+		Downloaded: d.Downloaded,
+		Skipped:    d.Skipped,
+		Failed:     d.Failed,
+		Drift:      s.Drift,
+		Bytes:      d.Bytes,
+		Estimate:   d.Estimate,
+		Duration:   time.Since(t0),
+		Err:        err,
+	}
+	switch {
+	case errors.Is(err, errSkippedByUser):
+		r.Status = targetSkipped
+	case err != nil:
+		r.Status = targetFailed
+	case d.Failed > 0:
+		r.Status = targetPartial
+	default:
+		r.Status = targetSuccess
+	}
+	return r
+}
+
+type RunReport struct {
+	mu      sync.Mutex
+	Targets []targetReport
+}
+
+func (r *RunReport) Add(t targetReport) {
+	r.mu.Lock()
+	r.Targets = append(r.Targets, t)
+	r.mu.Unlock()
+}
+
+func (r *RunReport) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var errs []error
+	for _, t := range r.Targets {
+		if t.Err == nil || t.Status == targetSkipped {
+			continue
+		}
+		if len(r.Targets) == 1 {
+			return t.Err
+		}
+		errs = append(errs, fmt.Errorf("@%s: %w", t.User, t.Err))
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	for _, t := range r.Targets {
+		if t.Status == targetPartial {
+			errs = append(errs, fmt.Errorf("%w: @%s (%d failed)", ErrPartial, t.User, t.Failed))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *RunReport) Counts() (ok, partial, failed int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.Targets {
+		switch t.Status {
+		case targetSuccess:
+			ok++
+		case targetPartial:
+			partial++
+		case targetFailed:
+			failed++
+		}
+	}
+	return
+}
+
+func printRunReport(r *RunReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.Targets) < 2 {
+		return
+	}
+
+	termMu.Lock()
+	defer termMu.Unlock()
+
+	fmt.Fprintln(utils.Stdout)
+	w := tabwriter.NewWriter(utils.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tSTATUS\tFOUND\tOK\tSKIP\tFAIL\tMB\tTIME\tERROR")
+	for _, t := range r.Targets {
+		msg := ""
+		if t.Err != nil {
+			msg = strings.SplitN(t.Err.Error(), "\n", 2)[0]
+		} else if t.Drift.Total() > 0 {
+			msg = fmt.Sprintf("%d entries not parsed", t.Drift.Total())
+		}
+		mb := fmt.Sprintf("%.2f", float64(t.Bytes)/1024.0/1024.0)
+		if t.Estimate != nil {
+			mb = fmt.Sprintf("~%.2f", float64(t.Estimate.Bytes)/1024.0/1024.0)
+		}
+		fmt.Fprintf(w, "@%s\t%s\t%d\t%d\t%d\t%d\t%s\t%.1fs\t%s\n",
+			t.User, t.Status, t.Found, t.Downloaded, t.Skipped, t.Failed,
+			mb, t.Duration.Seconds(), msg)
+	}
+	_ = w.Flush()
+
+	var d scraper.Drift
+	for _, t := range r.Targets {
+		d.Add(t.Drift)
+	}
+	if d.Total() > 0 {
+		fmt.Fprintf(utils.Stdout, "\n%d entries could not be parsed (%s) — run with -d and report it\n", d.Total(), driftDetail(d))
+	}
+}
+
+type jsonTarget struct {
+	User           string     `json:"user"`
+	Status         string     `json:"status"`
+	Found          int        `json:"found"`
+	Downloaded     int        `json:"downloaded"`
+	Skipped        int        `json:"skipped"`
+	Failed         int        `json:"failed"`
+	Unparsed       int        `json:"unparsed,omitempty"`
+	Drift          *jsonDrift `json:"drift,omitempty"`
+	Bytes          int64      `json:"bytes"`
+	EstimatedBytes int64      `json:"estimated_bytes,omitempty"`
+	Sampled        int        `json:"sampled,omitempty"`
+	DurationMS     int64      `json:"duration_ms"`
+	Error          string     `json:"error,omitempty"`
+	RateLimitReset string     `json:"rate_limit_reset,omitempty"`
+}
+
+func (t targetReport) json() jsonTarget {
+	j := jsonTarget{
+		User:       t.User,
+		Status:     string(t.Status),
+		Found:      t.Found,
+		Downloaded: t.Downloaded,
+		Skipped:    t.Skipped,
+		Failed:     t.Failed,
+		Unparsed:   t.Drift.Total(),
+		Bytes:      t.Bytes,
+		DurationMS: t.Duration.Milliseconds(),
+	}
+	if j.Unparsed > 0 {
+		j.Drift = &jsonDrift{Entries: t.Drift.Entries, Fields: t.Drift.Fields, Videos: t.Drift.Videos}
+	}
+	if t.Estimate != nil {
+		j.EstimatedBytes, j.Sampled = t.Estimate.Bytes, t.Estimate.Sampled
+	}
+	if t.Err != nil {
+		j.Error = strings.SplitN(t.Err.Error(), "\n", 2)[0]
+		if rs := errs.ResetTime(t.Err); !rs.IsZero() {
+			j.RateLimitReset = rs.UTC().Format(time.RFC3339)
+		}
+	}
+	return j
+}
+
+type jsonDrift struct {
+	Entries int `json:"unknown_entries"`
+	Fields  int `json:"missing_fields"`
+	Videos  int `json:"video_types"`
+}
+
+type jsonReport struct {
+	RunID    string       `json:"run_id"`
+	ExitCode int          `json:"exit_code"`
+	Targets  []jsonTarget `json:"targets"`
+}
+
+func writeJSONReport(r0 RunContext, r *RunReport) {
+	out := jsonReport{RunID: r0.RunID, ExitCode: ExitCode(r.Err())}
+
+	r.mu.Lock()
+	for _, t := range r.Targets {
+		out.Targets = append(out.Targets, t.json())
+	}
+	r.mu.Unlock()
+
+	termMu.Lock()
+	defer termMu.Unlock()
+	enc := json.NewEncoder(utils.Stdout)
+	if !r0.Headless {
+		enc.SetIndent("", "  ")
+	}
+	_ = enc.Encode(out)
+}
+
+// printSummaryLines writes the -q output: one logfmt line per user with the
+// fields of the -json report, so cron mail and scripts get one line each.
+func printSummaryLines(r *RunReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	termMu.Lock()
+	defer termMu.Unlock()
+	for _, t := range r.Targets {
+		j := t.json()
+		kv := []string{
+			"user=" + logfmtValue(j.User),
+			"status=" + j.Status,
+			"found=" + strconv.Itoa(j.Found),
+			"downloaded=" + strconv.Itoa(j.Downloaded),
+			"skipped=" + strconv.Itoa(j.Skipped),
+			"failed=" + strconv.Itoa(j.Failed),
+			"unparsed=" + strconv.Itoa(j.Unparsed),
+			"bytes=" + strconv.FormatInt(j.Bytes, 10),
+			"duration_ms=" + strconv.FormatInt(j.DurationMS, 10),
+		}
+		if t.Estimate != nil {
+			kv = append(kv, "estimated_bytes="+strconv.FormatInt(j.EstimatedBytes, 10))
+		}
+		if j.Error != "" {
+			kv = append(kv, "error="+logfmtValue(j.Error))
+		}
+		if j.RateLimitReset != "" {
+			kv = append(kv, "rate_limit_reset="+j.RateLimitReset)
+		}
+		fmt.Fprintln(utils.Stdout, strings.Join(kv, " "))
+	}
+}
+
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\t\n\\") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+var reportColumns = []string{"timestamp", "run_id", "user", "status", "found", "downloaded", "skipped", "failed", "bytes", "duration_s", "error"}
+
+func appendReportFile(r0 RunContext, r *RunReport) error {
+	p := r0.ReportFile
+	if p == "" {
+		return nil
+	}
+	if err := utils.EnsureDir(filepath.Dir(p)); err != nil {
+		return err
+	}
+	lk, err := utils.LockExclusive(p)
+	if err != nil {
+		return err
+	}
+	defer lk.Unlock()
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	ts := time.Now().UTC().Format(time.RFC3339)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".json", ".jsonl", ".ndjson":
+		enc := json.NewEncoder(f)
+		for _, t := range r.Targets {
+			if err := enc.Encode(struct {
+				Timestamp string `json:"timestamp"`
+				RunID     string `json:"run_id"`
+				jsonTarget
+			}{ts, r0.RunID, t.json()}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w := csv.NewWriter(f)
+	if st.Size() == 0 {
+		_ = w.Write(reportColumns)
+	}
+	for _, t := range r.Targets {
+		j := t.json()
+		_ = w.Write([]string{
+			ts, r0.RunID, j.User, j.Status,
+			strconv.Itoa(j.Found), strconv.Itoa(j.Downloaded), strconv.Itoa(j.Skipped), strconv.Itoa(j.Failed),
+			strconv.FormatInt(j.Bytes, 10), strconv.FormatFloat(t.Duration.Seconds(), 'f', 1, 64), j.Error,
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
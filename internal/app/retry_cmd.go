@@ -0,0 +1,203 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/httpx"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+func runRetryCommand(args []string, runID string, runSeed []byte) error {
+	var (
+		proxy   string
+		conc    int
+		classes string
+		list    bool
+	)
+	r0, rest, err := parseCommandArgs("retry", args, runID, runSeed, func(fs *flag.FlagSet) {
+		fs.StringVar(&proxy, "proxy", "", "Retry through this proxy (scheme://host:port)")
+		fs.IntVar(&conc, "concurrency", 0, "Parallel downloads for the retry")
+		fs.StringVar(&classes, "class", "", "Only retry these error classes (comma separated)")
+		fs.BoolVar(&list, "list", false, "List the recorded failures without retrying")
+	})
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 || strings.TrimSpace(rest[0]) == "" {
+		return fmt.Errorf("Usage: xdl retry [-list] [-class not_found,timeout] [-proxy URL] [-concurrency N] <username>\n\nExample:\n  xdl retry -concurrency 2 nasa")
+	}
+	user := strings.TrimPrefix(strings.TrimSpace(rest[0]), "@")
+	want, err := parseFailureClasses(classes)
+	if err != nil {
+		return err
+	}
+	if proxy != "" {
+		if u, err := url.Parse(proxy); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("Invalid -proxy %q (use scheme://host:port)", proxy)
+		}
+	}
+	if conc < 0 {
+		return fmt.Errorf("Invalid -concurrency %d", conc)
+	}
+
+	r0.Layout = paths.New(r0.OutRoot)
+	fp := failuresPath(r0.Layout, user)
+	f, err := readFailures(fp)
+	if err != nil {
+		return fmt.Errorf("Could not read %s: %w", fp, err)
+	}
+	var todo []failedMedia
+	for _, m := range f.Items {
+		if len(want) == 0 || want[m.Class] {
+			todo = append(todo, m)
+		}
+	}
+	if len(todo) == 0 {
+		utils.PrintInfo("No recorded failures to retry for @%s", user)
+		return nil
+	}
+	if list {
+		w := tabwriter.NewWriter(utils.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CLASS\tTRIES\tLAST\tURL\tERROR")
+		for _, m := range todo {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", m.Class, m.Attempts, ago(time.Since(m.Last)), m.URL, m.Error)
+		}
+		return w.Flush()
+	}
+
+	stopSignals := globalShutdown.watchSignals()
+	defer stopSignals()
+	defer globalShutdown.Flush()
+	globalShutdown.OnFlush(log.Close)
+
+	c0, err := loadSession(r0)
+	if err != nil {
+		return err
+	}
+	if proxy != "" {
+		c0.Transport.Proxy = proxy
+		httpx.SetTransportOptions(c0.TransportOptions())
+	}
+	if conc > 0 {
+		c0.Runtime.Concurrency = conc
+	}
+	r0.Store = openArchive(r0)
+	if r0.Store != nil {
+		globalShutdown.OnFlush(func() { saveArchive(r0.Store) })
+	}
+
+	p := newPipeline(r0, c0, nil, httpx.NewDownloadClient())
+	p.who = user
+	unlock, err := p.lockUser(user)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	quit := func() bool { return shouldStopDownloads() || globalShutdown.Draining() }
+	var total downloader.Summary
+	for _, g := range groupFailures(todo) {
+		if quit() {
+			break
+		}
+		ms := make([]scraper.Media, len(g))
+		for i, m := range g {
+			ms[i] = m.media()
+		}
+		m0 := g[0]
+		cp := downloader.NewCheckpoint(m0.Handle, r0.RunID, ms)
+		sum, err := downloader.DownloadAllCycles(p.dl, c0, ms, downloader.Options{
+			RunDir:      m0.Dir,
+			User:        m0.Handle,
+			ShouldPause: globalControl.ShouldPause,
+			ShouldQuit:  quit,
+			Checkpoint:  cp,
+			Sink:        downloader.DirSink{Root: m0.Dir},
+			Concurrency: c0.Concurrency(),
+		})
+		p.recordFailures(m0.UID, m0.Handle, m0.Dir, cp)
+		if m0.UID != "" {
+			recordArchivedMedia(r0.Store, m0.UID, m0.Handle, cp)
+		}
+		total.Downloaded += sum.Downloaded
+		total.Skipped += sum.Skipped
+		total.Failed += sum.Failed
+		total.TotalBytes += sum.TotalBytes
+		if err != nil {
+			log.LogError("retry", err.Error())
+			break
+		}
+	}
+	log.LogInfo("retry", fmt.Sprintf("user=%s tried=%d ok=%d skip=%d fail=%d bytes=%d", user, len(todo), total.Downloaded, total.Skipped, total.Failed, total.TotalBytes))
+
+	left, _ := readFailures(fp)
+	utils.PrintInfo("Retried %d failed downloads for @%s: %d downloaded, %d already on disk, %d still failing", len(todo), user, total.Downloaded, total.Skipped, total.Failed)
+	if globalControl.ShouldQuit() || globalShutdown.Draining() {
+		return errStoppedByUser
+	}
+	if left != nil && len(left.Items) > 0 {
+		return withHint(ErrPartial, "%d downloads for @%s still fail (%s). See them with: xdl retry -list %s", len(left.Items), user, failureCounts(left.Items), user)
+	}
+	return nil
+}
+
+func parseFailureClasses(s string) (map[downloader.FailureClass]bool, error) {
+	out := map[downloader.FailureClass]bool{}
+	for _, c := range strings.Split(s, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c == "" {
+			continue
+		}
+		if !slices.Contains(downloader.FailureClasses, downloader.FailureClass(c)) {
+			names := make([]string, len(downloader.FailureClasses))
+			for i, k := range downloader.FailureClasses {
+				names[i] = string(k)
+			}
+			return nil, fmt.Errorf("Unknown -class %q (use %s)", c, strings.Join(names, ", "))
+		}
+		out[downloader.FailureClass(c)] = true
+	}
+	return out, nil
+}
+
+// groupFailures splits the failures by the folder and account they belong
+// to, keeping the order they were recorded in.
+func groupFailures(ms []failedMedia) [][]failedMedia {
+	var out [][]failedMedia
+	at := map[[3]string]int{}
+	for _, m := range ms {
+		k := [3]string{m.Dir, m.UID, m.Handle}
+		i, ok := at[k]
+		if !ok {
+			i = len(out)
+			at[k] = i
+			out = append(out, nil)
+		}
+		out[i] = append(out[i], m)
+	}
+	return out
+}
+
+func failureCounts(ms []failedMedia) string {
+	n := map[downloader.FailureClass]int{}
+	for _, m := range ms {
+		n[m.Class]++
+	}
+	var parts []string
+	for _, c := range downloader.FailureClasses {
+		if n[c] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n[c], c))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+const (
+	attrRetweeter = "retweeter"
+	attrOriginal  = "original"
+)
+
+type authorGroup struct {
+	uid, user, dir string
+	media          []scraper.Media
+}
+
+func foreign(m scraper.Media, uid, user string) bool {
+	switch {
+	case m.AuthorID != "" && uid != "":
+		return m.AuthorID != uid
+	case m.Author != "":
+		return !strings.EqualFold(m.Author, user)
+	}
+	return false
+}
+
+func (p *Pipeline) ownMedia(uid, user string, ms []scraper.Media) []scraper.Media {
+	if p.r0.IncludeRetweets {
+		return ms
+	}
+	out := ms[:0:0]
+	for _, m := range ms {
+		if !foreign(m, uid, user) {
+			out = append(out, m)
+		}
+	}
+	if n := len(ms) - len(out); n > 0 {
+		log.LogInfo("media", fmt.Sprintf("@%s: skipped %d retweeted media (use -include-retweets)", user, n))
+	}
+	return out
+}
+
+// authorDirs remembers the folder of each original author seen during a scan
+// and holds their user locks until the scan ends.
+type authorDirs struct {
+	dirs    map[string]string
+	unlocks []func()
+}
+
+func (a *authorDirs) release() {
+	for _, u := range a.unlocks {
+		u()
+	}
+	a.unlocks = nil
+}
+
+// authorDir locks an original author and prepares their folder. It does not
+// wait for a lock held elsewhere: those media stay with the retweeter.
+func (p *Pipeline) authorDir(user string, a *authorDirs) string {
+	r0 := p.r0
+	r0.LockWait = 0
+	unlock, err := p.with(r0).lockUser(user)
+	if errors.Is(err, utils.ErrLocked) {
+		log.LogWarn("media", fmt.Sprintf("@%s is locked by another xdl run; keeping their media with the retweeter", user))
+		return ""
+	}
+	if err != nil {
+		log.LogError("media", err.Error())
+		return ""
+	}
+	d, err := prepareRunOutputDir(p.r0, p.c0, user, nil)
+	if err != nil {
+		unlock()
+		log.LogError("media", err.Error())
+		return ""
+	}
+	a.unlocks = append(a.unlocks, unlock)
+	return d
+}
+
+func (p *Pipeline) byAuthor(uid, user, dir string, ms []scraper.Media, a *authorDirs) []authorGroup {
+	own := authorGroup{uid: uid, user: user, dir: dir}
+	if !p.r0.IncludeRetweets || p.r0.Attr != attrOriginal {
+		own.media = ms
+		return []authorGroup{own}
+	}
+	var out []authorGroup
+	idx := map[string]int{}
+	for _, m := range ms {
+		if !foreign(m, uid, user) || m.Author == "" {
+			own.media = append(own.media, m)
+			continue
+		}
+		k := strings.ToLower(m.Author)
+		i, ok := idx[k]
+		if !ok {
+			d, ok := a.dirs[k]
+			if !ok {
+				d = p.authorDir(m.Author, a)
+				a.dirs[k] = d
+			}
+			if d == "" {
+				own.media = append(own.media, m)
+				continue
+			}
+			i = len(out)
+			idx[k] = i
+			out = append(out, authorGroup{uid: m.AuthorID, user: m.Author, dir: d})
+		}
+		out[i].media = append(out[i].media, m)
+	}
+	if len(own.media) > 0 {
+		out = append([]authorGroup{own}, out...)
+	}
+	return out
+}
@@ -6,139 +6,359 @@ import (
 	"net/http"
 	"path/filepath"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/ghostlawless/xdl/internal/archive"
+	"github.com/ghostlawless/xdl/internal/audit"
+	"github.com/ghostlawless/xdl/internal/auth"
 	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/httpx"
 	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/paths"
 	"github.com/ghostlawless/xdl/internal/runtime"
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/trace"
 	"github.com/ghostlawless/xdl/internal/utils"
 )
 
 func runWithContext(r0 RunContext) error {
 	_ = context.Background()
 
-	if r0.Mode == ModeVerbose {
-		utils.PrintBanner()
+	utils.IgnoreSIGPIPE()
+	utils.OnBrokenPipe(func() {
+		log.LogInfo("main", "stdout closed; progress output disabled")
+		if r0.AbortOnPipe {
+			globalShutdown.Interrupt("broken pipe")
+		}
+	})
+
+	r0.render = newRenderer(r0)
+	r0.ui().banner()
+
+	if !r0.Headless {
+		startKeyboardControlListener(globalControl)
+	}
+	defer globalControl.stop()
+	if r0.Confirm && !globalControl.live.Load() {
+		return fmt.Errorf("-confirm needs an interactive terminal on stdin")
 	}
 
-	startKeyboardControlListener(globalControl)
+	stopSignals := globalShutdown.watchSignals()
+	defer stopSignals()
+	defer globalShutdown.Flush()
+	globalShutdown.OnFlush(log.Close)
 
-	p0 := []string{
-		filepath.Join(".", "config", "essentials.json"),
-		filepath.Join(".", "essentials.json"),
+	if e5 := trace.Init(r0.OTLP, "xdl"); e5 != nil {
+		return fmt.Errorf("Could not enable tracing: %w", e5)
 	}
+	globalShutdown.OnFlush(trace.Shutdown)
 
-	c0, e0 := config.LoadEssentialsWithFallback(p0)
+	c0, e0 := loadSession(r0)
 	if e0 != nil {
-		log.LogError("config", "failed to load essentials: "+e0.Error())
 		return e0
 	}
 
-	if r0.Mode == ModeDebug {
-		c0.Paths.Debug = r0.LogPath
-		c0.Paths.DebugRaw = r0.LogPath
+	scraper.Cooldowns = runtime.NewCooldowns(sleepFor)
+	defer func() { scraper.Cooldowns = nil }()
+	if r0.WaitRateLimit {
+		scraper.RateLimitWait = func(who, op string, reset time.Time) bool {
+			return waitForRateLimit(r0, who, op, reset)
+		}
+		defer func() { scraper.RateLimitWait = nil }()
 	}
 
-	k0 := strings.TrimSpace(r0.CookiePath)
-	m0 := strings.TrimSpace(c0.Auth.Cookies.AuthToken) == "" || strings.TrimSpace(c0.Auth.Cookies.Ct0) == ""
+	stopProgress := r0.ui().startProgress(c0.ProgressRefresh())
+	defer stopProgress()
+	if r0.ProgressFile != "" {
+		stopFile, e4 := startProgressFile(r0.ProgressFile, r0.RunID)
+		if e4 != nil {
+			return fmt.Errorf("Could not write progress file %s: %w", r0.ProgressFile, e4)
+		}
+		defer stopFile()
+	}
+
+	r0.Layout = paths.New(r0.OutRoot)
+	if r0.Audit {
+		a0, e6 := audit.OpenFile(r0.Layout.StateFile("audit.jsonl"))
+		if e6 != nil {
+			return fmt.Errorf("Could not open audit log: %w", e6)
+		}
+		audit.SetSink(a0, r0.RunID)
+		globalShutdown.OnFlush(audit.Close)
+	}
+	r0.Store = openArchive(r0)
+	if r0.CacheTTL > 0 {
+		k1, e9 := httpx.NewCache(r0.Layout.StateFile("cache"), r0.CacheTTL)
+		if e9 != nil {
+			return fmt.Errorf("Could not open response cache: %w", e9)
+		}
+		if n := k1.Prune(max(r0.CacheTTL, 24*time.Hour)); n > 0 {
+			log.LogInfo("cache", fmt.Sprintf("pruned %d stale responses", n))
+		}
+		scraper.ResponseCache = k1
+		defer func() { scraper.ResponseCache = nil }()
+		r0.ui().info("Response cache: %s (ttl %s)", k1.Dir, r0.CacheTTL)
+	}
+	if r0.IDListPath != "" {
+		l0, e8 := archive.OpenIDList(r0.IDListPath)
+		if e8 != nil {
+			return fmt.Errorf("Could not open download archive %s: %w", r0.IDListPath, e8)
+		}
+		r0.DownloadArchive = l0
+		globalShutdown.OnFlush(func() { _ = l0.Close() })
+		r0.ui().info("Download archive: %s (%d tweets)", r0.IDListPath, l0.Len())
+	}
+	if r0.ArchiveOutput != "" && !r0.DryRun {
+		k0, e7 := downloader.OpenPack(r0.ArchiveOutput)
+		if e7 != nil {
+			return fmt.Errorf("Could not open archive output %s: %w", r0.ArchiveOutput, e7)
+		}
+		r0.Pack = k0
+		globalShutdown.OnFlush(func() { closePack(k0) })
+	}
+	globalControl.restorePaused(r0.Layout.StateFile("paused"))
+	if !r0.DryRun {
+		globalQuota = startQuota(r0.Layout.Root, c0)
+		defer func() { globalQuota = nil }()
+	}
+	scraper.Paused = globalControl.PausedInFlight
+	scraper.ScanProgress = r0.ui().scanProgress
+	defer func() { scraper.Paused, scraper.ScanProgress = nil, nil }()
+	scraper.StrictParse, scraper.Unparsed = r0.StrictParse, schemaDrift.add
+	defer func() { scraper.StrictParse, scraper.Unparsed = false, nil }()
+	scraper.IncludeCards = r0.IncludeCards
+	scraper.ExternalHosts = c0.ExternalHosts()
+	defer func() { scraper.IncludeCards, scraper.ExternalHosts = false, nil }()
+	globalShutdown.OnFlush(func() { saveArchive(r0.Store) })
 
-	if k0 != "" || m0 {
-		e1 := config.ApplyCookiesFromFile(c0, k0)
-		if e1 != nil {
-			log.LogError("config", "cookie setup failed: "+e1.Error())
-			return e1
+	t0 := c0.HTTPTimeout()
+	h0 := httpx.NewAPIClient(t0, c0.HeaderOrder)
+	h1 := httpx.NewDownloadClient()
+	if r0.Mode == ModeDebug {
+		httpx.LogTraces = true
+		defer func() { httpx.LogTraces = false }()
+		defer logPoolStats(r0)
+	}
+	if r0.HAR != "" {
+		closeHAR, e9 := httpx.RecordHAR(r0.HAR)
+		if e9 != nil {
+			return fmt.Errorf("Could not create HAR file %s: %w", r0.HAR, e9)
 		}
+		globalShutdown.OnFlush(func() {
+			if e := closeHAR(); e != nil {
+				log.LogError("http", "writing "+r0.HAR+" failed: "+e.Error())
+			}
+		})
+		r0.ui().info("Recording API traffic to %s", r0.HAR)
+	}
+	if r0.Record != "" {
+		k2, e9 := httpx.RecordCassette(r0.Record, h0.Transport)
+		if e9 != nil {
+			return fmt.Errorf("Could not create cassette %s: %w", r0.Record, e9)
+		}
+		k2.Redact = func(b []byte) []byte { return scraper.Redact(c0, b) }
+		h0.Transport = k2
+		globalShutdown.OnFlush(func() { _ = k2.Close() })
+		r0.ui().info("Recording API traffic to %s", r0.Record)
+	}
+	if r0.Replay != "" {
+		k2, e9 := httpx.ReplayCassette(r0.Replay)
+		if e9 != nil {
+			return fmt.Errorf("Could not read cassette %s: %w", r0.Replay, e9)
+		}
+		h0.Transport, h1.Transport = k2, k2
+		r0.ui().info("Replaying API traffic from %s", r0.Replay)
+	}
 
-		if r0.Mode == ModeDebug {
-			g0 := c0.Auth.Cookies.GuestID != ""
-			g1 := c0.Auth.Cookies.AuthToken != ""
-			g2 := c0.Auth.Cookies.Ct0 != ""
-			log.LogInfo("config", fmt.Sprintf("cookies loaded: guest_id=%v auth_token=%v ct0=%v", g0, g1, g2))
+	if a0 := strings.TrimSpace(r0.DebugAddr); a0 != "" {
+		stopDebug, e3 := startDebugServer(a0)
+		if e3 != nil {
+			return fmt.Errorf("Could not start debug endpoint on %s: %w", a0, e3)
 		}
+		defer stopDebug()
 	}
 
-	e2 := c0.ValidateRequiredCookies(k0)
-	if e2 != nil {
-		log.LogError("config", "missing auth cookies: "+e2.Error())
-		return e2
+	if a1 := strings.TrimSpace(r0.ServeAddr); a1 != "" {
+		s0 := r0.Store
+		stopServe, e4 := startArchiveServer(r0, a1, func() (*archive.Store, error) {
+			if s0 == nil {
+				return nil, fmt.Errorf("archive not open")
+			}
+			return s0, nil
+		})
+		if e4 != nil {
+			return fmt.Errorf("Could not start archive API on %s: %w", a1, e4)
+		}
+		defer stopServe()
 	}
 
-	t0 := c0.HTTPTimeout()
-	h0 := buildAPIClient(t0)
-	h1 := buildDownloadClient()
+	p0 := newPipeline(r0, c0, h0, h1)
+	scraper.Pacing = p0.pace
+	defer func() { scraper.Pacing = nil }()
+	return p0.Run()
+}
 
-	if len(r0.Users) == 1 {
-		return runSingleUser(r0, c0, h0, h1, r0.Users[0])
+func loadSession(r0 RunContext) (*config.EssentialsConfig, error) {
+	c0, e0 := config.LoadEssentialsWithFallback(essentialsPaths())
+	if e0 != nil {
+		log.LogError("config", "failed to load essentials: "+e0.Error())
+		return nil, e0
+	}
+	if e0 := c0.ApplyEnv(); e0 != nil {
+		return nil, fmt.Errorf("Invalid environment configuration: %w", e0)
+	}
+	if e0 := downloader.ValidateRetry(c0); e0 != nil {
+		return nil, fmt.Errorf("Invalid retry in essentials.json: %w", e0)
 	}
 
-	n0 := len(r0.Users)
-	if n0 > 4 {
-		n0 = 4
+	if e1 := log.Configure(log.Options{
+		Level:   c0.Logging.Level,
+		Format:  c0.Logging.Format,
+		Modules: c0.Logging.Modules,
+	}); e1 != nil {
+		utils.PrintWarn("Ignoring logging config: %v", e1)
 	}
 
-	q0 := make(chan error, len(r0.Users))
-	s1 := make(chan struct{}, n0)
+	if e2 := setupTransport(r0, c0); e2 != nil {
+		return nil, e2
+	}
 
-	var w0 sync.WaitGroup
-	for _, u0 := range r0.Users {
-		u1 := u0
-		w0.Add(1)
-		go func() {
-			defer w0.Done()
-			s1 <- struct{}{}
-			defer func() { <-s1 }()
+	globalShutdown.setGrace(r0.Grace)
+	if r0.Grace <= 0 {
+		globalShutdown.setGrace(c0.ShutdownGrace())
+	}
 
-			if e3 := runSingleUser(r0, c0, h0, h1, u1); e3 != nil {
-				q0 <- fmt.Errorf("@%s: %w", u1, e3)
-			}
-		}()
+	if r0.PageSize > 0 {
+		c0.Runtime.PageSize = r0.PageSize
 	}
 
-	w0.Wait()
-	close(q0)
+	if r0.Mode == ModeDebug {
+		c0.Paths.Debug = r0.LogPath
+		c0.Paths.DebugRaw = filepath.Join(r0.LogPath, "raw")
+	}
+	if r0.DumpRaw && r0.Mode != ModeDebug {
+		c0.Paths.DebugRaw = filepath.Join(p9(), "logs", "run_"+r0.RunID, "raw")
+	}
+	if r0.DumpRaw {
+		r0.ui().info("Raw responses: %s", c0.Paths.DebugRaw)
+	}
 
-	for e4 := range q0 {
-		if e4 != nil {
-			return e4
-		}
+	if r0.Replay != "" {
+		c0.Auth.Provider = "replay"
+		return c0, nil
 	}
 
-	return nil
+	if r0.NoAuth {
+		c0.Auth.Provider = "none"
+		r0.ui().info("Session: none (public syndication timeline; only recent media is available)")
+		return c0, nil
+	}
+
+	k0 := strings.TrimSpace(r0.CookiePath)
+	h0 := httpx.NewAPIClient(c0.HTTPTimeout(), c0.HeaderOrder)
+	if _, e1 := auth.Resolve(c0, authProviders(k0, h0)...); e1 != nil {
+		log.LogError("config", "cookie setup failed: "+e1.Error())
+		return nil, e1
+	}
 
+	g0 := c0.Auth.Cookies.GuestID != ""
+	g1 := c0.Auth.Cookies.AuthToken != ""
+	g2 := c0.Auth.Cookies.Ct0 != ""
+	r0.ui().debug("config", fmt.Sprintf("session provider: %s; cookies loaded: guest_id=%v auth_token=%v ct0=%v", c0.Auth.Provider, g0, g1, g2))
+	r0.ui().info("Session: %s", c0.Auth.Provider)
+	if c0.Auth.GuestToken != "" {
+		r0.ui().warn("No cookies found; using a guest session. Only public accounts work, timelines stop early and rate limits are tighter. Export cookies.json for full history.")
+	}
+
+	return c0, nil
 }
-func runSingleUser(r0 RunContext, c0 *config.EssentialsConfig, h0, h1 *http.Client, u0 string) error {
-	t0 := time.Now()
-	l0 := runtime.NewLimiterWith(r0.RunSeed, []byte(strings.TrimSpace(c0.Runtime.LimiterSecret)))
 
-	if r0.Mode == ModeDebug {
-		log.LogInfo("main", fmt.Sprintf("xdl start | run_id=%s | target=%s", r0.RunID, u0))
+// setupTransport applies the header profile, TLS fingerprint and transport
+// options of c0, which ApplyEnv has already updated, to every client built
+// afterwards.
+func setupTransport(r0 RunContext, c0 *config.EssentialsConfig) error {
+	if e0 := httpx.UseProfile(c0.Profile); e0 != nil {
+		return fmt.Errorf("Invalid header_profile in essentials.json: %w", e0)
+	}
+	if e0 := httpx.UseTLSFingerprint(c0.TLS, c0.Profile); e0 != nil {
+		return fmt.Errorf("Invalid tls_fingerprint in essentials.json: %w", e0)
+	}
+	if r0.IPVersion != 0 {
+		c0.Transport.IPVersion = r0.IPVersion
 	}
-	if r0.Mode == ModeVerbose {
-		utils.PrintInfo("Loading target profile: @%s", u0)
+	if v := c0.Transport.IPVersion; v != 0 && v != 4 && v != 6 {
+		return fmt.Errorf("Invalid transport.ip_version %d in essentials.json (use 4 or 6)", v)
 	}
+	httpx.SetTransportOptions(c0.TransportOptions())
+	return nil
+}
+
+func logPoolStats(r0 RunContext) {
+	for _, s := range httpx.Pools() {
+		if s.Requests == 0 {
+			continue
+		}
+		log.LogInfo("http", s.String())
+		r0.ui().debug("http", s.String())
+	}
+}
 
-	s0 := newSpinnerForUser(r0, u0)
-	if s0 != nil {
-		defer stopSpinner(s0)
+func essentialsPaths() []string {
+	ps := []string{
+		filepath.Join(".", "config", "essentials.json"),
+		filepath.Join(".", "essentials.json"),
 	}
+	if p := config.EssentialsEnvPath(); p != "" {
+		ps = append([]string{p}, ps...)
+	}
+	return ps
+}
 
-	d0, e0 := prepareRunOutputDir(r0, c0, u0, s0)
+func openArchive(r0 RunContext) *archive.Store {
+	s0, e0 := archive.Open(archive.DefaultPath(r0.OutRoot))
 	if e0 != nil {
-		return e0
+		log.LogError("archive", "open failed: "+e0.Error())
+		return nil
 	}
+	return s0
+}
 
-	i0, e1 := resolveUserID(r0, c0, h0, u0, s0)
-	if e1 != nil {
-		return e1
+func closePack(k0 *downloader.Pack) {
+	if e0 := k0.Close(); e0 != nil {
+		log.LogError("archive", "closing "+k0.Path()+" failed: "+e0.Error())
+		utils.PrintWarn("Could not finish %s: %v", k0.Path(), e0)
 	}
+}
 
-	a0, b0, e2 := scanAndDownloadUserMedia(r0, c0, h0, h1, i0, u0, d0, l0)
-	if e2 != nil {
-		return e2
+func saveArchive(s0 *archive.Store) {
+	if e0 := s0.Save(); e0 != nil {
+		log.LogError("archive", "save failed: "+e0.Error())
 	}
+}
 
-	printRunSummary(r0, u0, t0, a0, b0)
-	return nil
+func waitForRateLimit(r0 RunContext, who, op string, reset time.Time) bool {
+	d0 := 15 * time.Minute
+	if !reset.IsZero() {
+		d0 = time.Until(reset) + 2*time.Second
+	}
+	if d0 <= 0 {
+		return true
+	}
+	log.LogInfo("ratelimit", fmt.Sprintf("%s rate limited; waiting %s", op, d0.Round(time.Second)))
+	r0.ui().warn("Rate limited on %s; waiting until %s (use -wait-for-rate-limit=false to fail fast)", op, time.Now().Add(d0).Format("15:04:05"))
+	return sleepFor(who, d0)
+}
 
+func authProviders(cookiePath string, h0 *http.Client) []auth.Provider {
+	if cookiePath != "" {
+		return []auth.Provider{auth.CookieFile{Path: cookiePath}}
+	}
+	return []auth.Provider{
+		auth.Env{},
+		auth.Static{},
+		auth.CookieFile{},
+		&auth.Pool{Dirs: auth.DefaultPoolDirs()},
+		auth.Guest{Client: h0},
+	}
 }
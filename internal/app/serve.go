@@ -0,0 +1,131 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/archive"
+	"github.com/ghostlawless/xdl/internal/log"
+)
+
+const defaultServeAddr = "127.0.0.1:8787"
+
+type mediaSinceResponse struct {
+	User      string                `json:"user"`
+	UserID    string                `json:"user_id"`
+	Since     string                `json:"since,omitempty"`
+	NextSince string                `json:"next_since,omitempty"`
+	Items     []archive.MediaRecord `json:"items"`
+}
+
+func newArchiveHandler(load func() (*archive.Store, error)) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, rq *http.Request) {
+		if rq.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(rq.URL.Path, "/users/"), "/"), "/")
+		if len(parts) != 2 || (parts[1] != "media" && parts[1] != feedFile) || parts[0] == "" {
+			http.NotFound(w, rq)
+			return
+		}
+		since, err := parseSince(rq.URL.Query().Get("since"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		st, err := load()
+		if err != nil {
+			log.LogError("serve", "archive load failed: "+err.Error())
+			http.Error(w, "archive unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		u, items, ok := st.MediaSince(strings.TrimPrefix(parts[0], "@"), since)
+		if !ok {
+			http.NotFound(w, rq)
+			return
+		}
+		if parts[1] == feedFile {
+			b, err := buildFeed(u, items, st.Root())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+			_, _ = w.Write(b)
+			return
+		}
+
+		out := mediaSinceResponse{User: u.Handle, UserID: u.ID, Items: items}
+		if out.Items == nil {
+			out.Items = []archive.MediaRecord{}
+		}
+		if !since.IsZero() {
+			out.Since = since.UTC().Format(time.RFC3339Nano)
+		}
+		if n := len(items); n > 0 {
+			out.NextSince = items[n-1].AddedAt.UTC().Format(time.RFC3339Nano)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+	})
+	return mux
+}
+
+func parseSince(v string) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid since %q: use unix seconds or RFC3339", v)
+}
+
+func startArchiveServer(r0 RunContext, addr string, load func() (*archive.Store, error)) (func(), error) {
+	stop, bound, err := startHTTPServer(addr, newArchiveHandler(load), "serve")
+	if err != nil {
+		return nil, err
+	}
+	log.LogInfo("serve", "archive API at http://"+bound+"/users/<user>/media and /users/<user>/feed.xml")
+	r0.ui().info("Archive API: http://%s/users/<user>/media?since=<ts>", bound)
+	return stop, nil
+}
+
+func runServeCommand(args []string, runID string, runSeed []byte) error {
+	var addr string
+	r0, _, err := parseCommandArgs("serve", args, runID, runSeed, func(fs *flag.FlagSet) {
+		fs.StringVar(&addr, "addr", defaultServeAddr, "Address to listen on")
+	})
+	if err != nil {
+		return err
+	}
+
+	p0 := archive.DefaultPath(r0.OutRoot)
+	stop, err := startArchiveServer(r0, addr, func() (*archive.Store, error) {
+		return archive.Open(p0)
+	})
+	if err != nil {
+		return fmt.Errorf("Could not start archive API on %s: %w", addr, err)
+	}
+	defer stop()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	<-ctx.Done()
+	return nil
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+type shutdownCoordinator struct {
+	draining atomic.Bool
+	grace    time.Duration
+	once     sync.Once
+
+	mu       sync.Mutex
+	flushers []func()
+}
+
+var globalShutdown = &shutdownCoordinator{}
+
+func (s *shutdownCoordinator) Draining() bool { return s.draining.Load() }
+
+func (s *shutdownCoordinator) setGrace(d time.Duration) {
+	if d <= 0 {
+		d = 30 * time.Second
+	}
+	s.grace = d
+}
+
+func (s *shutdownCoordinator) Begin(reason string) {
+	s.once.Do(func() {
+		s.draining.Store(true)
+		log.LogInfo("shutdown", fmt.Sprintf("%s: draining (grace %s)", reason, s.grace))
+		utils.PrintWarn("Stopping: finishing in-flight downloads (up to %s); press Ctrl+C again to quit now", s.grace)
+		time.AfterFunc(s.grace, func() {
+			log.LogInfo("shutdown", "grace period elapsed; aborting remaining downloads")
+			globalControl.setQuit()
+		})
+	})
+}
+
+func (s *shutdownCoordinator) Interrupt(reason string) {
+	if s.Draining() {
+		log.LogInfo("shutdown", reason+": forcing quit")
+		globalControl.setQuit()
+		return
+	}
+	s.Begin(reason)
+}
+
+func (s *shutdownCoordinator) OnFlush(fn func()) {
+	if fn == nil {
+		return
+	}
+	s.mu.Lock()
+	s.flushers = append(s.flushers, fn)
+	s.mu.Unlock()
+}
+
+func (s *shutdownCoordinator) Flush() {
+	s.mu.Lock()
+	fs := s.flushers
+	s.flushers = nil
+	s.mu.Unlock()
+	for i := len(fs) - 1; i >= 0; i-- {
+		fs[i]()
+	}
+}
+
+func (s *shutdownCoordinator) watchSignals() func() {
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case sig := <-ch:
+				s.Interrupt("signal " + sig.String())
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(stop)
+	}
+}
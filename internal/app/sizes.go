@@ -0,0 +1,61 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/httpx"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/mediaurl"
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+const sizeMaxBytes = 64 << 20
+
+func (p *Pipeline) imageSizes(dir string, cp *downloader.Checkpoint) {
+	if !p.r0.AllImageSizes || p.r0.DryRun || p.r0.Pack != nil {
+		return
+	}
+	n := 0
+	for _, it := range cp.DoneItems() {
+		if it.Type != "image" || it.Path == "" || !mediaurl.IsPhoto(it.URL) {
+			continue
+		}
+		base := strings.TrimSuffix(filepath.Base(it.Path), filepath.Ext(it.Path))
+		tid := it.TweetID
+		if tid == "" {
+			tid = base
+		}
+		ext := mediaurl.Format(it.URL)
+		for _, sz := range mediaurl.ClientSizes {
+			if shouldStopDownloads() {
+				return
+			}
+			out := paths.SizeVariant(dir, tid, base, sz, ext)
+			if fileExists(out) {
+				continue
+			}
+			if err := utils.EnsureDir(filepath.Dir(out)); err != nil {
+				log.LogError("sizes", err.Error())
+				return
+			}
+			rq, err := http.NewRequest(http.MethodGet, p.c0.MediaURL(mediaurl.WithSize(it.URL, sz)), nil)
+			if err != nil {
+				log.LogWarn("sizes", err.Error())
+				continue
+			}
+			if _, st, err := httpx.DownloadToFile(p.dl, rq, out, sizeMaxBytes); err != nil {
+				log.LogWarn("sizes", fmt.Sprintf("%s %s: status=%d %v", it.URL, sz, st, err))
+				continue
+			}
+			n++
+		}
+	}
+	if n > 0 {
+		log.LogInfo("sizes", fmt.Sprintf("saved %d size variants under %s", n, filepath.Join(dir, paths.SizesDir)))
+	}
+}
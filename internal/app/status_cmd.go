@@ -0,0 +1,148 @@
+package app
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+type statusRow struct {
+	User        string    `json:"user"`
+	LastRun     time.Time `json:"last_run"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	Status      string    `json:"status"`
+	Downloaded  int       `json:"downloaded"`
+	Failed      int       `json:"failed"`
+	Failures    int       `json:"consecutive_failures,omitempty"`
+	Stale       bool      `json:"stale"`
+	Next        time.Time `json:"next_run,omitzero"`
+	Error       string    `json:"error,omitempty"`
+}
+
+func runStatusCommand(args []string, runID string, runSeed []byte) error {
+	var (
+		asJSON bool
+		stale  time.Duration
+	)
+	r0, rest, err := parseCommandArgs("status", args, runID, runSeed, func(fs *flag.FlagSet) {
+		fs.BoolVar(&asJSON, "json", false, "Print the status as JSON")
+		fs.DurationVar(&stale, "stale", 7*24*time.Hour, "Flag users without a successful run for this long")
+	})
+	if err != nil {
+		return err
+	}
+	fp := paths.New(r0.OutRoot).StateFile(historyFile)
+	h, err := readHistory(fp)
+	if err != nil {
+		return fmt.Errorf("Could not read %s: %w", fp, err)
+	}
+	want := map[string]bool{}
+	for _, u := range dedupeUsers(rest) {
+		want[strings.ToLower(u)] = true
+	}
+
+	now := time.Now()
+	rows := make([]statusRow, 0, len(h.Users))
+	for k, e := range h.Users {
+		if len(want) > 0 && !want[k] {
+			continue
+		}
+		r := statusRow{User: e.User, LastRun: e.LastRun, LastSuccess: e.LastSuccess, Failures: e.Failures, Next: e.Next}
+		if n := len(e.Runs); n > 0 {
+			l := e.Runs[n-1]
+			r.Status, r.Downloaded, r.Failed, r.Error = l.Status, l.Downloaded, l.Failed, l.Error
+		}
+		r.Stale = e.LastSuccess.IsZero() || now.Sub(e.LastSuccess) > stale
+		rows = append(rows, r)
+	}
+	for u := range want {
+		if _, ok := h.Users[u]; !ok {
+			rows = append(rows, statusRow{User: u, Status: "never", Stale: true})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return strings.ToLower(rows[i].User) < strings.ToLower(rows[j].User) })
+
+	if asJSON {
+		enc := json.NewEncoder(utils.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Updated  time.Time   `json:"updated,omitzero"`
+			WatchPID int         `json:"watch_pid,omitempty"`
+			Users    []statusRow `json:"users"`
+		}{h.Updated, h.WatchPID, rows})
+	}
+	if len(rows) == 0 {
+		utils.PrintInfo("No runs recorded yet in %s", fp)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(utils.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tLAST RUN\tSTATUS\tNEW\tFAIL\tNEXT\tNOTE")
+	var failed, stales int
+	for _, r := range rows {
+		var note []string
+		if r.Stale {
+			stales++
+			if r.LastSuccess.IsZero() {
+				note = append(note, "stale: never succeeded")
+			} else {
+				note = append(note, "stale: last success "+ago(now.Sub(r.LastSuccess)))
+			}
+		}
+		if r.Status == string(targetFailed) {
+			failed++
+			msg := "failed"
+			if r.Failures > 1 {
+				msg = fmt.Sprintf("failed %d times in a row", r.Failures)
+			}
+			if r.Error != "" {
+				msg += ": " + r.Error
+			}
+			note = append(note, msg)
+		}
+		last := "-"
+		if !r.LastRun.IsZero() {
+			last = ago(now.Sub(r.LastRun))
+		}
+		fmt.Fprintf(w, "@%s\t%s\t%s\t%d\t%d\t%s\t%s\n", r.User, last, r.Status, r.Downloaded, r.Failed, nextRun(now, r.Next), strings.Join(note, "; "))
+	}
+	_ = w.Flush()
+
+	if r0.Mode != ModeQuiet {
+		fmt.Fprintln(utils.Stdout)
+		utils.PrintInfo("%d targets: %d failed last time, %d stale (no success within %s)", len(rows), failed, stales, stale)
+		if h.WatchPID != 0 {
+			utils.PrintInfo("Next runs scheduled by -watch (pid %d)", h.WatchPID)
+		}
+	}
+	return nil
+}
+
+func ago(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+}
+
+func nextRun(now, next time.Time) string {
+	if next.IsZero() {
+		return "-"
+	}
+	if d := next.Sub(now); d > 0 {
+		return "in " + strings.TrimSuffix(d.Round(time.Minute).String(), "0s")
+	}
+	return "overdue"
+}
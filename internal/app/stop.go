@@ -0,0 +1,97 @@
+package app
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/ghostlawless/xdl/internal/archive"
+	"github.com/ghostlawless/xdl/internal/scraper"
+)
+
+var errStopCondition = errors.New("stop condition reached")
+
+type stopAt struct {
+	limit     int
+	newer     uint64
+	maxPages  int
+	maxTweets int
+	dups      int
+	pages     int
+	tweets    int
+	why       string
+}
+
+func newStopAt(r0 RunContext) *stopAt {
+	if r0.StopAfterDups <= 0 && r0.NewerThan == "" && r0.MaxPages <= 0 && r0.MaxTweets <= 0 {
+		return nil
+	}
+	n, _ := strconv.ParseUint(r0.NewerThan, 10, 64)
+	return &stopAt{limit: r0.StopAfterDups, newer: n, maxPages: r0.MaxPages, maxTweets: r0.MaxTweets}
+}
+
+// incremental reports whether the scan stops at already-seen tweets, which
+// turns off -deep. Page and tweet limits do not; they count the search too.
+func (s *stopAt) incremental() bool {
+	return s != nil && (s.limit > 0 || s.newer > 0)
+}
+
+func (s *stopAt) page(st *archive.Store, ms []scraper.Media) ([]scraper.Media, bool) {
+	if s == nil {
+		return ms, false
+	}
+	if len(ms) > 0 && !ms[0].Pinned {
+		s.pages++
+	}
+	out := ms[:0:0]
+	for i := 0; i < len(ms); {
+		j := i + 1
+		for ms[i].TweetID != "" && j < len(ms) && ms[j].TweetID == ms[i].TweetID {
+			j++
+		}
+		tw := ms[i:j]
+		i = j
+
+		if s.newer > 0 {
+			if n, err := strconv.ParseUint(tw[0].TweetID, 10, 64); err == nil && n <= s.newer {
+				s.why = "newer_than"
+				return out, true
+			}
+		}
+		if s.limit > 0 {
+			if archived(st, tw) {
+				s.dups++
+			} else {
+				s.dups = 0
+			}
+			if s.dups >= s.limit {
+				s.why = "duplicates"
+				return out, true
+			}
+		}
+		if s.maxTweets > 0 && tw[0].TweetID != "" {
+			if s.tweets >= s.maxTweets {
+				s.why = "max_tweets"
+				return out, true
+			}
+			s.tweets++
+		}
+		out = append(out, tw...)
+	}
+	if s.maxPages > 0 && s.pages >= s.maxPages {
+		s.why = "max_pages"
+		return out, true
+	}
+	return out, false
+}
+
+func archived(st *archive.Store, tw []scraper.Media) bool {
+	if st.ImportedTweet(tw[0].TweetID) {
+		return true
+	}
+	for _, m := range tw {
+		if !st.HasMedia(m.URL) {
+			return false
+		}
+	}
+	return st != nil
+}
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+const takeoutPageSize = 100
+
+var takeoutTweetFile = regexp.MustCompile(`^tweets?(-part\d+)?\.js(on)?$`)
+
+func runTakeoutCommand(args []string, runID string, runSeed []byte) error {
+	r0, rest, err := parseCommandArgs("import-takeout", args, runID, runSeed, nil)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 || strings.TrimSpace(rest[0]) == "" {
+		return fmt.Errorf("Usage: xdl import-takeout <twitter-archive.zip|folder>\n\nExample:\n  xdl import-takeout ~/Downloads/twitter-2026-01-01.zip")
+	}
+	r0.Takeout = filepath.Clean(rest[0])
+	if _, err := os.Stat(r0.Takeout); err != nil {
+		return fmt.Errorf("Could not find %s", r0.Takeout)
+	}
+	return runWithContext(r0)
+}
+
+func (p *Pipeline) runTakeout() targetReport {
+	t0 := time.Now()
+	acct, ms, err := readTakeout(p.r0.Takeout)
+	if err != nil {
+		return newTargetReport("takeout", t0, scanResult{}, downloadStats{}, fmt.Errorf("Could not read X data export %s: %w", p.r0.Takeout, err))
+	}
+	u0 := acct.Username
+	defer globalControl.begin(u0)()
+	defer p.r0.ui().drop(u0)
+	p.r0.ui().targetStart(u0, nil)
+	unlock, err := p.lockUser(u0)
+	if err != nil {
+		return newTargetReport(u0, t0, scanResult{}, downloadStats{}, err)
+	}
+	defer unlock()
+	p.r0.Store.RememberUser(acct.ID, u0)
+
+	a0 := newScanAccumulator(len(ms))
+	a0.Add(ms)
+	fresh := make([]scraper.Media, 0, len(ms))
+	for _, m := range ms {
+		if !p.r0.Store.HasMedia(m.URL) {
+			fresh = append(fresh, m)
+		}
+	}
+	p.r0.ui().info("Export for @%s lists %d media; %d are not archived yet", u0, len(ms), len(fresh))
+
+	s0 := downloadStats{}
+	if len(fresh) > 0 {
+		d0, err := prepareRunOutputDir(p.r0, p.c0, u0, nil)
+		if err != nil {
+			return newTargetReport(u0, t0, a0.Result(), s0, err)
+		}
+		for i, pg := 0, 1; i < len(fresh); i, pg = i+takeoutPageSize, pg+1 {
+			if err := p.interrupted(u0); err != nil {
+				return newTargetReport(u0, t0, a0.Result(), s0, err)
+			}
+			sum, err := p.download(acct.ID, u0, d0, pg, fresh[i:min(i+takeoutPageSize, len(fresh))])
+			s0.add(sum)
+			if err != nil {
+				return newTargetReport(u0, t0, a0.Result(), s0, err)
+			}
+		}
+	}
+
+	p.r0.ui().targetDone(u0, t0, a0.Result(), s0)
+	return newTargetReport(u0, t0, a0.Result(), s0, nil)
+}
+
+func readTakeout(p string) (scraper.TakeoutAccount, []scraper.Media, error) {
+	var fsys fs.FS
+	if utils.DirExists(p) {
+		fsys = os.DirFS(p)
+	} else {
+		z, err := zip.OpenReader(p)
+		if err != nil {
+			return scraper.TakeoutAccount{}, nil, err
+		}
+		defer z.Close()
+		fsys = z
+	}
+	if _, err := fs.Stat(fsys, "data/account.js"); err != nil {
+		if m, _ := fs.Glob(fsys, "*/data/account.js"); len(m) == 1 {
+			fsys, _ = fs.Sub(fsys, path.Dir(path.Dir(m[0])))
+		}
+	}
+
+	b, err := fs.ReadFile(fsys, "data/account.js")
+	if err != nil {
+		return scraper.TakeoutAccount{}, nil, errors.New("data/account.js not found; is this an X data export?")
+	}
+	acct, err := scraper.ParseTakeoutAccount(b)
+	if err != nil {
+		return scraper.TakeoutAccount{}, nil, fmt.Errorf("data/account.js: %w", err)
+	}
+
+	es, err := fs.ReadDir(fsys, "data")
+	if err != nil {
+		return acct, nil, err
+	}
+	var out []scraper.Media
+	n := 0
+	for _, e := range es {
+		if e.IsDir() || !takeoutTweetFile.MatchString(e.Name()) {
+			continue
+		}
+		b, err := fs.ReadFile(fsys, "data/"+e.Name())
+		if err != nil {
+			return acct, nil, err
+		}
+		ms, err := scraper.ParseTakeoutTweets(b)
+		if err != nil {
+			return acct, nil, fmt.Errorf("data/%s: %w", e.Name(), err)
+		}
+		out = append(out, ms...)
+		n++
+	}
+	if n == 0 {
+		return acct, nil, errors.New("no data/tweets.js or tweets.json in export")
+	}
+	return acct, out, nil
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/log"
+)
+
+type target struct {
+	User    string
+	Aliases []string
+}
+
+func singleTargets(users []string) []target {
+	out := make([]target, 0, len(users))
+	for _, u := range users {
+		out = append(out, target{User: u})
+	}
+	return out
+}
+
+func mergeTargetsByID(r0 RunContext, c0 *config.EssentialsConfig, h0 *http.Client, users []string, workers int) []target {
+	if len(users) < 2 {
+		return singleTargets(users)
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+
+	ids := make([]string, len(users))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, u := range users {
+		i, u := i, u
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if id, err := userLookups.Resolve(h0, c0, u); err == nil {
+				ids[i] = id
+			}
+		}()
+	}
+	wg.Wait()
+
+	out := make([]target, 0, len(users))
+	byID := make(map[string]int, len(users))
+	for i, u := range users {
+		id := ids[i]
+		if id == "" {
+			out = append(out, target{User: u})
+			continue
+		}
+		if j, ok := byID[id]; ok {
+			out[j].Aliases = append(out[j].Aliases, u)
+			log.LogInfo("main", fmt.Sprintf("target @%s resolves to the same account as @%s (id=%s); merged", u, out[j].User, id))
+			r0.ui().warn("@%s is the same account as @%s; downloading once", u, out[j].User)
+			continue
+		}
+		byID[id] = len(out)
+		out = append(out, target{User: u})
+	}
+	return out
+}
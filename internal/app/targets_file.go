@@ -0,0 +1,341 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/paths"
+)
+
+type targetOptions struct {
+	MaxPages        *int         `json:"max_pages"`
+	MaxTweets       *int         `json:"max_tweets"`
+	NewerThan       *json.Number `json:"newer_than"`
+	StopAfterDups   *int         `json:"stop_after_duplicates"`
+	Deep            *bool        `json:"deep"`
+	IncludeRetweets *bool        `json:"include_retweets"`
+	Attr            *string      `json:"attr"`
+	AllImageSizes   *bool        `json:"all_image_sizes"`
+	AltText         *bool        `json:"alt_text"`
+	WriteText       *string      `json:"write_text"`
+	GeoJSON         *bool        `json:"geojson"`
+	Thumbs          *bool        `json:"thumbs"`
+	Output          string       `json:"output"`
+	Watch           string       `json:"watch"`
+	Priority        *int         `json:"priority"`
+
+	every time.Duration
+}
+
+func loadTargetsFile(p string) ([]string, map[string]*targetOptions, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ext := strings.ToLower(filepath.Ext(p)); ext == ".yaml" || ext == ".yml" {
+		v, err := parseYAML(b)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", p, err)
+		}
+		if b, err = json.Marshal(v); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, nil, fmt.Errorf("%s: expected a map of usernames: %w", p, err)
+	}
+	users := make([]string, 0, len(raw))
+	out := make(map[string]*targetOptions, len(raw))
+	for u, r := range raw {
+		u = strings.TrimPrefix(strings.TrimSpace(u), "@")
+		if u == "" {
+			continue
+		}
+		users = append(users, u)
+		if len(r) == 0 || string(r) == "null" {
+			continue
+		}
+		o := &targetOptions{}
+		dec := json.NewDecoder(bytes.NewReader(r))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(o); err != nil {
+			return nil, nil, fmt.Errorf("%s: @%s: %w", p, u, err)
+		}
+		if err := o.validate(); err != nil {
+			return nil, nil, fmt.Errorf("%s: @%s: %w", p, u, err)
+		}
+		out[strings.ToLower(u)] = o
+	}
+	sort.Strings(users)
+	return users, out, nil
+}
+
+func (o *targetOptions) validate() error {
+	if o.Attr != nil && *o.Attr != attrOriginal && *o.Attr != attrRetweeter {
+		return fmt.Errorf("invalid attr %q (use original or retweeter)", *o.Attr)
+	}
+	if o.WriteText != nil && *o.WriteText != "" && *o.WriteText != textTxt && *o.WriteText != textNDJSON {
+		return fmt.Errorf("invalid write_text %q (use txt or ndjson)", *o.WriteText)
+	}
+	if o.NewerThan != nil && *o.NewerThan != "" {
+		if _, err := strconv.ParseUint(o.NewerThan.String(), 10, 64); err != nil {
+			return fmt.Errorf("invalid newer_than %q (use a numeric tweet ID)", *o.NewerThan)
+		}
+	}
+	for _, n := range []*int{o.MaxPages, o.MaxTweets, o.StopAfterDups} {
+		if n != nil && *n < 0 {
+			return fmt.Errorf("max_pages, max_tweets and stop_after_duplicates must be positive")
+		}
+	}
+	if o.Watch != "" {
+		d, err := time.ParseDuration(o.Watch)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid watch %q (use a duration such as 30m)", o.Watch)
+		}
+		o.every = d
+	}
+	return nil
+}
+
+func (r RunContext) forUser(u string) RunContext {
+	o := r.Overrides[strings.ToLower(u)]
+	if o == nil {
+		return r
+	}
+	setInt := func(dst *int, v *int) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+	setBool := func(dst *bool, v *bool) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+	setStr := func(dst *string, v *string) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+	setInt(&r.MaxPages, o.MaxPages)
+	setInt(&r.MaxTweets, o.MaxTweets)
+	setInt(&r.StopAfterDups, o.StopAfterDups)
+	if o.NewerThan != nil {
+		r.NewerThan = o.NewerThan.String()
+	}
+	setBool(&r.Deep, o.Deep)
+	setBool(&r.IncludeRetweets, o.IncludeRetweets)
+	setStr(&r.Attr, o.Attr)
+	setBool(&r.AllImageSizes, o.AllImageSizes)
+	setBool(&r.AltText, o.AltText)
+	setStr(&r.WriteText, o.WriteText)
+	setBool(&r.GeoJSON, o.GeoJSON)
+	setBool(&r.Thumbs, o.Thumbs)
+	if o.Output != "" {
+		r.OutRoot = paths.Expand(o.Output)
+		r.Layout = paths.New(r.OutRoot)
+	}
+	return r
+}
+
+type yamlLine struct {
+	n    int
+	ind  int
+	text string
+}
+
+// parseYAML reads the block-style subset of YAML a targets file needs: nested
+// maps, "- " lists, inline [a, b] lists, comments and plain or quoted scalars.
+func parseYAML(b []byte) (any, error) {
+	var ls []yamlLine
+	for i, s := range strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n") {
+		s = yamlStripComment(s)
+		t := strings.TrimLeft(s, " ")
+		if strings.TrimSpace(t) == "" || t == "---" {
+			continue
+		}
+		if strings.HasPrefix(t, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		ls = append(ls, yamlLine{n: i + 1, ind: len(s) - len(t), text: strings.TrimRight(t, " \t")})
+	}
+	if len(ls) == 0 {
+		return map[string]any{}, nil
+	}
+	v, i, err := yamlBlock(ls, 0, ls[0].ind)
+	if err != nil {
+		return nil, err
+	}
+	if i < len(ls) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", ls[i].n)
+	}
+	return v, nil
+}
+
+func yamlBlock(ls []yamlLine, i, ind int) (any, int, error) {
+	if yamlIsItem(ls[i].text) {
+		return yamlList(ls, i, ind)
+	}
+	return yamlMap(ls, i, ind)
+}
+
+func yamlIsItem(s string) bool {
+	return s == "-" || strings.HasPrefix(s, "- ")
+}
+
+func yamlList(ls []yamlLine, i, ind int) (any, int, error) {
+	out := []any{}
+	for i < len(ls) && ls[i].ind == ind && yamlIsItem(ls[i].text) {
+		item := strings.TrimLeft(ls[i].text[1:], " ")
+		switch {
+		case item == "":
+			i++
+			if i < len(ls) && ls[i].ind > ind {
+				v, j, err := yamlBlock(ls, i, ls[i].ind)
+				if err != nil {
+					return nil, 0, err
+				}
+				out, i = append(out, v), j
+			} else {
+				out = append(out, nil)
+			}
+		case yamlHasKey(item):
+			sub := append([]yamlLine(nil), ls[i:]...)
+			sub[0] = yamlLine{n: ls[i].n, ind: ind + len(ls[i].text) - len(item), text: item}
+			v, j, err := yamlMap(sub, 0, sub[0].ind)
+			if err != nil {
+				return nil, 0, err
+			}
+			out, i = append(out, v), i+j
+		default:
+			out = append(out, yamlScalar(item))
+			i++
+		}
+	}
+	return out, i, nil
+}
+
+func yamlMap(ls []yamlLine, i, ind int) (any, int, error) {
+	out := map[string]any{}
+	for i < len(ls) && ls[i].ind >= ind {
+		if ls[i].ind > ind {
+			return nil, 0, fmt.Errorf("line %d: unexpected indentation", ls[i].n)
+		}
+		k, rest, ok := yamlSplitKey(ls[i].text)
+		if !ok {
+			return nil, 0, fmt.Errorf("line %d: expected \"key: value\"", ls[i].n)
+		}
+		i++
+		switch {
+		case rest != "":
+			out[k] = yamlScalar(rest)
+		case i < len(ls) && (ls[i].ind > ind || (ls[i].ind == ind && yamlIsItem(ls[i].text))):
+			v, j, err := yamlBlock(ls, i, ls[i].ind)
+			if err != nil {
+				return nil, 0, err
+			}
+			out[k], i = v, j
+		default:
+			out[k] = nil
+		}
+	}
+	return out, i, nil
+}
+
+func yamlHasKey(s string) bool {
+	_, _, ok := yamlSplitKey(s)
+	return ok
+}
+
+func yamlSplitKey(s string) (string, string, bool) {
+	q := byte(0)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case q != 0:
+			if c == q {
+				q = 0
+			}
+		case c == '"' || c == '\'':
+			q = c
+		case c == ':' && (i == len(s)-1 || s[i+1] == ' '):
+			k := strings.TrimSpace(s[:i])
+			if k == "" {
+				return "", "", false
+			}
+			return yamlUnquote(k), strings.TrimSpace(s[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+func yamlScalar(s string) any {
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		out := []any{}
+		for _, p := range strings.Split(s[1:len(s)-1], ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				out = append(out, yamlScalar(p))
+			}
+		}
+		return out
+	}
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return yamlUnquote(s)
+	}
+	switch strings.ToLower(s) {
+	case "true", "yes", "on":
+		return true
+	case "false", "no", "off":
+		return false
+	case "null", "~":
+		return nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && !(len(s) > 1 && s[0] == '0') {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && strings.Contains(s, ".") {
+		return f
+	}
+	return s
+}
+
+func yamlUnquote(s string) string {
+	if len(s) < 2 || s[len(s)-1] != s[0] {
+		return s
+	}
+	switch s[0] {
+	case '"':
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+	case '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	}
+	return s
+}
+
+func yamlStripComment(s string) string {
+	q := byte(0)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case q != 0:
+			if c == q {
+				q = 0
+			}
+		case c == '"' || c == '\'':
+			q = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want any
+	}{
+		{"empty", "", map[string]any{}},
+		{"comments only", "# targets\n---\n", map[string]any{}},
+		{
+			"nested map",
+			"nasa:\n  max_pages: 3\n  include_retweets: yes\n",
+			map[string]any{"nasa": map[string]any{"max_pages": int64(3), "include_retweets": true}},
+		},
+		{"bare user", "google:\n", map[string]any{"google": nil}},
+		{
+			"scalars",
+			"a: 'it''s'\nb: \"x: y\"\nc: ~\nd: 1.5\ne: 007\nf: off\n",
+			map[string]any{"a": "it's", "b": "x: y", "c": nil, "d": 1.5, "e": "007", "f": false},
+		},
+		{
+			"comments and quoted hash",
+			"nasa: # the agency\n  output: \"/mnt/#1\" # trailing\n",
+			map[string]any{"nasa": map[string]any{"output": "/mnt/#1"}},
+		},
+		{"inline list", "tags: [a, 2, \"c\"]\n", map[string]any{"tags": []any{"a", int64(2), "c"}}},
+		{"block list", "tags:\n  - a\n  - b\n", map[string]any{"tags": []any{"a", "b"}}},
+		{"list at key indent", "tags:\n- a\n- b\n", map[string]any{"tags": []any{"a", "b"}}},
+		{
+			"list of maps",
+			"xs:\n  - name: a\n    n: 1\n  - name: b\n",
+			map[string]any{"xs": []any{map[string]any{"name": "a", "n": int64(1)}, map[string]any{"name": "b"}}},
+		},
+		{"windows line endings", "nasa:\r\n  deep: true\r\n", map[string]any{"nasa": map[string]any{"deep": true}}},
+		{"url value", "u: https://x.com/a\n", map[string]any{"u": "https://x.com/a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAML([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("parseYAML: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	for name, in := range map[string]string{
+		"tab indent":     "nasa:\n\tdeep: true\n",
+		"not a map":      "nasa\n",
+		"deeper sibling": "a: 1\n  b: 2\n",
+		"dedent below":   "  a: 1\nb: 2\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if v, err := parseYAML([]byte(in)); err == nil {
+				t.Errorf("expected an error, got %#v", v)
+			}
+		})
+	}
+}
+
+func TestLoadTargetsFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "targets.yaml")
+	in := "'@NASA':\n  max_pages: 0\n  deep: true\ngoogle:\n"
+	if err := os.WriteFile(p, []byte(in), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	users, opts, err := loadTargetsFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(users, []string{"NASA", "google"}) {
+		t.Errorf("users = %v", users)
+	}
+	r := RunContext{MaxPages: 5, Overrides: opts}.forUser("nasa")
+	if r.MaxPages != 0 || !r.Deep {
+		t.Errorf("forUser(nasa) = max_pages %d, deep %v; want 0, true", r.MaxPages, r.Deep)
+	}
+	if r := (RunContext{MaxPages: 5, Overrides: opts}).forUser("google"); r.MaxPages != 5 {
+		t.Errorf("forUser(google) max_pages = %d, want 5", r.MaxPages)
+	}
+
+	for name, in := range map[string]string{
+		"unknown key":    "nasa:\n  pages: 3\n",
+		"negative limit": "nasa:\n  max_tweets: -1\n",
+		"bad attr":       "nasa:\n  attr: author\n",
+		"bad watch":      "nasa:\n  watch: soon\n",
+		"bad newer_than": "nasa:\n  newer_than: abc\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := os.WriteFile(p, []byte(in), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := loadTargetsFile(p); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
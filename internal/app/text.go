@@ -0,0 +1,129 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ghostlawless/xdl/internal/audit"
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+const (
+	textTxt    = "txt"
+	textNDJSON = "ndjson"
+)
+
+type tweetTextRow struct {
+	TweetID string        `json:"tweet_id"`
+	Text    string        `json:"text"`
+	Files   []string      `json:"files,omitempty"`
+	Geo     *scraper.Geo  `json:"geo,omitempty"`
+	Poll    *scraper.Poll `json:"poll,omitempty"`
+}
+
+func (p *Pipeline) tweetTexts(dir string, ms []scraper.Media, cp *downloader.Checkpoint) {
+	if p.r0.WriteText == "" || p.r0.Pack != nil {
+		return
+	}
+	texts := map[string]string{}
+	geo := map[string]*scraper.Geo{}
+	poll := map[string]*scraper.Poll{}
+	for _, m := range ms {
+		if m.TweetID != "" && m.Text != "" {
+			texts[m.TweetID] = m.Text
+			geo[m.TweetID] = m.Geo
+			poll[m.TweetID] = m.Poll
+		}
+	}
+	files := map[string][]string{}
+	for _, it := range cp.Items {
+		if it.TweetID == "" || texts[it.TweetID] == "" {
+			continue
+		}
+		if it.Status != downloader.CheckpointDone && it.Status != downloader.CheckpointSkipped {
+			continue
+		}
+		f := files[it.TweetID]
+		if it.Path != "" {
+			if r, err := filepath.Rel(dir, it.Path); err == nil {
+				f = append(f, filepath.ToSlash(r))
+			}
+		}
+		files[it.TweetID] = f
+	}
+	rows := make([]tweetTextRow, 0, len(files))
+	for id, f := range files {
+		rows = append(rows, tweetTextRow{TweetID: id, Text: texts[id], Files: f, Geo: geo[id], Poll: poll[id]})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].TweetID < rows[j].TweetID })
+	var err error
+	if p.r0.WriteText == textNDJSON {
+		err = appendTweetRows(paths.Tweets(dir), rows)
+	} else {
+		err = writeTweetFiles(dir, rows)
+	}
+	if err != nil {
+		log.LogError("text", err.Error())
+	}
+}
+
+func writeTweetFiles(dir string, rows []tweetTextRow) error {
+	for _, r := range rows {
+		fp := paths.TweetText(dir, r.TweetID)
+		if fileExists(fp) {
+			continue
+		}
+		if err := utils.EnsureDir(filepath.Dir(fp)); err != nil {
+			return err
+		}
+		if err := utils.SaveToFile(fp, []byte(r.Text+"\n")); err != nil {
+			return err
+		}
+		audit.File(audit.FileWrite, fp, "")
+	}
+	return nil
+}
+
+func appendTweetRows(fp string, rows []tweetTextRow) error {
+	seen := map[string]bool{}
+	if f, err := os.Open(fp); err == nil {
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64<<10), 4<<20)
+		for sc.Scan() {
+			var r tweetTextRow
+			if json.Unmarshal(sc.Bytes(), &r) == nil {
+				seen[r.TweetID] = true
+			}
+		}
+		f.Close()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.OpenFile(fp, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	n := 0
+	for _, r := range rows {
+		if seen[r.TweetID] {
+			continue
+		}
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+		n++
+	}
+	if n > 0 {
+		audit.File(audit.FileWrite, fp, "")
+	}
+	return nil
+}
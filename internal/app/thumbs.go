@@ -0,0 +1,79 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/ffmpeg"
+	"github.com/ghostlawless/xdl/internal/httpx"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+const (
+	thumbWidth    = 480
+	thumbMaxBytes = 4 << 20
+)
+
+func (p *Pipeline) thumbnails(dir string, ms []scraper.Media, cp *downloader.Checkpoint) {
+	if !p.r0.Thumbs || p.r0.DryRun || p.r0.Pack != nil {
+		return
+	}
+	posters := make(map[string]string, len(ms))
+	for _, m := range ms {
+		if m.Type == "video" {
+			posters[m.URL] = m.Poster
+		}
+	}
+	for _, it := range cp.DoneItems() {
+		if it.Type != "video" || it.Path == "" {
+			continue
+		}
+		th := paths.Thumb(dir, it.Path)
+		if fileExists(th) {
+			continue
+		}
+		if err := utils.EnsureDir(filepath.Dir(th)); err != nil {
+			log.LogError("thumbs", err.Error())
+			return
+		}
+		how, err := p.thumbnail(it.Path, posters[it.URL], th)
+		if err != nil {
+			log.LogWarn("thumbs", fmt.Sprintf("no thumbnail for %s: %v", it.Path, err))
+			continue
+		}
+		log.LogInfo("thumbs", fmt.Sprintf("%s -> %s (%s)", it.Path, th, how))
+	}
+}
+
+func (p *Pipeline) thumbnail(video, poster, out string) (string, error) {
+	if ff, err := ffmpeg.Find(); err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := ff.Thumbnail(ctx, video, out, thumbWidth); err == nil && fileExists(out) {
+			return "ffmpeg", nil
+		} else if err != nil {
+			log.LogWarn("thumbs", fmt.Sprintf("%s: %v", video, err))
+		}
+	}
+	if poster == "" {
+		if err := ffmpeg.Unavailable("video thumbnails without a preview image"); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("no thumbnail for %s", video)
+	}
+	rq, err := http.NewRequest(http.MethodGet, p.c0.MediaURL(poster), nil)
+	if err != nil {
+		return "", err
+	}
+	if _, _, err := httpx.DownloadToFile(p.dl, rq, out, thumbMaxBytes); err != nil {
+		return "", err
+	}
+	return "preview", nil
+}
@@ -1,20 +1,28 @@
 package app
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
-	"path/filepath"
+	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/errs"
 	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/paths"
 	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/trace"
 	"github.com/ghostlawless/xdl/internal/utils"
 )
 
-func newSpinnerForUser(_ RunContext, label string) *spinner {
+func newSpinnerForUser(r0 RunContext, label string) *spinner {
+	if r0.Mode == ModeQuiet || r0.Headless || !utils.IsTerminal(os.Stdout) {
+		return nil
+	}
 	return startSpinner(label)
 }
 
@@ -25,100 +33,274 @@ func stopSpinner(s *spinner) {
 }
 
 func prepareRunOutputDir(r0 RunContext, _ *config.EssentialsConfig, u0 string, _ *spinner) (string, error) {
-	n0 := u0
-	p0 := filepath.Join(r0.OutRoot, n0)
-
 	if e0 := utils.EnsureDir(r0.OutRoot); e0 != nil {
 		return "", e0
 	}
 
-	if utils.DirExists(p0) {
-		i0 := 1
-		for {
-			n1 := fmt.Sprintf("%s_%03d", u0, i0)
-			p1 := filepath.Join(r0.OutRoot, n1)
-			if !utils.DirExists(p1) {
-				p0 = p1
-				break
-			}
-			i0++
-			if i0 > 9999 {
-				return "", fmt.Errorf("Could not create a new output folder for @%s (too many existing runs).", u0)
-			}
-		}
+	l0 := r0.Layout
+	if l0 == nil {
+		l0 = paths.New(r0.OutRoot)
 	}
-
-	if e1 := utils.EnsureDir(p0); e1 != nil {
-		return "", e1
+	p0, e1 := l0.RunDir(u0, r0.Watch > 0)
+	if e1 != nil {
+		return "", fmt.Errorf("Could not create a new output folder for @%s: %w", u0, e1)
 	}
 
-	if r0.Mode == ModeVerbose {
-		utils.PrintInfo("Output folder: %s", p0)
+	if e2 := utils.EnsureDir(p0); e2 != nil {
+		return "", e2
 	}
 
+	r0.ui().info("Output folder: %s", p0)
+
 	return p0, nil
 }
 
 func resolveUserID(r0 RunContext, c0 *config.EssentialsConfig, h0 *http.Client, u0 string, _ *spinner) (string, error) {
-	i0, e0 := scraper.FetchUserID(h0, c0, u0)
+	sp := trace.Start("user-lookup", nil)
+	sp.Set("user", u0)
+	defer sp.End()
+
+	i0, e0 := userLookups.Resolve(h0, c0, u0)
+	byHandle := e0 == nil
+	if e0 != nil && r0.IDFallback && (errors.Is(e0, errs.ErrUserNotFound) || errors.Is(e0, errs.ErrUserSuspended)) {
+		if i1, e1 := resolveByStoredID(r0, c0, h0, u0); i1 != "" || e1 != nil {
+			i0, e0 = i1, e1
+		}
+	}
 	if e0 != nil {
+		sp.Fail(e0)
+		var ae *errs.APIError
+		if errors.As(e0, &ae) {
+			sp.Set("status", ae.Status)
+		}
 		log.LogError("user", e0.Error())
 
 		if r0.Mode == ModeDebug {
 			return "", fmt.Errorf("user lookup failed for @%s: %w", u0, e0)
 		}
 
-		return "", fmt.Errorf(
+		if h0 := remediate(u0, e0); h0 != nil {
+			return "", h0
+		}
+
+		return "", withHint(e0,
 			"Could not load @%s.\n\nFix:\n  1) Make sure you are logged in to x.com in your browser\n  2) Export cookies as JSON and save to config/cookies.json\n  3) Run xdl again\n\nTip: run with -d to generate logs.",
 			u0,
 		)
 	}
 
-	if r0.Mode == ModeDebug {
-		log.LogInfo("user", "["+i0+"]")
-	}
+	r0.ui().debug("user", "["+i0+"]")
 
+	sp.Set("user_id", i0)
+	if byHandle {
+		r0.Store.RememberUser(i0, u0)
+	}
 	return i0, nil
 }
 
-func printRunSummary(r0 RunContext, u0 string, t0 time.Time, s0 scanResult, d0 downloadStats) {
-	if r0.Mode == ModeDebug {
-		log.LogInfo("media", fmt.Sprintf(
-			"media found: %d (images:%d videos:%d)",
-			s0.TotalMedia, s0.TotalImages, s0.TotalVideos,
-		))
-		log.LogInfo("download", fmt.Sprintf(
-			"done: ok=%d skipped=%d failed=%d bytes=%d",
-			d0.Downloaded, d0.Skipped, d0.Failed, d0.Bytes,
-		))
-		log.LogInfo("main", fmt.Sprintf(
-			"xdl[%s] exit [%.2fs] user=%s",
-			r0.RunID, time.Since(t0).Seconds(), u0,
-		))
-		return
+func resolveByStoredID(r0 RunContext, c0 *config.EssentialsConfig, h0 *http.Client, u0 string) (string, error) {
+	rec, ok := r0.Store.LookupHandle(u0)
+	if !ok {
+		return "", nil
 	}
+	log.LogInfo("user", fmt.Sprintf("@%s lookup failed; retrying by stored id %s", u0, rec.ID))
 
-	if r0.Mode == ModeVerbose {
-		mb := float64(d0.Bytes) / 1024.0 / 1024.0
-		utils.PrintSuccess(
-			"Done @%s — ok:%d skip:%d fail:%d (%.2f MB, %.2fs)",
-			u0, d0.Downloaded, d0.Skipped, d0.Failed, mb, time.Since(t0).Seconds(),
-		)
+	sn, err := scraper.FetchUserByRestID(h0, c0, rec.ID)
+	if err != nil {
+		return "", err
 	}
+	if !strings.EqualFold(sn, u0) {
+		log.LogInfo("user", fmt.Sprintf("@%s was renamed to @%s (id=%s)", u0, sn, rec.ID))
+		r0.ui().warn("@%s was renamed to @%s; continuing by user ID", u0, sn)
+		r0.Store.RememberUser(rec.ID, sn)
+	}
+	return rec.ID, nil
 }
 
 var termMu sync.Mutex
 
-type interactiveControl struct{}
+type interactiveControl struct {
+	paused  atomic.Bool
+	quit    atomic.Bool
+	restore func()
+	live    atomic.Bool
+	state   atomic.Pointer[string]
+	asking  atomic.Bool
+	keys    chan byte
+
+	mu      sync.Mutex
+	active  []string
+	skipped map[string]bool
+}
 
-func (c *interactiveControl) ShouldPause() bool { return false }
-func (c *interactiveControl) ShouldQuit() bool  { return false }
-func (c *interactiveControl) setPaused(bool)    {}
-func (c *interactiveControl) setQuit()          {}
+func (c *interactiveControl) ShouldPause() bool { return c.paused.Load() }
+func (c *interactiveControl) ShouldQuit() bool  { return c.quit.Load() }
+func (c *interactiveControl) setPaused(v bool)  { c.paused.Store(v); c.persistPaused(v) }
+func (c *interactiveControl) setQuit()          { c.quit.Store(true) }
 
 var globalControl = &interactiveControl{}
 
-func startKeyboardControlListener(_ *interactiveControl) {}
+func startKeyboardControlListener(c *interactiveControl) {
+	if c == nil || !utils.IsTerminal(os.Stdin) {
+		return
+	}
+	restore, err := utils.MakeRaw(os.Stdin)
+	if err != nil {
+		return
+	}
+	c.restore = restore
+	c.keys = make(chan byte, 1)
+	c.live.Store(true)
+
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			if n == 0 {
+				continue
+			}
+			if buf[0] != 0x03 && c.asking.Load() {
+				select {
+				case c.keys <- buf[0]:
+				default:
+				}
+				continue
+			}
+			switch buf[0] {
+			case 'p', 'P':
+				c.setPaused(!c.ShouldPause())
+				if c.ShouldPause() {
+					utils.PrintWarn("Paused: no new requests, transfers suspended. Press p to resume")
+				} else {
+					utils.PrintInfo("Resumed")
+					globalQuota.resumed()
+				}
+			case 's', 'S':
+				c.skip(1)
+			case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+				c.skip(int(buf[0] - '0'))
+			case 'q', 'Q':
+				c.setQuit()
+			case 0x03:
+				if c.ShouldQuit() {
+					c.stop()
+					os.Exit(ExitAborted)
+				}
+				globalShutdown.Interrupt("ctrl+c")
+			}
+		}
+	}()
+}
+
+// readKey waits for the next key pressed while a prompt is open. It gives up
+// with false once stop reports true, e.g. on Ctrl+C.
+func (c *interactiveControl) readKey(stop func() bool) (byte, bool) {
+	if c.keys == nil {
+		return 0, false
+	}
+	t := time.NewTicker(200 * time.Millisecond)
+	defer t.Stop()
+	for {
+		select {
+		case k := <-c.keys:
+			return k, true
+		case <-t.C:
+			if stop() {
+				return 0, false
+			}
+		}
+	}
+}
+
+func (c *interactiveControl) begin(u string) func() {
+	c.mu.Lock()
+	c.active = append(c.active, u)
+	c.mu.Unlock()
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, v := range c.active {
+			if v == u {
+				c.active = append(c.active[:i], c.active[i+1:]...)
+				break
+			}
+		}
+		delete(c.skipped, u)
+	}
+}
+
+func (c *interactiveControl) skip(slot int) {
+	c.mu.Lock()
+	if slot < 1 || slot > len(c.active) {
+		c.mu.Unlock()
+		return
+	}
+	u := c.active[slot-1]
+	if c.skipped == nil {
+		c.skipped = map[string]bool{}
+	}
+	c.skipped[u] = true
+	c.mu.Unlock()
+	utils.PrintWarn("Skipping @%s; moving on to the next target", u)
+}
+
+func (c *interactiveControl) Skipped(u string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.skipped[u]
+}
+
+func (c *interactiveControl) slot(u string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, v := range c.active {
+		if v == u {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (c *interactiveControl) PausedInFlight() bool {
+	return c.ShouldPause() && !c.ShouldQuit() && !globalShutdown.Draining()
+}
+
+func (c *interactiveControl) restorePaused(path string) {
+	c.state.Store(&path)
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+	if !c.live.Load() {
+		log.LogInfo("main", "ignoring saved pause state: no terminal to resume from")
+		_ = os.Remove(path)
+		return
+	}
+	c.paused.Store(true)
+	utils.PrintWarn("Paused in a previous session. Press p to resume")
+}
+
+func (c *interactiveControl) persistPaused(v bool) {
+	p := c.state.Load()
+	if p == nil || *p == "" {
+		return
+	}
+	if !v {
+		_ = os.Remove(*p)
+		return
+	}
+	if err := utils.SaveToFile(*p, []byte(time.Now().UTC().Format(time.RFC3339)+"\n")); err != nil {
+		log.LogError("main", "could not save pause state: "+err.Error())
+	}
+}
+
+func (c *interactiveControl) stop() {
+	if c != nil && c.restore != nil {
+		c.restore()
+	}
+}
 
 type spinner struct {
 	label   string
@@ -144,10 +326,16 @@ func startSpinner(label string) *spinner {
 			case <-s.stopCh:
 				return
 			case <-ticker.C:
+				if utils.StdoutClosed() {
+					continue
+				}
 				out := fmt.Sprintf("%s %c", s.label, frames[i%len(frames)])
-				s.lastLen = len(out)
-				fmt.Printf("\r%s", out)
 				i++
+				if globalProgress.Spin(s.label, out) {
+					continue
+				}
+				s.lastLen = len(out)
+				fmt.Fprintf(utils.Stdout, "%s%s", utils.ClearLine(), out)
 			}
 		}
 	}()
@@ -160,7 +348,15 @@ func (s *spinner) Stop() {
 	}
 	close(s.stopCh)
 	s.wg.Wait()
-	fmt.Printf("\r%s\r", strings.Repeat(" ", s.lastLen))
+	if globalProgress != nil {
+		globalProgress.Unspin(s.label)
+		return
+	}
+	if utils.ANSIEnabled() {
+		fmt.Fprint(utils.Stdout, utils.ClearLine())
+		return
+	}
+	fmt.Fprintf(utils.Stdout, "\r%s\r", strings.Repeat(" ", s.lastLen))
 }
 
 func buildProgressBar(width int, fraction float64) string {
@@ -0,0 +1,33 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+func (p *Pipeline) lockUser(u string) (func(), error) {
+	if p.r0.DryRun || p.r0.Layout == nil {
+		return func() {}, nil
+	}
+	fp := p.r0.Layout.StateFile(filepath.Join("locks", utils.SanitizeFilename(strings.ToLower(u))))
+	lk, err := utils.LockOwner(fp, 0)
+	if errors.Is(err, utils.ErrLocked) && p.r0.LockWait > 0 {
+		p.r0.ui().info("@%s is locked by another xdl run; waiting up to %s", u, p.r0.LockWait)
+		lk, err = utils.LockOwner(fp, p.r0.LockWait)
+	}
+	if errors.Is(err, utils.ErrLocked) {
+		by := "another xdl run"
+		if h := utils.LockHolder(fp); h != "" {
+			by += " (" + h + ")"
+		}
+		return nil, withHint(err, "@%s is already being downloaded by %s. Try again later, or add -lock-wait 30m to wait for it.", u, by)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not lock @%s: %w", u, err)
+	}
+	return func() { _ = lk.Unlock() }, nil
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+func runVerifyCommand(args []string, runID string, runSeed []byte) error {
+	var update bool
+	r0, rest, err := parseCommandArgs("verify", args, runID, runSeed, func(fs *flag.FlagSet) {
+		fs.BoolVar(&update, "update", false, "Add media missing from SHA256SUMS instead of reporting it")
+	})
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 || strings.TrimSpace(rest[0]) == "" {
+		return fmt.Errorf("Usage: xdl verify [-update] <runDir>\n\nExample:\n  xdl verify xDownloads/nasa")
+	}
+	dir := filepath.Clean(rest[0])
+	if !utils.DirExists(dir) {
+		return fmt.Errorf("Could not find folder %s", dir)
+	}
+	mf := paths.Manifest(dir)
+	if !fileExists(mf) && !update {
+		return fmt.Errorf("No %s in %s (run xdl verify -update %s to create one)", paths.ManifestFile, dir, dir)
+	}
+
+	var ok, bad, missing int
+	var unlisted []downloader.CheckpointItem
+	err = downloader.VerifyChecksums(dir, func(r downloader.ManifestResult) {
+		switch r.Status {
+		case downloader.ManifestOK:
+			ok++
+		case downloader.ManifestMismatch:
+			bad++
+			if r.Err != nil {
+				utils.PrintError("%s: %v", r.Path, r.Err)
+			} else {
+				utils.PrintError("%s: checksum mismatch", r.Path)
+			}
+			log.LogError("verify", fmt.Sprintf("%s want=%s got=%s err=%v", r.Path, r.Want, r.Got, r.Err))
+		case downloader.ManifestMissing:
+			missing++
+			utils.PrintWarn("%s: missing", r.Path)
+		case downloader.ManifestUnlisted:
+			unlisted = append(unlisted, downloader.CheckpointItem{Path: filepath.Join(dir, filepath.FromSlash(r.Path))})
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("Could not read %s: %w", mf, err)
+	}
+
+	added := 0
+	if update && len(unlisted) > 0 {
+		for i := range unlisted {
+			if unlisted[i].SHA256, err = downloader.HashFile(unlisted[i].Path); err != nil {
+				return fmt.Errorf("Could not hash %s: %w", unlisted[i].Path, err)
+			}
+		}
+		if err := downloader.RecordChecksums(dir, unlisted); err != nil {
+			return fmt.Errorf("Could not write %s: %w", mf, err)
+		}
+		added, unlisted = len(unlisted), nil
+	}
+
+	log.LogInfo("verify", fmt.Sprintf("dir=%s ok=%d mismatch=%d missing=%d unlisted=%d added=%d", dir, ok, bad, missing, len(unlisted), added))
+	if r0.Mode != ModeQuiet {
+		if added > 0 {
+			utils.PrintInfo("Added %d files to %s", added, mf)
+		}
+		if len(unlisted) > 0 {
+			utils.PrintWarn("%d files are not in %s (use -update to add them)", len(unlisted), paths.ManifestFile)
+		}
+	}
+	if bad > 0 || missing > 0 {
+		return fmt.Errorf("%d of %d files failed verification (%d changed, %d missing)", bad+missing, ok+bad+missing, bad, missing)
+	}
+	if r0.Mode != ModeQuiet {
+		utils.PrintSuccess("%d files verified in %s", ok+added, dir)
+	}
+	return nil
+}
+
+func (p *Pipeline) checksums(dir string, cp *downloader.Checkpoint) {
+	if p.r0.Pack != nil {
+		return
+	}
+	if err := downloader.RecordChecksums(dir, cp.DoneItems()); err != nil {
+		log.LogError("verify", "manifest update failed: "+err.Error())
+	}
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	goruntime "runtime"
+	"runtime/debug"
+	"sort"
+
+	"github.com/ghostlawless/xdl/internal/httpx"
+	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+// Version is set at release time with
+// -ldflags "-X github.com/ghostlawless/xdl/internal/app.Version=v1.2.3".
+var Version = ""
+
+type buildInfo struct {
+	Version  string
+	Commit   string
+	Built    string
+	Modified bool
+}
+
+func readBuildInfo() buildInfo {
+	b := buildInfo{Version: Version}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		if b.Version == "" {
+			b.Version = "dev"
+		}
+		return b
+	}
+	if b.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		b.Version = bi.Main.Version
+	}
+	if b.Version == "" {
+		b.Version = "dev"
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			b.Commit = s.Value
+			if len(b.Commit) > 12 {
+				b.Commit = b.Commit[:12]
+			}
+		case "vcs.time":
+			b.Built = s.Value
+		case "vcs.modified":
+			b.Modified = s.Value == "true"
+		}
+	}
+	return b
+}
+
+func probeVerdict(st int) string {
+	switch {
+	case st == 0:
+		return "network error"
+	case st == 200:
+		return "ok"
+	case st == 401, st == 403:
+		return "session rejected, check cookies"
+	case st == 404:
+		return "queryId is stale"
+	case st == 400, st == 422:
+		return "request rejected, features or variables changed"
+	case st == 429:
+		return "rate limited"
+	case st >= 500:
+		return "X server error"
+	}
+	return "unexpected status"
+}
+
+// probeCause drops the request URL, which holds the whole feature list.
+func probeCause(err error) error {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		return ue.Err
+	}
+	return err
+}
+
+func runVersionCommand(args []string, runID string, runSeed []byte) error {
+	var check bool
+	r0, _, err := parseCommandArgs("version", args, runID, runSeed, func(fs *flag.FlagSet) {
+		fs.BoolVar(&check, "check", false, "Also send one request for each configured GraphQL operation and print its status")
+	})
+	if err != nil {
+		return err
+	}
+
+	b := readBuildInfo()
+	commit := b.Commit
+	if commit == "" {
+		commit = "unknown"
+	} else if b.Modified {
+		commit += " (modified)"
+	}
+	fmt.Fprintf(utils.Stdout, "xdl %s\ncommit:  %s\n", b.Version, commit)
+	if b.Built != "" {
+		fmt.Fprintf(utils.Stdout, "built:   %s\n", b.Built)
+	}
+	fmt.Fprintf(utils.Stdout, "go:      %s %s/%s\n", goruntime.Version(), goruntime.GOOS, goruntime.GOARCH)
+	if !check {
+		return nil
+	}
+
+	c0, err := loadSession(r0)
+	if err != nil {
+		return err
+	}
+	h0 := httpx.NewAPIClient(c0.HTTPTimeout(), c0.HeaderOrder)
+	names := c0.OperationNames()
+	ks := make([]string, 0, len(names))
+	for k := range names {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+
+	fmt.Fprintf(utils.Stdout, "\nsession: %s\n", c0.Source())
+	var es []error
+	for _, k := range ks {
+		st, e0 := scraper.ProbeOperation(h0, c0, k)
+		v := probeVerdict(st)
+		if st == 0 && e0 != nil {
+			v += ": " + probeCause(e0).Error()
+		}
+		fmt.Fprintf(utils.Stdout, "%-24s %3d  %s\n", names[k], st, v)
+		if e0 != nil {
+			es = append(es, fmt.Errorf("%s: %w", names[k], e0))
+		}
+	}
+	if len(es) == 0 {
+		return nil
+	}
+	return withHint(errors.Join(es...), "%d of %d GraphQL operations failed; see the status column above.", len(es), len(ks))
+}
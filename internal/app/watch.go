@@ -0,0 +1,128 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	goruntime "runtime"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/errs"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+func (p *Pipeline) watch() error {
+	r0 := p.r0
+	stopStats := startRuntimeStats(time.Minute)
+	defer stopStats()
+
+	if r0.NoAuth {
+		log.LogInfo("watch", "canary skipped without a session")
+	} else if e0 := canaryErr(runCanary(p.c0, p.api)); errors.Is(e0, errs.ErrShapeChanged) {
+		utils.PrintWarn("Canary check: X response shape changed, update needed (%v)", e0)
+	}
+
+	ws := newWatchScheduler(r0, time.Now())
+	defer p.recordSchedule(nil)
+	for cycle := 1; ; cycle++ {
+		log.LogInfo("watch", fmt.Sprintf("cycle %d start", cycle))
+
+		r1 := r0
+		var ids bool
+		r1.Users, ids = ws.due(time.Now())
+		if !ids {
+			r1.IDsFile = ""
+		}
+		rep := p.with(r1).collect()
+		p.writeFeeds(rep)
+		err := rep.Err()
+		if globalControl.ShouldQuit() || globalShutdown.Draining() || errors.Is(err, downloader.ErrAborted) || errors.Is(err, ErrQuotaReached) {
+			return err
+		}
+		if err != nil {
+			log.LogError("watch", fmt.Sprintf("cycle %d: %v", cycle, err))
+			r0.ui().warn("Cycle %d finished with errors: %v", cycle, err)
+		}
+		for _, u := range ws.update(time.Now(), r1.Users, ids, rep) {
+			log.LogInfo("watch", "burst started for @"+u)
+			r0.ui().info("New media from @%s; checking more often for a while", u)
+		}
+
+		p.recordSchedule(ws)
+		st := readRuntimeStats()
+		d0 := ws.wait(time.Now())
+		log.LogInfo("watch", fmt.Sprintf("cycle %d done; next in %s; %s", cycle, d0.Round(time.Second), st))
+		r0.ui().info("Next run in %s (%s)", d0.Round(time.Second), st)
+
+		if !sleepWithControls(d0) {
+			return errStoppedByUser
+		}
+	}
+}
+
+func sleepWithControls(d time.Duration) bool {
+	return sleepFor("", d)
+}
+
+// sleepFor waits like sleepWithControls on behalf of who, which keeps the
+// watchdog from counting the wait as a stall and ends it early once who is
+// stopped.
+func sleepFor(who string, d time.Duration) bool {
+	defer globalWatchdog.sleep(who)()
+	end := time.Now().Add(d)
+	for time.Now().Before(end) {
+		if globalControl.ShouldQuit() || globalShutdown.Draining() || globalWatchdog.reason(who) != nil {
+			return false
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return !globalControl.ShouldQuit() && !globalShutdown.Draining()
+}
+
+type runtimeStats struct {
+	HeapAlloc  uint64
+	HeapInuse  uint64
+	Sys        uint64
+	NumGC      uint32
+	Goroutines int
+}
+
+func (s runtimeStats) String() string {
+	return fmt.Sprintf("heap=%.1fMB inuse=%.1fMB sys=%.1fMB gc=%d goroutines=%d",
+		float64(s.HeapAlloc)/1024/1024,
+		float64(s.HeapInuse)/1024/1024,
+		float64(s.Sys)/1024/1024,
+		s.NumGC,
+		s.Goroutines,
+	)
+}
+
+func readRuntimeStats() runtimeStats {
+	var m goruntime.MemStats
+	goruntime.ReadMemStats(&m)
+	return runtimeStats{
+		HeapAlloc:  m.HeapAlloc,
+		HeapInuse:  m.HeapInuse,
+		Sys:        m.Sys,
+		NumGC:      m.NumGC,
+		Goroutines: goruntime.NumGoroutine(),
+	}
+}
+
+func startRuntimeStats(every time.Duration) func() {
+	stop := make(chan struct{})
+	go func() {
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				log.LogInfo("runtime", readRuntimeStats().String())
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/log"
+)
+
+const (
+	defaultStallTimeout = 30 * time.Minute
+	watchdogTick        = 5 * time.Second
+)
+
+var (
+	errUserTimeout = errors.New("user timeout")
+	errStalled     = errors.New("no progress")
+)
+
+// watchdogEntry is one user's watch. ctx is cancelled with the reason once
+// -user-timeout or -stall-timeout runs out; progress moves the stall deadline
+// on. sleeping counts that user's waits on a rate limit, a cooldown or a
+// -confirm prompt, which do not count as stalled.
+type watchdogEntry struct {
+	ctx      context.Context
+	cancel   context.CancelCauseFunc
+	start    time.Time
+	seen     time.Time
+	what     string
+	sleeping int
+	limit    *time.Timer
+	stall    *time.Timer
+}
+
+type userWatchdog struct {
+	mu sync.Mutex
+	m  map[string]*watchdogEntry
+}
+
+var globalWatchdog = &userWatchdog{m: map[string]*watchdogEntry{}}
+
+func (w *userWatchdog) begin(u string, timeout, stall time.Duration) func() {
+	k := strings.ToLower(u)
+	now := time.Now()
+	ctx, cancel := context.WithCancelCause(context.Background())
+	e := &watchdogEntry{ctx: ctx, cancel: cancel, start: now, seen: now, what: "looking up the account"}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.m[k] = e
+	if timeout > 0 {
+		e.limit = time.AfterFunc(timeout, func() { w.expire(e, u, timeout) })
+	}
+	if stall > 0 {
+		e.stall = time.AfterFunc(stall, func() { w.stalled(e, u, stall) })
+	}
+	return func() { w.end(k, e) }
+}
+
+func (w *userWatchdog) end(k string, e *watchdogEntry) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if e.limit != nil {
+		e.limit.Stop()
+	}
+	if e.stall != nil {
+		e.stall.Stop()
+	}
+	e.cancel(context.Canceled)
+	if w.m[k] == e {
+		delete(w.m, k)
+	}
+}
+
+func (w *userWatchdog) expire(e *watchdogEntry, u string, timeout time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if e.ctx.Err() != nil {
+		return
+	}
+	if globalControl.ShouldPause() {
+		e.limit.Reset(watchdogTick)
+		return
+	}
+	w.stop(e, withHint(errUserTimeout, "@%s hit -user-timeout %s while %s; stopped so the other users can finish. Progress was saved; the next run resumes.", u, timeout, e.what))
+}
+
+func (w *userWatchdog) stalled(e *watchdogEntry, u string, stall time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if e.ctx.Err() != nil {
+		return
+	}
+	now := time.Now()
+	switch {
+	case globalControl.ShouldPause():
+		e.seen = now
+		e.stall.Reset(watchdogTick)
+		return
+	case e.sleeping > 0:
+		e.seen = now
+		e.stall.Reset(stall)
+		return
+	}
+	if d := e.seen.Add(stall).Sub(now); d > 0 {
+		e.stall.Reset(d)
+		return
+	}
+	w.stop(e, withHint(errStalled, "@%s made no progress for %s (last: %s); stopped. Check the connection, or raise -stall-timeout.", u, stall, e.what))
+}
+
+func (w *userWatchdog) stop(e *watchdogEntry, err error) {
+	log.LogError("watchdog", err.Error())
+	e.cancel(err)
+}
+
+func (w *userWatchdog) touch(u, what string) {
+	w.mu.Lock()
+	if e := w.m[strings.ToLower(u)]; e != nil {
+		e.seen, e.what = time.Now(), what
+	}
+	w.mu.Unlock()
+}
+
+// sleep marks u as waiting until the returned func is called. The stall
+// window starts over once the wait ends.
+func (w *userWatchdog) sleep(u string) func() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	e := w.m[strings.ToLower(u)]
+	if e == nil {
+		return func() {}
+	}
+	e.sleeping++
+	return func() {
+		w.mu.Lock()
+		e.sleeping--
+		e.seen = time.Now()
+		w.mu.Unlock()
+	}
+}
+
+func (w *userWatchdog) reason(u string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if e := w.m[strings.ToLower(u)]; e != nil && e.ctx.Err() != nil {
+		return context.Cause(e.ctx)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWatchdogStall(t *testing.T) {
+	const stall = 150 * time.Millisecond
+	w := &userWatchdog{m: map[string]*watchdogEntry{}}
+	defer w.begin("Busy", 0, stall)()
+	defer w.begin("Waiting", 0, stall)()
+	defer w.begin("Idle", 0, stall)()
+
+	wake := w.sleep("waiting")
+	for i := 0; i < 5; i++ {
+		time.Sleep(stall / 2)
+		w.touch("busy", "scanning")
+	}
+	if err := w.reason("busy"); err != nil {
+		t.Errorf("busy: %v", err)
+	}
+	if err := w.reason("waiting"); err != nil {
+		t.Errorf("a wait on one user counted as a stall: %v", err)
+	}
+	if err := w.reason("idle"); !errors.Is(err, errStalled) {
+		t.Errorf("idle: err = %v, want a stall", err)
+	}
+
+	// The stall window starts over once the wait ends.
+	wake()
+	time.Sleep(stall / 2)
+	if err := w.reason("waiting"); err != nil {
+		t.Errorf("waiting stalled right after the wait: %v", err)
+	}
+	time.Sleep(2 * stall)
+	if err := w.reason("waiting"); !errors.Is(err, errStalled) {
+		t.Errorf("waiting: err = %v, want a stall", err)
+	}
+}
+
+func TestWatchdogUserTimeout(t *testing.T) {
+	w := &userWatchdog{m: map[string]*watchdogEntry{}}
+	end := w.begin("nasa", 50*time.Millisecond, 0)
+	w.touch("nasa", "downloading")
+	time.Sleep(150 * time.Millisecond)
+	if err := w.reason("NASA"); !errors.Is(err, errUserTimeout) {
+		t.Errorf("err = %v, want a user timeout", err)
+	}
+	end()
+	if err := w.reason("nasa"); err != nil {
+		t.Errorf("after end: %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package archive
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+const idListCategory = "twitter"
+
+type IDList struct {
+	path string
+	mu   sync.Mutex
+	ids  map[string]struct{}
+	f    *os.File
+}
+
+func OpenIDList(path string) (*IDList, error) {
+	l := &IDList{path: path, ids: map[string]struct{}{}}
+	if f, err := os.Open(path); err == nil {
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if id := parseIDLine(sc.Text()); id != "" {
+				l.ids[id] = struct{}{}
+			}
+		}
+		err = sc.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := utils.EnsureDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l.f = f
+	return l, nil
+}
+
+func parseIDLine(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= len(idListCategory) || !strings.EqualFold(s[:len(idListCategory)], idListCategory) {
+		return ""
+	}
+	s = strings.TrimSpace(s[len(idListCategory):])
+	if i := strings.IndexByte(s, '_'); i >= 0 {
+		s = s[:i]
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return ""
+		}
+	}
+	return s
+}
+
+func (l *IDList) Path() string { return l.path }
+
+func (l *IDList) Len() int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.ids)
+}
+
+func (l *IDList) Has(id string) bool {
+	if l == nil || id == "" {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.ids[id]
+	return ok
+}
+
+func (l *IDList) Add(id string) error {
+	if l == nil || id == "" {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.ids[id]; ok {
+		return nil
+	}
+	if l.f == nil {
+		return fmt.Errorf("%s is closed", l.path)
+	}
+	if _, err := fmt.Fprintf(l.f, "%s %s\n", idListCategory, id); err != nil {
+		return err
+	}
+	l.ids[id] = struct{}{}
+	return nil
+}
+
+func (l *IDList) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
@@ -0,0 +1,385 @@
+package archive
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/audit"
+	"github.com/ghostlawless/xdl/internal/mediaurl"
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+const storeVersion = 1
+
+type UserRecord struct {
+	ID        string         `json:"id"`
+	Handle    string         `json:"handle"`
+	Handles   []string       `json:"handles,omitempty"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	Avatars   []ProfileImage `json:"avatars,omitempty"`
+	Banners   []ProfileImage `json:"banners,omitempty"`
+}
+
+type ProfileImage struct {
+	URL    string    `json:"url"`
+	Path   string    `json:"path,omitempty"`
+	SeenAt time.Time `json:"seen_at"`
+}
+
+type MediaRecord struct {
+	UserID  string    `json:"user_id"`
+	Handle  string    `json:"handle"`
+	TweetID string    `json:"tweet_id,omitempty"`
+	URL     string    `json:"url"`
+	Type    string    `json:"type,omitempty"`
+	Path    string    `json:"path,omitempty"`
+	SHA256  string    `json:"sha256,omitempty"`
+	Size    int64     `json:"size"`
+	MIME    string    `json:"mime,omitempty"`
+	AltText string    `json:"alt_text,omitempty"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+type ImportRecord struct {
+	TweetID string    `json:"tweet_id"`
+	Handle  string    `json:"handle,omitempty"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+type storeData struct {
+	Version  int                      `json:"version"`
+	Users    map[string]*UserRecord   `json:"users"`
+	Media    map[string]*MediaRecord  `json:"media,omitempty"`
+	Imported map[string]*ImportRecord `json:"imported,omitempty"`
+}
+
+type Store struct {
+	path string
+
+	mu     sync.Mutex
+	data   storeData
+	dirty  bool
+	tweets map[string]struct{}
+}
+
+const fileName = "archive.json"
+
+func DefaultPath(outRoot string) string {
+	return paths.New(outRoot).StateFile(fileName)
+}
+
+func Open(path string) (*Store, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, errors.New("empty archive path")
+	}
+	s := &Store{path: path}
+	s.data.Version = storeVersion
+	s.data.Users = make(map[string]*UserRecord)
+	s.data.Media = make(map[string]*MediaRecord)
+
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if lk, err := utils.LockShared(path); err == nil {
+		defer lk.Unlock()
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if len(b) == 0 {
+		return s, nil
+	}
+	if err := json.Unmarshal(b, &s.data); err != nil {
+		return nil, err
+	}
+	if s.data.Users == nil {
+		s.data.Users = make(map[string]*UserRecord)
+	}
+	if s.data.Media == nil {
+		s.data.Media = make(map[string]*MediaRecord)
+	}
+	for k, m := range s.data.Media {
+		if c := mediaurl.Photo(k); c != k {
+			delete(s.data.Media, k)
+			if _, ok := s.data.Media[c]; !ok {
+				m.URL = c
+				s.data.Media[c] = m
+			}
+		}
+	}
+	return s, nil
+}
+
+func (s *Store) Import(r ImportRecord) bool {
+	if s == nil || r.Path == "" || r.TweetID == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Imported == nil {
+		s.data.Imported = make(map[string]*ImportRecord)
+	}
+	if _, ok := s.data.Imported[r.Path]; ok {
+		return false
+	}
+	if r.AddedAt.IsZero() {
+		r.AddedAt = time.Now().UTC()
+	}
+	s.data.Imported[r.Path] = &r
+	if s.tweets != nil {
+		s.tweets[r.TweetID] = struct{}{}
+	}
+	s.dirty = true
+	audit.Record(audit.Event{Action: audit.StoreImport, Path: r.Path, User: r.Handle, Size: r.Size, Detail: "tweet=" + r.TweetID})
+	return true
+}
+
+func (s *Store) ImportedTweet(id string) bool {
+	if s == nil || id == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tweets == nil {
+		s.tweets = make(map[string]struct{}, len(s.data.Imported))
+		for _, r := range s.data.Imported {
+			s.tweets[r.TweetID] = struct{}{}
+		}
+	}
+	_, ok := s.tweets[id]
+	return ok
+}
+
+func (s *Store) Root() string {
+	if s == nil {
+		return ""
+	}
+	return filepath.Dir(filepath.Dir(s.path))
+}
+
+func (s *Store) Path() string {
+	if s == nil {
+		return ""
+	}
+	return s.path
+}
+
+func (s *Store) Save() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	b, err := json.MarshalIndent(&s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	lk, err := utils.LockExclusive(s.path)
+	if err != nil {
+		return err
+	}
+	err = utils.SaveToFile(s.path, b)
+	lk.Unlock()
+	if err != nil {
+		return err
+	}
+	s.dirty = false
+	audit.File(audit.StoreSave, s.path, "")
+	return nil
+}
+
+func (s *Store) RememberUser(id, handle string) {
+	if s == nil || id == "" || handle == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.data.Users[id]
+	if !ok {
+		r = &UserRecord{ID: id}
+		s.data.Users[id] = r
+	}
+	if r.Handle == handle {
+		return
+	}
+	if r.Handle != "" && !containsFold(r.Handles, r.Handle) {
+		r.Handles = append(r.Handles, r.Handle)
+	}
+	r.Handle = handle
+	r.UpdatedAt = time.Now().UTC()
+	s.dirty = true
+	audit.Record(audit.Event{Action: audit.StoreUser, User: handle, Detail: "id=" + id})
+}
+
+func (s *Store) LookupHandle(handle string) (UserRecord, bool) {
+	if s == nil || handle == "" {
+		return UserRecord{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.data.Users {
+		if strings.EqualFold(r.Handle, handle) || containsFold(r.Handles, handle) {
+			return *r, true
+		}
+	}
+	return UserRecord{}, false
+}
+
+func (s *Store) AddMedia(m MediaRecord) bool {
+	if s == nil || m.URL == "" {
+		return false
+	}
+	m.URL = mediaurl.Photo(m.URL)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data.Media[m.URL]; ok {
+		return false
+	}
+	if m.AddedAt.IsZero() {
+		m.AddedAt = time.Now().UTC()
+	}
+	s.data.Media[m.URL] = &m
+	s.dirty = true
+	audit.Record(audit.Event{Action: audit.StoreMedia, Path: m.Path, URL: m.URL, User: m.Handle, SHA256: m.SHA256, Size: m.Size})
+	return true
+}
+
+func (s *Store) HasMedia(url string) bool {
+	if s == nil || url == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.data.Media[mediaurl.Photo(url)]
+	return ok
+}
+
+func (s *Store) AllMedia() []MediaRecord {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]MediaRecord, 0, len(s.data.Media))
+	for _, m := range s.data.Media {
+		out = append(out, *m)
+	}
+	return out
+}
+
+func (s *Store) MediaSince(user string, since time.Time) (UserRecord, []MediaRecord, bool) {
+	if s == nil || user == "" {
+		return UserRecord{}, nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var u *UserRecord
+	if r, ok := s.data.Users[user]; ok {
+		u = r
+	} else {
+		for _, r := range s.data.Users {
+			if strings.EqualFold(r.Handle, user) || containsFold(r.Handles, user) {
+				u = r
+				break
+			}
+		}
+	}
+	if u == nil {
+		return UserRecord{}, nil, false
+	}
+	var out []MediaRecord
+	for _, m := range s.data.Media {
+		if m.UserID == u.ID && m.AddedAt.After(since) {
+			out = append(out, *m)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].AddedAt.Equal(out[j].AddedAt) {
+			return out[i].URL < out[j].URL
+		}
+		return out[i].AddedAt.Before(out[j].AddedAt)
+	})
+	return *u, out, true
+}
+
+func (s *Store) LatestProfileImage(id, kind string) (ProfileImage, bool) {
+	if s == nil || id == "" {
+		return ProfileImage{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.data.Users[id]
+	if !ok {
+		return ProfileImage{}, false
+	}
+	h := r.profileImages(kind)
+	if h == nil || len(*h) == 0 {
+		return ProfileImage{}, false
+	}
+	return (*h)[len(*h)-1], true
+}
+
+func (s *Store) AddProfileImage(id, kind string, img ProfileImage) bool {
+	if s == nil || id == "" || img.URL == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.data.Users[id]
+	if !ok {
+		r = &UserRecord{ID: id}
+		s.data.Users[id] = r
+	}
+	h := r.profileImages(kind)
+	if h == nil {
+		return false
+	}
+	if n := len(*h); n > 0 && (*h)[n-1].URL == img.URL {
+		return false
+	}
+	if img.SeenAt.IsZero() {
+		img.SeenAt = time.Now().UTC()
+	}
+	*h = append(*h, img)
+	s.dirty = true
+	audit.Record(audit.Event{Action: audit.StoreImage, Path: img.Path, URL: img.URL, User: r.Handle, Detail: kind})
+	return true
+}
+
+func (r *UserRecord) profileImages(kind string) *[]ProfileImage {
+	switch kind {
+	case "avatar":
+		return &r.Avatars
+	case "banner":
+		return &r.Banners
+	}
+	return nil
+}
+
+func containsFold(xs []string, v string) bool {
+	for _, x := range xs {
+		if strings.EqualFold(x, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	FileWrite   = "file.write"
+	FileReplace = "file.replace"
+	FileAppend  = "file.append"
+	StoreMedia  = "store.media"
+	StoreUser   = "store.user"
+	StoreImage  = "store.profile_image"
+	StoreSave   = "store.save"
+	StoreImport = "store.import"
+)
+
+type Event struct {
+	At     time.Time `json:"at"`
+	RunID  string    `json:"run_id,omitempty"`
+	Action string    `json:"action"`
+	Path   string    `json:"path,omitempty"`
+	From   string    `json:"from,omitempty"`
+	URL    string    `json:"url,omitempty"`
+	User   string    `json:"user,omitempty"`
+	SHA256 string    `json:"sha256,omitempty"`
+	Size   int64     `json:"size,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+type Sink interface {
+	Record(Event) error
+	Close() error
+}
+
+var (
+	mu    sync.RWMutex
+	sink  Sink
+	runID string
+)
+
+func SetSink(s Sink, run string) {
+	mu.Lock()
+	defer mu.Unlock()
+	sink, runID = s, run
+}
+
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return sink != nil
+}
+
+func Record(e Event) {
+	mu.RLock()
+	s, run := sink, runID
+	mu.RUnlock()
+	if s == nil {
+		return
+	}
+	if e.At.IsZero() {
+		e.At = time.Now().UTC()
+	}
+	if e.RunID == "" {
+		e.RunID = run
+	}
+	_ = s.Record(e)
+}
+
+func File(action, path, url string) {
+	if !Enabled() {
+		return
+	}
+	e := Event{Action: action, Path: path, URL: url}
+	if st, err := os.Stat(path); err == nil {
+		e.Size = st.Size()
+	}
+	e.SHA256 = HashFile(path)
+	Record(e)
+}
+
+func HashFile(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func Close() {
+	mu.Lock()
+	s := sink
+	sink = nil
+	mu.Unlock()
+	if s != nil {
+		_ = s.Close()
+	}
+}
+
+type FileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func OpenFile(path string) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{f: f}, nil
+}
+
+func (s *FileSink) Record(e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(append(b, '\n'))
+	return err
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.f.Sync(); err != nil {
+		_ = s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/httpx"
+)
+
+type Provider interface {
+	Name() string
+	Apply(cfg *config.EssentialsConfig) (string, error)
+}
+
+type Static struct{}
+
+func (Static) Name() string { return "static" }
+
+func (Static) Apply(cfg *config.EssentialsConfig) (string, error) {
+	if strings.TrimSpace(cfg.Auth.Cookies.AuthToken) == "" || strings.TrimSpace(cfg.Auth.Cookies.Ct0) == "" {
+		return "", config.ErrCookieFileMissing
+	}
+	cfg.UseEssentialsSession()
+	return "essentials.json", nil
+}
+
+type Env struct{}
+
+func (Env) Name() string { return "env" }
+
+func (Env) Apply(cfg *config.EssentialsConfig) (string, error) {
+	ok, err := config.ApplyEnvCookies(cfg)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", config.ErrCookieFileMissing
+	}
+	return "XDL_*", nil
+}
+
+type CookieFile struct {
+	Path string
+}
+
+func (CookieFile) Name() string { return "cookie-file" }
+
+func (p CookieFile) Apply(cfg *config.EssentialsConfig) (string, error) {
+	return config.ApplyCookiesFromFileSource(cfg, p.Path)
+}
+
+type Guest struct {
+	Client *http.Client
+}
+
+func (Guest) Name() string { return "guest" }
+
+func (g Guest) Apply(cfg *config.EssentialsConfig) (string, error) {
+	cl := g.Client
+	if cl == nil {
+		cl = httpx.NewAPIClient(cfg.HTTPTimeout(), cfg.HeaderOrder)
+	}
+	if err := cfg.StartGuestSession(cl); err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+type Pool struct {
+	Dirs []string
+
+	mu   sync.Mutex
+	next int
+}
+
+func (*Pool) Name() string { return "account-pool" }
+
+func (p *Pool) files() []string {
+	var out []string
+	for _, d := range p.Dirs {
+		for _, pat := range []string{"*.json", "*.txt"} {
+			m, _ := filepath.Glob(filepath.Join(d, pat))
+			out = append(out, m...)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (p *Pool) Apply(cfg *config.EssentialsConfig) (string, error) {
+	fs := p.files()
+	if len(fs) == 0 {
+		return "", config.ErrCookieFileMissing
+	}
+	p.mu.Lock()
+	start := p.next
+	p.next++
+	p.mu.Unlock()
+
+	var last error
+	for i := 0; i < len(fs); i++ {
+		f := fs[(start+i)%len(fs)]
+		src, err := config.ApplyCookiesFromFileSource(cfg, f)
+		if err == nil {
+			return src, nil
+		}
+		last = err
+	}
+	return "", last
+}
+
+func DefaultPoolDirs() []string {
+	dirs := []string{filepath.Join("config", "accounts")}
+	if p, err := os.Executable(); err == nil {
+		if rp, err := filepath.EvalSymlinks(p); err == nil {
+			p = rp
+		}
+		d := filepath.Join(filepath.Dir(p), "accounts")
+		dirs = append([]string{d}, dirs...)
+	}
+	return dirs
+}
+
+func Resolve(cfg *config.EssentialsConfig, ps ...Provider) (string, error) {
+	if cfg == nil {
+		return "", errors.New("nil config")
+	}
+	var first, last error
+	for _, p := range ps {
+		if p == nil {
+			continue
+		}
+		saved := cfg.Auth.Cookies
+		src, err := p.Apply(cfg)
+		if err == nil && cfg.Auth.GuestToken == "" {
+			err = cfg.ValidateRequiredCookies(src)
+		}
+		if err == nil {
+			name := p.Name()
+			if strings.TrimSpace(src) != "" {
+				name += " (" + src + ")"
+			}
+			cfg.Auth.Provider = name
+			return name, nil
+		}
+		cfg.Auth.Cookies = saved
+		if first == nil && err != config.ErrCookieFileMissing {
+			first = err
+		}
+		last = err
+	}
+	if first != nil {
+		return "", first
+	}
+	if last != nil {
+		return "", last
+	}
+	return "", config.ErrCookieFileMissing
+}
@@ -7,12 +7,15 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ghostlawless/xdl/internal/httpx"
+	"github.com/ghostlawless/xdl/internal/utils"
 )
 
 //go:embed defaults/essentials.json
@@ -24,6 +27,16 @@ type GraphQLOperation struct {
 	Path string `json:"path"`
 }
 
+func (op GraphQLOperation) operationName() string {
+	if op.Name != "" {
+		return op.Name
+	}
+	if i := strings.LastIndex(op.Path, "/"); i >= 0 {
+		return op.Path[i+1:]
+	}
+	return ""
+}
+
 type GraphQLSection struct {
 	Operations map[string]GraphQLOperation `json:"operations"`
 }
@@ -35,8 +48,11 @@ type AuthCookies struct {
 }
 
 type AuthSection struct {
-	Bearer  string      `json:"bearer"`
-	Cookies AuthCookies `json:"cookies"`
+	Bearer     string      `json:"bearer"`
+	Cookies    AuthCookies `json:"cookies"`
+	Provider   string      `json:"-"`
+	GuestToken string      `json:"-"`
+	guestAt    time.Time
 }
 
 type FeaturesSection struct {
@@ -52,10 +68,50 @@ type PathsSection struct {
 }
 
 type RuntimeSection struct {
-	DebugEnabled   bool   `json:"debug_enabled"`
-	TimeoutSeconds int    `json:"timeout_seconds"`
-	MaxRetries     int    `json:"max_retries"`
-	LimiterSecret  string `json:"limiter_secret"`
+	DebugEnabled         bool   `json:"debug_enabled"`
+	TimeoutSeconds       int    `json:"timeout_seconds"`
+	MaxRetries           int    `json:"max_retries"`
+	LimiterSecret        string `json:"limiter_secret"`
+	ShutdownGraceSeconds int    `json:"shutdown_grace_seconds,omitempty"`
+	ProgressRefreshMS    int    `json:"progress_refresh_ms,omitempty"`
+	PageSize             int    `json:"page_size,omitempty"`
+	MaxPages             int    `json:"max_pages,omitempty"`
+	MaxTweets            int    `json:"max_tweets,omitempty"`
+	MinDelayMS           int    `json:"min_delay_ms,omitempty"`
+	MaxDelayMS           int    `json:"max_delay_ms,omitempty"`
+	Concurrency          int    `json:"concurrency,omitempty"`
+}
+
+type LoggingSection struct {
+	Level   string            `json:"level,omitempty"`
+	Format  string            `json:"format,omitempty"`
+	Modules map[string]string `json:"modules,omitempty"`
+}
+
+type StorageSection struct {
+	SoftQuotaMB int64 `json:"soft_quota_mb,omitempty"`
+	WarnAt      []int `json:"warn_at,omitempty"`
+}
+
+type ExternalSection struct {
+	Hosts   []string `json:"hosts,omitempty"`
+	Command []string `json:"command,omitempty"`
+}
+
+type TransportSection struct {
+	MaxConnsPerHost     int    `json:"max_conns_per_host,omitempty"`
+	MaxIdleConnsPerHost int    `json:"max_idle_conns_per_host,omitempty"`
+	HTTP2               *bool  `json:"http2,omitempty"`
+	KeepAliveSeconds    int    `json:"keepalive_seconds,omitempty"`
+	IPVersion           int    `json:"ip_version,omitempty"`
+	Proxy               string `json:"proxy,omitempty"`
+}
+
+type RetryPolicy struct {
+	Attempts       int `json:"attempts,omitempty"`
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+	BackoffMS      int `json:"backoff_ms,omitempty"`
+	MaxBackoffMS   int `json:"max_backoff_ms,omitempty"`
 }
 
 type XSection struct {
@@ -63,13 +119,29 @@ type XSection struct {
 }
 
 type EssentialsConfig struct {
-	X        XSection          `json:"x,omitempty"`
-	GraphQL  GraphQLSection    `json:"graphql"`
-	Auth     AuthSection       `json:"auth"`
-	Headers  map[string]string `json:"headers"`
-	Features FeaturesSection   `json:"features"`
-	Paths    PathsSection      `json:"paths"`
-	Runtime  RuntimeSection    `json:"runtime"`
+	X           XSection               `json:"x,omitempty"`
+	GraphQL     GraphQLSection         `json:"graphql"`
+	Auth        AuthSection            `json:"auth"`
+	Headers     map[string]string      `json:"headers"`
+	HeaderOrder []string               `json:"header_order,omitempty"`
+	Profile     string                 `json:"header_profile,omitempty"`
+	TLS         string                 `json:"tls_fingerprint,omitempty"`
+	Features    FeaturesSection        `json:"features"`
+	Paths       PathsSection           `json:"paths"`
+	Runtime     RuntimeSection         `json:"runtime"`
+	Logging     LoggingSection         `json:"logging,omitempty"`
+	Storage     StorageSection         `json:"storage,omitempty"`
+	Transport   TransportSection       `json:"transport,omitempty"`
+	MediaHosts  map[string]string      `json:"media_hosts,omitempty"`
+	External    ExternalSection        `json:"external,omitempty"`
+	Retry       map[string]RetryPolicy `json:"retry,omitempty"`
+	source      string
+
+	// sessionFile is where a rotated ct0 is written back: the essentials
+	// file or the browser cookie file the session came from. Sessions from
+	// the environment leave it empty and keep the new ct0 in memory.
+	sessionFile string
+	cookieFile  bool
 }
 
 func LoadEssentialsWithFallback(paths []string) (*EssentialsConfig, error) {
@@ -105,6 +177,12 @@ func LoadEssentialsWithFallback(paths []string) (*EssentialsConfig, error) {
 }
 
 func loadEssentialsFromPath(path string) (*EssentialsConfig, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	if lk, err := utils.LockShared(path); err == nil {
+		defer lk.Unlock()
+	}
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -114,9 +192,14 @@ func loadEssentialsFromPath(path string) (*EssentialsConfig, error) {
 		return nil, fmt.Errorf("failed to parse essentials.json: %w", err)
 	}
 	cfg.X.Network = normalizeNetwork(cfg.X.Network)
+	cfg.source = path
 	return &cfg, nil
 }
 
+func (c *EssentialsConfig) Source() string {
+	return c.source
+}
+
 func normalizeNetwork(network string) string {
 	if strings.TrimSpace(network) == "" {
 		return "https://x.com"
@@ -134,10 +217,169 @@ func (c *EssentialsConfig) HTTPTimeout() time.Duration {
 	return time.Duration(c.Runtime.TimeoutSeconds) * time.Second
 }
 
+func (c *EssentialsConfig) ShutdownGrace() time.Duration {
+	if c == nil || c.Runtime.ShutdownGraceSeconds <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.Runtime.ShutdownGraceSeconds) * time.Second
+}
+
+func (c *EssentialsConfig) ProgressRefresh() time.Duration {
+	if c == nil || c.Runtime.ProgressRefreshMS <= 0 {
+		return 100 * time.Millisecond
+	}
+	return time.Duration(c.Runtime.ProgressRefreshMS) * time.Millisecond
+}
+
+func (c *EssentialsConfig) PacingBounds() (time.Duration, time.Duration) {
+	lo, hi := 150*time.Millisecond, 30*time.Second
+	if c == nil {
+		return lo, hi
+	}
+	if c.Runtime.MinDelayMS > 0 {
+		lo = time.Duration(c.Runtime.MinDelayMS) * time.Millisecond
+	}
+	if c.Runtime.MaxDelayMS > 0 {
+		hi = time.Duration(c.Runtime.MaxDelayMS) * time.Millisecond
+	}
+	return lo, hi
+}
+
+func (c *EssentialsConfig) TransportOptions() httpx.TransportOptions {
+	if c == nil {
+		return httpx.TransportOptions{}
+	}
+	t := c.Transport
+	o := httpx.TransportOptions{
+		MaxConnsPerHost:     t.MaxConnsPerHost,
+		MaxIdleConnsPerHost: t.MaxIdleConnsPerHost,
+		DisableHTTP2:        t.HTTP2 != nil && !*t.HTTP2,
+		Proxy:               strings.TrimSpace(t.Proxy),
+	}
+	switch t.IPVersion {
+	case 4:
+		o.Network = "tcp4"
+	case 6:
+		o.Network = "tcp6"
+	}
+	switch {
+	case t.KeepAliveSeconds < 0:
+		o.KeepAlive = -1
+	case t.KeepAliveSeconds > 0:
+		o.KeepAlive = time.Duration(t.KeepAliveSeconds) * time.Second
+	}
+	return o
+}
+
+func (c *EssentialsConfig) MediaURL(raw string) string {
+	if c == nil || len(c.MediaHosts) == 0 {
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	to := ""
+	for k, v := range c.MediaHosts {
+		if strings.EqualFold(k, u.Host) {
+			to = strings.TrimSpace(v)
+			break
+		}
+	}
+	if to == "" {
+		return raw
+	}
+	if !strings.Contains(to, "://") {
+		u.Host = to
+		return u.String()
+	}
+	m, err := url.Parse(to)
+	if err != nil {
+		return raw
+	}
+	u.Scheme, u.Host = m.Scheme, m.Host
+	u.Path = strings.TrimRight(m.Path, "/") + u.Path
+	u.RawPath = ""
+	return u.String()
+}
+
+var defaultExternalHosts = []string{
+	"youtube.com", "youtu.be", "periscope.tv", "pscp.tv", "vimeo.com",
+	"twitch.tv", "tiktok.com", "streamable.com", "rumble.com", "dailymotion.com",
+}
+
+func (c *EssentialsConfig) ExternalHosts() []string {
+	if c == nil || len(c.External.Hosts) == 0 {
+		return defaultExternalHosts
+	}
+	return c.External.Hosts
+}
+
+func (c *EssentialsConfig) PageSize() int {
+	if c == nil || c.Runtime.PageSize <= 0 {
+		return 100
+	}
+	return min(c.Runtime.PageSize, 100)
+}
+
+func (c *EssentialsConfig) MaxPages() int {
+	if c == nil || c.Runtime.MaxPages <= 0 {
+		return 200
+	}
+	return c.Runtime.MaxPages
+}
+
+func (c *EssentialsConfig) Concurrency() int {
+	if c == nil || c.Runtime.Concurrency <= 0 {
+		return 0
+	}
+	return c.Runtime.Concurrency
+}
+
+// RetryPolicy returns the "retry" settings for one failure class, with the
+// "default" entry filling in the fields the class leaves out.
+func (c *EssentialsConfig) RetryPolicy(class string) RetryPolicy {
+	if c == nil {
+		return RetryPolicy{}
+	}
+	p, q := c.Retry["default"], c.Retry[class]
+	if q.Attempts != 0 {
+		p.Attempts = q.Attempts
+	}
+	if q.TimeoutSeconds != 0 {
+		p.TimeoutSeconds = q.TimeoutSeconds
+	}
+	if q.BackoffMS != 0 {
+		p.BackoffMS = q.BackoffMS
+	}
+	if q.MaxBackoffMS != 0 {
+		p.MaxBackoffMS = q.MaxBackoffMS
+	}
+	return p
+}
+
+func (c *EssentialsConfig) SoftQuota() int64 {
+	if c == nil || c.Storage.SoftQuotaMB <= 0 {
+		return 0
+	}
+	return c.Storage.SoftQuotaMB << 20
+}
+
+func (c *EssentialsConfig) QuotaWarnAt() []int {
+	if c == nil || len(c.Storage.WarnAt) == 0 {
+		return []int{80, 90, 95}
+	}
+	return c.Storage.WarnAt
+}
+
+var opsMu sync.RWMutex
+
 func (c *EssentialsConfig) GraphQLURL(key string) (string, error) {
 	if c == nil {
 		return "", fmt.Errorf("nil config")
 	}
+	opsMu.RLock()
+	defer opsMu.RUnlock()
 	if c.GraphQL.Operations == nil {
 		return "", fmt.Errorf("graphql.operations is empty")
 	}
@@ -153,8 +395,10 @@ func (c *EssentialsConfig) FeatureJSONFor(key string) (string, error) {
 	if c == nil {
 		return "{}", nil
 	}
-	src := c.featureSource(key)
+	opsMu.RLock()
+	src := patchFeatures(key, c.featureSource(key))
 	data, err := json.Marshal(src)
+	opsMu.RUnlock()
 	if err != nil {
 		return "{}", err
 	}
@@ -163,11 +407,11 @@ func (c *EssentialsConfig) FeatureJSONFor(key string) (string, error) {
 
 func (c *EssentialsConfig) featureSource(key string) any {
 	switch key {
-	case "user_by_screen_name":
+	case "user_by_screen_name", "user_by_rest_id":
 		return c.Features.User
 	case "user_media":
 		return c.Features.Media
-	case "tweet_detail":
+	case "tweet_detail", "tweet_results_by_rest_ids", "search_timeline":
 		return map[string]bool{
 			"rweb_video_screen_enabled":                                               false,
 			"profile_label_improvements_pcf_label_in_post_enabled":                    true,
@@ -209,12 +453,147 @@ func (c *EssentialsConfig) featureSource(key string) any {
 	}
 }
 
+var featurePatches = map[string]map[string]*bool{}
+
+func patchFeatures(key string, src any) any {
+	p := featurePatches[key]
+	if len(p) == 0 {
+		return src
+	}
+	out := map[string]any{}
+	switch m := src.(type) {
+	case map[string]any:
+		for k, v := range m {
+			out[k] = v
+		}
+	case map[string]bool:
+		for k, v := range m {
+			out[k] = v
+		}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(out, k)
+			continue
+		}
+		out[k] = *v
+	}
+	return out
+}
+
+func PatchedFeatureJSON(key string, base map[string]bool) (string, error) {
+	opsMu.RLock()
+	src := patchFeatures(key, base)
+	data, err := json.Marshal(src)
+	opsMu.RUnlock()
+	if err != nil {
+		return "{}", err
+	}
+	return string(data), nil
+}
+
+func NegotiateFeatures(key string, add map[string]bool, drop []string) bool {
+	opsMu.Lock()
+	defer opsMu.Unlock()
+	p := featurePatches[key]
+	if p == nil {
+		p = map[string]*bool{}
+		featurePatches[key] = p
+	}
+	changed := false
+	for k, v := range add {
+		if cur, ok := p[k]; ok && cur != nil {
+			continue
+		}
+		v := v
+		p[k] = &v
+		changed = true
+	}
+	for _, k := range drop {
+		if cur, ok := p[k]; ok && cur == nil {
+			continue
+		}
+		p[k] = nil
+		changed = true
+	}
+	return changed
+}
+
+func (c *EssentialsConfig) OperationNames() map[string]string {
+	out := map[string]string{}
+	if c == nil {
+		return out
+	}
+	opsMu.RLock()
+	defer opsMu.RUnlock()
+	for k, op := range c.GraphQL.Operations {
+		if n := op.operationName(); n != "" {
+			out[k] = n
+		}
+	}
+	return out
+}
+
+func (c *EssentialsConfig) SetOperationID(key, id string) bool {
+	if c == nil || id == "" {
+		return false
+	}
+	opsMu.Lock()
+	defer opsMu.Unlock()
+	op, ok := c.GraphQL.Operations[key]
+	if !ok {
+		return false
+	}
+	n := op.operationName()
+	if op.ID == id && op.Path == id+"/"+n {
+		return false
+	}
+	op.ID = id
+	op.Name = n
+	op.Path = id + "/" + n
+	c.GraphQL.Operations[key] = op
+	return true
+}
+
+func (c *EssentialsConfig) AddMissingFeatures(key string, names []string, defaults map[string]bool) []string {
+	if c == nil {
+		return nil
+	}
+	opsMu.Lock()
+	defer opsMu.Unlock()
+	var m map[string]any
+	switch key {
+	case "user_by_screen_name", "user_by_rest_id":
+		if c.Features.User == nil {
+			c.Features.User = map[string]any{}
+		}
+		m = c.Features.User
+	case "user_media":
+		if c.Features.Media == nil {
+			c.Features.Media = map[string]any{}
+		}
+		m = c.Features.Media
+	default:
+		return nil
+	}
+	var added []string
+	for _, n := range names {
+		if _, ok := m[n]; ok {
+			continue
+		}
+		m[n] = defaults[n]
+		added = append(added, n)
+	}
+	return added
+}
+
 func (c *EssentialsConfig) BuildRequestHeaders(req *http.Request, ref string) {
 	if c == nil || req == nil {
 		return
 	}
 	httpx.ApplyConfiguredHeaders(req)
 	c.applyConfiguredHeaders(req)
+	c.applyProfileHeaders(req)
 	c.applyRefererHeader(req, ref)
 	c.applyAuthHeaders(req)
 	c.applyCookieHeader(req)
@@ -232,6 +611,24 @@ func (c *EssentialsConfig) applyConfiguredHeaders(req *http.Request) {
 	}
 }
 
+func (c *EssentialsConfig) applyProfileHeaders(req *http.Request) {
+	if c.Profile == "" {
+		return
+	}
+	p, err := httpx.LookupProfile(c.Profile)
+	if err != nil {
+		return
+	}
+	p.Apply(req, httpx.ClientLanguage(c.Headers["accept-language"]))
+}
+
+func (c *EssentialsConfig) UserAgent() string {
+	if p, err := httpx.LookupProfile(c.Profile); err == nil {
+		return p.UserAgent
+	}
+	return c.Headers["user-agent"]
+}
+
 func (c *EssentialsConfig) applyRefererHeader(req *http.Request, ref string) {
 	if ref == "" {
 		return
@@ -243,8 +640,12 @@ func (c *EssentialsConfig) applyAuthHeaders(req *http.Request) {
 	if c.Auth.Bearer != "" {
 		req.Header.Set("Authorization", "Bearer "+c.Auth.Bearer)
 	}
-	if c.Auth.Cookies.Ct0 != "" {
-		req.Header.Set("x-csrf-token", c.Auth.Cookies.Ct0)
+	ck, gt := c.session()
+	if ck.Ct0 != "" {
+		req.Header.Set("x-csrf-token", ck.Ct0)
+	}
+	if gt != "" {
+		req.Header.Set("x-guest-token", gt)
 	}
 }
 
@@ -258,14 +659,18 @@ func (c *EssentialsConfig) applyCookieHeader(req *http.Request) {
 
 func (c *EssentialsConfig) buildCookieHeader() string {
 	var parts []string
-	if c.Auth.Cookies.GuestID != "" {
-		parts = append(parts, "guest_id="+c.Auth.Cookies.GuestID)
+	ck, gt := c.session()
+	if ck.GuestID != "" {
+		parts = append(parts, "guest_id="+ck.GuestID)
 	}
-	if c.Auth.Cookies.AuthToken != "" {
-		parts = append(parts, "auth_token="+c.Auth.Cookies.AuthToken)
+	if ck.AuthToken != "" {
+		parts = append(parts, "auth_token="+ck.AuthToken)
 	}
-	if c.Auth.Cookies.Ct0 != "" {
-		parts = append(parts, "ct0="+c.Auth.Cookies.Ct0)
+	if ck.Ct0 != "" {
+		parts = append(parts, "ct0="+ck.Ct0)
+	}
+	if gt != "" {
+		parts = append(parts, "gt="+gt)
 	}
 	if len(parts) == 0 {
 		return ""
@@ -273,6 +678,146 @@ func (c *EssentialsConfig) buildCookieHeader() string {
 	return strings.Join(parts, "; ")
 }
 
+const guestTokenTTL = 2 * time.Hour
+
+var sessionMu sync.Mutex
+
+func (c *EssentialsConfig) session() (AuthCookies, string) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	return c.Auth.Cookies, c.Auth.GuestToken
+}
+
+func (c *EssentialsConfig) StartGuestSession(cl *http.Client) error {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	return c.activateGuest(cl)
+}
+
+func (c *EssentialsConfig) activateGuest(cl *http.Client) error {
+	gt, err := httpx.ActivateGuestToken(cl, c.Auth.Bearer)
+	if err != nil {
+		return err
+	}
+	c.Auth.GuestToken, c.Auth.guestAt = gt, time.Now()
+	return nil
+}
+
+func (c *EssentialsConfig) FreshGuestToken(cl *http.Client) string {
+	if c == nil {
+		return ""
+	}
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	if c.Auth.GuestToken != "" && time.Since(c.Auth.guestAt) > guestTokenTTL {
+		_ = c.activateGuest(cl)
+	}
+	return c.Auth.GuestToken
+}
+
+func (c *EssentialsConfig) RefreshGuestSession(cl *http.Client, stale string) bool {
+	if c == nil {
+		return false
+	}
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	if c.Auth.GuestToken == "" {
+		return false
+	}
+	if c.Auth.GuestToken != stale {
+		return true
+	}
+	return c.activateGuest(cl) == nil
+}
+
+func (c *EssentialsConfig) CsrfToken() string {
+	if c == nil {
+		return ""
+	}
+	ck, _ := c.session()
+	return ck.Ct0
+}
+
+func (c *EssentialsConfig) RotateCt0(h http.Header) (bool, error) {
+	if c == nil || len(h) == 0 {
+		return false, nil
+	}
+	var ct0 string
+	for _, ck := range (&http.Response{Header: h}).Cookies() {
+		if ck.Name == "ct0" && ck.Value != "" && ck.MaxAge >= 0 {
+			ct0 = ck.Value
+		}
+	}
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	if ct0 == "" || c.Auth.Cookies.Ct0 == "" || ct0 == c.Auth.Cookies.Ct0 {
+		return false, nil
+	}
+	c.Auth.Cookies.Ct0 = ct0
+	switch {
+	case c.sessionFile == "":
+		return true, nil
+	case c.cookieFile:
+		return true, persistCookieCt0(c.sessionFile, ct0)
+	}
+	return true, persistCt0(c.sessionFile, ct0)
+}
+
+// SessionFile names the file a rotated ct0 is saved to, if any.
+func (c *EssentialsConfig) SessionFile() string {
+	return c.sessionFile
+}
+
+// UseEssentialsSession marks the cookies as coming from the essentials file
+// itself.
+func (c *EssentialsConfig) UseEssentialsSession() {
+	c.sessionFile, c.cookieFile = c.source, false
+}
+
+func persistCt0(path, ct0 string) error {
+	cfg, err := loadEssentialsFromPath(path)
+	if err != nil {
+		return err
+	}
+	cfg.Auth.Cookies.Ct0 = ct0
+	return SaveEssentials(cfg, path)
+}
+
+// persistCookieCt0 updates the x.com ct0 entries of a browser cookie file and
+// keeps every other field as it was exported.
+func persistCookieCt0(path, ct0 string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var cookies []map[string]any
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		return fmt.Errorf("failed to parse cookie file %q: %w", path, err)
+	}
+	n := 0
+	for _, ck := range cookies {
+		name, _ := ck["name"].(string)
+		domain, _ := ck["domain"].(string)
+		if strings.EqualFold(name, "ct0") && strings.Contains(normalizeDomain(domain), "x.com") {
+			ck["value"] = ct0
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	out, err := json.MarshalIndent(cookies, "", "  ")
+	if err != nil {
+		return err
+	}
+	lk, err := utils.LockExclusive(path)
+	if err != nil {
+		return err
+	}
+	defer lk.Unlock()
+	return writeEssentialsAtomically(path, out)
+}
+
 type BrowserCookie struct {
 	Domain string `json:"domain"`
 	Name   string `json:"name"`
@@ -425,8 +970,13 @@ func (c *EssentialsConfig) ValidateRequiredCookies(cookiePath string) error {
 }
 
 func ApplyCookiesFromFile(cfg *EssentialsConfig, path string) error {
+	_, err := ApplyCookiesFromFileSource(cfg, path)
+	return err
+}
+
+func ApplyCookiesFromFileSource(cfg *EssentialsConfig, path string) (string, error) {
 	if cfg == nil {
-		return fmt.Errorf("nil config")
+		return "", fmt.Errorf("nil config")
 	}
 
 	candidates := cookiePathCandidates(path)
@@ -437,22 +987,23 @@ func ApplyCookiesFromFile(cfg *EssentialsConfig, path string) error {
 			if errors.Is(err, ErrCookieFileMissing) {
 				continue
 			}
-			return err
+			return "", err
 		}
 
 		cfg.applyBrowserCookies(cookies)
 
 		if err := cfg.ValidateRequiredCookies(candidate); err != nil {
-			return err
+			return "", err
 		}
 
-		return nil
+		cfg.sessionFile, cfg.cookieFile = candidate, true
+		return candidate, nil
 	}
 
 	expectedTxt := preferredCookiePathFor("cookies.txt")
 	expectedJSON := preferredCookiePathFor("cookies.json")
 
-	return fmt.Errorf(
+	return "", fmt.Errorf(
 		"%w\n\nCookie file not found.\n\n"+
 			"Expected location:\n  %s\n  or %s\n\n"+
 			"How to fix:\n"+
@@ -537,10 +1088,12 @@ func SaveEssentials(cfg *EssentialsConfig, path string) error {
 	if err != nil {
 		return fmt.Errorf("failed to marshal essentials: %w", err)
 	}
-	if err := writeEssentialsAtomically(path, data); err != nil {
-		return err
+	lk, err := utils.LockExclusive(path)
+	if err != nil {
+		return fmt.Errorf("failed to lock essentials: %w", err)
 	}
-	return err
+	defer lk.Unlock()
+	return writeEssentialsAtomically(path, data)
 }
 
 func ensureEssentialsDir(path string) error {
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+type envInt struct {
+	name string
+	dst  func(c *EssentialsConfig) *int
+}
+
+var envInts = []envInt{
+	{"XDL_CONCURRENCY", func(c *EssentialsConfig) *int { return &c.Runtime.Concurrency }},
+	{"XDL_TIMEOUT_SECONDS", func(c *EssentialsConfig) *int { return &c.Runtime.TimeoutSeconds }},
+	{"XDL_MAX_RETRIES", func(c *EssentialsConfig) *int { return &c.Runtime.MaxRetries }},
+	{"XDL_PAGE_SIZE", func(c *EssentialsConfig) *int { return &c.Runtime.PageSize }},
+	{"XDL_MAX_PAGES", func(c *EssentialsConfig) *int { return &c.Runtime.MaxPages }},
+	{"XDL_MAX_TWEETS", func(c *EssentialsConfig) *int { return &c.Runtime.MaxTweets }},
+	{"XDL_MAX_CONNS_PER_HOST", func(c *EssentialsConfig) *int { return &c.Transport.MaxConnsPerHost }},
+}
+
+type envString struct {
+	name string
+	dst  func(c *EssentialsConfig) *string
+}
+
+var envStrings = []envString{
+	{"XDL_NETWORK", func(c *EssentialsConfig) *string { return &c.X.Network }},
+	{"XDL_BEARER", func(c *EssentialsConfig) *string { return &c.Auth.Bearer }},
+	{"XDL_PROXY", func(c *EssentialsConfig) *string { return &c.Transport.Proxy }},
+	{"XDL_HEADER_PROFILE", func(c *EssentialsConfig) *string { return &c.Profile }},
+	{"XDL_LOG_LEVEL", func(c *EssentialsConfig) *string { return &c.Logging.Level }},
+	{"XDL_LOG_FORMAT", func(c *EssentialsConfig) *string { return &c.Logging.Format }},
+}
+
+// ApplyEnv overrides the loaded settings with XDL_* environment variables.
+// Each one can also be given as <NAME>_FILE naming a file with the value.
+// Endpoints are set per operation as XDL_GRAPHQL_<KEY>=<id>, e.g.
+// XDL_GRAPHQL_USER_MEDIA. Cookies are applied separately by ApplyEnvCookies.
+func (c *EssentialsConfig) ApplyEnv() error {
+	if c == nil {
+		return fmt.Errorf("nil config")
+	}
+	for _, e := range envStrings {
+		v, err := utils.Getenv(e.name)
+		if err != nil {
+			return err
+		}
+		if v != "" {
+			*e.dst(c) = v
+		}
+	}
+	for _, e := range envInts {
+		v, err := utils.Getenv(e.name)
+		if err != nil {
+			return err
+		}
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s: invalid number %q", e.name, v)
+		}
+		*e.dst(c) = n
+	}
+	if p := c.Transport.Proxy; p != "" {
+		if u, err := url.Parse(p); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid proxy %q (use scheme://host:port)", p)
+		}
+	}
+	for k := range c.OperationNames() {
+		n := "XDL_GRAPHQL_" + strings.ToUpper(k)
+		v, err := utils.Getenv(n)
+		if err != nil {
+			return err
+		}
+		if v != "" {
+			c.SetOperationID(k, v)
+		}
+	}
+	return nil
+}
+
+// ApplyEnvCookies reads XDL_COOKIES (a cookie export as JSON) and the single
+// XDL_AUTH_TOKEN, XDL_CT0 and XDL_GUEST_ID values. It reports whether any of
+// them was set.
+func ApplyEnvCookies(c *EssentialsConfig) (bool, error) {
+	if c == nil {
+		return false, fmt.Errorf("nil config")
+	}
+	set := false
+	raw, err := utils.Getenv("XDL_COOKIES")
+	if err != nil {
+		return false, err
+	}
+	if raw != "" {
+		var cs []BrowserCookie
+		if err := json.Unmarshal([]byte(raw), &cs); err != nil {
+			return false, fmt.Errorf("XDL_COOKIES: invalid JSON cookie export: %w", err)
+		}
+		c.applyBrowserCookies(cs)
+		set = true
+	}
+	for _, e := range []struct {
+		name string
+		dst  *string
+	}{
+		{"XDL_AUTH_TOKEN", &c.Auth.Cookies.AuthToken},
+		{"XDL_CT0", &c.Auth.Cookies.Ct0},
+		{"XDL_GUEST_ID", &c.Auth.Cookies.GuestID},
+	} {
+		v, err := utils.Getenv(e.name)
+		if err != nil {
+			return false, err
+		}
+		if v != "" {
+			*e.dst, set = v, true
+		}
+	}
+	return set, nil
+}
+
+// EssentialsEnvPath is the essentials.json named by XDL_ESSENTIALS, if any.
+func EssentialsEnvPath() string {
+	return strings.TrimSpace(os.Getenv("XDL_ESSENTIALS"))
+}
@@ -0,0 +1,246 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/httpx"
+	"github.com/ghostlawless/xdl/internal/log"
+)
+
+type Operation struct {
+	Name     string
+	ID       string
+	Features []string
+}
+
+type Result struct {
+	Operations map[string]Operation
+	Defaults   map[string]bool
+	Bundles    []string
+	FetchedAt  time.Time
+}
+
+type Change struct {
+	Key      string
+	Name     string
+	OldID    string
+	NewID    string
+	Features []string
+}
+
+var (
+	reBundle   = regexp.MustCompile(`https://abs\.twimg\.com/responsive-web/client-web[\w./-]*/[\w.~-]+\.js`)
+	reOp       = regexp.MustCompile(`queryId:"([\w-]+)",operationName:"(\w+)",operationType:"\w+",metadata:\{featureSwitches:\[([^\]]*)\]`)
+	reSwitch   = regexp.MustCompile(`"(\w+)":\{"value":(true|false)\}`)
+	reQuoted   = regexp.MustCompile(`"(\w+)"`)
+	ErrNoMatch = errors.New("no graphql operations found in x.com bundles")
+)
+
+const (
+	maxBundles = 12
+	cacheTTL   = 10 * time.Minute
+)
+
+var cache struct {
+	mu  sync.Mutex
+	res *Result
+	err error
+	at  time.Time
+}
+
+func Fetch(cl *http.Client, cf *config.EssentialsConfig) (*Result, error) {
+	if cl == nil || cf == nil {
+		return nil, errors.New("nil client or config")
+	}
+	want := map[string]struct{}{}
+	for _, n := range cf.OperationNames() {
+		want[n] = struct{}{}
+	}
+
+	home := strings.TrimRight(cf.X.Network, "/") + "/"
+	page, err := get(cl, cf, home, 4<<20)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", home, err)
+	}
+
+	res := &Result{
+		Operations: map[string]Operation{},
+		Defaults:   map[string]bool{},
+		FetchedAt:  time.Now(),
+	}
+	for _, m := range reSwitch.FindAllSubmatch(page, -1) {
+		res.Defaults[string(m[1])] = string(m[2]) == "true"
+	}
+
+	for _, u := range bundleURLs(page) {
+		if len(res.Bundles) >= maxBundles || len(want) == 0 {
+			break
+		}
+		b, err := get(cl, cf, u, 16<<20)
+		if err != nil {
+			log.LogError("discovery", fmt.Sprintf("bundle %s: %v", u, err))
+			continue
+		}
+		res.Bundles = append(res.Bundles, u)
+		for _, m := range reOp.FindAllSubmatch(b, -1) {
+			name := string(m[2])
+			if _, ok := want[name]; !ok {
+				continue
+			}
+			op := Operation{Name: name, ID: string(m[1])}
+			for _, q := range reQuoted.FindAllSubmatch(m[3], -1) {
+				op.Features = append(op.Features, string(q[1]))
+			}
+			res.Operations[name] = op
+			delete(want, name)
+		}
+	}
+
+	if len(res.Operations) == 0 {
+		return nil, ErrNoMatch
+	}
+	log.LogInfo("discovery", fmt.Sprintf("found %d operations in %d bundles", len(res.Operations), len(res.Bundles)))
+	return res, nil
+}
+
+func Cached(cl *http.Client, cf *config.EssentialsConfig) (*Result, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if !cache.at.IsZero() && time.Since(cache.at) < cacheTTL {
+		return cache.res, cache.err
+	}
+	cache.res, cache.err = Fetch(cl, cf)
+	cache.at = time.Now()
+	return cache.res, cache.err
+}
+
+func CachedDefaults() map[string]bool {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.res == nil {
+		return nil
+	}
+	return cache.res.Defaults
+}
+
+func Apply(cf *config.EssentialsConfig, res *Result) []Change {
+	if cf == nil || res == nil {
+		return nil
+	}
+	names := cf.OperationNames()
+	keys := make([]string, 0, len(names))
+	for k := range names {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var out []Change
+	for _, k := range keys {
+		op, ok := res.Operations[names[k]]
+		if !ok {
+			continue
+		}
+		ch := Change{Key: k, Name: op.Name, OldID: storedID(cf, k), NewID: op.ID}
+		changed := cf.SetOperationID(k, op.ID)
+		ch.Features = cf.AddMissingFeatures(k, op.Features, res.Defaults)
+		if changed || len(ch.Features) > 0 {
+			out = append(out, ch)
+		}
+	}
+	return out
+}
+
+func storedID(cf *config.EssentialsConfig, key string) string {
+	u, err := cf.GraphQLURL(key)
+	if err != nil {
+		return ""
+	}
+	i := strings.LastIndex(u, "/graphql/")
+	if i < 0 {
+		return ""
+	}
+	return strings.SplitN(u[i+len("/graphql/"):], "/", 2)[0]
+}
+
+// Refresh applies the discovered operations after a 404 and saves them to
+// the essentials file cf was loaded from. It reports whether key now has the
+// discovered queryId, including when another request already updated it.
+func Refresh(cl *http.Client, cf *config.EssentialsConfig, key string) bool {
+	res, err := Cached(cl, cf)
+	if err != nil {
+		log.LogError("discovery", "refresh failed: "+err.Error())
+		return false
+	}
+	changes := Apply(cf, res)
+	for _, ch := range changes {
+		log.LogInfo("discovery", fmt.Sprintf("%s: %s -> %s (+%d features)", ch.Name, ch.OldID, ch.NewID, len(ch.Features)))
+	}
+	if len(changes) > 0 {
+		if err := save(cf.Source(), res); err != nil {
+			log.LogError("discovery", "saving refreshed operations failed: "+err.Error())
+		}
+	}
+	op, ok := res.Operations[cf.OperationNames()[key]]
+	return ok && storedID(cf, key) == op.ID
+}
+
+// save applies res to the file at path alone, so settings that came from the
+// environment or a cookie file are not written into it.
+func save(path string, res *Result) error {
+	if path == "" {
+		return nil
+	}
+	fc, err := config.LoadEssentialsWithFallback([]string{path})
+	if err != nil {
+		return err
+	}
+	if fc.Source() != path || len(Apply(fc, res)) == 0 {
+		return nil
+	}
+	return config.SaveEssentials(fc, path)
+}
+
+func bundleURLs(page []byte) []string {
+	seen := map[string]struct{}{}
+	var main, rest []string
+	for _, m := range reBundle.FindAll(page, -1) {
+		u := string(m)
+		if _, ok := seen[u]; ok {
+			continue
+		}
+		seen[u] = struct{}{}
+		base := u[strings.LastIndex(u, "/")+1:]
+		if strings.HasPrefix(base, "main.") || strings.HasPrefix(base, "api.") {
+			main = append(main, u)
+			continue
+		}
+		rest = append(rest, u)
+	}
+	return append(main, rest...)
+}
+
+func get(cl *http.Client, cf *config.EssentialsConfig, u string, max int64) ([]byte, error) {
+	rq, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if ua := cf.UserAgent(); ua != "" {
+		rq.Header.Set("User-Agent", ua)
+	}
+	if al := cf.Headers["accept-language"]; al != "" {
+		rq.Header.Set("Accept-Language", al)
+	}
+	b, _, err := httpx.DoRequestWithOptions(cl, rq, httpx.RequestOptions{
+		MaxBytes: max,
+		Decode:   true,
+	})
+	return b, err
+}
@@ -0,0 +1,55 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/config"
+)
+
+func TestRefreshSavesAndReportsCurrentID(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "essentials.json")
+	in := `{"graphql":{"operations":{"user_media":{"id":"old","name":"UserMedia","path":"old/UserMedia"}}}}`
+	if err := os.WriteFile(p, []byte(in), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cf, err := config.LoadEssentialsWithFallback([]string{p})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache.mu.Lock()
+	cache.res = &Result{Operations: map[string]Operation{"UserMedia": {Name: "UserMedia", ID: "new"}}}
+	cache.err, cache.at = nil, time.Now()
+	cache.mu.Unlock()
+	t.Cleanup(func() {
+		cache.mu.Lock()
+		cache.res, cache.at = nil, time.Time{}
+		cache.mu.Unlock()
+	})
+
+	if !Refresh(nil, cf, "user_media") {
+		t.Fatal("Refresh = false after changing the queryId")
+	}
+	if got := storedID(cf, "user_media"); got != "new" {
+		t.Errorf("in-memory queryId = %q, want new", got)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"new/UserMedia"`) {
+		t.Errorf("essentials.json was not updated:\n%s", b)
+	}
+
+	// A second 404 finds the ID already current, as after a concurrent refresh.
+	if !Refresh(nil, cf, "user_media") {
+		t.Error("Refresh = false when the stored queryId already matches")
+	}
+	if Refresh(nil, cf, "user_by_screen_name") {
+		t.Error("Refresh = true for an operation discovery did not find")
+	}
+}
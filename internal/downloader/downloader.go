@@ -3,28 +3,36 @@ package downloader
 import (
 	"context"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"hash/fnv"
+	"io"
 	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
-	"os"
 	"path"
 	"path/filepath"
 	"runtime"
+	"runtime/debug"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/ghostlawless/xdl/internal/audit"
 	"github.com/ghostlawless/xdl/internal/config"
 	"github.com/ghostlawless/xdl/internal/httpx"
+	"github.com/ghostlawless/xdl/internal/mediaurl"
+	"github.com/ghostlawless/xdl/internal/paths"
 	"github.com/ghostlawless/xdl/internal/scraper"
+	"github.com/ghostlawless/xdl/internal/trace"
 	"github.com/ghostlawless/xdl/internal/utils"
 )
 
+var ErrAborted = errors.New("download aborted by user")
+
 type Options struct {
 	RunDir            string
 	User              string
@@ -36,6 +44,10 @@ type Options struct {
 	ShouldPause       func() bool
 	ShouldQuit        func() bool
 	Checkpoint        *Checkpoint
+	Written           func(n int64)
+	Sink              Sink
+	CheckSize         bool
+	OnPanic           func(url string, v any, stack []byte)
 
 	Concurrency         int
 	BatchSize           int
@@ -43,6 +55,20 @@ type Options struct {
 	JitterDeterministic bool
 }
 
+func (o Options) sink() Sink {
+	if o.Sink != nil {
+		return o.Sink
+	}
+	return DirSink{Root: o.RunDir}
+}
+
+func (o Options) pausedInFlight() bool {
+	if o.ShouldPause == nil || !o.ShouldPause() {
+		return false
+	}
+	return o.ShouldQuit == nil || !o.ShouldQuit()
+}
+
 type Summary struct {
 	Downloaded int
 	Skipped    int
@@ -57,12 +83,16 @@ const (
 	ProgressKindDownloaded ProgressKind = iota
 	ProgressKindSkipped
 	ProgressKindFailed
+	ProgressKindBytes
 )
 
 type ProgressEvent struct {
-	User string
-	Kind ProgressKind
-	Size int64
+	User  string
+	Kind  ProgressKind
+	Size  int64
+	URL   string
+	Done  int64
+	Total int64
 }
 
 type item struct {
@@ -78,12 +108,6 @@ func DownloadAllCycles(cl *http.Client, cf *config.EssentialsConfig, ms []scrape
 	if len(ms) == 0 {
 		return s, nil
 	}
-	ds := binsOf(opt.RunDir)
-	for _, d := range ds.all() {
-		if err := utils.EnsureDir(d); err != nil {
-			return s, err
-		}
-	}
 	cp := opt.Checkpoint
 	if cp == nil {
 		cp = NewCheckpoint(opt.User, "", ms)
@@ -117,17 +141,17 @@ func DownloadAllCycles(cl *http.Client, cf *config.EssentialsConfig, ms []scrape
 
 	for len(pd) > 0 {
 		if opt.ShouldQuit != nil && opt.ShouldQuit() {
-			return s, errors.New("download aborted by user")
+			return s, ErrAborted
 		}
 		if opt.ShouldPause != nil && opt.ShouldPause() {
 			for opt.ShouldPause != nil && opt.ShouldPause() {
 				if opt.ShouldQuit != nil && opt.ShouldQuit() {
-					return s, errors.New("download aborted by user")
+					return s, ErrAborted
 				}
 				time.Sleep(200 * time.Millisecond)
 			}
 			if opt.ShouldQuit != nil && opt.ShouldQuit() {
-				return s, errors.New("download aborted by user")
+				return s, ErrAborted
 			}
 		}
 
@@ -138,7 +162,7 @@ func DownloadAllCycles(cl *http.Client, cf *config.EssentialsConfig, ms []scrape
 		b := pd[:k]
 		pd = pd[k:]
 
-		ok, sk, fl, by := doBatch(cl, cf, b, ds, opt, cp)
+		ok, sk, fl, by := doBatch(cl, cf, b, opt.sink(), opt, cp)
 		s.Downloaded += ok
 		s.Skipped += sk
 		s.Failed += fl
@@ -148,23 +172,7 @@ func DownloadAllCycles(cl *http.Client, cf *config.EssentialsConfig, ms []scrape
 	return s, nil
 }
 
-type bins struct {
-	I string
-	V string
-}
-
-func binsOf(root string) bins {
-	return bins{
-		I: filepath.Join(root, "images"),
-		V: filepath.Join(root, "videos"),
-	}
-}
-
-func (sd bins) all() []string {
-	return []string{sd.I, sd.V}
-}
-
-func doBatch(cl *http.Client, cf *config.EssentialsConfig, b []item, ds bins, opt Options, cp *Checkpoint) (ok, sk, fl int, by int64) {
+func doBatch(cl *http.Client, cf *config.EssentialsConfig, b []item, out Sink, opt Options, cp *Checkpoint) (ok, sk, fl int, by int64) {
 	var wg sync.WaitGroup
 	wg.Add(len(b))
 
@@ -204,16 +212,34 @@ func doBatch(cl *http.Client, cf *config.EssentialsConfig, b []item, ds bins, op
 				return
 			}
 
-			r := doOne(cl, cf, it, ds, opt)
+			sp := trace.Start("download", nil)
+			sp.Set("url", it.URL)
+			sp.Set("user", opt.User)
+			var onBytes httpx.ProgressFunc
+			if opt.Progress != nil {
+				onBytes = func(done, total int64) {
+					mu.Lock()
+					opt.Progress(ProgressEvent{User: opt.User, Kind: ProgressKindBytes, URL: it.URL, Done: done, Total: total})
+					mu.Unlock()
+				}
+			}
+			r := safeDoOne(cl, cf, it, out, opt, onBytes)
+			sp.Set("status", r.status)
+			sp.Set("bytes", r.size)
+			sp.Set("retries", r.retries)
+			sp.Set("skipped", r.skipped)
+			sp.Fail(r.err)
+			sp.End()
 			mu.Lock()
 			defer mu.Unlock()
 			if r.err != nil {
 				fl++
 				if cp != nil {
 					cp.MarkByURL(it.URL, CheckpointFailed, 0)
+					cp.SetFailure(it.URL, Classify(r.err, r.status), r.err.Error())
 				}
 				if opt.Progress != nil {
-					opt.Progress(ProgressEvent{User: opt.User, Kind: ProgressKindFailed, Size: 0})
+					opt.Progress(ProgressEvent{User: opt.User, Kind: ProgressKindFailed, URL: it.URL})
 				}
 				return
 			}
@@ -223,17 +249,22 @@ func doBatch(cl *http.Client, cf *config.EssentialsConfig, b []item, ds bins, op
 					cp.MarkByURL(it.URL, CheckpointSkipped, r.size)
 				}
 				if opt.Progress != nil {
-					opt.Progress(ProgressEvent{User: opt.User, Kind: ProgressKindSkipped, Size: 0})
+					opt.Progress(ProgressEvent{User: opt.User, Kind: ProgressKindSkipped, URL: it.URL})
 				}
 				return
 			}
 			ok++
 			by += r.size
+			if opt.Written != nil && !opt.DryRun {
+				opt.Written(r.size)
+			}
 			if cp != nil {
 				cp.MarkByURL(it.URL, CheckpointDone, r.size)
+				cp.SetMIME(it.URL, r.mime)
+				cp.SetFile(it.URL, r.path, r.sha256)
 			}
 			if opt.Progress != nil {
-				opt.Progress(ProgressEvent{User: opt.User, Kind: ProgressKindDownloaded, Size: r.size})
+				opt.Progress(ProgressEvent{User: opt.User, Kind: ProgressKindDownloaded, Size: r.size, URL: it.URL})
 			}
 		}()
 	}
@@ -245,19 +276,36 @@ type result struct {
 	ok      bool
 	skipped bool
 	size    int64
+	mime    string
+	path    string
+	sha256  string
+	status  int
+	retries int
 	err     error
 }
 
-func doOne(cl *http.Client, cf *config.EssentialsConfig, it item, ds bins, opt Options) result {
-	dst := pick(it, ds)
-	_ = utils.EnsureDir(dst)
+// safeDoOne keeps a panic in one download from taking the whole process
+// down: the file fails and opt.OnPanic gets the stack.
+func safeDoOne(cl *http.Client, cf *config.EssentialsConfig, it item, out Sink, opt Options, onBytes httpx.ProgressFunc) (r result) {
+	defer func() {
+		if v := recover(); v != nil {
+			r = result{err: fmt.Errorf("panic: %v", v)}
+			if opt.OnPanic != nil {
+				opt.OnPanic(it.URL, v, debug.Stack())
+			}
+		}
+	}()
+	return doOne(cl, cf, it, out, opt, onBytes)
+}
+
+func doOne(cl *http.Client, cf *config.EssentialsConfig, it item, out Sink, opt Options, onBytes httpx.ProgressFunc) result {
 	base := baseFrom(it.URL)
 	if base == "" {
 		base = sh(it.URL)
 	}
-	base = utils.SanitizeFilename(base)
+	rs := int64(-1)
 	if opt.DryRun || opt.MediaMaxBytes > 0 {
-		_, sz, _, st, err := httpx.Head(cl, it.URL, cf.X.Network)
+		_, sz, _, st, err := httpx.Head(cl, cf.MediaURL(it.URL), cf.X.Network)
 		if err != nil {
 			if cf.Runtime.DebugEnabled {
 				meta := fmt.Sprintf("HEAD_ERROR\nSTATUS: %d\nURL: %s\n", st, it.URL)
@@ -265,6 +313,9 @@ func doOne(cl *http.Client, cf *config.EssentialsConfig, it item, ds bins, opt O
 			}
 			return result{err: err}
 		}
+		if st == http.StatusOK {
+			rs = sz
+		}
 		if opt.MediaMaxBytes > 0 && sz > 0 && sz > opt.MediaMaxBytes {
 			return result{skipped: true}
 		}
@@ -276,55 +327,164 @@ func doOne(cl *http.Client, cf *config.EssentialsConfig, it item, ds bins, opt O
 	if ext == "" {
 		ext = httpx.InferExt("", it.URL, it.Type)
 	}
-	fn := base
-	if ext != "" && !strings.HasSuffix(strings.ToLower(fn), "."+ext) {
-		fn += "." + ext
-	}
-	full := filepath.Join(dst, fn)
-	if st, err := os.Stat(full); err == nil && st.Size() > 0 {
-		return result{skipped: true, size: st.Size()}
+	rel := paths.MediaFile(pick(it), base, ext)
+	full := out.Path(rel)
+	if sz, ok := out.Stat(rel); ok && sz > 0 {
+		if !opt.CheckSize || sameSize(cl, cf, it.URL, sz, &rs) {
+			return result{skipped: true, size: sz}
+		}
+	} else if p, sz := existingVariant(out, rel); p != "" {
+		if !opt.CheckSize || sameSize(cl, cf, it.URL, sz, &rs) {
+			return result{skipped: true, size: sz}
+		}
 	}
-	req, err := http.NewRequest(http.MethodGet, it.URL, nil)
+	src := mediaurl.Fallbacks(it.URL)
+	req, err := mediaRequest(cf, src[0])
 	if err != nil {
 		return result{err: err}
 	}
-	cf.BuildRequestHeaders(req, cf.X.Network)
-	req.Header.Set("Accept", "*/*")
-	at := opt.Attempts
-	if at <= 0 {
-		at = 3
-	}
-	to := opt.PerAttemptTimeout
-	if to <= 0 {
-		to = 2 * time.Minute
+	pol := policyFor(cf, opt, "")
+	act := audit.FileWrite
+	if _, ok := out.Stat(rel); ok {
+		act = audit.FileReplace
 	}
 	var n int64
 	var st int
 	var last error
-	for i := 0; i < at; i++ {
-		n, st, last = httpx.DownloadToFileWithTimeout(cl, req, full, opt.MediaMaxBytes, to)
-		if last == nil {
-			return result{ok: true, size: n}
+	i := 0
+	for ; ; i++ {
+		w, err := out.Create(rel)
+		if err != nil {
+			return result{err: err}
 		}
-		if isTemp(last) {
-			sl := backoff(i)
+		h, hd := sha256.New(), &head{}
+		n, st, last = httpx.DownloadWith(cl, req, io.MultiWriter(w, h, hd), httpx.DownloadOptions{
+			MaxBytes: opt.MediaMaxBytes,
+			Timeout:  pol.timeout,
+			Progress: onBytes,
+			Paused:   opt.pausedInFlight,
+		})
+		if last != nil {
+			w.Abort()
+		} else {
+			fp, mt := fixExt(out, rel, ext, hd.b)
+			if last = w.Commit(fp); last == nil {
+				sum := hex.EncodeToString(h.Sum(nil))
+				audit.Record(audit.Event{Action: act, Path: out.Path(fp), URL: it.URL, User: opt.User, SHA256: sum, Size: n})
+				return result{ok: true, size: n, mime: mt, path: out.Path(fp), sha256: sum, status: st, retries: i}
+			}
+		}
+		if st == http.StatusNotFound && len(src) > 1 {
+			src = src[1:]
+			if req, err = mediaRequest(cf, src[0]); err != nil {
+				return result{err: err}
+			}
 			if cf.Runtime.DebugEnabled {
-				meta := fmt.Sprintf("RETRY a=%d sleep=%s status=%d url=%s err=%v\n", i+1, sl, st, it.URL, last)
+				meta := fmt.Sprintf("FALLBACK status=%d url=%s next=%s\n", st, it.URL, src[0])
 				_, _ = utils.SaveTimestamped(cf.Paths.Debug, "err_download_meta", "txt", []byte(meta))
 			}
-			time.Sleep(sl)
+			i--
 			continue
 		}
-		break
+		c := Classify(last, st)
+		if pol = policyFor(cf, opt, c); i+1 >= pol.attempts {
+			break
+		}
+		sl := pol.wait(i)
+		if cf.Runtime.DebugEnabled {
+			meta := fmt.Sprintf("RETRY a=%d class=%s sleep=%s status=%d url=%s err=%v\n", i+1, c, sl, st, it.URL, last)
+			_, _ = utils.SaveTimestamped(cf.Paths.Debug, "err_download_meta", "txt", []byte(meta))
+		}
+		if waitDurationWithControls(sl, opt) != nil {
+			break
+		}
 	}
 	if cf.Runtime.DebugEnabled {
 		meta := fmt.Sprintf("DOWNLOAD_ERROR\nSTATUS: %d\nURL: %s\nDEST: %s\nERR: %v\n", st, it.URL, full, last)
 		_, _ = utils.SaveTimestamped(cf.Paths.Debug, "err_download_meta", "txt", []byte(meta))
 	}
-	return result{err: last}
+	return result{err: last, status: st, retries: i}
+}
+
+func mediaRequest(cf *config.EssentialsConfig, u string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, cf.MediaURL(u), nil)
+	if err != nil {
+		return nil, err
+	}
+	cf.BuildRequestHeaders(req, cf.X.Network)
+	req.Header.Set("Accept", "*/*")
+	if req.URL.String() != u {
+		for _, k := range []string{"Cookie", "Authorization", "x-csrf-token", "x-guest-token"} {
+			req.Header.Del(k)
+		}
+	}
+	return req, nil
+}
+
+// sameSize reports whether a file already on disk has the remote
+// Content-Length. An unknown remote size counts as a match, so a failed HEAD
+// never replaces a file. rs caches the size once fetched.
+func sameSize(cl *http.Client, cf *config.EssentialsConfig, u string, sz int64, rs *int64) bool {
+	if *rs < 0 {
+		_, n, _, st, err := httpx.Head(cl, cf.MediaURL(u), cf.X.Network)
+		if err != nil || st != http.StatusOK {
+			return true
+		}
+		*rs = n
+	}
+	if *rs <= 0 || *rs == sz {
+		return true
+	}
+	if cf.Runtime.DebugEnabled {
+		meta := fmt.Sprintf("SIZE_MISMATCH local=%d remote=%d url=%s\n", sz, *rs, u)
+		_, _ = utils.SaveTimestamped(cf.Paths.Debug, "err_download_meta", "txt", []byte(meta))
+	}
+	return false
+}
+
+var knownExts = []string{"jpg", "png", "webp", "gif", "mp4", "m3u8"}
+
+func existingVariant(sk Sink, rel string) (string, int64) {
+	ext := filepath.Ext(rel)
+	base := strings.TrimSuffix(rel, ext)
+	for _, e := range knownExts {
+		p := base + "." + e
+		if p == rel {
+			continue
+		}
+		if sz, ok := sk.Stat(p); ok && sz > 0 {
+			return p, sz
+		}
+	}
+	return "", 0
+}
+
+func fixExt(sk Sink, rel, want string, b []byte) (string, string) {
+	mt := httpx.SniffMIME(b)
+	got := httpx.InferExt(mt, "", "")
+	if got == "" || got == want {
+		return rel, mt
+	}
+	if strings.HasPrefix(mt, "image/") != strings.HasPrefix(httpx.MIMEForExt(want), "image/") {
+		return rel, mt
+	}
+	np := strings.TrimSuffix(rel, filepath.Ext(rel)) + "." + got
+	if _, ok := sk.Stat(np); ok {
+		return rel, mt
+	}
+	return np, mt
+}
+
+type head struct{ b []byte }
+
+func (h *head) Write(p []byte) (int, error) {
+	if n := 512 - len(h.b); n > 0 {
+		h.b = append(h.b, p[:min(n, len(p))]...)
+	}
+	return len(p), nil
 }
 
-func pick(it item, ds bins) string {
+func pick(it item) string {
 	u := it.URL
 	if i := strings.IndexByte(u, '?'); i >= 0 {
 		u = u[:i]
@@ -332,11 +492,11 @@ func pick(it item, ds bins) string {
 	l := strings.ToLower(u)
 	switch {
 	case strings.HasSuffix(l, ".mp4"), strings.HasSuffix(l, ".m3u8"), it.Type == "video":
-		return ds.V
+		return paths.MediaDir("", true)
 	case strings.HasSuffix(l, ".jpg"), strings.HasSuffix(l, ".jpeg"), strings.HasSuffix(l, ".png"), strings.HasSuffix(l, ".webp"), strings.HasSuffix(l, ".gif"), it.Type == "image":
-		return ds.I
+		return paths.MediaDir("", false)
 	default:
-		return ds.I
+		return paths.MediaDir("", false)
 	}
 }
 
@@ -374,17 +534,6 @@ func isTemp(err error) bool {
 	return strings.Contains(e, "timeout") || strings.Contains(e, "deadline")
 }
 
-func backoff(i int) time.Duration {
-	base := 500 * time.Millisecond
-	max := 8 * time.Second
-	d := base * time.Duration(1<<i)
-	if d > max {
-		d = max
-	}
-	j := time.Duration(rand.Int63n(int64(d/2))) - d/4
-	return d + j
-}
-
 func calcJobJitter(it item, opt Options) time.Duration {
 	if opt.JobJitterMax <= 0 {
 		return 0
@@ -408,12 +557,12 @@ func waitDurationWithControls(d time.Duration, opt Options) error {
 	tick := 50 * time.Millisecond
 	for {
 		if opt.ShouldQuit != nil && opt.ShouldQuit() {
-			return errors.New("aborted by user")
+			return ErrAborted
 		}
 		if opt.ShouldPause != nil && opt.ShouldPause() {
 			for opt.ShouldPause != nil && opt.ShouldPause() {
 				if opt.ShouldQuit != nil && opt.ShouldQuit() {
-					return errors.New("aborted by user")
+					return ErrAborted
 				}
 				time.Sleep(100 * time.Millisecond)
 			}
@@ -0,0 +1,98 @@
+package downloader
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/httpx"
+	"github.com/ghostlawless/xdl/internal/scraper"
+)
+
+const (
+	DefaultEstimateSample = 24
+	estimateConcurrency   = 4
+)
+
+type Estimate struct {
+	Media   int
+	Sampled int
+	Failed  int
+	Bytes   int64
+	Exact   bool
+	Unknown int
+}
+
+func EstimateSize(cl *http.Client, cf *config.EssentialsConfig, ms []scraper.Media, sample int, quit func() bool) Estimate {
+	est := Estimate{Media: len(ms), Exact: true}
+	groups := map[string][]scraper.Media{}
+	var order []string
+	for _, m := range ms {
+		if _, ok := groups[m.Type]; !ok {
+			order = append(order, m.Type)
+		}
+		groups[m.Type] = append(groups[m.Type], m)
+	}
+	for _, t := range order {
+		g := groups[t]
+		pick := sampleMedia(g, sample)
+		sizes := headSizes(cl, cf, pick, quit)
+		var sum int64
+		for _, sz := range sizes {
+			sum += sz
+		}
+		est.Sampled += len(sizes)
+		est.Failed += len(pick) - len(sizes)
+		switch {
+		case len(sizes) == 0:
+			est.Unknown += len(g)
+			est.Exact = false
+		case len(sizes) == len(g):
+			est.Bytes += sum
+		default:
+			est.Bytes += sum * int64(len(g)) / int64(len(sizes))
+			est.Exact = false
+		}
+	}
+	return est
+}
+
+func sampleMedia(ms []scraper.Media, n int) []scraper.Media {
+	if n <= 0 || len(ms) <= n {
+		return ms
+	}
+	out := make([]scraper.Media, n)
+	for i := range out {
+		out[i] = ms[i*len(ms)/n]
+	}
+	return out
+}
+
+func headSizes(cl *http.Client, cf *config.EssentialsConfig, ms []scraper.Media, quit func() bool) []int64 {
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		out = make([]int64, 0, len(ms))
+		sem = make(chan struct{}, estimateConcurrency)
+	)
+	for _, m := range ms {
+		if quit != nil && quit() {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			_, sz, _, st, err := httpx.Head(cl, cf.MediaURL(m.URL), cf.X.Network)
+			if err != nil || st != http.StatusOK || sz <= 0 {
+				return
+			}
+			mu.Lock()
+			out = append(out, sz)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return out
+}
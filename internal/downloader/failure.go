@@ -0,0 +1,140 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/config"
+)
+
+type FailureClass string
+
+const (
+	FailNotFound    FailureClass = "not_found"
+	FailForbidden   FailureClass = "forbidden"
+	FailRateLimited FailureClass = "rate_limited"
+	FailServer      FailureClass = "server"
+	FailHTTP        FailureClass = "http"
+	FailTimeout     FailureClass = "timeout"
+	FailNetwork     FailureClass = "network"
+	FailDisk        FailureClass = "disk"
+	FailOther       FailureClass = "other"
+)
+
+var FailureClasses = []FailureClass{FailNotFound, FailForbidden, FailRateLimited, FailServer, FailHTTP, FailTimeout, FailNetwork, FailDisk, FailOther}
+
+// Classify buckets a failed download by what a retry could change: a proxy
+// or a slower pace helps with network and rate-limit failures, nothing helps
+// with media X has deleted.
+func Classify(err error, status int) FailureClass {
+	switch {
+	case status == http.StatusNotFound || status == http.StatusGone:
+		return FailNotFound
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return FailForbidden
+	case status == http.StatusTooManyRequests:
+		return FailRateLimited
+	case status >= 500:
+		return FailServer
+	case status >= 300:
+		return FailHTTP
+	case err == nil:
+		return FailOther
+	case isTemp(err):
+		return FailTimeout
+	}
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return FailDisk
+	}
+	var ne net.Error
+	var oe *net.OpError
+	if errors.As(err, &ne) || errors.As(err, &oe) {
+		return FailNetwork
+	}
+	return FailOther
+}
+
+func (c FailureClass) transient() bool {
+	switch c {
+	case FailTimeout, FailNetwork, FailServer, FailRateLimited:
+		return true
+	}
+	return false
+}
+
+type retryPolicy struct {
+	attempts   int
+	timeout    time.Duration
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// policyFor decides how a file that failed with class c is retried. Without
+// a "retry" entry in the config, transient failures get opt.Attempts tries
+// and permanent ones (404, 403, a full disk) fail at once. The empty class
+// gives the policy for the first try.
+func policyFor(cf *config.EssentialsConfig, opt Options, c FailureClass) retryPolicy {
+	p := retryPolicy{attempts: 1, timeout: opt.PerAttemptTimeout, backoff: 500 * time.Millisecond, maxBackoff: 8 * time.Second}
+	if p.timeout <= 0 {
+		p.timeout = 2 * time.Minute
+	}
+	if c == "" || c.transient() {
+		p.attempts = opt.Attempts
+		if p.attempts <= 0 {
+			p.attempts = 3
+		}
+	}
+	if c == FailRateLimited {
+		p.backoff, p.maxBackoff = 5*time.Second, time.Minute
+	}
+	q := cf.RetryPolicy(string(c))
+	if q.Attempts > 0 {
+		p.attempts = q.Attempts
+	}
+	if q.TimeoutSeconds > 0 {
+		p.timeout = time.Duration(q.TimeoutSeconds) * time.Second
+	}
+	if q.BackoffMS > 0 {
+		p.backoff = time.Duration(q.BackoffMS) * time.Millisecond
+	}
+	if q.MaxBackoffMS > 0 {
+		p.maxBackoff = time.Duration(q.MaxBackoffMS) * time.Millisecond
+	}
+	return p
+}
+
+func (p retryPolicy) wait(i int) time.Duration {
+	d := p.backoff << min(i, 30)
+	if d > p.maxBackoff || d <= 0 {
+		d = p.maxBackoff
+	}
+	if d < 4 {
+		return d
+	}
+	j := time.Duration(rand.Int63n(int64(d/2))) - d/4
+	return d + j
+}
+
+// ValidateRetry rejects "retry" entries for unknown classes or with
+// negative values.
+func ValidateRetry(cf *config.EssentialsConfig) error {
+	if cf == nil {
+		return nil
+	}
+	for k, v := range cf.Retry {
+		if k != "default" && !slices.Contains(FailureClasses, FailureClass(k)) {
+			return fmt.Errorf("unknown failure class %q", k)
+		}
+		if v.Attempts < 0 || v.TimeoutSeconds < 0 || v.BackoffMS < 0 || v.MaxBackoffMS < 0 {
+			return fmt.Errorf("%s: values must not be negative", k)
+		}
+	}
+	return nil
+}
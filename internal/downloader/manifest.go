@@ -0,0 +1,169 @@
+package downloader
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ghostlawless/xdl/internal/audit"
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+type ManifestStatus string
+
+const (
+	ManifestOK       ManifestStatus = "ok"
+	ManifestMismatch ManifestStatus = "mismatch"
+	ManifestMissing  ManifestStatus = "missing"
+	ManifestUnlisted ManifestStatus = "unlisted"
+)
+
+type ManifestResult struct {
+	Path   string
+	Status ManifestStatus
+	Want   string
+	Got    string
+	Err    error
+}
+
+var manifestMu sync.Mutex
+
+func RecordChecksums(runDir string, items []CheckpointItem) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	mf := paths.Manifest(runDir)
+	sums, err := ReadManifest(mf)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if sums == nil {
+		sums = map[string]string{}
+	}
+	n := 0
+	for _, it := range items {
+		if it.Path == "" || it.SHA256 == "" {
+			continue
+		}
+		rel, err := filepath.Rel(runDir, it.Path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if sums[rel] != it.SHA256 {
+			sums[rel] = it.SHA256
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	if err := utils.SaveToFile(mf, formatManifest(sums)); err != nil {
+		return err
+	}
+	audit.File(audit.FileWrite, mf, "")
+	return nil
+}
+
+func ReadManifest(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	out := map[string]string{}
+	sc := bufio.NewScanner(f)
+	for ln := 1; sc.Scan(); ln++ {
+		s := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(s) == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		sum, name, ok := strings.Cut(s, " ")
+		if !ok || len(sum) != sha256.Size*2 || len(name) < 2 || (name[0] != ' ' && name[0] != '*') {
+			return nil, fmt.Errorf("%s:%d: not a SHA256SUMS line", path, ln)
+		}
+		out[name[1:]] = strings.ToLower(sum)
+	}
+	return out, sc.Err()
+}
+
+func formatManifest(sums map[string]string) []byte {
+	names := make([]string, 0, len(sums))
+	for k := range sums {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, k := range names {
+		b.WriteString(sums[k])
+		b.WriteString("  ")
+		b.WriteString(k)
+		b.WriteByte('\n')
+	}
+	return []byte(b.String())
+}
+
+func VerifyChecksums(runDir string, each func(ManifestResult)) error {
+	sums, err := ReadManifest(paths.Manifest(runDir))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	names := make([]string, 0, len(sums))
+	for k := range sums {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		r := ManifestResult{Path: k, Want: sums[k]}
+		r.Got, r.Err = HashFile(filepath.Join(runDir, filepath.FromSlash(k)))
+		switch {
+		case os.IsNotExist(r.Err):
+			r.Status, r.Err = ManifestMissing, nil
+		case r.Err != nil, r.Got != r.Want:
+			r.Status = ManifestMismatch
+		default:
+			r.Status = ManifestOK
+		}
+		each(r)
+	}
+	for _, sub := range []string{paths.ImagesDir, paths.VideosDir} {
+		es, err := os.ReadDir(filepath.Join(runDir, sub))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, e := range es {
+			if e.IsDir() || strings.Contains(e.Name(), ".tmp-") || filepath.Ext(e.Name()) == ".json" || filepath.Ext(e.Name()) == ".txt" {
+				continue
+			}
+			k := sub + "/" + e.Name()
+			if _, ok := sums[k]; !ok {
+				each(ManifestResult{Path: k, Status: ManifestUnlisted})
+			}
+		}
+	}
+	return nil
+}
+
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
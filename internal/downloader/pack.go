@@ -0,0 +1,395 @@
+package downloader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/utils"
+	"github.com/klauspost/compress/zstd"
+)
+
+type packFormat int
+
+const (
+	packTar packFormat = iota
+	packTarGz
+	packTarZst
+	packZip
+)
+
+func packFormatOf(p string) (packFormat, error) {
+	l := strings.ToLower(p)
+	switch {
+	case strings.HasSuffix(l, ".zip"):
+		return packZip, nil
+	case strings.HasSuffix(l, ".tar.zst"), strings.HasSuffix(l, ".tzst"):
+		return packTarZst, nil
+	case strings.HasSuffix(l, ".tar.gz"), strings.HasSuffix(l, ".tgz"):
+		return packTarGz, nil
+	case strings.HasSuffix(l, ".tar"):
+		return packTar, nil
+	}
+	return 0, fmt.Errorf("unsupported archive type %q (use .tar, .tar.gz, .tar.zst or .zip)", filepath.Base(p))
+}
+
+// Pack collects downloaded files for -archive-output. New entries go to a
+// plain tar journal next to the archive, flushed after every file, and are
+// merged into the archive on Close. A journal left by a run that died is
+// picked up by the next OpenPack.
+type Pack struct {
+	path    string
+	f       packFormat
+	journal *os.File
+
+	mu     sync.Mutex
+	index  map[string]int64
+	jw     *tar.Writer
+	added  int
+	closed bool
+}
+
+func journalPath(p string) string { return p + ".partial.tar" }
+
+func OpenPack(p string) (*Pack, error) {
+	f, err := packFormatOf(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.EnsureDir(filepath.Dir(p)); err != nil {
+		return nil, err
+	}
+	k := &Pack{path: p, f: f, index: map[string]int64{}}
+	if err := k.eachExisting(func(name string, size int64, _ func(*tar.Writer, *zip.Writer) error) error {
+		k.index[name] = size
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("read existing %s: %w", filepath.Base(p), err)
+	}
+	if err := k.openJournal(); err != nil {
+		return nil, fmt.Errorf("open %s: %w", filepath.Base(journalPath(p)), err)
+	}
+	return k, nil
+}
+
+// openJournal keeps the complete entries of an earlier journal and cuts off
+// a file that was only partly written when that run died.
+func (k *Pack) openJournal() error {
+	j, err := os.OpenFile(journalPath(k.path), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	cr := &countingReader{r: j}
+	tr := tar.NewReader(cr)
+	var good int64
+	for {
+		h, err := tr.Next()
+		if err != nil {
+			break
+		}
+		end := cr.n
+		if n, err := io.Copy(io.Discard, tr); err != nil || n != h.Size {
+			break
+		}
+		good = end + (h.Size+511)/512*512
+		k.index[h.Name] = h.Size
+		k.added++
+	}
+	if err := j.Truncate(good); err != nil {
+		_ = j.Close()
+		return err
+	}
+	if _, err := j.Seek(good, io.SeekStart); err != nil {
+		_ = j.Close()
+		return err
+	}
+	k.journal, k.jw = j, tar.NewWriter(j)
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// eachExisting calls fn for every entry of the archive already on disk; copy
+// writes that entry into a new tar or zip writer.
+func (k *Pack) eachExisting(fn func(name string, size int64, cp func(*tar.Writer, *zip.Writer) error) error) error {
+	in, err := os.Open(k.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if k.f == packZip {
+		st, err := in.Stat()
+		if err != nil {
+			return err
+		}
+		zr, err := zip.NewReader(in, st.Size())
+		if err != nil {
+			return err
+		}
+		for _, e := range zr.File {
+			if err := fn(e.Name, int64(e.UncompressedSize64), func(_ *tar.Writer, zw *zip.Writer) error { return zw.Copy(e) }); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var r io.Reader = in
+	switch k.f {
+	case packTarGz:
+		gz, err := gzip.NewReader(in)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case packTarZst:
+		zd, err := zstd.NewReader(in)
+		if err != nil {
+			return err
+		}
+		defer zd.Close()
+		r = zd
+	}
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(h.Name, h.Size, func(tw *tar.Writer, _ *zip.Writer) error {
+			if err := tw.WriteHeader(h); err != nil {
+				return err
+			}
+			_, err := io.Copy(tw, tr)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+func (k *Pack) Path() string { return k.path }
+
+func (k *Pack) Sub(prefix string) Sink {
+	return packSink{k: k, prefix: filepath.ToSlash(prefix)}
+}
+
+func (k *Pack) Add(name string, data []byte) error {
+	return k.write(name, int64(len(data)), bytes.NewReader(data))
+}
+
+func (k *Pack) write(name string, size int64, r io.Reader) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.closed {
+		return fmt.Errorf("%s is already closed", filepath.Base(k.path))
+	}
+	if err := k.jw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: time.Now(), Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	if _, err := io.Copy(k.jw, r); err != nil {
+		return err
+	}
+	if err := k.jw.Flush(); err != nil {
+		return err
+	}
+	k.index[name] = size
+	k.added++
+	return nil
+}
+
+// Close merges the journal into the archive through a temporary file and a
+// rename, then removes the journal. Without new entries the archive is left
+// as it is.
+func (k *Pack) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.closed {
+		return nil
+	}
+	k.closed = true
+	err := k.jw.Flush()
+	if k.added == 0 {
+		_ = k.journal.Close()
+		if err == nil {
+			err = os.Remove(journalPath(k.path))
+		}
+		return err
+	}
+	if err == nil {
+		err = k.merge()
+	}
+	if e := k.journal.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	return os.Remove(journalPath(k.path))
+}
+
+func (k *Pack) merge() error {
+	tmp, err := os.CreateTemp(paths.TempPattern(k.path))
+	if err != nil {
+		return err
+	}
+	var (
+		tw  *tar.Writer
+		zw  *zip.Writer
+		enc io.WriteCloser
+	)
+	switch k.f {
+	case packZip:
+		zw = zip.NewWriter(tmp)
+	case packTarGz:
+		enc = gzip.NewWriter(tmp)
+		tw = tar.NewWriter(enc)
+	case packTarZst:
+		if enc, err = zstd.NewWriter(tmp); err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+			return err
+		}
+		tw = tar.NewWriter(enc)
+	default:
+		tw = tar.NewWriter(tmp)
+	}
+
+	err = k.eachExisting(func(_ string, _ int64, cp func(*tar.Writer, *zip.Writer) error) error {
+		return cp(tw, zw)
+	})
+	if err == nil {
+		err = k.copyJournal(tw, zw)
+	}
+	if err == nil && zw != nil {
+		err = zw.Close()
+	} else if err == nil {
+		err = tw.Close()
+	}
+	if enc != nil {
+		if e := enc.Close(); err == nil {
+			err = e
+		}
+	}
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return utils.ReplaceFile(tmp.Name(), k.path)
+}
+
+func (k *Pack) copyJournal(tw *tar.Writer, zw *zip.Writer) error {
+	if _, err := k.journal.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	tr := tar.NewReader(k.journal)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if zw == nil {
+			if err := tw.WriteHeader(h); err != nil {
+				return err
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				return err
+			}
+			continue
+		}
+		m := zip.Store
+		if path.Ext(h.Name) == ".json" {
+			m = zip.Deflate
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: h.Name, Method: m, Modified: h.ModTime})
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, tr); err != nil {
+			return err
+		}
+	}
+}
+
+type packSink struct {
+	k      *Pack
+	prefix string
+}
+
+func (packSink) Name() string { return "pack" }
+
+func (s packSink) name(rel string) string { return path.Join(s.prefix, filepath.ToSlash(rel)) }
+
+func (s packSink) Path(rel string) string {
+	return filepath.Join(s.k.path, filepath.FromSlash(s.name(rel)))
+}
+
+func (s packSink) Stat(rel string) (int64, bool) {
+	s.k.mu.Lock()
+	defer s.k.mu.Unlock()
+	n, ok := s.k.index[s.name(rel)]
+	return n, ok
+}
+
+func (s packSink) Create(string) (SinkWriter, error) {
+	f, err := os.CreateTemp(filepath.Dir(s.k.path), ".xdl-pack-*")
+	if err != nil {
+		return nil, err
+	}
+	return &packWriter{File: f, s: s}, nil
+}
+
+type packWriter struct {
+	*os.File
+	s packSink
+}
+
+func (w *packWriter) Commit(rel string) error {
+	defer w.Abort()
+	st, err := w.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return w.s.k.write(w.s.name(rel), st.Size(), w.File)
+}
+
+func (w *packWriter) Abort() {
+	_ = w.File.Close()
+	_ = os.Remove(w.Name())
+}
@@ -0,0 +1,83 @@
+package downloader
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/httpx"
+	"github.com/ghostlawless/xdl/internal/mediaurl"
+	"github.com/ghostlawless/xdl/internal/scraper"
+)
+
+const preflightConcurrency = 8
+
+type Preflight struct {
+	Live    []scraper.Media
+	Dead    []scraper.Media
+	Bytes   int64
+	Unknown int
+}
+
+// CheckURLs HEADs every media URL, trying the same fallbacks a download
+// would, and splits them into live and dead. Only a 404 or 410 on every
+// fallback counts as dead; anything else stays live with its size unknown.
+func CheckURLs(cl *http.Client, cf *config.EssentialsConfig, ms []scraper.Media, conc int, quit func() bool) Preflight {
+	if conc <= 0 {
+		conc = preflightConcurrency
+	}
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, conc)
+		size = make([]int64, len(ms))
+		dead = make([]bool, len(ms))
+	)
+	for i, m := range ms {
+		if quit != nil && quit() {
+			for j := i; j < len(ms); j++ {
+				size[j] = -1
+			}
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			size[i], dead[i] = headMedia(cl, cf, m.URL)
+		}()
+	}
+	wg.Wait()
+
+	var out Preflight
+	for i, m := range ms {
+		switch {
+		case dead[i]:
+			out.Dead = append(out.Dead, m)
+			continue
+		case size[i] > 0:
+			out.Bytes += size[i]
+		default:
+			out.Unknown++
+		}
+		out.Live = append(out.Live, m)
+	}
+	return out
+}
+
+func headMedia(cl *http.Client, cf *config.EssentialsConfig, u string) (int64, bool) {
+	for _, s := range mediaurl.Fallbacks(u) {
+		_, sz, _, st, err := httpx.Head(cl, cf.MediaURL(s), cf.X.Network)
+		switch {
+		case err != nil:
+			return -1, false
+		case st == http.StatusNotFound || st == http.StatusGone:
+			continue
+		case st == http.StatusOK:
+			return sz, false
+		default:
+			return -1, false
+		}
+	}
+	return -1, true
+}
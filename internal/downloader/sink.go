@@ -0,0 +1,73 @@
+package downloader
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+type Sink interface {
+	Name() string
+	Path(rel string) string
+	Stat(rel string) (int64, bool)
+	Create(rel string) (SinkWriter, error)
+}
+
+type SinkWriter interface {
+	io.Writer
+	Commit(rel string) error
+	Abort()
+}
+
+type DirSink struct {
+	Root string
+}
+
+func (DirSink) Name() string { return "dir" }
+
+func (s DirSink) Path(rel string) string { return filepath.Join(s.Root, rel) }
+
+func (s DirSink) Stat(rel string) (int64, bool) {
+	st, err := os.Stat(s.Path(rel))
+	if err != nil || st.IsDir() {
+		return 0, false
+	}
+	return st.Size(), true
+}
+
+func (s DirSink) Create(rel string) (SinkWriter, error) {
+	full := s.Path(rel)
+	if err := utils.EnsureDir(filepath.Dir(full)); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(paths.TempPattern(full))
+	if err != nil {
+		return nil, err
+	}
+	return &dirWriter{File: f, root: s.Root}, nil
+}
+
+type dirWriter struct {
+	*os.File
+	root string
+}
+
+func (w *dirWriter) Commit(rel string) error {
+	if err := w.File.Close(); err != nil {
+		_ = os.Remove(w.Name())
+		return err
+	}
+	if err := utils.ReplaceFile(w.Name(), filepath.Join(w.root, rel)); err != nil {
+		_ = os.Remove(w.Name())
+		return err
+	}
+	return nil
+}
+
+func (w *dirWriter) Abort() {
+	_ = w.File.Close()
+	_ = os.Remove(w.Name())
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/ghostlawless/xdl/internal/audit"
 	"github.com/ghostlawless/xdl/internal/scraper"
 	"github.com/ghostlawless/xdl/internal/utils"
 )
@@ -23,11 +24,23 @@ const (
 const checkpointVersion = 1
 
 type CheckpointItem struct {
-	Index  int              `json:"index"`
-	URL    string           `json:"url"`
-	Type   string           `json:"type"`
-	Status CheckpointStatus `json:"status"`
-	Size   int64            `json:"size"`
+	Index   int              `json:"index"`
+	URL     string           `json:"url"`
+	Type    string           `json:"type"`
+	Status  CheckpointStatus `json:"status"`
+	Size    int64            `json:"size"`
+	MIME    string           `json:"mime,omitempty"`
+	TweetID string           `json:"tweet_id,omitempty"`
+	Path    string           `json:"path,omitempty"`
+	SHA256  string           `json:"sha256,omitempty"`
+	AltText string           `json:"alt_text,omitempty"`
+	Geo     *scraper.Geo     `json:"geo,omitempty"`
+	Card    string           `json:"card,omitempty"`
+	Poll    *scraper.Poll    `json:"poll,omitempty"`
+	Pinned  bool             `json:"pinned,omitempty"`
+	Author  string           `json:"author,omitempty"`
+	Class   FailureClass     `json:"class,omitempty"`
+	Error   string           `json:"error,omitempty"`
 }
 
 type Checkpoint struct {
@@ -44,7 +57,7 @@ func NewCheckpoint(user, runID string, medias []scraper.Media) *Checkpoint {
 	t := time.Now().UTC()
 	items := make([]CheckpointItem, len(medias))
 	for i, m := range medias {
-		items[i] = CheckpointItem{Index: i, URL: m.URL, Type: m.Type, Status: CheckpointPending}
+		items[i] = CheckpointItem{Index: i, URL: m.URL, Type: m.Type, TweetID: m.TweetID, AltText: m.AltText, Geo: m.Geo, Card: m.Card, Poll: m.Poll, Pinned: m.Pinned, Author: m.Author, Status: CheckpointPending}
 	}
 	cp := &Checkpoint{
 		Version:   checkpointVersion,
@@ -98,6 +111,76 @@ func (c *Checkpoint) MarkByURL(url string, status CheckpointStatus, size int64)
 	c.MarkByIndex(i, status, size)
 }
 
+func (c *Checkpoint) SetMIME(url, mime string) {
+	if c == nil || url == "" || mime == "" {
+		return
+	}
+	if c.urlIndex == nil {
+		c.buildIndex()
+	}
+	i, ok := c.urlIndex[url]
+	if !ok {
+		return
+	}
+	c.Items[i].MIME = mime
+}
+
+func (c *Checkpoint) SetFile(url, path, sum string) {
+	if c == nil || url == "" {
+		return
+	}
+	if c.urlIndex == nil {
+		c.buildIndex()
+	}
+	i, ok := c.urlIndex[url]
+	if !ok {
+		return
+	}
+	c.Items[i].Path = path
+	c.Items[i].SHA256 = sum
+}
+
+func (c *Checkpoint) SetFailure(url string, class FailureClass, msg string) {
+	if c == nil || url == "" {
+		return
+	}
+	if c.urlIndex == nil {
+		c.buildIndex()
+	}
+	i, ok := c.urlIndex[url]
+	if !ok {
+		return
+	}
+	c.Items[i].Class = class
+	c.Items[i].Error = msg
+}
+
+func (c *Checkpoint) DoneItems() []CheckpointItem {
+	if c == nil {
+		return nil
+	}
+	out := make([]CheckpointItem, 0, len(c.Items))
+	for _, it := range c.Items {
+		if it.Status == CheckpointDone {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+func (c *Checkpoint) FailedItems() []CheckpointItem {
+	if c == nil {
+		return nil
+	}
+	out := make([]CheckpointItem, 0)
+	for _, it := range c.Items {
+		if it.Status == CheckpointFailed {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
 func (c *Checkpoint) PendingItems() []CheckpointItem {
 	if c == nil {
 		return nil
@@ -144,7 +227,11 @@ func (c *Checkpoint) Save(path string) error {
 	if err != nil {
 		return err
 	}
-	return utils.SaveToFile(path, data)
+	if err := utils.SaveToFile(path, data); err != nil {
+		return err
+	}
+	audit.File(audit.FileWrite, path, "")
+	return nil
 }
 
 func LoadCheckpoint(path string) (*Checkpoint, error) {
@@ -0,0 +1,197 @@
+package errs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrUserSuspended    = errors.New("user suspended")
+	ErrProtectedAccount = errors.New("protected account")
+	ErrRateLimited      = errors.New("rate limited")
+	ErrAuthExpired      = errors.New("authentication expired")
+	ErrShapeChanged     = errors.New("response shape changed, update needed")
+)
+
+type APIError struct {
+	Op      string
+	Status  int
+	Code    int
+	Message string
+	Kind    error
+	Reset   time.Time
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Op)
+	b.WriteString(": ")
+	if e.Message != "" {
+		b.WriteString(e.Message)
+	} else if e.Kind != nil {
+		b.WriteString(e.Kind.Error())
+	} else {
+		b.WriteString("request failed")
+	}
+	if e.Status > 0 || e.Code > 0 {
+		fmt.Fprintf(&b, " (status %d, code %d)", e.Status, e.Code)
+	}
+	if !e.Reset.IsZero() {
+		fmt.Fprintf(&b, "; resets at %s", e.Reset.Format(time.RFC3339))
+	}
+	return b.String()
+}
+
+func (e *APIError) Unwrap() error { return e.Kind }
+
+func FromStatus(op string, st int, err error) error {
+	if err == nil {
+		return nil
+	}
+	var kind error
+	switch st {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		kind = ErrAuthExpired
+	case http.StatusTooManyRequests:
+		kind = ErrRateLimited
+	default:
+		return err
+	}
+	return &APIError{Op: op, Status: st, Message: err.Error(), Kind: kind}
+}
+
+func WithReset(err error, h http.Header) error {
+	var ae *APIError
+	if !errors.As(err, &ae) || !errors.Is(ae.Kind, ErrRateLimited) || h == nil {
+		return err
+	}
+	if n, perr := strconv.ParseInt(strings.TrimSpace(h.Get("x-rate-limit-reset")), 10, 64); perr == nil && n > 0 {
+		ae.Reset = time.Unix(n, 0)
+	}
+	return err
+}
+
+func ResetTime(err error) time.Time {
+	var ae *APIError
+	if errors.As(err, &ae) {
+		return ae.Reset
+	}
+	return time.Time{}
+}
+
+type graphQLErrors struct {
+	Errors []struct {
+		Message string `json:"message"`
+		Code    int    `json:"code"`
+		Name    string `json:"name"`
+		Kind    string `json:"kind"`
+	} `json:"errors"`
+}
+
+func FromGraphQL(op string, st int, body []byte) error {
+	if len(body) == 0 {
+		return nil
+	}
+	var ge graphQLErrors
+	if err := json.Unmarshal(body, &ge); err != nil || len(ge.Errors) == 0 {
+		return nil
+	}
+	e := ge.Errors[0]
+	return &APIError{Op: op, Status: st, Code: e.Code, Message: e.Message, Kind: kindForCode(e.Code, e.Message)}
+}
+
+func kindForCode(code int, msg string) error {
+	switch code {
+	case 88:
+		return ErrRateLimited
+	case 32, 89, 215, 239, 353:
+		return ErrAuthExpired
+	case 50:
+		return ErrUserNotFound
+	case 63, 64:
+		return ErrUserSuspended
+	case 179:
+		return ErrProtectedAccount
+	}
+	m := strings.ToLower(msg)
+	switch {
+	case strings.Contains(m, "rate limit"):
+		return ErrRateLimited
+	case strings.Contains(m, "suspended"):
+		return ErrUserSuspended
+	case strings.Contains(m, "not found"), strings.Contains(m, "does not exist"):
+		return ErrUserNotFound
+	case strings.Contains(m, "authenticate"), strings.Contains(m, "expired token"):
+		return ErrAuthExpired
+	}
+	return nil
+}
+
+type userResult struct {
+	Data struct {
+		User struct {
+			Result struct {
+				Typename string `json:"__typename"`
+				Reason   string `json:"reason"`
+			} `json:"result"`
+		} `json:"user"`
+	} `json:"data"`
+}
+
+func FromUserResult(op string, body []byte) error {
+	var ur userResult
+	if err := json.Unmarshal(body, &ur); err != nil {
+		return nil
+	}
+	r := ur.Data.User.Result
+	if r.Typename != "UserUnavailable" {
+		return nil
+	}
+	kind := ErrUserNotFound
+	if strings.EqualFold(r.Reason, "Suspended") || strings.EqualFold(r.Reason, "Deactivated") {
+		kind = ErrUserSuspended
+	}
+	msg := "user unavailable"
+	if r.Reason != "" {
+		msg += ": " + strings.ToLower(r.Reason)
+	}
+	return &APIError{Op: op, Status: http.StatusOK, Message: msg, Kind: kind}
+}
+
+var (
+	reFeaturesNull    = regexp.MustCompile(`(?i)features cannot be null:\s*([\w,\s]+)`)
+	reFeaturesUnknown = regexp.MustCompile(`(?i)(?:unknown|unsupported|not supported)[^:]*features?:\s*([\w,\s]+)`)
+)
+
+func FeatureErrors(body []byte) (missing, unknown []string) {
+	var ge graphQLErrors
+	if err := json.Unmarshal(body, &ge); err != nil {
+		return nil, nil
+	}
+	for _, e := range ge.Errors {
+		if m := reFeaturesNull.FindStringSubmatch(e.Message); m != nil {
+			missing = append(missing, splitFeatureList(m[1])...)
+		}
+		if m := reFeaturesUnknown.FindStringSubmatch(e.Message); m != nil {
+			unknown = append(unknown, splitFeatureList(m[1])...)
+		}
+	}
+	return missing, unknown
+}
+
+func splitFeatureList(s string) []string {
+	var out []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
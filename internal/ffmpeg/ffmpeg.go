@@ -0,0 +1,101 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minMajor   = 4
+	runTimeout = 10 * time.Minute
+)
+
+var ErrUnavailable = errors.New("ffmpeg not found")
+
+type Tool struct {
+	FFmpeg  string
+	Version string
+}
+
+var find = sync.OnceValues(locate)
+
+func Find() (*Tool, error) { return find() }
+
+func Unavailable(feature string) error {
+	_, err := Find()
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%s is unavailable: %w; install ffmpeg %d or newer and make sure it is on PATH (or set XDL_FFMPEG)", feature, err, minMajor)
+}
+
+func locate() (*Tool, error) {
+	ff := strings.TrimSpace(os.Getenv("XDL_FFMPEG"))
+	if ff == "" {
+		p, err := exec.LookPath("ffmpeg")
+		if err != nil {
+			return nil, ErrUnavailable
+		}
+		ff = p
+	}
+	out, err := exec.Command(ff, "-hide_banner", "-version").Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s -version failed: %v", ErrUnavailable, ff, err)
+	}
+	v := parseVersion(string(out))
+	if n := majorOf(v); n > 0 && n < minMajor {
+		return nil, fmt.Errorf("%w: %s is version %s, need %d or newer", ErrUnavailable, ff, v, minMajor)
+	}
+	return &Tool{FFmpeg: ff, Version: v}, nil
+}
+
+var versionRe = regexp.MustCompile(`(?m)^ffmpeg version n?([0-9][^\s]*)`)
+
+func parseVersion(s string) string {
+	if m := versionRe.FindStringSubmatch(s); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+func majorOf(v string) int {
+	i := strings.IndexAny(v, ".-")
+	if i > 0 {
+		v = v[:i]
+	}
+	n, _ := strconv.Atoi(v)
+	return n
+}
+
+func (t *Tool) run(ctx context.Context, args ...string) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, runTimeout)
+		defer cancel()
+	}
+	args = append([]string{"-hide_banner", "-loglevel", "error", "-nostdin", "-y"}, args...)
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.FFmpeg, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if i := strings.LastIndexByte(msg, '\n'); i >= 0 {
+			msg = msg[i+1:]
+		}
+		return fmt.Errorf("ffmpeg: %v: %s", err, msg)
+	}
+	return nil
+}
+
+func (t *Tool) Thumbnail(ctx context.Context, in, out string, width int) error {
+	return t.run(ctx, "-i", in, "-frames:v", "1", "-vf", fmt.Sprintf("scale='min(%d,iw)':-2", width), out)
+}
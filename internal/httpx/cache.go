@@ -0,0 +1,131 @@
+package httpx
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+type Cache struct {
+	Dir string
+	TTL time.Duration
+}
+
+type cacheEntry struct {
+	Stored       time.Time `json:"stored"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Body         []byte    `json:"body"`
+}
+
+func NewCache(dir string, ttl time.Duration) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Cache{Dir: dir, TTL: ttl}, nil
+}
+
+func CacheKey(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.Dir, key[:2], key+".json")
+}
+
+func (c *Cache) load(key string) (cacheEntry, bool) {
+	var e cacheEntry
+	b, err := os.ReadFile(c.path(key))
+	if err != nil || json.Unmarshal(b, &e) != nil {
+		return cacheEntry{}, false
+	}
+	return e, true
+}
+
+func (c *Cache) store(key string, e cacheEntry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	_ = utils.SaveToFile(c.path(key), b)
+}
+
+func (c *Cache) Prune(maxAge time.Duration) int {
+	if c == nil {
+		return 0
+	}
+	n := 0
+	_ = filepath.WalkDir(c.Dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, ".json") {
+			return nil
+		}
+		if fi, err := d.Info(); err == nil && time.Since(fi.ModTime()) > maxAge {
+			if os.Remove(p) == nil {
+				n++
+			}
+		}
+		return nil
+	})
+	return n
+}
+
+func DoCached(cl *http.Client, rq *http.Request, op RequestOptions, c *Cache, key string) ([]byte, int, bool, error) {
+	if c == nil || rq.Method != http.MethodGet {
+		b, st, err := DoRequestWithOptions(cl, rq, op)
+		return b, st, false, err
+	}
+	e, ok := c.load(key)
+	if ok && time.Since(e.Stored) < c.TTL {
+		if op.Header != nil {
+			*op.Header = http.Header{}
+		}
+		return e.Body, http.StatusOK, true, nil
+	}
+	if ok {
+		if e.ETag != "" {
+			rq.Header.Set("If-None-Match", e.ETag)
+		}
+		if e.LastModified != "" {
+			rq.Header.Set("If-Modified-Since", e.LastModified)
+		}
+	}
+	accept := op.Accept
+	if accept == nil {
+		accept = func(s int) bool { return s >= 200 && s < 300 }
+	}
+	op.Accept = func(s int) bool { return (ok && s == http.StatusNotModified) || accept(s) }
+
+	var h http.Header
+	if op.Header == nil {
+		op.Header = &h
+	}
+	b, st, err := DoRequestWithOptions(cl, rq, op)
+	switch {
+	case err != nil:
+		return b, st, false, err
+	case st == http.StatusNotModified:
+		e.Stored = time.Now()
+		c.store(key, e)
+		return e.Body, http.StatusOK, true, nil
+	case st == http.StatusOK && len(b) > 0:
+		c.store(key, cacheEntry{
+			Stored:       time.Now(),
+			ETag:         (*op.Header).Get("ETag"),
+			LastModified: (*op.Header).Get("Last-Modified"),
+			Body:         b,
+		})
+	}
+	return b, st, false, nil
+}
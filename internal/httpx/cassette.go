@@ -0,0 +1,184 @@
+package httpx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+type Interaction struct {
+	Method        string      `json:"method"`
+	URL           string      `json:"url"`
+	RequestHeader http.Header `json:"request_header,omitempty"`
+	Status        int         `json:"status"`
+	Header        http.Header `json:"header,omitempty"`
+	Body          string      `json:"body,omitempty"`
+}
+
+type Cassette struct {
+	Path   string
+	Redact func([]byte) []byte
+
+	next   http.RoundTripper
+	replay bool
+
+	mu     sync.Mutex
+	f      *os.File
+	tape   []Interaction
+	used   []bool
+	exact  map[string][]int
+	byPath map[string][]int
+}
+
+var cassetteSecretHeaders = []string{"authorization", "cookie", "set-cookie", "x-csrf-token", "x-guest-token", "x-client-transaction-id"}
+
+func RecordCassette(path string, next http.RoundTripper) (*Cassette, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &Cassette{Path: path, next: next, f: f}, nil
+}
+
+func ReplayCassette(path string) (*Cassette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c := &Cassette{Path: path, replay: true, exact: map[string][]int{}, byPath: map[string][]int{}}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 64<<20)
+	for ln := 1; sc.Scan(); ln++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var it Interaction
+		if err := json.Unmarshal(sc.Bytes(), &it); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, ln, err)
+		}
+		i := len(c.tape)
+		c.tape = append(c.tape, it)
+		k := it.Method + " " + it.URL
+		c.exact[k] = append(c.exact[k], i)
+		p := it.Method + " " + stripQuery(it.URL)
+		c.byPath[p] = append(c.byPath[p], i)
+	}
+	c.used = make([]bool, len(c.tape))
+	return c, sc.Err()
+}
+
+func (c *Cassette) Replaying() bool {
+	return c != nil && c.replay
+}
+
+func (c *Cassette) RoundTrip(rq *http.Request) (*http.Response, error) {
+	if c.replay {
+		return c.play(rq)
+	}
+	res, err := c.next.RoundTrip(rq)
+	if err != nil {
+		return nil, err
+	}
+	b, err := DecodeWithLimit(res, 0)
+	if err != nil {
+		return nil, err
+	}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = int64(len(b))
+	res.Body = io.NopCloser(bytes.NewReader(b))
+
+	body := b
+	if c.Redact != nil {
+		body = c.Redact(bytes.Clone(b))
+	}
+	it := Interaction{
+		Method:        rq.Method,
+		URL:           rq.URL.String(),
+		RequestHeader: scrubHeader(rq.Header),
+		Status:        res.StatusCode,
+		Header:        scrubHeader(res.Header),
+		Body:          string(body),
+	}
+	line, err := json.Marshal(it)
+	if err != nil {
+		return res, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, _ = c.f.Write(append(line, '\n'))
+	return res, nil
+}
+
+func (c *Cassette) play(rq *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i, ok := c.shift(c.exact, rq.Method+" "+rq.URL.String())
+	if !ok {
+		i, ok = c.shift(c.byPath, rq.Method+" "+stripQuery(rq.URL.String()))
+	}
+	if !ok {
+		return nil, fmt.Errorf("cassette %s has no recorded response for %s %s", c.Path, rq.Method, stripQuery(rq.URL.String()))
+	}
+	it := c.tape[i]
+	h := it.Header.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", it.Status, http.StatusText(it.Status)),
+		StatusCode:    it.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(strings.NewReader(it.Body)),
+		ContentLength: int64(len(it.Body)),
+		Request:       rq,
+	}, nil
+}
+
+func (c *Cassette) Close() error {
+	if c == nil || c.f == nil {
+		return nil
+	}
+	return c.f.Close()
+}
+
+func (c *Cassette) shift(m map[string][]int, k string) (int, bool) {
+	q := m[k]
+	for len(q) > 0 && c.used[q[0]] {
+		q = q[1:]
+	}
+	m[k] = q
+	if len(q) == 0 {
+		return 0, false
+	}
+	c.used[q[0]] = true
+	return q[0], true
+}
+
+func stripQuery(u string) string {
+	p, _, _ := strings.Cut(u, "?")
+	return p
+}
+
+func scrubHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, k := range cassetteSecretHeaders {
+		if len(out.Values(k)) > 0 {
+			out.Set(k, "[REDACTED]")
+		}
+	}
+	return out
+}
@@ -0,0 +1,187 @@
+package httpx
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const secret = "s3cr3t-token"
+
+func cassetteServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "ct0="+secret)
+		w.Header().Set("X-Page", r.URL.Query().Get("cursor"))
+		switch r.URL.Path {
+		case "/gz":
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			_, _ = gz.Write([]byte(`{"zipped":true}`))
+			_ = gz.Close()
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, "nope")
+		default:
+			_, _ = io.WriteString(w, `{"page":"`+r.URL.Query().Get("cursor")+`","token":"`+secret+`"}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, rt http.RoundTripper, url string, h map[string]string) (*http.Response, string) {
+	t.Helper()
+	rq, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range h {
+		rq.Header.Set(k, v)
+	}
+	res, err := rt.RoundTrip(rq)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, string(b)
+}
+
+func TestCassetteRoundTrip(t *testing.T) {
+	srv := cassetteServer(t)
+	p := filepath.Join(t.TempDir(), "tape.jsonl")
+
+	rec, err := RecordCassette(p, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Redact = func(b []byte) []byte { return bytes.ReplaceAll(b, []byte(secret), []byte("[REDACTED]")) }
+	hdr := map[string]string{"Authorization": "Bearer " + secret, "Cookie": "auth_token=" + secret, "X-Csrf-Token": secret}
+
+	live := map[string]string{}
+	for _, u := range []string{"/api?cursor=1", "/api?cursor=2", "/missing"} {
+		_, body := get(t, rec, srv.URL+u, hdr)
+		live[u] = body
+	}
+	if !strings.Contains(live["/api?cursor=1"], secret) {
+		t.Errorf("recording handed the caller a redacted body: %s", live["/api?cursor=1"])
+	}
+	res, body := get(t, rec, srv.URL+"/gz", map[string]string{"Accept-Encoding": "gzip"})
+	if body != `{"zipped":true}` || res.Header.Get("Content-Encoding") != "" {
+		t.Errorf("gzip response = %q (encoding %q), want it decoded", body, res.Header.Get("Content-Encoding"))
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte(secret)) {
+		t.Errorf("cassette leaks the secret:\n%s", raw)
+	}
+	sc := bufio.NewScanner(bytes.NewReader(raw))
+	var n int
+	for sc.Scan() {
+		var it Interaction
+		if err := json.Unmarshal(sc.Bytes(), &it); err != nil {
+			t.Fatalf("line %d: %v", n+1, err)
+		}
+		n++
+		for _, k := range []string{"Authorization", "Cookie", "X-Csrf-Token"} {
+			if v := it.RequestHeader.Get(k); v != "" && v != "[REDACTED]" {
+				t.Errorf("%s request header %s = %q", it.URL, k, v)
+			}
+		}
+		if v := it.Header.Get("Set-Cookie"); v != "[REDACTED]" {
+			t.Errorf("%s Set-Cookie = %q", it.URL, v)
+		}
+	}
+	if n != 4 {
+		t.Fatalf("cassette has %d interactions, want 4", n)
+	}
+
+	play, err := ReplayCassette(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !play.Replaying() {
+		t.Error("Replaying() = false")
+	}
+	// Exact matches come first, whatever order they are asked for in.
+	for _, u := range []string{"/api?cursor=2", "/api?cursor=1"} {
+		res, body := get(t, play, srv.URL+u, nil)
+		want := strings.ReplaceAll(live[u], secret, "[REDACTED]")
+		if res.StatusCode != http.StatusOK || body != want {
+			t.Errorf("replay %s = %d %q, want 200 %q", u, res.StatusCode, body, want)
+		}
+		if res.Header.Get("X-Page") != u[len(u)-1:] {
+			t.Errorf("replay %s X-Page = %q", u, res.Header.Get("X-Page"))
+		}
+	}
+	res, body = get(t, play, srv.URL+"/missing", nil)
+	if res.StatusCode != http.StatusNotFound || body != "nope" {
+		t.Errorf("replay /missing = %d %q", res.StatusCode, body)
+	}
+	res, body = get(t, play, srv.URL+"/gz?v=2", nil)
+	if body != `{"zipped":true}` {
+		t.Errorf("replay by path = %q", body)
+	}
+
+	// Every interaction is used once.
+	rq, _ := http.NewRequest(http.MethodGet, srv.URL+"/api?cursor=1", nil)
+	if _, err := play.RoundTrip(rq); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("replaying a spent interaction: err = %v", err)
+	}
+}
+
+func TestCassetteReplayByPathInOrder(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "tape.jsonl")
+	var buf bytes.Buffer
+	for _, it := range []Interaction{
+		{Method: "GET", URL: "https://x.com/i/api/graphql/q/UserMedia?variables=a", Status: 200, Body: "first"},
+		{Method: "GET", URL: "https://x.com/i/api/graphql/q/UserMedia?variables=b", Status: 200, Body: "second"},
+		{Method: "POST", URL: "https://x.com/i/api/graphql/q/UserMedia?variables=c", Status: 200, Body: "post"},
+	} {
+		b, _ := json.Marshal(it)
+		buf.Write(append(b, '\n'))
+	}
+	buf.WriteString("\n")
+	if err := os.WriteFile(p, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := ReplayCassette(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := "https://x.com/i/api/graphql/q/UserMedia?variables=changed"
+	for _, want := range []string{"first", "second"} {
+		if _, body := get(t, c, u, nil); body != want {
+			t.Errorf("body = %q, want %q", body, want)
+		}
+	}
+	rq, _ := http.NewRequest(http.MethodGet, u, nil)
+	if _, err := c.RoundTrip(rq); err == nil {
+		t.Error("expected an error once the GETs are spent")
+	}
+
+	if err := os.WriteFile(p, []byte("{not json}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReplayCassette(p); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("bad line: err = %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package httpx
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+func NewAPIClient(x0 time.Duration, o0 []string) *http.Client {
+	n0 := &net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	a0 := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 15 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	p0 := currentTransportOptions()
+	p0.apply(a0, n0)
+
+	if x0 <= 0 {
+		x0 = 15 * time.Second
+	}
+
+	f0 := fingerprintDialer(TLSFingerprint(), p0.dialer(&net.Dialer{Timeout: 5 * time.Second, KeepAlive: n0.KeepAlive}))
+	if f0 != nil {
+		a0.DialTLSContext = f0
+		a0.ForceAttemptHTTP2 = false
+	}
+
+	if len(o0) > 0 {
+		d0 := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: n0.KeepAlive}
+		t0 := NewOrderedTransport(o0, d0, a0)
+		t0.DialTLS = f0
+		t0.Network = p0.Network
+		return &http.Client{Transport: counting("api", traced(t0)), Timeout: x0}
+	}
+
+	return &http.Client{Transport: counting("api", traced(a0)), Timeout: x0}
+
+}
+
+func NewDownloadClient() *http.Client {
+	a0 := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          200,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       120 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	n0 := &net.Dialer{
+		Timeout:   7 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	currentTransportOptions().apply(a0, n0)
+
+	return &http.Client{Transport: counting("download", a0), Timeout: 0}
+
+}
@@ -0,0 +1,94 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+var fingerprints = map[string]utls.ClientHelloID{
+	"chrome":  utls.HelloChrome_Auto,
+	"firefox": utls.HelloFirefox_Auto,
+	"android": utls.HelloAndroid_11_OkHttp,
+}
+
+var (
+	fingerprintMu sync.RWMutex
+	fingerprint   string
+)
+
+func UseTLSFingerprint(name, profile string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "", "off", "go":
+		name = ""
+	case "profile":
+		name = strings.ToLower(strings.TrimSpace(profile))
+		if name == "" {
+			return fmt.Errorf("tls_fingerprint is \"profile\" but header_profile is empty")
+		}
+	}
+	if _, ok := fingerprints[name]; name != "" && !ok {
+		return fmt.Errorf("unknown TLS fingerprint %q (want off, profile, chrome, firefox or android)", name)
+	}
+	fingerprintMu.Lock()
+	fingerprint = name
+	fingerprintMu.Unlock()
+	return nil
+}
+
+func TLSFingerprint() string {
+	fingerprintMu.RLock()
+	defer fingerprintMu.RUnlock()
+	return fingerprint
+}
+
+func fingerprintDialer(name string, dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	id, ok := fingerprints[name]
+	if !ok {
+		return nil
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		nc, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		sn, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			sn = addr
+		}
+		spec, err := utls.UTLSIdToSpec(id)
+		if err != nil {
+			nc.Close()
+			return nil, err
+		}
+		onlyHTTP1(&spec)
+		uc := utls.UClient(nc, &utls.Config{ServerName: sn}, utls.HelloCustom)
+		if err := uc.ApplyPreset(&spec); err != nil {
+			nc.Close()
+			return nil, err
+		}
+		if err := uc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, err
+		}
+		return uc, nil
+	}
+}
+
+func onlyHTTP1(spec *utls.ClientHelloSpec) {
+	for _, ext := range spec.Extensions {
+		switch e := ext.(type) {
+		case *utls.ALPNExtension:
+			e.AlpnProtocols = []string{"http/1.1"}
+		case *utls.ApplicationSettingsExtension:
+			e.SupportedProtocols = []string{"http/1.1"}
+		case *utls.ApplicationSettingsExtensionNew:
+			e.SupportedProtocols = []string{"http/1.1"}
+		}
+	}
+}
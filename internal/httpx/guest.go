@@ -0,0 +1,36 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const GuestActivateURL = "https://api.x.com/1.1/guest/activate.json"
+
+func ActivateGuestToken(cl *http.Client, bearer string) (string, error) {
+	if cl == nil {
+		return "", errors.New("nil client")
+	}
+	if strings.TrimSpace(bearer) == "" {
+		return "", errors.New("no bearer token in essentials.json")
+	}
+	rq, err := http.NewRequest(http.MethodPost, GuestActivateURL, nil)
+	if err != nil {
+		return "", err
+	}
+	rq.Header.Set("Authorization", "Bearer "+bearer)
+	b, st, err := DoRequestWithOptions(cl, rq, RequestOptions{MaxBytes: 64 << 10, Decode: true})
+	if err != nil {
+		return "", fmt.Errorf("guest activation failed (status %d): %w", st, err)
+	}
+	var r struct {
+		GuestToken string `json:"guest_token"`
+	}
+	if err := json.Unmarshal(b, &r); err != nil || r.GuestToken == "" {
+		return "", fmt.Errorf("guest activation returned no token (status %d)", st)
+	}
+	return r.GuestToken, nil
+}
@@ -0,0 +1,248 @@
+package httpx
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	xlog "github.com/ghostlawless/xdl/internal/log"
+)
+
+const (
+	TraceHeader  = "X-Xdl-Trace-Id"
+	harBodyLimit = 64 << 10
+)
+
+type harNV struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harEntry struct {
+	Started  time.Time `json:"startedDateTime"`
+	Time     float64   `json:"time"`
+	Request  harReq    `json:"request"`
+	Response harRes    `json:"response"`
+	Cache    struct{}  `json:"cache"`
+	Timings  harTiming `json:"timings"`
+	Comment  string    `json:"comment,omitempty"`
+}
+
+type harReq struct {
+	Method      string  `json:"method"`
+	URL         string  `json:"url"`
+	HTTPVersion string  `json:"httpVersion"`
+	Headers     []harNV `json:"headers"`
+	QueryString []harNV `json:"queryString"`
+	Cookies     []harNV `json:"cookies"`
+	HeadersSize int     `json:"headersSize"`
+	BodySize    int     `json:"bodySize"`
+}
+
+type harRes struct {
+	Status      int        `json:"status"`
+	StatusText  string     `json:"statusText"`
+	HTTPVersion string     `json:"httpVersion"`
+	Headers     []harNV    `json:"headers"`
+	Cookies     []harNV    `json:"cookies"`
+	Content     harContent `json:"content"`
+	RedirectURL string     `json:"redirectURL"`
+	HeadersSize int        `json:"headersSize"`
+	BodySize    int        `json:"bodySize"`
+	Comment     string     `json:"comment,omitempty"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+}
+
+type harTiming struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+type harLog struct {
+	path    string
+	mu      sync.Mutex
+	entries []harEntry
+}
+
+var (
+	harMu   sync.Mutex
+	harSink *harLog
+)
+
+func RecordHAR(path string) (func() error, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	h := &harLog{path: path}
+	harMu.Lock()
+	harSink = h
+	harMu.Unlock()
+	return func() error {
+		harMu.Lock()
+		if harSink == h {
+			harSink = nil
+		}
+		harMu.Unlock()
+		return h.write()
+	}, nil
+}
+
+func (h *harLog) write() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	doc := map[string]any{"log": map[string]any{
+		"version": "1.2",
+		"creator": map[string]string{"name": "xdl", "version": "1"},
+		"entries": h.entries,
+	}}
+	b, err := json.MarshalIndent(doc, "", " ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(h.path, b, 0o644)
+}
+
+func currentHAR() *harLog {
+	harMu.Lock()
+	defer harMu.Unlock()
+	return harSink
+}
+
+func NewTraceID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// LogTraces logs every API request with its trace ID at info level instead
+// of debug, so -d runs show them without a debug log level.
+var LogTraces bool
+
+func logTrace(msg string) {
+	if LogTraces {
+		xlog.LogInfo("http", msg)
+		return
+	}
+	xlog.LogDebug("http", msg)
+}
+
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+func traced(next http.RoundTripper) http.RoundTripper {
+	return &tracingTransport{next: next}
+}
+
+func (t *tracingTransport) RoundTrip(rq *http.Request) (*http.Response, error) {
+	id := NewTraceID()
+	t0 := time.Now()
+	res, err := t.next.RoundTrip(rq)
+	d := time.Since(t0)
+	if err != nil {
+		logTrace(fmt.Sprintf("[%s] %s %s failed after %s: %v", id, rq.Method, stripQuery(rq.URL.String()), d.Round(time.Millisecond), err))
+		return nil, err
+	}
+	logTrace(fmt.Sprintf("[%s] %s %s -> %d in %s", id, rq.Method, stripQuery(rq.URL.String()), res.StatusCode, d.Round(time.Millisecond)))
+	res.Header.Set(TraceHeader, id)
+	if h := currentHAR(); h != nil {
+		h.add(id, rq, res, t0, d)
+	}
+	return res, nil
+}
+
+func (t *tracingTransport) CloseIdleConnections() {
+	if c, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+func (h *harLog) add(id string, rq *http.Request, res *http.Response, t0 time.Time, d time.Duration) {
+	b, err := DecodeWithLimit(res, 0)
+	note := ""
+	if err != nil {
+		note = "body not captured: " + err.Error()
+	}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = int64(len(b))
+	res.Body = io.NopCloser(bytes.NewReader(b))
+
+	size := len(b)
+	if len(b) > harBodyLimit {
+		b = b[:harBodyLimit]
+		note = fmt.Sprintf("body truncated to %d of %d bytes", harBodyLimit, size)
+	}
+	var qs []harNV
+	for k, vs := range rq.URL.Query() {
+		for _, v := range vs {
+			qs = append(qs, harNV{Name: k, Value: v})
+		}
+	}
+	sort.Slice(qs, func(i, j int) bool { return qs[i].Name < qs[j].Name })
+	e := harEntry{
+		Started: t0,
+		Time:    float64(d.Microseconds()) / 1000,
+		Request: harReq{
+			Method:      rq.Method,
+			URL:         rq.URL.String(),
+			HTTPVersion: "HTTP/1.1",
+			Headers:     harHeaders(rq.Header),
+			QueryString: qs,
+			Cookies:     []harNV{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Response: harRes{
+			Status:      res.StatusCode,
+			StatusText:  http.StatusText(res.StatusCode),
+			HTTPVersion: res.Proto,
+			Headers:     harHeaders(res.Header),
+			Cookies:     []harNV{},
+			Content:     harContent{Size: size, MimeType: res.Header.Get("Content-Type"), Text: string(b)},
+			HeadersSize: -1,
+			BodySize:    size,
+			Comment:     note,
+		},
+		Timings: harTiming{Wait: float64(d.Microseconds()) / 1000},
+		Comment: "trace " + id,
+	}
+	if e.Request.QueryString == nil {
+		e.Request.QueryString = []harNV{}
+	}
+	h.mu.Lock()
+	h.entries = append(h.entries, e)
+	h.mu.Unlock()
+}
+
+func harHeaders(h http.Header) []harNV {
+	s := scrubHeader(h)
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]harNV, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range s[k] {
+			out = append(out, harNV{Name: k, Value: v})
+		}
+	}
+	return out
+}
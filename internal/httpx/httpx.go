@@ -10,10 +10,12 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/utils"
+
 	"github.com/andybalholm/brotli"
 	"github.com/klauspost/compress/zstd"
 )
@@ -23,6 +25,7 @@ type RequestOptions struct {
 	MaxBytes     int64
 	Accept       func(int) bool
 	DebugLogPath string
+	Header       *http.Header
 }
 
 func ualist() []string {
@@ -56,6 +59,9 @@ func stdh(rq *http.Request) {
 	}
 	if rq.Header.Get("User-Agent") == "" {
 		ua := strings.TrimSpace(os.Getenv("XDL_UA"))
+		if pf, ok := ActiveProfile(); ok && ua == "" {
+			ua = pf.UserAgent
+		}
 		if ua == "" {
 			ua = uapick(rq.URL)
 		}
@@ -85,6 +91,9 @@ func DoRequestWithOptions(cl *http.Client, rq *http.Request, op RequestOptions)
 	defer res.Body.Close()
 
 	st := res.StatusCode
+	if op.Header != nil {
+		*op.Header = res.Header.Clone()
+	}
 
 	if op.Accept == nil {
 		op.Accept = func(s int) bool { return s >= 200 && s < 300 }
@@ -144,7 +153,35 @@ func Head(cl *http.Client, raw, ref string) (http.Header, int64, string, int, er
 	return res.Header.Clone(), res.ContentLength, res.Header.Get("Content-Type"), res.StatusCode, nil
 }
 
+type ProgressFunc func(done, total int64)
+
+type progressReader struct {
+	r     io.Reader
+	n     int64
+	total int64
+	fn    ProgressFunc
+	gate  func() error
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	if p.gate != nil {
+		if err := p.gate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := p.r.Read(b)
+	if n > 0 && p.fn != nil {
+		p.n += int64(n)
+		p.fn(p.n, p.total)
+	}
+	return n, err
+}
+
 func DownloadToFile(cl *http.Client, rq *http.Request, dst string, max int64) (int64, int, error) {
+	return DownloadToFileWith(cl, rq, dst, DownloadOptions{MaxBytes: max})
+}
+
+func fetchTo(cl *http.Client, rq *http.Request, w io.Writer, max int64, fn ProgressFunc, gate func() error) (int64, int, error) {
 	if cl == nil || rq == nil {
 		return 0, 0, errors.New("nil client or request")
 	}
@@ -159,58 +196,15 @@ func DownloadToFile(cl *http.Client, rq *http.Request, dst string, max int64) (i
 		_, _ = io.Copy(io.Discard, res.Body)
 		return 0, res.StatusCode, fmt.Errorf("unacceptable HTTP status: %d", res.StatusCode)
 	}
-	dir := filepath.Dir(dst)
-	base := filepath.Base(dst)
-	tmp, err := os.CreateTemp(dir, base+".tmp-*")
-	if err != nil {
-		_, _ = io.Copy(io.Discard, res.Body)
-		return 0, res.StatusCode, err
-	}
-	tpath := tmp.Name()
 	var src io.Reader = res.Body
 	if max > 0 {
 		src = io.LimitReader(res.Body, max)
 	}
-	n, cerr := io.Copy(tmp, src)
-	clos := tmp.Close()
-	if cerr != nil {
-		_ = os.Remove(tpath)
-		return n, res.StatusCode, cerr
-	}
-	if clos != nil {
-		_ = os.Remove(tpath)
-		return n, res.StatusCode, clos
+	if fn != nil || gate != nil {
+		src = &progressReader{r: src, total: res.ContentLength, fn: fn, gate: gate}
 	}
-	if _, err := os.Stat(dst); err == nil {
-		_ = os.Remove(dst)
-	}
-	if err := os.Rename(tpath, dst); err == nil {
-		return n, res.StatusCode, nil
-	}
-	in, err := os.Open(tpath)
-	if err != nil {
-		_ = os.Remove(tpath)
-		return n, res.StatusCode, err
-	}
-	defer in.Close()
-	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
-	if err != nil {
-		_ = os.Remove(tpath)
-		return n, res.StatusCode, err
-	}
-	if _, err := io.Copy(out, in); err != nil {
-		out.Close()
-		_ = os.Remove(dst)
-		_ = os.Remove(tpath)
-		return n, res.StatusCode, err
-	}
-	if err := out.Close(); err != nil {
-		_ = os.Remove(dst)
-		_ = os.Remove(tpath)
-		return n, res.StatusCode, err
-	}
-	_ = os.Remove(tpath)
-	return n, res.StatusCode, nil
+	n, err := io.Copy(w, src)
+	return n, res.StatusCode, err
 }
 
 var ErrNot2xx = errors.New("non-2xx response")
@@ -246,6 +240,16 @@ func InferExt(ct, raw, mt string) string {
 	case strings.HasSuffix(u, ".webp"):
 		return "webp"
 	}
+	if _, q, ok := strings.Cut(raw, "?"); ok {
+		if v, err := url.ParseQuery(q); err == nil {
+			switch f := strings.ToLower(v.Get("format")); f {
+			case "jpg", "jpeg":
+				return "jpg"
+			case "png", "gif", "webp":
+				return f
+			}
+		}
+	}
 	if mt == "video" {
 		return "mp4"
 	}
@@ -255,16 +259,93 @@ func InferExt(ct, raw, mt string) string {
 	return ""
 }
 
-func DownloadToFileWithTimeout(cl *http.Client, rq *http.Request, dst string, max int64, per time.Duration) (int64, int, error) {
+func MIMEForExt(ext string) string {
+	switch strings.TrimPrefix(strings.ToLower(ext), ".") {
+	case "mp4":
+		return "video/mp4"
+	case "m3u8":
+		return "application/x-mpegurl"
+	case "jpg", "jpeg":
+		return "image/jpeg"
+	case "png":
+		return "image/png"
+	case "gif":
+		return "image/gif"
+	case "webp":
+		return "image/webp"
+	}
+	return ""
+}
+
+type DownloadOptions struct {
+	MaxBytes int64
+	Timeout  time.Duration
+	Progress ProgressFunc
+	Paused   func() bool
+}
+
+func DownloadToFileWith(cl *http.Client, rq *http.Request, dst string, op DownloadOptions) (int64, int, error) {
+	tmp, err := os.CreateTemp(paths.TempPattern(dst))
+	if err != nil {
+		return 0, 0, err
+	}
+	n, st, err := DownloadWith(cl, rq, tmp, op)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return n, st, err
+	}
+	if err := utils.ReplaceFile(tmp.Name(), dst); err != nil {
+		_ = os.Remove(tmp.Name())
+		return n, st, err
+	}
+	return n, st, nil
+}
+
+func DownloadWith(cl *http.Client, rq *http.Request, w io.Writer, op DownloadOptions) (int64, int, error) {
 	if cl == nil || rq == nil {
 		return 0, 0, errors.New("nil client or request")
 	}
-	ctx := rq.Context()
-	if per > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, per)
-		defer cancel()
-	}
+	ctx, cancel := context.WithCancelCause(rq.Context())
+	defer cancel(nil)
 	rq = rq.Clone(ctx)
-	return DownloadToFile(cl, rq, dst, max)
+
+	var tm *time.Timer
+	var dl time.Time
+	if op.Timeout > 0 {
+		dl = time.Now().Add(op.Timeout)
+		tm = time.AfterFunc(op.Timeout, func() { cancel(context.DeadlineExceeded) })
+		defer tm.Stop()
+	}
+
+	var gate func() error
+	if op.Paused != nil {
+		gate = func() error {
+			if !op.Paused() {
+				return nil
+			}
+			var left time.Duration
+			if tm != nil && tm.Stop() {
+				left = time.Until(dl)
+			}
+			for op.Paused() {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				time.Sleep(200 * time.Millisecond)
+			}
+			if tm != nil && left > 0 {
+				dl = time.Now().Add(left)
+				tm.Reset(left)
+			}
+			return ctx.Err()
+		}
+	}
+	n, st, err := fetchTo(cl, rq, w, op.MaxBytes, op.Progress, gate)
+	if err != nil && errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+		err = fmt.Errorf("download timed out after %s: %w", op.Timeout, context.DeadlineExceeded)
+	}
+	return n, st, err
 }
@@ -0,0 +1,308 @@
+package httpx
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxIdlePerHost = 4
+
+type OrderedTransport struct {
+	Order       []string
+	Dialer      *net.Dialer
+	Fallback    http.RoundTripper
+	DialTLS     func(ctx context.Context, network, addr string) (net.Conn, error)
+	Network     string
+	IdleTimeout time.Duration
+
+	mu   sync.Mutex
+	idle map[string][]*orderedConn
+}
+
+type orderedConn struct {
+	net.Conn
+	br   *bufio.Reader
+	bw   *bufio.Writer
+	used time.Time
+}
+
+func NewOrderedTransport(order []string, d *net.Dialer, fallback http.RoundTripper) *OrderedTransport {
+	if d == nil {
+		d = &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+	}
+	return &OrderedTransport{
+		Order:       order,
+		Dialer:      d,
+		Fallback:    fallback,
+		IdleTimeout: 90 * time.Second,
+		idle:        map[string][]*orderedConn{},
+	}
+}
+
+func (t *OrderedTransport) RoundTrip(rq *http.Request) (*http.Response, error) {
+	if t.Fallback != nil {
+		if p, err := proxyFor(rq); err != nil || p != nil {
+			return t.Fallback.RoundTrip(rq)
+		}
+	}
+	if rq.URL.Scheme != "http" && rq.URL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", rq.URL.Scheme)
+	}
+
+	var body []byte
+	if rq.Body != nil {
+		b, err := io.ReadAll(rq.Body)
+		rq.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+
+	key := rq.URL.Scheme + "://" + hostPort(rq.URL.Scheme, rq.URL.Host)
+	for try := 0; ; try++ {
+		c, reused, err := t.conn(rq.Context(), rq.URL.Scheme, rq.URL.Host, key)
+		if err != nil {
+			return nil, err
+		}
+		if tr := httptrace.ContextClientTrace(rq.Context()); tr != nil && tr.GotConn != nil {
+			gi := httptrace.GotConnInfo{Conn: c.Conn, Reused: reused}
+			if reused {
+				gi.IdleTime = time.Since(c.used)
+			}
+			tr.GotConn(gi)
+		}
+		res, err := t.send(c, rq, body)
+		if err != nil {
+			c.Close()
+			if reused && try == 0 {
+				continue
+			}
+			return nil, err
+		}
+		res.Body = t.track(res, c, key)
+		return res, nil
+	}
+}
+
+func (t *OrderedTransport) send(c *orderedConn, rq *http.Request, body []byte) (*http.Response, error) {
+	ctx := rq.Context()
+	if dl, ok := ctx.Deadline(); ok {
+		_ = c.SetDeadline(dl)
+	} else {
+		_ = c.SetDeadline(time.Time{})
+	}
+	stop := context.AfterFunc(ctx, func() { _ = c.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	if err := writeOrdered(c.bw, rq, body, t.Order); err != nil {
+		return nil, err
+	}
+	if err := c.bw.Flush(); err != nil {
+		return nil, err
+	}
+	res, err := http.ReadResponse(c.br, rq)
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return res, err
+}
+
+func writeOrdered(w *bufio.Writer, rq *http.Request, body []byte, order []string) error {
+	host := rq.Host
+	if host == "" {
+		host = rq.URL.Host
+	}
+	fmt.Fprintf(w, "%s %s HTTP/1.1\r\n", rq.Method, rq.URL.RequestURI())
+
+	done := map[string]bool{"host": true, "content-length": true, "connection": true}
+	for _, n := range order {
+		ln := strings.ToLower(strings.TrimSpace(n))
+		switch {
+		case ln == "host":
+			writeHeader(w, n, host)
+			continue
+		case done[ln]:
+			continue
+		}
+		for _, v := range rq.Header.Values(n) {
+			writeHeader(w, n, v)
+		}
+		done[ln] = true
+	}
+	if !orderHas(order, "host") {
+		writeHeader(w, "Host", host)
+	}
+
+	rest := make([]string, 0, len(rq.Header))
+	for k := range rq.Header {
+		if !done[strings.ToLower(k)] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	for _, k := range rest {
+		for _, v := range rq.Header[k] {
+			writeHeader(w, k, v)
+		}
+	}
+	if len(body) > 0 || (rq.Method != http.MethodGet && rq.Method != http.MethodHead) {
+		writeHeader(w, "Content-Length", fmt.Sprint(len(body)))
+	}
+	if _, err := w.WriteString("\r\n"); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+func writeHeader(w *bufio.Writer, k, v string) {
+	v = strings.NewReplacer("\r", " ", "\n", " ").Replace(v)
+	fmt.Fprintf(w, "%s: %s\r\n", k, v)
+}
+
+func orderHas(order []string, name string) bool {
+	for _, n := range order {
+		if strings.EqualFold(strings.TrimSpace(n), name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *OrderedTransport) conn(ctx context.Context, scheme, host, key string) (*orderedConn, bool, error) {
+	t.mu.Lock()
+	for cs := t.idle[key]; len(cs) > 0; cs = t.idle[key] {
+		c := cs[len(cs)-1]
+		t.idle[key] = cs[:len(cs)-1]
+		if time.Since(c.used) < t.IdleTimeout {
+			t.mu.Unlock()
+			return c, true, nil
+		}
+		c.Close()
+	}
+	t.mu.Unlock()
+
+	addr := hostPort(scheme, host)
+	if scheme == "https" && t.DialTLS != nil {
+		nc, err := t.DialTLS(ctx, "tcp", addr)
+		if err != nil {
+			return nil, false, err
+		}
+		return &orderedConn{Conn: nc, br: bufio.NewReader(nc), bw: bufio.NewWriter(nc)}, false, nil
+	}
+	nw := "tcp"
+	if t.Network != "" {
+		nw = t.Network
+	}
+	nc, err := t.Dialer.DialContext(ctx, nw, addr)
+	if err != nil {
+		return nil, false, err
+	}
+	if scheme == "https" {
+		sn, _, _ := net.SplitHostPort(addr)
+		tc := tls.Client(nc, &tls.Config{ServerName: sn, NextProtos: []string{"http/1.1"}})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, false, err
+		}
+		nc = tc
+	}
+	return &orderedConn{Conn: nc, br: bufio.NewReader(nc), bw: bufio.NewWriter(nc)}, false, nil
+}
+
+func (t *OrderedTransport) release(key string, c *orderedConn) {
+	c.used = time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.idle[key]) >= maxIdlePerHost {
+		c.Close()
+		return
+	}
+	t.idle[key] = append(t.idle[key], c)
+}
+
+func (t *OrderedTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k, cs := range t.idle {
+		for _, c := range cs {
+			c.Close()
+		}
+		delete(t.idle, k)
+	}
+	if f, ok := t.Fallback.(interface{ CloseIdleConnections() }); ok {
+		f.CloseIdleConnections()
+	}
+}
+
+type orderedBody struct {
+	io.ReadCloser
+	t    *OrderedTransport
+	c    *orderedConn
+	key  string
+	keep bool
+	eof  bool
+	once sync.Once
+}
+
+func (t *OrderedTransport) track(res *http.Response, c *orderedConn, key string) io.ReadCloser {
+	b := &orderedBody{ReadCloser: res.Body, t: t, c: c, key: key, keep: !res.Close}
+	if res.ContentLength == 0 || res.Request.Method == http.MethodHead {
+		b.eof = true
+		_ = b.Close()
+		return http.NoBody
+	}
+	return b
+}
+
+func (b *orderedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if errors.Is(err, io.EOF) {
+		b.eof = true
+	}
+	return n, err
+}
+
+func (b *orderedBody) Close() error {
+	b.once.Do(func() {
+		if !b.eof {
+			_, _ = io.Copy(io.Discard, io.LimitReader(b.ReadCloser, 256<<10))
+			b.eof = b.drained()
+		}
+		_ = b.ReadCloser.Close()
+		if b.keep && b.eof {
+			b.t.release(b.key, b.c)
+			return
+		}
+		b.c.Close()
+	})
+	return nil
+}
+
+func (b *orderedBody) drained() bool {
+	var one [1]byte
+	n, err := b.ReadCloser.Read(one[:])
+	return n == 0 && errors.Is(err, io.EOF)
+}
+
+func hostPort(scheme, host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	if scheme == "https" {
+		return net.JoinHostPort(host, "443")
+	}
+	return net.JoinHostPort(host, "80")
+}
@@ -0,0 +1,156 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type TransportOptions struct {
+	MaxConnsPerHost     int
+	MaxIdleConnsPerHost int
+	DisableHTTP2        bool
+	KeepAlive           time.Duration
+	Network             string
+	Proxy               string
+}
+
+var (
+	transportMu   sync.RWMutex
+	transportOpts TransportOptions
+)
+
+func SetTransportOptions(o TransportOptions) {
+	transportMu.Lock()
+	transportOpts = o
+	transportMu.Unlock()
+}
+
+func currentTransportOptions() TransportOptions {
+	transportMu.RLock()
+	defer transportMu.RUnlock()
+	return transportOpts
+}
+
+func (o TransportOptions) apply(t *http.Transport, d *net.Dialer) {
+	if o.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = o.MaxConnsPerHost
+	}
+	if o.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = o.MaxIdleConnsPerHost
+	}
+	if o.DisableHTTP2 {
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	if o.KeepAlive != 0 {
+		d.KeepAlive = o.KeepAlive
+	}
+	if u, err := url.Parse(o.Proxy); err == nil && o.Proxy != "" {
+		t.Proxy = http.ProxyURL(u)
+	}
+	t.DialContext = o.dialer(d)
+}
+
+func proxyFor(rq *http.Request) (*url.URL, error) {
+	if p := currentTransportOptions().Proxy; p != "" {
+		return url.Parse(p)
+	}
+	return http.ProxyFromEnvironment(rq)
+}
+
+func (o TransportOptions) dialer(d *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if o.Network != "" && network == "tcp" {
+			network = o.Network
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+}
+
+type PoolStats struct {
+	Name     string
+	Requests int64
+	Reused   int64
+	Idle     time.Duration
+}
+
+func (s PoolStats) String() string {
+	n := s.Requests - s.Reused
+	pct := 0.0
+	if s.Requests > 0 {
+		pct = float64(s.Reused) * 100 / float64(s.Requests)
+	}
+	return fmt.Sprintf("%s: %d requests, %d new connections, %d reused (%.0f%%), %s idle before reuse", s.Name, s.Requests, n, s.Reused, pct, s.Idle.Round(time.Millisecond))
+}
+
+type poolCounter struct {
+	name     string
+	requests atomic.Int64
+	reused   atomic.Int64
+	idle     atomic.Int64
+}
+
+var (
+	poolMu   sync.Mutex
+	counters = map[string]*poolCounter{}
+	order    []string
+)
+
+func counterFor(name string) *poolCounter {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	c, ok := counters[name]
+	if !ok {
+		c = &poolCounter{name: name}
+		counters[name] = c
+		order = append(order, name)
+	}
+	return c
+}
+
+func Pools() []PoolStats {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	out := make([]PoolStats, 0, len(order))
+	for _, n := range order {
+		c := counters[n]
+		out = append(out, PoolStats{Name: n, Requests: c.requests.Load(), Reused: c.reused.Load(), Idle: time.Duration(c.idle.Load())})
+	}
+	return out
+}
+
+type countingTransport struct {
+	next http.RoundTripper
+	c    *poolCounter
+}
+
+func counting(name string, next http.RoundTripper) http.RoundTripper {
+	return &countingTransport{next: next, c: counterFor(name)}
+}
+
+func (t *countingTransport) RoundTrip(rq *http.Request) (*http.Response, error) {
+	tr := &httptrace.ClientTrace{
+		GotConn: func(i httptrace.GotConnInfo) {
+			t.c.requests.Add(1)
+			if i.Reused {
+				t.c.reused.Add(1)
+				t.c.idle.Add(int64(i.IdleTime))
+			}
+		},
+	}
+	return t.next.RoundTrip(rq.WithContext(httptrace.WithClientTrace(rq.Context(), tr)))
+}
+
+func (t *countingTransport) CloseIdleConnections() {
+	if c, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,123 @@
+package httpx
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type HeaderProfile struct {
+	Name      string
+	UserAgent string
+	Headers   map[string]string
+}
+
+var profiles = map[string]HeaderProfile{
+	"chrome": {
+		Name:      "chrome",
+		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/141.0.0.0 Safari/537.36",
+		Headers: map[string]string{
+			"sec-ch-ua":          `"Google Chrome";v="141", "Not?A_Brand";v="8", "Chromium";v="141"`,
+			"sec-ch-ua-mobile":   "?0",
+			"sec-ch-ua-platform": `"Windows"`,
+		},
+	},
+	"firefox": {
+		Name:      "firefox",
+		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:143.0) Gecko/20100101 Firefox/143.0",
+		Headers: map[string]string{
+			"sec-ch-ua":          "",
+			"sec-ch-ua-mobile":   "",
+			"sec-ch-ua-platform": "",
+		},
+	},
+	"android": {
+		Name:      "android",
+		UserAgent: "TwitterAndroid/10.21.0-release.0 (310210000-r-0) Pixel+7/14 (Google;Pixel+7;google;panther;0;;1;2022)",
+		Headers: map[string]string{
+			"sec-ch-ua":                "",
+			"sec-ch-ua-mobile":         "",
+			"sec-ch-ua-platform":       "",
+			"x-twitter-client":         "TwitterAndroid",
+			"x-twitter-client-version": "10.21.0-release.0",
+			"x-twitter-api-version":    "5",
+		},
+	},
+}
+
+var (
+	profileMu sync.RWMutex
+	profile   *HeaderProfile
+)
+
+func ProfileNames() []string {
+	out := make([]string, 0, len(profiles))
+	for k := range profiles {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func LookupProfile(name string) (HeaderProfile, error) {
+	p, ok := profiles[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return HeaderProfile{}, fmt.Errorf("unknown header profile %q (want one of %s)", name, strings.Join(ProfileNames(), ", "))
+	}
+	return p, nil
+}
+
+func UseProfile(name string) error {
+	if strings.TrimSpace(name) == "" {
+		profileMu.Lock()
+		profile = nil
+		profileMu.Unlock()
+		return nil
+	}
+	p, err := LookupProfile(name)
+	if err != nil {
+		return err
+	}
+	profileMu.Lock()
+	profile = &p
+	profileMu.Unlock()
+	return nil
+}
+
+func ActiveProfile() (HeaderProfile, bool) {
+	profileMu.RLock()
+	defer profileMu.RUnlock()
+	if profile == nil {
+		return HeaderProfile{}, false
+	}
+	return *profile, true
+}
+
+func (p HeaderProfile) Apply(req *http.Request, lang string) {
+	if req == nil || p.Name == "" {
+		return
+	}
+	req.Header.Set("User-Agent", p.UserAgent)
+	for k, v := range p.Headers {
+		if v == "" {
+			req.Header.Del(k)
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	if lang != "" {
+		req.Header.Set("x-twitter-client-language", lang)
+	}
+}
+
+func ClientLanguage(acceptLanguage string) string {
+	tag, _, _ := strings.Cut(acceptLanguage, ",")
+	tag, _, _ = strings.Cut(tag, ";")
+	tag, _, _ = strings.Cut(strings.TrimSpace(tag), "-")
+	if tag == "" || tag == "*" {
+		return "en"
+	}
+	return strings.ToLower(tag)
+}
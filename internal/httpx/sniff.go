@@ -0,0 +1,21 @@
+package httpx
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+func SniffMIME(b []byte) string {
+	switch {
+	case len(b) >= 12 && bytes.Equal(b[4:8], []byte("ftyp")):
+		return "video/mp4"
+	case bytes.HasPrefix(b, []byte("#EXTM3U")):
+		return "application/x-mpegurl"
+	}
+	mt := http.DetectContentType(b)
+	if i := strings.IndexByte(mt, ';'); i >= 0 {
+		mt = mt[:i]
+	}
+	return strings.TrimSpace(mt)
+}
@@ -1,22 +1,50 @@
 package log
 
 import (
+	"context"
 	"fmt"
 	"io"
-	stdlog "log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+type Options struct {
+	Level   string
+	Format  string
+	Modules map[string]string
+}
+
 var (
-	mu  sync.RWMutex
-	lg  *stdlog.Logger
-	on  = true
-	out io.Closer
+	mu      sync.RWMutex
+	lg      *slog.Logger
+	on      = true
+	out     io.Closer
+	file    io.Writer
+	opts    Options
+	level   = slog.LevelInfo
+	modules map[string]slog.Level
+	color   atomic.Bool
+	jsonOut io.Writer
 )
 
+// SetConsoleColor colors the xdl> and xdl! prefixes of log lines on stderr.
+func SetConsoleColor(v bool) { color.Store(v) }
+
+// UseJSON replaces the xdl> lines on stderr with one JSON object per record
+// on w, and turns logging on if Disable turned it off.
+func UseJSON(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	jsonOut = w
+	lg = build()
+	on = true
+}
+
 func Init(path string) {
 	mu.Lock()
 	defer mu.Unlock()
@@ -28,22 +56,78 @@ func Init(path string) {
 		a = filepath.Join(baseDir, path)
 	}
 
+	file = nil
 	if err := os.MkdirAll(filepath.Dir(a), 0o755); err != nil {
-		lg = stdlog.New(os.Stderr, "", 0)
-		_ = lg.Output(2, "log init fallback stderr: "+err.Error())
-		return
+		fmt.Fprintln(os.Stderr, "xdl! log init fallback stderr: "+err.Error())
+	} else if f, err := os.OpenFile(a, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "xdl! log open fallback stderr: "+err.Error())
+	} else {
+		out = f
+		file = f
 	}
 
-	f, err := os.OpenFile(a, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	lg = build()
+	on = true
+}
+
+func Configure(o Options) error {
+	lv, err := ParseLevel(o.Level)
 	if err != nil {
-		lg = stdlog.New(os.Stderr, "", 0)
-		_ = lg.Output(2, "log open fallback stderr: "+err.Error())
-		return
+		return err
+	}
+	mods := make(map[string]slog.Level, len(o.Modules))
+	for k, v := range o.Modules {
+		ml, err := ParseLevel(v)
+		if err != nil {
+			return fmt.Errorf("logging.modules.%s: %w", k, err)
+		}
+		mods[strings.ToLower(k)] = ml
+	}
+	switch strings.ToLower(o.Format) {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("unknown log format %q (use text or json)", o.Format)
 	}
 
-	out = f
-	lg = stdlog.New(io.MultiWriter(f, os.Stderr), "", 0)
-	on = true
+	mu.Lock()
+	defer mu.Unlock()
+	opts = o
+	level = lv
+	modules = mods
+	if lg != nil {
+		lg = build()
+	}
+	return nil
+}
+
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q", s)
+}
+
+func build() *slog.Logger {
+	hs := []slog.Handler{consoleHandler{w: os.Stderr}}
+	if jsonOut != nil {
+		hs[0] = slog.NewJSONHandler(jsonOut, &slog.HandlerOptions{Level: slog.LevelDebug})
+	}
+	if file != nil {
+		ho := &slog.HandlerOptions{Level: slog.LevelDebug}
+		if strings.EqualFold(opts.Format, "json") {
+			hs = append(hs, slog.NewJSONHandler(file, ho))
+		} else {
+			hs = append(hs, slog.NewTextHandler(file, ho))
+		}
+	}
+	return slog.New(fanout(hs))
 }
 
 func Disable() {
@@ -52,38 +136,126 @@ func Disable() {
 	on = false
 }
 
-func LogInfo(tag, msg string) { fx("INFO", tag, msg) }
+func Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	if out != nil {
+		_ = out.Close()
+		out = nil
+	}
+	file = nil
+	lg = nil
+	on = false
+}
+
+func LogDebug(tag, msg string) { fx(slog.LevelDebug, tag, msg) }
+
+func LogInfo(tag, msg string) { fx(slog.LevelInfo, tag, msg) }
 
-func LogDebug(tag, msg string) { fx("DEBUG", tag, msg) }
+func LogWarn(tag, msg string) { fx(slog.LevelWarn, tag, msg) }
 
-func LogError(tag, msg string) { fx("ERROR", tag, msg) }
+func LogError(tag, msg string) { fx(slog.LevelError, tag, msg) }
 
-func fx(level, tag, msg string) {
+func fx(lv slog.Level, tag, msg string) {
 	mu.RLock()
 	defer mu.RUnlock()
 
 	if !on {
 		return
 	}
-	if lg == nil {
-		lg = stdlog.New(os.Stderr, "", 0)
+	min := level
+	if ml, ok := modules[strings.ToLower(tag)]; ok {
+		min = ml
 	}
+	if lv < min {
+		return
+	}
+	l := lg
+	if l == nil {
+		l = slog.New(consoleHandler{w: os.Stderr})
+	}
+	r := slog.NewRecord(time.Now(), lv, msg, 0)
+	if tag != "" {
+		r.AddAttrs(slog.String("module", tag))
+	}
+	_ = l.Handler().Handle(context.Background(), r)
+}
 
-	prefix := "xdl>"
-	if level == "ERROR" {
-		prefix = "xdl!"
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, l slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, l) {
+			return true
+		}
 	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			_ = h.Handle(ctx, r.Clone())
+		}
+	}
+	return nil
+}
 
+func (f fanout) WithAttrs(as []slog.Attr) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(as)
+	}
+	return out
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
+
+type consoleHandler struct {
+	w io.Writer
+}
+
+func (consoleHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	prefix, code := "xdl>", "36"
+	switch {
+	case r.Level >= slog.LevelError:
+		prefix, code = "xdl!", "31"
+	case r.Level >= slog.LevelWarn:
+		prefix, code = "xdl!", "33"
+	}
+	if color.Load() {
+		prefix = "\x1b[" + code + "m" + prefix + "\x1b[0m"
+	}
+	tag := ""
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "module" {
+			tag = a.Value.String()
+			return false
+		}
+		return true
+	})
 	var line string
 	if tag != "" {
-		line = fmt.Sprintf("%s [%s] %s", prefix, tag, msg)
+		line = fmt.Sprintf("%s [%s] %s\n", prefix, tag, r.Message)
 	} else {
-		line = fmt.Sprintf("%s %s", prefix, msg)
+		line = fmt.Sprintf("%s %s\n", prefix, r.Message)
 	}
-
-	_ = lg.Output(3, line)
+	_, err := io.WriteString(h.w, line)
+	return err
 }
 
+func (h consoleHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h consoleHandler) WithGroup(string) slog.Handler { return h }
+
 func BuildRunFolderName(username, userID, runID string) string {
 	base := []string{username, runID}
 	basePath := strings.Join(base, "_")
@@ -0,0 +1,99 @@
+package mediaurl
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+const (
+	SizeOrig   = "orig"
+	Size4096   = "4096x4096"
+	SizeLarge  = "large"
+	SizeMedium = "medium"
+	SizeSmall  = "small"
+)
+
+var photoSizes = []string{SizeOrig, Size4096, SizeLarge}
+
+var ClientSizes = []string{SizeSmall, SizeMedium, SizeLarge, SizeOrig}
+
+var photoFormats = map[string]bool{"jpg": true, "jpeg": true, "png": true, "webp": true}
+
+func IsPhoto(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return isPhotoPath(u) && format(u) != ""
+}
+
+func Photo(raw string) string {
+	return WithSize(raw, SizeOrig)
+}
+
+func WithSize(raw, size string) string {
+	u, err := url.Parse(raw)
+	if err != nil || !isPhotoPath(u) {
+		return raw
+	}
+	f := format(u)
+	if f == "" {
+		return raw
+	}
+	u.Path = stripLegacySize(u.Path)
+	if ext := path.Ext(u.Path); ext != "" {
+		u.Path = strings.TrimSuffix(u.Path, ext)
+	}
+	u.RawPath = ""
+	q := url.Values{}
+	q.Set("format", f)
+	q.Set("name", size)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func Fallbacks(raw string) []string {
+	if !IsPhoto(raw) {
+		return []string{raw}
+	}
+	out := make([]string, 0, len(photoSizes))
+	for _, s := range photoSizes {
+		out = append(out, WithSize(raw, s))
+	}
+	return out
+}
+
+func Format(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return format(u)
+}
+
+func format(u *url.URL) string {
+	f := strings.ToLower(u.Query().Get("format"))
+	if f == "" {
+		f = strings.ToLower(strings.TrimPrefix(path.Ext(stripLegacySize(u.Path)), "."))
+	}
+	if f == "jpeg" {
+		f = "jpg"
+	}
+	if !photoFormats[f] {
+		return ""
+	}
+	return f
+}
+
+func isPhotoPath(u *url.URL) bool {
+	return strings.EqualFold(u.Host, "pbs.twimg.com") && !strings.HasPrefix(u.Path, "/profile_")
+}
+
+// stripLegacySize drops the ":large" style size suffix of old photo URLs.
+func stripLegacySize(p string) string {
+	if i := strings.LastIndexByte(p, ':'); i > strings.LastIndexByte(p, '/') {
+		return p[:i]
+	}
+	return p
+}
@@ -0,0 +1,125 @@
+package mediaurl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPhoto(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://pbs.twimg.com/media/abc.jpg", "https://pbs.twimg.com/media/abc?format=jpg&name=orig"},
+		{"https://pbs.twimg.com/media/abc.jpeg", "https://pbs.twimg.com/media/abc?format=jpg&name=orig"},
+		{"https://pbs.twimg.com/media/abc.png:large", "https://pbs.twimg.com/media/abc?format=png&name=orig"},
+		{"https://pbs.twimg.com/media/abc.jpg:orig", "https://pbs.twimg.com/media/abc?format=jpg&name=orig"},
+		{"https://pbs.twimg.com/media/abc?format=png&name=small", "https://pbs.twimg.com/media/abc?format=png&name=orig"},
+		{"https://pbs.twimg.com/media/abc?format=webp", "https://pbs.twimg.com/media/abc?format=webp&name=orig"},
+		{"https://pbs.twimg.com/media/abc.jpg?name=medium", "https://pbs.twimg.com/media/abc?format=jpg&name=orig"},
+		{"https://PBS.twimg.com/media/abc.jpg", "https://PBS.twimg.com/media/abc?format=jpg&name=orig"},
+		{"https://pbs.twimg.com/profile_images/1/a.jpg", "https://pbs.twimg.com/profile_images/1/a.jpg"},
+		{"https://video.twimg.com/ext_tw_video/1/vid.mp4", "https://video.twimg.com/ext_tw_video/1/vid.mp4"},
+		{"https://pbs.twimg.com/media/abc?format=gif", "https://pbs.twimg.com/media/abc?format=gif"},
+		{"https://pbs.twimg.com/media/abc", "https://pbs.twimg.com/media/abc"},
+		{"://bad", "://bad"},
+	}
+	for _, tt := range tests {
+		if got := Photo(tt.in); got != tt.want {
+			t.Errorf("Photo(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWithSize(t *testing.T) {
+	tests := []struct {
+		in, size, want string
+	}{
+		{"https://pbs.twimg.com/media/abc.jpg", SizeSmall, "https://pbs.twimg.com/media/abc?format=jpg&name=small"},
+		{"https://pbs.twimg.com/media/abc?format=jpg&name=orig", Size4096, "https://pbs.twimg.com/media/abc?format=jpg&name=4096x4096"},
+		{"https://pbs.twimg.com/media/abc?name=large&format=png", SizeMedium, "https://pbs.twimg.com/media/abc?format=png&name=medium"},
+		{"https://pbs.twimg.com/tweet_video_thumb/abc.jpg", SizeLarge, "https://pbs.twimg.com/tweet_video_thumb/abc?format=jpg&name=large"},
+		{"https://example.com/abc.jpg", SizeSmall, "https://example.com/abc.jpg"},
+	}
+	for _, tt := range tests {
+		if got := WithSize(tt.in, tt.size); got != tt.want {
+			t.Errorf("WithSize(%q, %q) = %q, want %q", tt.in, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestFallbacks(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{
+			"https://pbs.twimg.com/media/abc.jpg",
+			[]string{
+				"https://pbs.twimg.com/media/abc?format=jpg&name=orig",
+				"https://pbs.twimg.com/media/abc?format=jpg&name=4096x4096",
+				"https://pbs.twimg.com/media/abc?format=jpg&name=large",
+			},
+		},
+		{
+			"https://pbs.twimg.com/media/abc?format=png&name=small",
+			[]string{
+				"https://pbs.twimg.com/media/abc?format=png&name=orig",
+				"https://pbs.twimg.com/media/abc?format=png&name=4096x4096",
+				"https://pbs.twimg.com/media/abc?format=png&name=large",
+			},
+		},
+		{"https://video.twimg.com/a.mp4", []string{"https://video.twimg.com/a.mp4"}},
+		{"https://pbs.twimg.com/profile_banners/1/2", []string{"https://pbs.twimg.com/profile_banners/1/2"}},
+	}
+	for _, tt := range tests {
+		if got := Fallbacks(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Fallbacks(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIsPhoto(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"https://pbs.twimg.com/media/abc.jpg", true},
+		{"https://pbs.twimg.com/media/abc.JPEG", true},
+		{"https://pbs.twimg.com/media/abc.jpg:small", true},
+		{"https://pbs.twimg.com/media/abc?format=webp&name=orig", true},
+		{"https://pbs.twimg.com/media/abc?format=png", true},
+		{"https://pbs.twimg.com/media/abc?format=gif", false},
+		{"https://pbs.twimg.com/media/abc", false},
+		{"https://pbs.twimg.com/profile_images/1/a.jpg", false},
+		{"https://video.twimg.com/a.mp4", false},
+		{"https://example.com/a.jpg", false},
+		{"%", false},
+	}
+	for _, tt := range tests {
+		if got := IsPhoto(tt.in); got != tt.want {
+			t.Errorf("IsPhoto(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://pbs.twimg.com/media/abc.jpg", "jpg"},
+		{"https://pbs.twimg.com/media/abc.jpeg", "jpg"},
+		{"https://pbs.twimg.com/media/abc?format=JPEG&name=small", "jpg"},
+		{"https://pbs.twimg.com/media/abc.png?name=orig", "png"},
+		{"https://pbs.twimg.com/media/abc.webp:large", "webp"},
+		{"https://pbs.twimg.com/media/abc.jpg?format=webp", "webp"},
+		{"https://example.com/a.webp", "webp"},
+		{"https://video.twimg.com/a.mp4", ""},
+		{"https://pbs.twimg.com/media/abc", ""},
+		{"%", ""},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.in); got != tt.want {
+			t.Errorf("Format(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package paths
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+const (
+	ImagesDir      = "images"
+	VideosDir      = "videos"
+	ThumbsDir      = "thumbs"
+	SizesDir       = "sizes"
+	TextDir        = "text"
+	ProfileDir     = "profile"
+	HistoryFile    = "history.ndjson"
+	StateDir       = ".xdl"
+	CheckpointFile = ".xdl-checkpoint.json"
+	ManifestFile   = "SHA256SUMS"
+	AltTextFile    = "alt_text.csv"
+	TweetsFile     = "tweets.ndjson"
+	GeoJSONFile    = "geo.geojson"
+	ExternalFile   = "external_urls.txt"
+	DefaultRoot    = "xDownloads"
+	RootEnv        = "XDL_OUTPUT_DIR"
+	maxSuffix      = 9999
+)
+
+var ErrTooManyCollisions = errors.New("too many existing paths with the same name")
+
+type Layout struct {
+	Root string
+
+	mu      sync.Mutex
+	claimed map[string]struct{}
+}
+
+func New(root string) *Layout {
+	if strings.TrimSpace(root) == "" {
+		root = "."
+	}
+	return &Layout{Root: root, claimed: map[string]struct{}{}}
+}
+
+// Root resolves the download root: dir, else $XDL_OUTPUT_DIR, else xDownloads.
+// A leading ~ and $VAR references are expanded.
+func Root(dir string) string {
+	if dir = strings.TrimSpace(dir); dir == "" {
+		dir = strings.TrimSpace(os.Getenv(RootEnv))
+	}
+	if dir == "" {
+		return DefaultRoot
+	}
+	return Expand(dir)
+}
+
+func Expand(p string) string {
+	p = os.ExpandEnv(p)
+	if p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, `~\`) {
+		if h, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(h, p[1:])
+		}
+	}
+	return p
+}
+
+func (l *Layout) UserDir(user string) string {
+	return filepath.Join(l.Root, utils.SanitizeFilename(user))
+}
+
+func (l *Layout) RunDir(user string, reuse bool) (string, error) {
+	p := l.UserDir(user)
+	if reuse {
+		l.claim(p)
+		return p, nil
+	}
+	return l.unique(p, "_%03d", false)
+}
+
+func (l *Layout) StateFile(name string) string {
+	return filepath.Join(l.Root, StateDir, name)
+}
+
+func (l *Layout) unique(p, suffix string, keepExt bool) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.claimed == nil {
+		l.claimed = map[string]struct{}{}
+	}
+	ext := ""
+	if keepExt {
+		ext = filepath.Ext(p)
+	}
+	stem := strings.TrimSuffix(p, ext)
+	c := p
+	for i := 1; l.taken(c); i++ {
+		if i > maxSuffix {
+			return "", fmt.Errorf("%s: %w", p, ErrTooManyCollisions)
+		}
+		c = stem + fmt.Sprintf(suffix, i) + ext
+	}
+	l.claimed[key(c)] = struct{}{}
+	return c, nil
+}
+
+func (l *Layout) claim(p string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.claimed == nil {
+		l.claimed = map[string]struct{}{}
+	}
+	l.claimed[key(p)] = struct{}{}
+}
+
+func (l *Layout) taken(p string) bool {
+	if _, ok := l.claimed[key(p)]; ok {
+		return true
+	}
+	_, err := os.Lstat(p)
+	return err == nil
+}
+
+func key(p string) string {
+	return strings.ToLower(filepath.Clean(p))
+}
+
+func MediaDir(runDir string, video bool) string {
+	if video {
+		return filepath.Join(runDir, VideosDir)
+	}
+	return filepath.Join(runDir, ImagesDir)
+}
+
+func MediaFile(dir, base, ext string) string {
+	fn := utils.SanitizeFilename(base)
+	ext = strings.TrimPrefix(strings.ToLower(ext), ".")
+	if ext != "" && !strings.HasSuffix(strings.ToLower(fn), "."+ext) {
+		fn += "." + ext
+	}
+	return filepath.Join(dir, fn)
+}
+
+func Sidecar(mediaPath, ext string) string {
+	return strings.TrimSuffix(mediaPath, filepath.Ext(mediaPath)) + "." + strings.TrimPrefix(ext, ".")
+}
+
+func Thumb(runDir, mediaPath string) string {
+	base := filepath.Base(strings.TrimSuffix(mediaPath, filepath.Ext(mediaPath)))
+	return filepath.Join(runDir, ThumbsDir, base+".jpg")
+}
+
+func SizeVariant(runDir, tweetID, base, size, ext string) string {
+	return MediaFile(filepath.Join(runDir, SizesDir, tweetID), base+"_"+size, ext)
+}
+
+func ProfileImage(userDir, kind string, at time.Time, ext string) string {
+	return MediaFile(filepath.Join(userDir, ProfileDir), kind+"_"+at.UTC().Format("20060102T150405Z"), ext)
+}
+
+func ProfileHistory(userDir string) string {
+	return filepath.Join(userDir, ProfileDir, HistoryFile)
+}
+
+func Checkpoint(runDir string) string {
+	return filepath.Join(runDir, CheckpointFile)
+}
+
+func Manifest(runDir string) string {
+	return filepath.Join(runDir, ManifestFile)
+}
+
+func TweetText(runDir, tweetID string) string {
+	return filepath.Join(runDir, TextDir, utils.SanitizeFilename(tweetID)+".txt")
+}
+
+func Tweets(runDir string) string {
+	return filepath.Join(runDir, TweetsFile)
+}
+
+func External(runDir string) string {
+	return filepath.Join(runDir, ExternalFile)
+}
+
+func GeoJSON(runDir string) string {
+	return filepath.Join(runDir, GeoJSONFile)
+}
+
+func AltText(runDir string) string {
+	return filepath.Join(runDir, AltTextFile)
+}
+
+func TempPattern(dst string) (dir, pattern string) {
+	return filepath.Dir(dst), filepath.Base(dst) + ".tmp-*"
+}
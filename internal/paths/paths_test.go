@@ -0,0 +1,161 @@
+package paths
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUserDirSanitises(t *testing.T) {
+	l := New("root")
+	tests := []struct {
+		user, want string
+	}{
+		{"nasa", "nasa"},
+		{"a/b", "b"},
+		{"we:ird*?<>|\"", "we_ird______"},
+		{"  spaced  ", "spaced"},
+		{"trailing. .", "trailing"},
+		{"..", "file"},
+		{"", "file"},
+		{"CON", "_CON"},
+		{"nul.txt", "_nul.txt"},
+		{"com1", "_com1"},
+		{"LPT9.jpg", "_LPT9.jpg"},
+		{"com0", "com0"},
+		{"console", "console"},
+		{"conan.jpg", "conan.jpg"},
+	}
+	for _, tt := range tests {
+		if got, want := l.UserDir(tt.user), filepath.Join("root", tt.want); got != want {
+			t.Errorf("UserDir(%q) = %q, want %q", tt.user, got, want)
+		}
+	}
+}
+
+func TestMediaFile(t *testing.T) {
+	tests := []struct {
+		base, ext, want string
+	}{
+		{"abc", "jpg", "abc.jpg"},
+		{"abc", ".MP4", "abc.mp4"},
+		{"abc.jpg", "jpg", "abc.jpg"},
+		{"ABC.JPG", "jpg", "ABC.JPG"},
+		{"abc", "", "abc"},
+		{"a:b", "png", "a_b.png"},
+		{"aux", "jpg", "_aux.jpg"},
+	}
+	for _, tt := range tests {
+		if got, want := MediaFile("d", tt.base, tt.ext), filepath.Join("d", tt.want); got != want {
+			t.Errorf("MediaFile(%q, %q) = %q, want %q", tt.base, tt.ext, got, want)
+		}
+	}
+}
+
+func TestRunDirCollisions(t *testing.T) {
+	root := t.TempDir()
+	l := New(root)
+	if err := os.MkdirAll(filepath.Join(root, "nasa"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"nasa_001", "nasa_002"}
+	for _, w := range want {
+		got, err := l.RunDir("nasa", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != filepath.Join(root, w) {
+			t.Errorf("RunDir = %q, want %q", got, filepath.Join(root, w))
+		}
+	}
+
+	got, err := l.RunDir("nasa", true)
+	if err != nil || got != filepath.Join(root, "nasa") {
+		t.Errorf("RunDir(reuse) = %q, %v; want the plain user dir", got, err)
+	}
+
+	// Claims are case-insensitive, so NASA and nasa never share a folder
+	// on a case-insensitive file system.
+	got, err = l.RunDir("NASA", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != filepath.Join(root, "NASA_003") {
+		t.Errorf("RunDir(NASA) = %q, want NASA_003", got)
+	}
+}
+
+func TestUniqueKeepsExtension(t *testing.T) {
+	root := t.TempDir()
+	l := New(root)
+	p := filepath.Join(root, "a.jpg")
+	if err := os.WriteFile(p, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range []string{"a_1.jpg", "a_2.jpg"} {
+		got, err := l.unique(p, "_%d", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != filepath.Join(root, w) {
+			t.Errorf("unique = %q, want %q", got, w)
+		}
+	}
+	if got, _ := l.unique(filepath.Join(root, "b.jpg"), "_%d", true); got != filepath.Join(root, "b.jpg") {
+		t.Errorf("unique(free path) = %q", got)
+	}
+}
+
+func TestUniqueGivesUp(t *testing.T) {
+	l := New(t.TempDir())
+	p := filepath.Join(l.Root, "x")
+	l.claim(p)
+	for i := 1; i <= maxSuffix; i++ {
+		l.claim(fmt.Sprintf("%s_%d", p, i))
+	}
+	if _, err := l.unique(p, "_%d", true); !errors.Is(err, ErrTooManyCollisions) {
+		t.Errorf("err = %v, want ErrTooManyCollisions", err)
+	}
+}
+
+func TestFixedNames(t *testing.T) {
+	at := time.Date(2024, 5, 6, 7, 8, 9, 0, time.FixedZone("x", 3600))
+	tests := []struct {
+		got, want string
+	}{
+		{Thumb("run", filepath.Join("run", "videos", "v.mp4")), filepath.Join("run", ThumbsDir, "v.jpg")},
+		{Sidecar(filepath.Join("d", "a.jpg"), ".txt"), filepath.Join("d", "a.txt")},
+		{SizeVariant("run", "42", "a", "small", "jpg"), filepath.Join("run", SizesDir, "42", "a_small.jpg")},
+		{ProfileImage("u", "avatar", at, "png"), filepath.Join("u", ProfileDir, "avatar_20240506T060809Z.png")},
+		{TweetText("run", "a/b"), filepath.Join("run", TextDir, "b.txt")},
+		{MediaDir("run", true), filepath.Join("run", VideosDir)},
+		{MediaDir("run", false), filepath.Join("run", ImagesDir)},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}
+
+func TestRoot(t *testing.T) {
+	t.Setenv(RootEnv, "")
+	if got := Root(""); got != DefaultRoot {
+		t.Errorf("Root(\"\") = %q, want %q", got, DefaultRoot)
+	}
+	t.Setenv(RootEnv, "/srv/x")
+	if got := Root(""); got != "/srv/x" {
+		t.Errorf("Root from env = %q", got)
+	}
+	if got := Root(" /data "); got != "/data" {
+		t.Errorf("Root(flag) = %q, want the flag over the env", got)
+	}
+	t.Setenv("XDL_TEST_DIR", "/mnt")
+	if got := Root("$XDL_TEST_DIR/dl"); got != "/mnt/dl" {
+		t.Errorf("Root($VAR) = %q", got)
+	}
+}
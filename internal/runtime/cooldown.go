@@ -0,0 +1,73 @@
+package runtime
+
+import (
+	"sync"
+	"time"
+)
+
+type Cooldowns struct {
+	sleep func(string, time.Duration) bool
+
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func NewCooldowns(sleep func(string, time.Duration) bool) *Cooldowns {
+	if sleep == nil {
+		sleep = func(_ string, d time.Duration) bool {
+			time.Sleep(d)
+			return true
+		}
+	}
+	return &Cooldowns{
+		sleep: sleep,
+		until: make(map[string]time.Time),
+	}
+}
+
+func (c *Cooldowns) Hold(ep string, until time.Time) {
+	if c == nil || until.IsZero() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if until.After(c.until[ep]) {
+		c.until[ep] = until
+	}
+}
+
+func (c *Cooldowns) Until(ep string) time.Time {
+	if c == nil {
+		return time.Time{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.untilLocked(ep)
+}
+
+func (c *Cooldowns) untilLocked(ep string) time.Time {
+	t, ok := c.until[ep]
+	if !ok {
+		return time.Time{}
+	}
+	if !time.Now().Before(t) {
+		delete(c.until, ep)
+		return time.Time{}
+	}
+	return t
+}
+
+func (c *Cooldowns) Wait(ep, who string) bool {
+	if c == nil {
+		return true
+	}
+	for {
+		t := c.Until(ep)
+		if t.IsZero() {
+			return true
+		}
+		if !c.sleep(who, time.Until(t)) {
+			return false
+		}
+	}
+}
@@ -5,16 +5,17 @@ import (
 	"crypto/sha512"
 	"encoding/binary"
 	"math"
-	"os"
-	"strings"
 	"sync"
 	"time"
+
+	"github.com/ghostlawless/xdl/internal/utils"
 )
 
 type Limiter struct {
 	seed []byte
 	sec  []byte
 	per  int
+	pace *Pacing
 
 	mu sync.Mutex
 	m  map[string]map[int]SectionBehavior
@@ -33,7 +34,8 @@ func NewLimiterWith(b []byte, s []byte) *Limiter {
 }
 
 func NewLimiter(b []byte) *Limiter {
-	return NewLimiterWith(b, []byte(strings.TrimSpace(os.Getenv("XDL_LIMITER_SECRET"))))
+	s, _ := utils.Getenv("XDL_LIMITER_SECRET")
+	return NewLimiterWith(b, []byte(s))
 }
 
 func (l *Limiter) SetPagesPerSection(n int) {
@@ -45,6 +47,12 @@ func (l *Limiter) SetPagesPerSection(n int) {
 	l.mu.Unlock()
 }
 
+func (l *Limiter) SetPacing(p *Pacing) {
+	l.mu.Lock()
+	l.pace = p
+	l.mu.Unlock()
+}
+
 func (l *Limiter) BehaviorFor(u string, p int) SectionBehavior {
 	if p <= 0 {
 		p = 1
@@ -94,6 +102,10 @@ func (l *Limiter) SleepBeforeRequest(ctx context.Context, u string, p, r int) {
 	if sb.BurstEvery > 0 && r > 0 && r%sb.BurstEvery == 0 {
 		d += sb.BurstExtra
 	}
+	l.mu.Lock()
+	pc := l.pace
+	l.mu.Unlock()
+	d = pc.Apply(d)
 	if d <= 0 {
 		return
 	}
@@ -124,7 +136,7 @@ func dup(b []byte) []byte {
 }
 
 func dsec() []byte {
-	s := strings.TrimSpace(os.Getenv("XDL_LIMITER_SECRET"))
+	s, _ := utils.Getenv("XDL_LIMITER_SECRET")
 	if s == "" {
 		s = "xdl-limiter-secret-v1"
 	}
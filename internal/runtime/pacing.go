@@ -0,0 +1,100 @@
+package runtime
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minPaceScale = 0.25
+	maxPaceScale = 8
+)
+
+type Pacing struct {
+	min, max time.Duration
+
+	mu    sync.Mutex
+	scale float64
+	errs  float64
+}
+
+func NewPacing(min, max time.Duration) *Pacing {
+	if max > 0 && min > max {
+		min = max
+	}
+	return &Pacing{min: min, max: max, scale: 1}
+}
+
+func (p *Pacing) Observe(st int, h http.Header) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	failed := st == http.StatusTooManyRequests || st >= 500
+	p.errs *= 0.8
+	if failed {
+		p.errs += 0.2
+	}
+	switch {
+	case st == http.StatusTooManyRequests:
+		p.scale *= 2
+	case p.errs > 0.2:
+		p.scale *= 1.3
+	default:
+		rem, ok1 := headerInt(h, "x-rate-limit-remaining")
+		lim, ok2 := headerInt(h, "x-rate-limit-limit")
+		if !ok1 || !ok2 || lim <= 0 {
+			if !failed {
+				p.scale *= 0.98
+			}
+			break
+		}
+		switch f := float64(rem) / float64(lim); {
+		case f < 0.1:
+			p.scale *= 1.5
+		case f < 0.25:
+			p.scale *= 1.2
+		case f > 0.5 && !failed:
+			p.scale *= 0.9
+		}
+	}
+	p.scale = min(max(p.scale, minPaceScale), maxPaceScale)
+}
+
+func (p *Pacing) Apply(d time.Duration) time.Duration {
+	if p == nil {
+		return d
+	}
+	p.mu.Lock()
+	d = time.Duration(float64(d) * p.scale)
+	p.mu.Unlock()
+	if d < p.min {
+		d = p.min
+	}
+	if p.max > 0 && d > p.max {
+		d = p.max
+	}
+	return d
+}
+
+func (p *Pacing) Scale() float64 {
+	if p == nil {
+		return 1
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.scale
+}
+
+func headerInt(h http.Header, k string) (int, bool) {
+	if h == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(h.Get(k)))
+	return n, err == nil
+}
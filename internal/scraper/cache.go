@@ -0,0 +1,15 @@
+package scraper
+
+import (
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/httpx"
+)
+
+var ResponseCache *httpx.Cache
+
+func cacheKey(cf *config.EssentialsConfig, u string) string {
+	if ResponseCache == nil {
+		return ""
+	}
+	return httpx.CacheKey(u, cf.Auth.Cookies.AuthToken)
+}
@@ -0,0 +1,119 @@
+package scraper
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/mediaurl"
+)
+
+// IncludeCards makes the parsers keep card preview images and the poll
+// results of tweets that carry media.
+var IncludeCards bool
+
+var cardImageKeys = []string{
+	"photo_image_full_size_original",
+	"summary_photo_image_original",
+	"thumbnail_image_original",
+	"player_image_original",
+	"event_thumbnail_original",
+}
+
+type Poll struct {
+	Choices []PollChoice `json:"choices"`
+	EndsAt  time.Time    `json:"ends_at,omitempty"`
+	Final   bool         `json:"final,omitempty"`
+}
+
+type PollChoice struct {
+	Label string `json:"label"`
+	Votes int    `json:"votes"`
+}
+
+type cardValue struct {
+	Image string
+	Str   string
+	Bool  bool
+}
+
+func cardBindings(card map[string]any) (string, map[string]cardValue) {
+	if lg, ok := card["legacy"].(map[string]any); ok {
+		card = lg
+	}
+	name, _ := card["name"].(string)
+	out := map[string]cardValue{}
+	add := func(k string, v map[string]any) {
+		var cv cardValue
+		if iv, ok := v["image_value"].(map[string]any); ok {
+			cv.Image, _ = iv["url"].(string)
+		}
+		cv.Str, _ = v["string_value"].(string)
+		cv.Bool, _ = v["boolean_value"].(bool)
+		out[k] = cv
+	}
+	switch bv := card["binding_values"].(type) {
+	case []any:
+		for _, e := range bv {
+			kv, _ := e.(map[string]any)
+			k, _ := kv["key"].(string)
+			if v, ok := kv["value"].(map[string]any); ok && k != "" {
+				add(k, v)
+			}
+		}
+	case map[string]any:
+		for k, e := range bv {
+			if v, ok := e.(map[string]any); ok {
+				add(k, v)
+			}
+		}
+	}
+	if n := strings.LastIndexByte(name, ':'); n >= 0 {
+		name = name[n+1:]
+	}
+	return name, out
+}
+
+func cardMedia(card map[string]any, tweetID string, out *[]Media, seen map[string]struct{}) {
+	name, bv := cardBindings(card)
+	for _, k := range cardImageKeys {
+		u := bv[k].Image
+		if u == "" {
+			continue
+		}
+		u = mediaurl.Photo(u)
+		if _, dup := seen[u]; dup {
+			return
+		}
+		seen[u] = struct{}{}
+		*out = append(*out, Media{URL: u, Type: "image", TweetID: tweetID, Card: name})
+		return
+	}
+}
+
+func tweetPoll(t map[string]any) *Poll {
+	card, ok := t["card"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	name, bv := cardBindings(card)
+	if !strings.HasPrefix(name, "poll") {
+		return nil
+	}
+	p := &Poll{Final: bv["counts_are_final"].Bool}
+	for i := 1; ; i++ {
+		c, ok := bv["choice"+strconv.Itoa(i)+"_label"]
+		if !ok {
+			break
+		}
+		n, _ := strconv.Atoi(bv["choice"+strconv.Itoa(i)+"_count"].Str)
+		p.Choices = append(p.Choices, PollChoice{Label: c.Str, Votes: n})
+	}
+	if ts, err := time.Parse(time.RFC3339, bv["end_datetime_utc"].Str); err == nil {
+		p.EndsAt = ts
+	}
+	if len(p.Choices) == 0 {
+		return nil
+	}
+	return p
+}
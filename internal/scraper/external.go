@@ -0,0 +1,30 @@
+package scraper
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ExternalHosts lists video hosts xdl cannot download from. Links to them are
+// returned as Media of type MediaExternal; nil drops them.
+var ExternalHosts []string
+
+const MediaExternal = "external"
+
+func externalLink(raw string) bool {
+	if len(ExternalHosts) == 0 || raw == "" {
+		return false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	h := strings.ToLower(strings.TrimPrefix(u.Hostname(), "www."))
+	for _, x := range ExternalHosts {
+		x = strings.ToLower(strings.TrimSpace(x))
+		if h == x || strings.HasSuffix(h, "."+x) {
+			return true
+		}
+	}
+	return false
+}
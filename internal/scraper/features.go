@@ -0,0 +1,34 @@
+package scraper
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/discovery"
+	"github.com/ghostlawless/xdl/internal/errs"
+	"github.com/ghostlawless/xdl/internal/log"
+)
+
+const maxFeatureRetries = 3
+
+func negotiateFeatures(key string, st int, b []byte) bool {
+	if st != http.StatusBadRequest {
+		return false
+	}
+	missing, unknown := errs.FeatureErrors(b)
+	if len(missing) == 0 && len(unknown) == 0 {
+		return false
+	}
+	defaults := discovery.CachedDefaults()
+	add := make(map[string]bool, len(missing))
+	for _, f := range missing {
+		add[f] = defaults[f]
+	}
+	if !config.NegotiateFeatures(key, add, unknown) {
+		return false
+	}
+	log.LogInfo("features", fmt.Sprintf("%s: added [%s] removed [%s]; retrying", key, strings.Join(missing, ", "), strings.Join(unknown, ", ")))
+	return true
+}
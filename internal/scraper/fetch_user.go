@@ -5,13 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
 	"strings"
 
 	"github.com/ghostlawless/xdl/internal/config"
-	"github.com/ghostlawless/xdl/internal/httpx"
+	"github.com/ghostlawless/xdl/internal/errs"
 	"github.com/ghostlawless/xdl/internal/log"
-	"github.com/ghostlawless/xdl/internal/utils"
 )
 
 type userByScreenNameResponse struct {
@@ -19,65 +17,146 @@ type userByScreenNameResponse struct {
 		User struct {
 			Result struct {
 				RestID string `json:"rest_id"`
+				Legacy struct {
+					Protected            bool     `json:"protected"`
+					Following            bool     `json:"following"`
+					ScreenName           string   `json:"screen_name"`
+					Name                 string   `json:"name"`
+					Description          string   `json:"description"`
+					Location             string   `json:"location"`
+					FollowersCount       int64    `json:"followers_count"`
+					FriendsCount         int64    `json:"friends_count"`
+					MediaCount           int64    `json:"media_count"`
+					ProfileImageURLHTTPS string   `json:"profile_image_url_https"`
+					ProfileBannerURL     string   `json:"profile_banner_url"`
+					PinnedTweetIDs       []string `json:"pinned_tweet_ids_str"`
+				} `json:"legacy"`
+				Core struct {
+					ScreenName string `json:"screen_name"`
+					Name       string `json:"name"`
+				} `json:"core"`
+				Location struct {
+					Location string `json:"location"`
+				} `json:"location"`
+				Avatar struct {
+					ImageURL string `json:"image_url"`
+				} `json:"avatar"`
+				Privacy struct {
+					Protected bool `json:"protected"`
+				} `json:"privacy"`
+				RelationshipPerspectives struct {
+					Following bool `json:"following"`
+				} `json:"relationship_perspectives"`
 			} `json:"result"`
 		} `json:"user"`
 	} `json:"data"`
 }
 
+func (r *userByScreenNameResponse) protectedNotFollowing() bool {
+	u := r.Data.User.Result
+	protected := u.Legacy.Protected || u.Privacy.Protected
+	following := u.Legacy.Following || u.RelationshipPerspectives.Following
+	return protected && !following
+}
+
+type UserProfile struct {
+	ID          string
+	ScreenName  string
+	Name        string
+	Description string
+	Location    string
+	Followers   int64
+	Following   int64
+	MediaCount  int64
+	AvatarURL   string
+	BannerURL   string
+	Pinned      []string
+}
+
+func (r *userByScreenNameResponse) profile() UserProfile {
+	u := r.Data.User.Result
+	p := UserProfile{
+		ID:          u.RestID,
+		ScreenName:  u.Core.ScreenName,
+		Name:        u.Core.Name,
+		Description: u.Legacy.Description,
+		Location:    u.Location.Location,
+		Followers:   u.Legacy.FollowersCount,
+		Following:   u.Legacy.FriendsCount,
+		MediaCount:  u.Legacy.MediaCount,
+		AvatarURL:   u.Avatar.ImageURL,
+		BannerURL:   u.Legacy.ProfileBannerURL,
+		Pinned:      u.Legacy.PinnedTweetIDs,
+	}
+	if p.ScreenName == "" {
+		p.ScreenName = u.Legacy.ScreenName
+	}
+	if p.Name == "" {
+		p.Name = u.Legacy.Name
+	}
+	if p.Location == "" {
+		p.Location = u.Legacy.Location
+	}
+	if p.AvatarURL == "" {
+		p.AvatarURL = u.Legacy.ProfileImageURLHTTPS
+	}
+	if p.AvatarURL != "" {
+		p.AvatarURL = strings.Replace(p.AvatarURL, "_normal.", ".", 1)
+	}
+	if p.BannerURL != "" {
+		p.BannerURL = strings.TrimRight(p.BannerURL, "/") + "/1500x500"
+	}
+	return p
+}
+
 func FetchUserID(cl *http.Client, cf *config.EssentialsConfig, usr string) (string, error) {
+	p, err := FetchUserProfile(cl, cf, usr)
+	return p.ID, err
+}
+
+func FetchUserProfile(cl *http.Client, cf *config.EssentialsConfig, usr string) (UserProfile, error) {
 	if cl == nil || cf == nil {
-		return "", errors.New("nil client or config")
+		return UserProfile{}, errors.New("nil client or config")
 	}
 	if usr == "" {
-		return "", errors.New("empty username")
+		return UserProfile{}, errors.New("empty username")
 	}
-	ep, err := cf.GraphQLURL("user_by_screen_name")
-	if err != nil {
-		return "", err
-	}
-	vj, _ := json.Marshal(map[string]string{"screen_name": usr})
-	fj, _ := cf.FeatureJSONFor("user_by_screen_name")
 	ref := strings.TrimRight(cf.X.Network, "/") + "/" + usr
-
-	q := fmt.Sprintf("%s?variables=%s&features=%s", ep, url.QueryEscape(string(vj)), url.QueryEscape(fj))
-
-	rq, err := http.NewRequest(http.MethodGet, q, nil)
+	b, st, err := queryGraphQL(cl, cf, usr, "user_by_screen_name", "UserByScreenName", map[string]any{"screen_name": usr}, ref, usr)
 	if err != nil {
-		return "", fmt.Errorf("build request: %w", err)
+		return UserProfile{}, err
 	}
-	cf.BuildRequestHeaders(rq, ref)
-	rq.Header.Set("Accept", "application/json, */*;q=0.1")
 
-	b, st, err := httpx.DoRequestWithOptions(cl, rq, httpx.RequestOptions{
-		MaxBytes: 2 << 20,
-		Decode:   true,
-	})
-
-	if err != nil {
-		if cf.Runtime.DebugEnabled {
-			p, _ := utils.SaveTimestamped(cf.Paths.Debug, "err_user_by_screen_name", "json", b)
-			meta := fmt.Sprintf("METHOD: GET\nSTATUS: %d\nURL: %s\n", st, q)
-			_, _ = utils.SaveTimestamped(cf.Paths.Debug, "err_user_by_screen_name_meta", "txt", []byte(meta))
-			log.LogError("user", fmt.Sprintf("UserByScreenName failed (status %d). see: %s", st, p))
-		} else {
-			log.LogError("user", fmt.Sprintf("UserByScreenName failed (status %d). run with -d for details.", st))
+	var typed userByScreenNameResponse
+	if jerr := json.Unmarshal(b, &typed); jerr == nil && typed.Data.User.Result.RestID != "" {
+		if typed.protectedNotFollowing() {
+			log.LogInfo("user", fmt.Sprintf("@%s is protected and not followed by this session", usr))
+			return UserProfile{}, &errs.APIError{
+				Op:      "UserByScreenName",
+				Status:  st,
+				Message: "account is protected; you must follow them",
+				Kind:    errs.ErrProtectedAccount,
+			}
 		}
-		return "", err
+		return typed.profile(), nil
 	}
 
-	var typed userByScreenNameResponse
-	if jerr := json.Unmarshal(b, &typed); jerr == nil && typed.Data.User.Result.RestID != "" {
-		return typed.Data.User.Result.RestID, nil
+	if ue := errs.FromUserResult("UserByScreenName", b); ue != nil {
+		return UserProfile{}, ue
 	}
 
 	var generic any
 	if jerr := json.Unmarshal(b, &generic); jerr == nil {
 		if id := extractRestIDFromAny(generic); id != "" {
-			return id, nil
+			return UserProfile{ID: id, ScreenName: usr}, nil
 		}
 	}
 
-	return "", errors.New("rest_id not found in response")
+	if ge := errs.FromGraphQL("UserByScreenName", st, b); ge != nil {
+		return UserProfile{}, ge
+	}
+
+	return UserProfile{}, &errs.APIError{Op: "UserByScreenName", Status: st, Message: "@" + usr + " (rest_id not found in response)", Kind: errs.ErrUserNotFound}
 }
 
 func extractRestIDFromAny(v any) string {
@@ -100,3 +179,50 @@ func extractRestIDFromAny(v any) string {
 	}
 	return ""
 }
+
+type userByRestIDResponse struct {
+	Data struct {
+		User struct {
+			Result struct {
+				RestID string `json:"rest_id"`
+				Legacy struct {
+					ScreenName string `json:"screen_name"`
+				} `json:"legacy"`
+				Core struct {
+					ScreenName string `json:"screen_name"`
+				} `json:"core"`
+			} `json:"result"`
+		} `json:"user"`
+	} `json:"data"`
+}
+
+func FetchUserByRestID(cl *http.Client, cf *config.EssentialsConfig, id string) (string, error) {
+	if cl == nil || cf == nil {
+		return "", errors.New("nil client or config")
+	}
+	if id == "" {
+		return "", errors.New("empty userID")
+	}
+	ref := strings.TrimRight(cf.X.Network, "/") + "/i/user/" + id
+	b, st, err := queryGraphQL(cl, cf, "", "user_by_rest_id", "UserByRestId", map[string]any{"userId": id}, ref, id)
+	if err != nil {
+		return "", err
+	}
+	if ue := errs.FromUserResult("UserByRestId", b); ue != nil {
+		return "", ue
+	}
+	var typed userByRestIDResponse
+	if jerr := json.Unmarshal(b, &typed); jerr == nil {
+		r := typed.Data.User.Result
+		if sn := r.Core.ScreenName; sn != "" {
+			return sn, nil
+		}
+		if sn := r.Legacy.ScreenName; sn != "" {
+			return sn, nil
+		}
+	}
+	if ge := errs.FromGraphQL("UserByRestId", st, b); ge != nil {
+		return "", ge
+	}
+	return "", &errs.APIError{Op: "UserByRestId", Status: st, Message: "user " + id + " not found", Kind: errs.ErrUserNotFound}
+}
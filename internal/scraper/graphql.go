@@ -0,0 +1,101 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/discovery"
+	"github.com/ghostlawless/xdl/internal/errs"
+	"github.com/ghostlawless/xdl/internal/httpx"
+	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+func queryGraphQL(cl *http.Client, cf *config.EssentialsConfig, who, key, op string, vars map[string]any, ref, tag string) ([]byte, int, error) {
+	gt := cf.FreshGuestToken(cl)
+	ct := cf.CsrfToken()
+	b, st, err := queryGraphQLOnce(cl, cf, who, key, op, vars, ref, tag)
+	if ct != "" && st == http.StatusForbidden && cf.CsrfToken() != ct {
+		log.LogInfo("graphql", op+" returned 403 after X rotated ct0; retrying with the new token")
+		b, st, err = queryGraphQLOnce(cl, cf, who, key, op, vars, ref, tag)
+	}
+	if gt != "" && guestRejected(st) && cf.RefreshGuestSession(cl, gt) {
+		log.LogInfo("graphql", fmt.Sprintf("%s returned %d for the guest token; retrying with a new one", op, st))
+		b, st, err = queryGraphQLOnce(cl, cf, who, key, op, vars, ref, tag)
+	}
+	if st == http.StatusNotFound && discovery.Refresh(cl, cf, key) {
+		log.LogInfo("graphql", op+" returned 404; retrying with a refreshed queryId")
+		b, st, err = queryGraphQLOnce(cl, cf, who, key, op, vars, ref, tag)
+	}
+	for i := 0; i < maxFeatureRetries && negotiateFeatures(key, st, b); i++ {
+		b, st, err = queryGraphQLOnce(cl, cf, who, key, op, vars, ref, tag)
+	}
+	for i := 0; i < maxRateLimitWaits && waitRateLimit(who, op, err); i++ {
+		b, st, err = queryGraphQLOnce(cl, cf, who, key, op, vars, ref, tag)
+	}
+	return b, st, err
+}
+
+func guestRejected(st int) bool {
+	return st == http.StatusUnauthorized || st == http.StatusForbidden || st == http.StatusTooManyRequests
+}
+
+func queryGraphQLOnce(cl *http.Client, cf *config.EssentialsConfig, who, key, op string, vars map[string]any, ref, tag string) ([]byte, int, error) {
+	waitWhilePaused()
+	if err := awaitCooldown(who, op); err != nil {
+		return nil, http.StatusTooManyRequests, err
+	}
+	ep, err := cf.GraphQLURL(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	vj, _ := json.Marshal(vars)
+	fj, _ := cf.FeatureJSONFor(key)
+
+	q := fmt.Sprintf("%s?variables=%s&features=%s", ep, url.QueryEscape(string(vj)), url.QueryEscape(fj))
+
+	rq, err := http.NewRequest(http.MethodGet, q, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("build request: %w", err)
+	}
+	cf.BuildRequestHeaders(rq, ref)
+	rq.Header.Set("Accept", "application/json, */*;q=0.1")
+
+	var h http.Header
+	b, st, hit, err := httpx.DoCached(cl, rq, httpx.RequestOptions{
+		MaxBytes: 2 << 20,
+		Decode:   true,
+		Header:   &h,
+	}, ResponseCache, cacheKey(cf, q))
+	if hit {
+		log.LogInfo("graphql", op+" served from the response cache")
+		return b, st, nil
+	}
+	dumpRaw(cf, rawName(op, tag), b)
+	Pacing.Observe(st, h)
+	if ok, perr := cf.RotateCt0(h); ok {
+		log.LogInfo("graphql", "ct0 cookie rotated by X; session updated")
+		if perr != nil {
+			log.LogError("graphql", "could not persist the rotated ct0 to "+cf.SessionFile()+": "+perr.Error())
+		}
+	}
+
+	if err != nil {
+		if cf.Runtime.DebugEnabled {
+			p, _ := utils.SaveTimestamped(cf.Paths.Debug, "err_"+key, "json", b)
+			meta := fmt.Sprintf("METHOD: GET\nSTATUS: %d\nTRACE: %s\nURL: %s\n", st, h.Get(httpx.TraceHeader), q)
+			_, _ = utils.SaveTimestamped(cf.Paths.Debug, "err_"+key+"_meta", "txt", []byte(meta))
+			log.LogError("graphql", fmt.Sprintf("%s failed (status %d, trace %s). see: %s", op, st, h.Get(httpx.TraceHeader), p))
+		} else {
+			log.LogError("graphql", fmt.Sprintf("%s failed (status %d, trace %s). run with -d for details.", op, st, h.Get(httpx.TraceHeader)))
+		}
+		if ge := errs.FromGraphQL(op, st, b); ge != nil {
+			return b, st, errs.WithReset(ge, h)
+		}
+		return b, st, errs.WithReset(errs.FromStatus(op, st, err), h)
+	}
+	return b, st, nil
+}
@@ -9,18 +9,31 @@ import (
 	"net/url"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/discovery"
+	"github.com/ghostlawless/xdl/internal/errs"
 	"github.com/ghostlawless/xdl/internal/httpx"
 	"github.com/ghostlawless/xdl/internal/log"
 	xruntime "github.com/ghostlawless/xdl/internal/runtime"
+	"github.com/ghostlawless/xdl/internal/trace"
 	"github.com/ghostlawless/xdl/internal/utils"
 )
 
 type Media struct {
-	URL     string `json:"url"`
-	Type    string `json:"type"`
-	TweetID string `json:"tweet_id,omitempty"`
+	URL      string `json:"url"`
+	Type     string `json:"type"`
+	TweetID  string `json:"tweet_id,omitempty"`
+	Poster   string `json:"poster,omitempty"`
+	AltText  string `json:"alt_text,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Geo      *Geo   `json:"geo,omitempty"`
+	Card     string `json:"card,omitempty"`
+	Poll     *Poll  `json:"poll,omitempty"`
+	Pinned   bool   `json:"pinned,omitempty"`
+	Author   string `json:"author,omitempty"`
+	AuthorID string `json:"author_id,omitempty"`
 }
 
 type PageHandler func(page int, cursor string, medias []Media) error
@@ -33,6 +46,28 @@ func WalkUserMediaPages(
 	vb bool,
 	lim *xruntime.Limiter,
 	handler PageHandler,
+) error {
+	mt := 0
+	if cf != nil {
+		mt = cf.Runtime.MaxTweets
+	}
+	return WalkUserMediaPagesLimited(cl, cf, uid, sn, vb, lim, 0, mt, handler)
+}
+
+// WalkUserMediaPagesLimited is WalkUserMediaPages with the page and tweet
+// limits given by the caller instead of taken from the runtime config, so
+// one user can have its own. maxPages 0 means the configured cap and
+// maxTweets 0 means no limit.
+func WalkUserMediaPagesLimited(
+	cl *http.Client,
+	cf *config.EssentialsConfig,
+	uid string,
+	sn string,
+	vb bool,
+	lim *xruntime.Limiter,
+	maxPages int,
+	maxTweets int,
+	handler PageHandler,
 ) error {
 	if cl == nil || cf == nil {
 		return errors.New("nil client or config")
@@ -81,20 +116,26 @@ func WalkUserMediaPages(
 		return walk(root)
 	}
 
-	ep, err := cf.GraphQLURL("user_media")
-	if err != nil {
+	if _, err := cf.GraphQLURL("user_media"); err != nil {
 		return err
 	}
 
 	cur := ""
 	pg := 1
 	stg := 0
-	const mx = 200
+	mx := maxPages
+	if mx <= 0 {
+		mx = cf.MaxPages()
+	}
+	mt := maxTweets
+	capped := false
 
 	seenCursors := make(map[string]struct{}, 256)
 	seenCursors[""] = struct{}{}
 
 	seenMedia := make(map[string]struct{}, 1024)
+	seenTweets := make(map[string]struct{}, 1024)
+	var oldest time.Time
 
 	ic := 0
 	vc := 0
@@ -116,44 +157,15 @@ func WalkUserMediaPages(
 			lim.SleepBeforeRequest(context.Background(), sn, pg, ri)
 		}
 
-		vars := map[string]any{
-			"userId":                 uid,
-			"count":                  100,
-			"includePromotedContent": false,
-			"withClientEventToken":   false,
-			"withVoice":              false,
-		}
-		if cur != "" {
-			vars["cursor"] = cur
-		}
-
-		vj, err := json.Marshal(vars)
-		if cf.Runtime.DebugEnabled && err != nil {
-			return fmt.Errorf("marshal variables: %w", err)
-		}
-		fj, err := cf.FeatureJSONFor("user_media")
-		if cf.Runtime.DebugEnabled && err != nil {
-			return fmt.Errorf("get features for user_media: %w", err)
-		}
-
-		q := fmt.Sprintf("%s?variables=%s&features=%s",
-			ep,
-			url.QueryEscape(string(vj)),
-			url.QueryEscape(fj),
-		)
-
-		rq, gerr := http.NewRequest(http.MethodGet, q, nil)
-		if gerr != nil {
-			return fmt.Errorf("build request: %w", gerr)
-		}
-		cf.BuildRequestHeaders(rq, ref)
-		rq.Header.Set("Accept", "application/json, */*;q=0.1")
-
-		b, st, reqErr := httpx.DoRequestWithOptions(cl, rq, httpx.RequestOptions{
-			MaxBytes: 8 << 20,
-			Decode:   true,
-			Accept:   func(s int) bool { return s >= 200 && s < 300 },
-		})
+		sp := trace.Start("media-page", nil)
+		sp.Set("user", sn)
+		sp.Set("page", pg)
+		b, q, st, reqErr := fetchUserMediaPage(cl, cf, sn, uid, cur, ref, sp)
+		sp.Set("status", st)
+		sp.Set("bytes", len(b))
+		sp.Fail(reqErr)
+		sp.End()
+		dumpRaw(cf, rawName("UserMedia", sn, fmt.Sprintf("%03d", pg)), b)
 		if reqErr != nil {
 			if cf.Runtime.DebugEnabled {
 				p, _ := utils.SaveTimestamped(cf.Paths.Debug, "err_user_media", "json", b)
@@ -166,6 +178,15 @@ func WalkUserMediaPages(
 			} else {
 				log.LogError("media", fmt.Sprintf("UserMedia failed (status %d). run with -d for details.", st))
 			}
+			if errors.Is(reqErr, errs.ErrRateLimited) {
+				return reqErr
+			}
+			if ge := errs.FromGraphQL("UserMedia", st, b); ge != nil && errors.Unwrap(ge) != nil {
+				return ge
+			}
+			if ce := errs.FromStatus("UserMedia", st, reqErr); ce != reqErr {
+				return ce
+			}
 			end = "http_error"
 			break
 		}
@@ -198,6 +219,9 @@ func WalkUserMediaPages(
 			end = "parse_error"
 			break
 		}
+		if err := checkShape("UserMedia", sn, pg, b); err != nil {
+			return err
+		}
 
 		pageBatch := make([]Media, 0, len(pms))
 		for _, m := range pms {
@@ -207,8 +231,19 @@ func WalkUserMediaPages(
 			if _, dup := seenMedia[m.URL]; dup {
 				continue
 			}
+			_, known := seenTweets[m.TweetID]
+			if !known && mt > 0 && len(seenTweets) >= mt {
+				capped = true
+				continue
+			}
 			seenMedia[m.URL] = struct{}{}
 			pageBatch = append(pageBatch, m)
+			if !known && m.TweetID != "" {
+				seenTweets[m.TweetID] = struct{}{}
+				if t := TweetTime(m.TweetID); !t.IsZero() && (oldest.IsZero() || t.Before(oldest)) {
+					oldest = t
+				}
+			}
 			if m.Type == "image" {
 				ic++
 			} else if m.Type == "video" {
@@ -222,7 +257,12 @@ func WalkUserMediaPages(
 			log.LogInfo("media", fmt.Sprintf("page %d: +%d (total %d)", pg, delta, total))
 		}
 
-		if vb {
+		if ScanProgress != nil {
+			ScanProgress(ScanEvent{
+				User: sn, Pages: pg, Tweets: len(seenTweets), Media: total,
+				Images: ic, Videos: vc, Expected: totalExpected, Oldest: oldest,
+			})
+		} else if vb {
 			spin := frames[(ri-1)%len(frames)]
 
 			if totalExpected > 0 {
@@ -249,7 +289,7 @@ func WalkUserMediaPages(
 
 					bar := buildScanProgressBar(24, frac)
 
-					fmt.Printf(
+					fmt.Fprintf(utils.Stdout,
 						"scanning media for target @%s [%c] [%s] %3d%% eos:%d (total:%d/%d img:%d vid:%d page:%d)\n",
 						sn, spin, bar, pct, ri, total, totalExpected, ic, vc, pg,
 					)
@@ -259,7 +299,7 @@ func WalkUserMediaPages(
 					lastScanTotal = total
 					lastScanReq = ri
 
-					fmt.Printf(
+					fmt.Fprintf(utils.Stdout,
 						"scanning media for target @%s [%c] eos:%d (total:%d img:%d vid:%d page:%d)\n",
 						sn, spin, ri, total, ic, vc, pg,
 					)
@@ -273,6 +313,12 @@ func WalkUserMediaPages(
 			}
 		}
 
+		if capped {
+			log.LogInfo("media", fmt.Sprintf("max tweets reached (%d) — stopping", mt))
+			end = "max_tweets"
+			break
+		}
+
 		if len(pageBatch) == 0 {
 			stg++
 		} else {
@@ -380,3 +426,123 @@ func GetMediaLinksForUser(cl *http.Client, cf *config.EssentialsConfig, uid stri
 
 	return all, nil
 }
+
+func fetchUserMediaPage(cl *http.Client, cf *config.EssentialsConfig, who, uid, cur, ref string, sp *trace.Span) ([]byte, string, int, error) {
+	n := 0
+	b, q, st, err := fetchUserMediaPageOnce(cl, cf, who, uid, cur, ref)
+	if st == http.StatusNotFound && discovery.Refresh(cl, cf, "user_media") {
+		log.LogInfo("media", "UserMedia returned 404; retrying with a refreshed queryId")
+		b, q, st, err = fetchUserMediaPageOnce(cl, cf, who, uid, cur, ref)
+		n++
+	}
+	for i := 0; i < maxFeatureRetries && negotiateFeatures("user_media", st, b); i++ {
+		b, q, st, err = fetchUserMediaPageOnce(cl, cf, who, uid, cur, ref)
+		n++
+	}
+	for i := 0; i < maxRateLimitWaits && waitRateLimit(who, "UserMedia", err); i++ {
+		b, q, st, err = fetchUserMediaPageOnce(cl, cf, who, uid, cur, ref)
+		n++
+	}
+	sp.Set("retries", n)
+	return b, q, st, err
+}
+
+func fetchUserMediaPageOnce(cl *http.Client, cf *config.EssentialsConfig, who, uid, cur, ref string) ([]byte, string, int, error) {
+	waitWhilePaused()
+	if err := awaitCooldown(who, "UserMedia"); err != nil {
+		return nil, "", http.StatusTooManyRequests, err
+	}
+	ep, err := cf.GraphQLURL("user_media")
+	if err != nil {
+		return nil, "", 0, err
+	}
+	vars := map[string]any{
+		"userId":                 uid,
+		"count":                  cf.PageSize(),
+		"includePromotedContent": false,
+		"withClientEventToken":   false,
+		"withVoice":              false,
+	}
+	if cur != "" {
+		vars["cursor"] = cur
+	}
+
+	vj, err := json.Marshal(vars)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("marshal variables: %w", err)
+	}
+	fj, err := cf.FeatureJSONFor("user_media")
+	if cf.Runtime.DebugEnabled && err != nil {
+		return nil, "", 0, fmt.Errorf("get features for user_media: %w", err)
+	}
+
+	q := fmt.Sprintf("%s?variables=%s&features=%s",
+		ep,
+		url.QueryEscape(string(vj)),
+		url.QueryEscape(fj),
+	)
+
+	rq, gerr := http.NewRequest(http.MethodGet, q, nil)
+	if gerr != nil {
+		return nil, q, 0, fmt.Errorf("build request: %w", gerr)
+	}
+	cf.BuildRequestHeaders(rq, ref)
+	rq.Header.Set("Accept", "application/json, */*;q=0.1")
+
+	var h http.Header
+	b, st, hit, err := httpx.DoCached(cl, rq, httpx.RequestOptions{
+		MaxBytes: 8 << 20,
+		Decode:   true,
+		Accept:   func(s int) bool { return s >= 200 && s < 300 },
+		Header:   &h,
+	}, ResponseCache, cacheKey(cf, rq.URL.String()))
+	if hit {
+		log.LogInfo("media", "UserMedia page served from the response cache")
+		return b, q, st, nil
+	}
+	Pacing.Observe(st, h)
+	if rl := rateLimitError("UserMedia", st, b, err, h); rl != nil {
+		err = rl
+	}
+	return b, q, st, err
+}
+
+type MediaProbe struct {
+	Media  int
+	Cursor bool
+}
+
+func ProbeUserMedia(cl *http.Client, cf *config.EssentialsConfig, uid string) (MediaProbe, error) {
+	if cl == nil || cf == nil {
+		return MediaProbe{}, errors.New("nil client or config")
+	}
+	if _, err := cf.GraphQLURL("user_media"); err != nil {
+		return MediaProbe{}, err
+	}
+	ref := strings.TrimRight(cf.X.Network, "/") + "/i/user/" + uid + "/media"
+	b, _, st, err := fetchUserMediaPage(cl, cf, "", uid, "", ref, nil)
+	if err != nil {
+		if errors.Is(err, errs.ErrRateLimited) {
+			return MediaProbe{}, err
+		}
+		if ge := errs.FromGraphQL("UserMedia", st, b); ge != nil && errors.Unwrap(ge) != nil {
+			return MediaProbe{}, ge
+		}
+		return MediaProbe{}, errs.FromStatus("UserMedia", st, err)
+	}
+	ms, err := fold(b)
+	if err != nil {
+		return MediaProbe{}, &errs.APIError{Op: "UserMedia", Status: st, Message: err.Error(), Kind: errs.ErrShapeChanged}
+	}
+	p := MediaProbe{Media: len(ms), Cursor: bottom(mustJSON(b)) != ""}
+	if p.Media == 0 || !p.Cursor {
+		return p, &errs.APIError{Op: "UserMedia", Status: st, Message: "no media or cursor in a known non-empty timeline", Kind: errs.ErrShapeChanged}
+	}
+	return p, nil
+}
+
+func mustJSON(b []byte) any {
+	var v any
+	_ = json.Unmarshal(b, &v)
+	return v
+}
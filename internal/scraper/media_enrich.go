@@ -109,6 +109,7 @@ func EnrichMediaWithTweetDetail(
 
 		ref := strings.TrimRight(cf.X.Network, "/") + "/" + screenName + "/status/" + tid
 
+		waitWhilePaused()
 		req, rerr := http.NewRequest(http.MethodGet, q, nil)
 		if rerr != nil {
 			httpErrors++
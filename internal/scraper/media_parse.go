@@ -2,10 +2,20 @@ package scraper
 
 import (
 	"encoding/json"
-	"net/url"
+	"strconv"
 	"strings"
+
+	"github.com/ghostlawless/xdl/internal/mediaurl"
 )
 
+func ParseMediaPage(b []byte) ([]Media, string, error) {
+	ms, err := fold(b)
+	if err != nil {
+		return nil, "", err
+	}
+	return ms, bottom(mustJSON(b)), nil
+}
+
 func fold(b []byte) ([]Media, error) {
 	var root any
 	if err := json.Unmarshal(b, &root); err != nil {
@@ -16,6 +26,9 @@ func fold(b []byte) ([]Media, error) {
 	seen := make(map[string]struct{}, 64)
 
 	collectMedia(root, "", &out, seen)
+	tw := map[string]tweetInfo{}
+	collectTweets(root, tw)
+	withTweets(out, tw)
 
 	return out, nil
 }
@@ -43,15 +56,17 @@ func collectMedia(v any, currentTweetID string, out *[]Media, seen map[string]st
 					}
 				}
 
-				urlStr := base
+				urlStr, poster := base, ""
 				if mediaType == "video" {
 					if vu, _ := bestVideoVariant(t); vu != "" {
-						urlStr = vu
+						urlStr, poster = vu, base
 					}
 				} else {
-					urlStr = normalizeImageURL(base)
+					urlStr = mediaurl.Photo(base)
 				}
 
+				alt, _ := t["ext_alt_text"].(string)
+
 				if urlStr != "" {
 					if _, dup := seen[urlStr]; !dup {
 						seen[urlStr] = struct{}{}
@@ -59,12 +74,25 @@ func collectMedia(v any, currentTweetID string, out *[]Media, seen map[string]st
 							URL:     urlStr,
 							Type:    mediaType,
 							TweetID: currentTweetID,
+							Poster:  poster,
+							AltText: strings.TrimSpace(alt),
 						})
 					}
 				}
 			}
 		}
 
+		if u, ok := t["expanded_url"].(string); ok && externalLink(u) {
+			if _, dup := seen[u]; !dup {
+				seen[u] = struct{}{}
+				*out = append(*out, Media{URL: u, Type: MediaExternal, TweetID: currentTweetID})
+			}
+		}
+
+		if c, ok := t["card"].(map[string]any); ok && IncludeCards {
+			cardMedia(c, currentTweetID, out, seen)
+		}
+
 		for _, child := range t {
 			collectMedia(child, currentTweetID, out, seen)
 		}
@@ -76,29 +104,6 @@ func collectMedia(v any, currentTweetID string, out *[]Media, seen map[string]st
 	}
 }
 
-func normalizeImageURL(u string) string {
-	if u == "" {
-		return ""
-	}
-	pu, err := url.Parse(u)
-	if err != nil {
-		return u
-	}
-	if !strings.Contains(strings.ToLower(pu.Host), "twimg.com") {
-		return u
-	}
-	q := pu.Query()
-	format := q.Get("format")
-
-	nq := url.Values{}
-	if format != "" {
-		nq.Set("format", format)
-	}
-	nq.Set("name", "orig")
-	pu.RawQuery = nq.Encode()
-	return pu.String()
-}
-
 func bestVideoVariant(m map[string]any) (string, int) {
 	vi, ok := m["video_info"].(map[string]any)
 	if !ok {
@@ -127,8 +132,11 @@ func bestVideoVariant(m map[string]any) (string, int) {
 			continue
 		}
 		br := 0
-		if f, ok := mv["bitrate"].(float64); ok {
-			br = int(f)
+		switch b := mv["bitrate"].(type) {
+		case float64:
+			br = int(b)
+		case string:
+			br, _ = strconv.Atoi(b)
 		}
 		if br > bestBR {
 			bestBR = br
@@ -0,0 +1,11 @@
+package scraper
+
+import "time"
+
+var Paused func() bool
+
+func waitWhilePaused() {
+	for Paused != nil && Paused() {
+		time.Sleep(200 * time.Millisecond)
+	}
+}
@@ -0,0 +1,57 @@
+package scraper
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ghostlawless/xdl/internal/config"
+)
+
+// probeVars are harmless variables for each known operation, pointing at @X
+// and its first tweet.
+var probeVars = map[string]map[string]any{
+	"user_by_screen_name": {"screen_name": "X"},
+	"user_by_rest_id":     {"userId": "783214"},
+	"user_media": {
+		"userId":                 "783214",
+		"count":                  1,
+		"includePromotedContent": false,
+		"withClientEventToken":   false,
+		"withVoice":              false,
+	},
+	"tweet_results_by_rest_ids": {
+		"tweetIds":               []string{"20"},
+		"includePromotedContent": false,
+		"withBirdwatchNotes":     false,
+		"withVoice":              true,
+		"withCommunity":          true,
+	},
+	"tweet_detail": {
+		"focalTweetId":           "20",
+		"includePromotedContent": false,
+		"withBirdwatchNotes":     false,
+		"withVoice":              false,
+		"withV2Timeline":         true,
+	},
+	"search_timeline": {
+		"rawQuery":    "from:X",
+		"count":       1,
+		"querySource": "typed_query",
+		"product":     "Latest",
+	},
+}
+
+// ProbeOperation sends one request for a configured GraphQL operation and
+// returns its HTTP status. Unlike a normal call it does not refresh the
+// queryId, negotiate features or retry, so the status is what the config in
+// use gets right now. The status is 0 when the request never got an answer.
+func ProbeOperation(cl *http.Client, cf *config.EssentialsConfig, key string) (int, error) {
+	op := cf.OperationNames()[key]
+	vars := probeVars[key]
+	if vars == nil {
+		vars = map[string]any{}
+	}
+	ref := strings.TrimRight(cf.X.Network, "/") + "/"
+	_, st, err := queryGraphQLOnce(cl, cf, "", key, op, vars, ref, "probe")
+	return st, err
+}
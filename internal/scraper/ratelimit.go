@@ -0,0 +1,59 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/errs"
+	"github.com/ghostlawless/xdl/internal/log"
+	xruntime "github.com/ghostlawless/xdl/internal/runtime"
+)
+
+const maxRateLimitWaits = 3
+
+var RateLimitWait func(who, op string, reset time.Time) bool
+
+var Cooldowns *xruntime.Cooldowns
+
+var Pacing *xruntime.Pacing
+
+func waitRateLimit(who, op string, err error) bool {
+	if !errors.Is(err, errs.ErrRateLimited) {
+		return false
+	}
+	Cooldowns.Hold(op, errs.ResetTime(err))
+	if RateLimitWait == nil {
+		return false
+	}
+	return RateLimitWait(who, op, errs.ResetTime(err))
+}
+
+func awaitCooldown(who, op string) error {
+	t := Cooldowns.Until(op)
+	if t.IsZero() {
+		return nil
+	}
+	if RateLimitWait == nil {
+		return &errs.APIError{Op: op, Status: http.StatusTooManyRequests, Message: "endpoint is cooling down", Kind: errs.ErrRateLimited, Reset: t}
+	}
+	log.LogInfo("ratelimit", fmt.Sprintf("%s is cooling down; queued until %s", op, t.Format("15:04:05")))
+	if !Cooldowns.Wait(op, who) {
+		return &errs.APIError{Op: op, Status: http.StatusTooManyRequests, Message: "stopped while waiting for the rate limit", Kind: errs.ErrRateLimited, Reset: t}
+	}
+	return nil
+}
+
+func rateLimitError(op string, st int, b []byte, err error, h http.Header) error {
+	if err == nil {
+		return nil
+	}
+	if ge := errs.FromGraphQL(op, st, b); errors.Is(ge, errs.ErrRateLimited) {
+		return errs.WithReset(ge, h)
+	}
+	if se := errs.FromStatus(op, st, err); errors.Is(se, errs.ErrRateLimited) {
+		return errs.WithReset(se, h)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/utils"
+)
+
+const redacted = "[REDACTED]"
+
+var sensitiveKeys = []string{
+	"auth_token", "ct0", "guest_id", "authorization", "bearer", "cookie",
+	"password", "email", "phone", "token", "secret",
+}
+
+func dumpRaw(cf *config.EssentialsConfig, name string, b []byte) {
+	if cf == nil || cf.Paths.DebugRaw == "" || len(b) == 0 {
+		return
+	}
+	utils.SaveJSONDebug(cf.Paths.DebugRaw, name, redactRaw(cf, b))
+}
+
+func rawName(op string, parts ...any) string {
+	s := op
+	for _, p := range parts {
+		s += "_" + fmt.Sprint(p)
+	}
+	return s
+}
+
+func Redact(cf *config.EssentialsConfig, b []byte) []byte {
+	return redactRaw(cf, b)
+}
+
+func redactRaw(cf *config.EssentialsConfig, b []byte) []byte {
+	for _, v := range []string{cf.Auth.Cookies.AuthToken, cf.CsrfToken(), cf.Auth.Cookies.GuestID, cf.Auth.Bearer} {
+		if len(v) >= 8 {
+			b = bytes.ReplaceAll(b, []byte(v), []byte(redacted))
+		}
+	}
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return b
+	}
+	out, err := json.MarshalIndent(redactValue(v), "", "  ")
+	if err != nil {
+		return b
+	}
+	return out
+}
+
+func redactValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, vv := range t {
+			if isSensitiveKey(k) {
+				if _, ok := vv.(string); ok {
+					t[k] = redacted
+					continue
+				}
+			}
+			t[k] = redactValue(vv)
+		}
+	case []any:
+		for i, vv := range t {
+			t[i] = redactValue(vv)
+		}
+	}
+	return v
+}
+
+func isSensitiveKey(k string) bool {
+	k = strings.ToLower(k)
+	if strings.HasSuffix(k, "_count") || strings.HasSuffix(k, "_enabled") {
+		return false
+	}
+	for _, s := range sensitiveKeys {
+		if strings.Contains(k, s) {
+			return true
+		}
+	}
+	return false
+}
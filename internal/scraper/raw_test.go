@@ -0,0 +1,51 @@
+package scraper
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ghostlawless/xdl/internal/config"
+)
+
+func TestRedact(t *testing.T) {
+	cf := &config.EssentialsConfig{}
+	cf.Auth.Bearer = "AAAAbearer123"
+	cf.Auth.Cookies = config.AuthCookies{AuthToken: "authtok12345", Ct0: "csrf98765432", GuestID: "v1%3A42"}
+
+	in := `{"data":{"note":"see authtok12345 and csrf98765432","guest":"v1%3A42",` +
+		`"user":{"email":"a@b.c","Phone_Number":"555","followers_count":"12","token_enabled":"yes",` +
+		`"has_password":true,"secrets":[{"api_secret":"x"}]}}}`
+	out := Redact(cf, []byte(in))
+	for _, s := range []string{"authtok12345", "csrf98765432", "a@b.c", "555", `"x"`} {
+		if strings.Contains(string(out), s) {
+			t.Errorf("output still holds %q:\n%s", s, out)
+		}
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	want := map[string]any{"data": map[string]any{
+		"note":  "see [REDACTED] and [REDACTED]",
+		"guest": "v1%3A42",
+		"user": map[string]any{
+			"email":           "[REDACTED]",
+			"Phone_Number":    "[REDACTED]",
+			"followers_count": "12",
+			"token_enabled":   "yes",
+			"has_password":    true,
+			"secrets":         []any{map[string]any{"api_secret": "[REDACTED]"}},
+		},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Redact =\n%s", out)
+	}
+
+	// Bodies that are not JSON still lose the session values.
+	if got := string(Redact(cf, []byte("Bearer AAAAbearer123; ct0=csrf98765432"))); got != "Bearer [REDACTED]; ct0=[REDACTED]" {
+		t.Errorf("Redact(text) = %q", got)
+	}
+}
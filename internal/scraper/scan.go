@@ -0,0 +1,29 @@
+package scraper
+
+import (
+	"strconv"
+	"time"
+)
+
+type ScanEvent struct {
+	User     string
+	Pages    int
+	Tweets   int
+	Media    int
+	Images   int
+	Videos   int
+	Expected int
+	Oldest   time.Time
+}
+
+var ScanProgress func(ScanEvent)
+
+const twitterEpochMS = 1288834974657
+
+func TweetTime(id string) time.Time {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || n < 1<<22 {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(n>>22) + twitterEpochMS).UTC()
+}
@@ -0,0 +1,135 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/log"
+	xruntime "github.com/ghostlawless/xdl/internal/runtime"
+)
+
+const (
+	DeepWindow       = 90 * 24 * time.Hour
+	deepMaxEmpty     = 12
+	deepPagesPerSpan = 50
+)
+
+var deepFloor = time.Date(2006, 3, 21, 0, 0, 0, 0, time.UTC)
+
+func SearchQuery(sn string, since, until time.Time) string {
+	return fmt.Sprintf("from:%s since:%s until:%s filter:media include:nativeretweets",
+		sn, since.UTC().Format("2006-01-02"), until.UTC().Format("2006-01-02"))
+}
+
+func WalkSearchMediaWindows(
+	cl *http.Client,
+	cf *config.EssentialsConfig,
+	sn string,
+	before time.Time,
+	page int,
+	lim *xruntime.Limiter,
+	handler PageHandler,
+) error {
+	if cl == nil || cf == nil {
+		return errors.New("nil client or config")
+	}
+	if _, err := cf.GraphQLURL("search_timeline"); err != nil {
+		return err
+	}
+	if before.IsZero() {
+		before = time.Now().UTC()
+	}
+	until := before.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	ref := strings.TrimRight(cf.X.Network, "/") + "/search?f=media&q=" + url.QueryEscape("from:"+sn)
+
+	found, empty := 0, 0
+	for ri := 1; until.After(deepFloor) && empty < deepMaxEmpty; ri++ {
+		since := until.Add(-DeepWindow)
+		if since.Before(deepFloor) {
+			since = deepFloor
+		}
+		n, err := walkSearchWindow(cl, cf, sn, since, until, ref, &page, ri, lim, handler)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			empty++
+		} else {
+			empty = 0
+		}
+		found += n
+		if ScanProgress != nil {
+			ScanProgress(ScanEvent{User: sn, Pages: page, Media: found, Oldest: since})
+		}
+		until = since
+	}
+	log.LogInfo("media", fmt.Sprintf("deep search for @%s finished at %s: %d media", sn, until.Format("2006-01-02"), found))
+	return nil
+}
+
+func walkSearchWindow(
+	cl *http.Client,
+	cf *config.EssentialsConfig,
+	sn string,
+	since, until time.Time,
+	ref string,
+	page *int,
+	ri int,
+	lim *xruntime.Limiter,
+	handler PageHandler,
+) (int, error) {
+	q := SearchQuery(sn, since, until)
+	cur := ""
+	seen := map[string]struct{}{"": {}}
+	total := 0
+	for i := 0; i < deepPagesPerSpan; i++ {
+		*page++
+		if lim != nil {
+			lim.SleepBeforeRequest(context.Background(), sn, *page, ri)
+		}
+		vars := map[string]any{
+			"rawQuery":    q,
+			"count":       cf.PageSize(),
+			"querySource": "typed_query",
+			"product":     "Media",
+		}
+		if cur != "" {
+			vars["cursor"] = cur
+		}
+		b, _, err := queryGraphQL(cl, cf, sn, "search_timeline", "SearchTimeline", vars, ref, fmt.Sprintf("%s_%s", sn, since.Format("20060102")))
+		if err != nil {
+			return total, err
+		}
+		ms, err := fold(b)
+		if err != nil {
+			log.LogError("media", fmt.Sprintf("parse search page for %q failed: %v", q, err))
+			return total, nil
+		}
+		if err := checkShape("SearchTimeline", sn, *page, b); err != nil {
+			return total, err
+		}
+		log.LogInfo("media", fmt.Sprintf("search %q page %d: %d media", q, i+1, len(ms)))
+		if len(ms) == 0 {
+			return total, nil
+		}
+		total += len(ms)
+		if handler != nil {
+			if err := handler(*page, cur, ms); err != nil {
+				return total, err
+			}
+		}
+		nx := next(b)
+		if _, dup := seen[nx]; dup {
+			return total, nil
+		}
+		seen[nx] = struct{}{}
+		cur = nx
+	}
+	return total, nil
+}
@@ -0,0 +1,282 @@
+package scraper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ghostlawless/xdl/internal/errs"
+	"github.com/ghostlawless/xdl/internal/log"
+)
+
+// StrictParse fails a timeline page that has an entry or media object the
+// parser does not recognise with ErrShapeChanged. By default they are skipped
+// and reported through Unparsed.
+var StrictParse bool
+
+// Unparsed receives the schema drift found on one timeline page.
+var Unparsed func(user string, d Drift)
+
+// Drift counts what a timeline page had that the parser could not use: entries
+// of an unknown type, media without the fields xdl reads, and videos whose
+// variants have an unknown content type.
+type Drift struct {
+	Entries int
+	Fields  int
+	Videos  int
+	First   string
+}
+
+func (d Drift) Total() int { return d.Entries + d.Fields + d.Videos }
+
+func (d *Drift) Add(o Drift) {
+	if d.First == "" {
+		d.First = o.First
+	}
+	d.Entries += o.Entries
+	d.Fields += o.Fields
+	d.Videos += o.Videos
+}
+
+var knownItemTypes = map[string]bool{
+	"TimelineTimelineCursor": true,
+	"TimelineUser":           true,
+	"TimelineLabel":          true,
+	"TimelineMessagePrompt":  true,
+	"TimelinePrompt":         true,
+	"TimelineTombstone":      true,
+}
+
+var knownVideoTypes = map[string]bool{
+	"video/mp4":             true,
+	"application/x-mpegurl": true,
+}
+
+// checkShape looks at every entry and media object of a timeline page and
+// either reports the drift or, with StrictParse, turns it into an error.
+func checkShape(op, user string, pg int, b []byte) error {
+	d := pageDrift(mustJSON(b))
+	if d.Total() == 0 {
+		return nil
+	}
+	log.LogWarn("media", fmt.Sprintf("%s page %d for @%s: unknown entries=%d missing media fields=%d unknown video types=%d, first: %s",
+		op, pg, user, d.Entries, d.Fields, d.Videos, d.First))
+	if StrictParse {
+		return &errs.APIError{Op: op, Message: fmt.Sprintf("page %d: %s", pg, d.First), Kind: errs.ErrShapeChanged}
+	}
+	if Unparsed != nil {
+		Unparsed(user, d)
+	}
+	return nil
+}
+
+func pageDrift(v any) Drift {
+	var d Drift
+	note := func(n *int, s string) {
+		*n++
+		if d.First == "" {
+			d.First = s
+		}
+	}
+	for _, s := range entryIssues(v) {
+		note(&d.Entries, s)
+	}
+	for _, m := range mediaObjects(v) {
+		if s := mediaIssue(m); s != "" {
+			note(&d.Fields, s)
+		}
+		if s := videoIssue(m); s != "" {
+			note(&d.Videos, s)
+		}
+	}
+	return d
+}
+
+func entryIssues(v any) []string {
+	var out []string
+	var walk func(v any)
+	walk = func(v any) {
+		switch t := v.(type) {
+		case map[string]any:
+			for k, c := range t {
+				es, ok := c.([]any)
+				if !ok || (k != "entries" && k != "moduleItems") {
+					walk(c)
+					continue
+				}
+				for _, e := range es {
+					if s := entryIssue(e); s != "" {
+						out = append(out, s)
+					}
+				}
+			}
+		case []any:
+			for _, c := range t {
+				walk(c)
+			}
+		}
+	}
+	walk(v)
+	return out
+}
+
+func entryIssue(v any) string {
+	e, ok := v.(map[string]any)
+	if !ok {
+		return "entry is not an object"
+	}
+	id := str(e["entryId"])
+	if it, ok := e["item"].(map[string]any); ok {
+		return withEntry(id, itemIssue(it["itemContent"]))
+	}
+	c, ok := e["content"].(map[string]any)
+	if !ok {
+		return withEntry(id, "no content")
+	}
+	switch t := typeOf(c, "entryType"); t {
+	case "TimelineTimelineCursor":
+		return ""
+	case "TimelineTimelineItem":
+		return withEntry(id, itemIssue(c["itemContent"]))
+	case "TimelineTimelineModule":
+		is, _ := c["items"].([]any)
+		for _, x := range is {
+			m, _ := x.(map[string]any)
+			it, _ := m["item"].(map[string]any)
+			if s := itemIssue(it["itemContent"]); s != "" {
+				return withEntry(id, s)
+			}
+		}
+		return ""
+	default:
+		return withEntry(id, "unknown entry type "+quoteType(t))
+	}
+}
+
+func itemIssue(v any) string {
+	ic, ok := v.(map[string]any)
+	if !ok {
+		return "item without itemContent"
+	}
+	t := typeOf(ic, "itemType")
+	if t != "TimelineTweet" {
+		if knownItemTypes[t] {
+			return ""
+		}
+		return "unknown item type " + quoteType(t)
+	}
+	tr, _ := ic["tweet_results"].(map[string]any)
+	r, ok := tr["result"].(map[string]any)
+	if !ok {
+		return ""
+	}
+	switch rt := str(r["__typename"]); rt {
+	case "TweetTombstone", "TweetUnavailable":
+		return ""
+	case "TweetWithVisibilityResults":
+		tw, _ := r["tweet"].(map[string]any)
+		if _, ok := tw["legacy"].(map[string]any); !ok {
+			return "tweet without legacy"
+		}
+		return ""
+	case "Tweet", "":
+		if _, ok := r["legacy"].(map[string]any); !ok {
+			return "tweet without legacy"
+		}
+		return ""
+	default:
+		return "unknown tweet result " + quoteType(rt)
+	}
+}
+
+func typeOf(m map[string]any, key string) string {
+	if t := str(m[key]); t != "" {
+		return t
+	}
+	return str(m["__typename"])
+}
+
+func quoteType(t string) string {
+	if t == "" {
+		return "(none)"
+	}
+	return fmt.Sprintf("%q", t)
+}
+
+func withEntry(id, issue string) string {
+	if issue == "" || id == "" {
+		return issue
+	}
+	return id + ": " + issue
+}
+
+// mediaObjects returns the items of every extended_entities.media list, once
+// per media key, since quoted and retweeted tweets repeat them.
+func mediaObjects(v any) []map[string]any {
+	var out []map[string]any
+	seen := map[string]bool{}
+	var walk func(v any)
+	walk = func(v any) {
+		switch t := v.(type) {
+		case map[string]any:
+			if ee, ok := t["extended_entities"].(map[string]any); ok {
+				ms, _ := ee["media"].([]any)
+				for _, x := range ms {
+					m, ok := x.(map[string]any)
+					if !ok {
+						continue
+					}
+					k := str(m["media_key"])
+					if k == "" {
+						k = str(m["id_str"])
+					}
+					if k != "" && seen[k] {
+						continue
+					}
+					seen[k] = true
+					out = append(out, m)
+				}
+			}
+			for _, c := range t {
+				walk(c)
+			}
+		case []any:
+			for _, c := range t {
+				walk(c)
+			}
+		}
+	}
+	walk(v)
+	return out
+}
+
+func mediaIssue(m map[string]any) string {
+	id := str(m["media_key"])
+	if str(m["media_url_https"]) == "" {
+		return withEntry(id, "media without media_url_https")
+	}
+	switch t := str(m["type"]); t {
+	case "photo":
+		return ""
+	case "video", "animated_gif":
+		vi, _ := m["video_info"].(map[string]any)
+		if vs, _ := vi["variants"].([]any); len(vs) == 0 {
+			return withEntry(id, t+" without video_info.variants")
+		}
+		return ""
+	default:
+		return withEntry(id, "unknown media type "+quoteType(t))
+	}
+}
+
+func videoIssue(m map[string]any) string {
+	vi, _ := m["video_info"].(map[string]any)
+	vs, _ := vi["variants"].([]any)
+	for _, x := range vs {
+		v, _ := x.(map[string]any)
+		ct := strings.ToLower(str(v["content_type"]))
+		if !knownVideoTypes[ct] {
+			return withEntry(str(m["media_key"]), "unknown video content type "+quoteType(ct))
+		}
+	}
+	return ""
+}
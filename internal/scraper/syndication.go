@@ -0,0 +1,172 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ghostlawless/xdl/internal/errs"
+	"github.com/ghostlawless/xdl/internal/httpx"
+	"github.com/ghostlawless/xdl/internal/log"
+)
+
+const (
+	syndicationTimelineURL = "https://syndication.twitter.com/srv/timeline-profile/screen-name/"
+	syndicationTweetURL    = "https://cdn.syndication.twimg.com/tweet-result"
+)
+
+var reNextData = regexp.MustCompile(`(?s)<script id="__NEXT_DATA__" type="application/json">(.*?)</script>`)
+
+func WalkSyndicationTimeline(cl *http.Client, sn string, handler PageHandler) error {
+	if cl == nil {
+		return errors.New("nil client")
+	}
+	if sn == "" {
+		return errors.New("empty username")
+	}
+	waitWhilePaused()
+	b, err := syndicationGet(cl, "SyndicationTimeline", syndicationTimelineURL+url.PathEscape(sn), sn)
+	if err != nil {
+		return err
+	}
+	m := reNextData.FindSubmatch(b)
+	if m == nil {
+		return &errs.APIError{Op: "SyndicationTimeline", Message: "no timeline data in page", Kind: errs.ErrShapeChanged}
+	}
+	var root any
+	if err := json.Unmarshal(m[1], &root); err != nil {
+		return &errs.APIError{Op: "SyndicationTimeline", Message: err.Error(), Kind: errs.ErrShapeChanged}
+	}
+	out := make([]Media, 0, 64)
+	collectSyndication(root, "", &out, map[string]struct{}{})
+	tw := map[string]tweetInfo{}
+	collectTweets(root, tw)
+	withTweets(out, tw)
+	log.LogInfo("syndication", fmt.Sprintf("@%s: %d media from the public timeline", sn, len(out)))
+	if ScanProgress != nil {
+		ev := ScanEvent{User: sn, Pages: 1, Media: len(out)}
+		for _, x := range out {
+			if x.Type == "video" {
+				ev.Videos++
+			} else {
+				ev.Images++
+			}
+		}
+		ScanProgress(ev)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return handler(1, "", out)
+}
+
+func FetchSyndicationTweet(cl *http.Client, id string) ([]Media, error) {
+	if cl == nil {
+		return nil, errors.New("nil client")
+	}
+	waitWhilePaused()
+	q := url.Values{"id": {id}, "lang": {"en"}, "token": {syndicationToken(id)}}
+	b, err := syndicationGet(cl, "SyndicationTweet", syndicationTweetURL+"?"+q.Encode(), id)
+	if err != nil {
+		return nil, err
+	}
+	var root any
+	if err := json.Unmarshal(b, &root); err != nil {
+		return nil, &errs.APIError{Op: "SyndicationTweet", Message: err.Error(), Kind: errs.ErrShapeChanged}
+	}
+	out := make([]Media, 0, 4)
+	collectSyndication(root, id, &out, map[string]struct{}{})
+	tw := map[string]tweetInfo{}
+	collectTweets(root, tw)
+	withTweets(out, tw)
+	return out, nil
+}
+
+func syndicationGet(cl *http.Client, op, u, tag string) ([]byte, error) {
+	rq, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	var h http.Header
+	b, st, err := httpx.DoRequestWithOptions(cl, rq, httpx.RequestOptions{MaxBytes: 8 << 20, Decode: true, Header: &h})
+	switch {
+	case err == nil:
+		return b, nil
+	case st == http.StatusNotFound:
+		return nil, &errs.APIError{Op: op, Status: st, Message: tag + " not found", Kind: errs.ErrUserNotFound}
+	}
+	if rl := rateLimitError(op, st, b, err, h); rl != nil {
+		return nil, rl
+	}
+	return nil, &errs.APIError{Op: op, Status: st, Message: err.Error()}
+}
+
+func collectSyndication(v any, id string, out *[]Media, seen map[string]struct{}) {
+	switch t := v.(type) {
+	case map[string]any:
+		if s, ok := t["id_str"].(string); ok && s != "" && t["media_url_https"] == nil {
+			id = s
+		}
+		if md, ok := t["mediaDetails"]; ok {
+			collectMedia(md, id, out, seen)
+		} else if ee, ok := t["extended_entities"]; ok {
+			collectMedia(ee, id, out, seen)
+		}
+		for k, c := range t {
+			switch k {
+			case "mediaDetails", "extended_entities", "entities", "photos", "video":
+				continue
+			}
+			collectSyndication(c, id, out, seen)
+		}
+	case []any:
+		for _, c := range t {
+			collectSyndication(c, id, out, seen)
+		}
+	}
+}
+
+func syndicationToken(id string) string {
+	n, err := strconv.ParseFloat(id, 64)
+	if err != nil {
+		return "a"
+	}
+	x := n / 1e15 * math.Pi
+	ip, fp := math.Modf(x)
+	delta := max(0.5*(math.Nextafter(x, math.Inf(1))-x), math.SmallestNonzeroFloat64)
+	var ds []int
+	for fp >= delta {
+		fp, delta = fp*36, delta*36
+		d := int(fp)
+		ds = append(ds, d)
+		fp -= float64(d)
+		if (fp > 0.5 || (fp == 0.5 && d&1 == 1)) && fp+delta > 1 {
+			for {
+				if len(ds) == 0 {
+					ip++
+					break
+				}
+				last := len(ds) - 1
+				if ds[last]+1 < 36 {
+					ds[last]++
+					break
+				}
+				ds = ds[:last]
+			}
+			break
+		}
+	}
+	const digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+	var b strings.Builder
+	b.WriteString(strconv.FormatInt(int64(ip), 36))
+	for _, d := range ds {
+		b.WriteByte(digits[d])
+	}
+	return strings.ReplaceAll(b.String(), "0", "")
+}
@@ -0,0 +1,85 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+type TakeoutAccount struct {
+	ID       string
+	Username string
+}
+
+type takeoutTweet struct {
+	Tweet json.RawMessage `json:"tweet"`
+}
+
+type takeoutFields struct {
+	IDStr            string         `json:"id_str"`
+	FullText         string         `json:"full_text"`
+	ExtendedEntities map[string]any `json:"extended_entities"`
+}
+
+func ParseTakeoutTweets(b []byte) ([]Media, error) {
+	b, err := takeoutPayload(b)
+	if err != nil {
+		return nil, err
+	}
+	var rows []takeoutTweet
+	if err := json.Unmarshal(b, &rows); err != nil {
+		return nil, err
+	}
+	out := make([]Media, 0, len(rows))
+	seen := make(map[string]struct{}, len(rows))
+	for _, r := range rows {
+		raw := r.Tweet
+		if len(raw) == 0 {
+			continue
+		}
+		var t takeoutFields
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return nil, err
+		}
+		if t.IDStr == "" || t.ExtendedEntities == nil || strings.HasPrefix(t.FullText, "RT @") {
+			continue
+		}
+		n := len(out)
+		collectMedia(t.ExtendedEntities, t.IDStr, &out, seen)
+		withTweets(out[n:], map[string]tweetInfo{t.IDStr: {Text: t.FullText}})
+	}
+	return out, nil
+}
+
+func ParseTakeoutAccount(b []byte) (TakeoutAccount, error) {
+	b, err := takeoutPayload(b)
+	if err != nil {
+		return TakeoutAccount{}, err
+	}
+	var rows []struct {
+		Account struct {
+			AccountID string `json:"accountId"`
+			Username  string `json:"username"`
+		} `json:"account"`
+	}
+	if err := json.Unmarshal(b, &rows); err != nil {
+		return TakeoutAccount{}, err
+	}
+	if len(rows) == 0 || rows[0].Account.Username == "" {
+		return TakeoutAccount{}, errors.New("no account in export")
+	}
+	return TakeoutAccount{ID: rows[0].Account.AccountID, Username: rows[0].Account.Username}, nil
+}
+
+func takeoutPayload(b []byte) ([]byte, error) {
+	b = bytes.TrimSpace(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf")))
+	if len(b) > 0 && b[0] == '[' {
+		return b, nil
+	}
+	i := bytes.IndexByte(b, '=')
+	if !bytes.HasPrefix(b, []byte("window.YTD.")) || i < 0 {
+		return nil, errors.New("not an X data export file")
+	}
+	return bytes.TrimSuffix(bytes.TrimSpace(b[i+1:]), []byte(";")), nil
+}
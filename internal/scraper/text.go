@@ -0,0 +1,177 @@
+package scraper
+
+import "strings"
+
+type Geo struct {
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	Exact   bool    `json:"exact,omitempty"`
+	Place   string  `json:"place,omitempty"`
+	Country string  `json:"country,omitempty"`
+}
+
+type tweetInfo struct {
+	Text     string
+	Geo      *Geo
+	Poll     *Poll
+	Author   string
+	AuthorID string
+}
+
+func collectTweets(v any, out map[string]tweetInfo) {
+	switch t := v.(type) {
+	case map[string]any:
+		if id, ok := t["rest_id"].(string); ok && id != "" {
+			lg, _ := t["legacy"].(map[string]any)
+			ti := tweetInfo{Text: tweetText(t), Geo: tweetGeo(lg, t)}
+			if IncludeCards {
+				ti.Poll = tweetPoll(t)
+			}
+			if c, ok := t["core"].(map[string]any); ok {
+				if ur, ok := c["user_results"].(map[string]any); ok {
+					u, _ := ur["result"].(map[string]any)
+					ti.Author, ti.AuthorID = userHandle(u)
+				}
+			}
+			if ti.Text != "" || ti.Geo != nil || ti.Poll != nil || ti.Author != "" {
+				out[id] = ti
+			}
+		} else if id, ok := t["id_str"].(string); ok && id != "" && t["media_url_https"] == nil {
+			ti := tweetInfo{Geo: tweetGeo(t)}
+			if IncludeCards {
+				ti.Poll = tweetPoll(t)
+			}
+			for _, k := range []string{"full_text", "text"} {
+				if s, ok := t[k].(string); ok && s != "" {
+					ti.Text = s
+					break
+				}
+			}
+			if u, ok := t["user"].(map[string]any); ok {
+				ti.Author, _ = u["screen_name"].(string)
+				ti.AuthorID, _ = u["id_str"].(string)
+			}
+			if ti.Text != "" || ti.Geo != nil || ti.Poll != nil || ti.Author != "" {
+				out[id] = ti
+			}
+		}
+		for _, c := range t {
+			collectTweets(c, out)
+		}
+	case []any:
+		for _, c := range t {
+			collectTweets(c, out)
+		}
+	}
+}
+
+func userHandle(u map[string]any) (string, string) {
+	if u == nil {
+		return "", ""
+	}
+	id, _ := u["rest_id"].(string)
+	for _, k := range []string{"core", "legacy"} {
+		if m, ok := u[k].(map[string]any); ok {
+			if s, ok := m["screen_name"].(string); ok && s != "" {
+				return s, id
+			}
+		}
+	}
+	return "", id
+}
+
+func tweetText(t map[string]any) string {
+	if nt, ok := t["note_tweet"].(map[string]any); ok {
+		if r, ok := nt["note_tweet_results"].(map[string]any); ok {
+			if res, ok := r["result"].(map[string]any); ok {
+				if s, ok := res["text"].(string); ok && s != "" {
+					return s
+				}
+			}
+		}
+	}
+	if lg, ok := t["legacy"].(map[string]any); ok {
+		if s, ok := lg["full_text"].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+func tweetGeo(ms ...map[string]any) *Geo {
+	var g Geo
+	for _, m := range ms {
+		if m == nil {
+			continue
+		}
+		if c, ok := m["coordinates"].(map[string]any); ok && !g.Exact {
+			if xy, ok := c["coordinates"].([]any); ok && len(xy) == 2 {
+				lon, ok1 := xy[0].(float64)
+				lat, ok2 := xy[1].(float64)
+				if ok1 && ok2 {
+					g.Lat, g.Lon, g.Exact = lat, lon, true
+				}
+			}
+		}
+		if p, ok := m["place"].(map[string]any); ok && g.Place == "" {
+			g.Place, _ = p["full_name"].(string)
+			if g.Place == "" {
+				g.Place, _ = p["name"].(string)
+			}
+			g.Country, _ = p["country"].(string)
+			if !g.Exact {
+				g.Lat, g.Lon = bboxCenter(p["bounding_box"])
+			}
+		}
+	}
+	if !g.Exact && g.Place == "" {
+		return nil
+	}
+	return &g
+}
+
+func bboxCenter(v any) (lat, lon float64) {
+	bb, _ := v.(map[string]any)
+	rings, _ := bb["coordinates"].([]any)
+	if len(rings) == 0 {
+		return 0, 0
+	}
+	pts, _ := rings[0].([]any)
+	n := 0
+	for _, p := range pts {
+		xy, _ := p.([]any)
+		if len(xy) != 2 {
+			continue
+		}
+		x, ok1 := xy[0].(float64)
+		y, ok2 := xy[1].(float64)
+		if ok1 && ok2 {
+			lon, lat, n = lon+x, lat+y, n+1
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return lat / float64(n), lon / float64(n)
+}
+
+func withTweets(ms []Media, info map[string]tweetInfo) {
+	for i := range ms {
+		ti, ok := info[ms[i].TweetID]
+		if !ok || ms[i].TweetID == "" {
+			continue
+		}
+		if ms[i].Text == "" {
+			ms[i].Text = strings.TrimSpace(ti.Text)
+		}
+		if ms[i].Geo == nil {
+			ms[i].Geo = ti.Geo
+		}
+		if ms[i].Poll == nil {
+			ms[i].Poll = ti.Poll
+		}
+		if ms[i].Author == "" {
+			ms[i].Author, ms[i].AuthorID = ti.Author, ti.AuthorID
+		}
+	}
+}
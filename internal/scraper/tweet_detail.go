@@ -13,6 +13,7 @@ import (
 
 	"github.com/ghostlawless/xdl/internal/config"
 	"github.com/ghostlawless/xdl/internal/log"
+	"github.com/ghostlawless/xdl/internal/mediaurl"
 	xruntime "github.com/ghostlawless/xdl/internal/runtime"
 )
 
@@ -36,6 +37,7 @@ type tweetDetailResponse struct {
 }
 
 type tweetLegacy struct {
+	FullText string `json:"full_text"`
 	Entities struct {
 		Media []legacyMedia `json:"media"`
 	} `json:"entities"`
@@ -57,6 +59,7 @@ type legacyMedia struct {
 	IDStr         string `json:"id_str"`
 	MediaURLHTTPS string `json:"media_url_https"`
 	Type          string `json:"type"`
+	ExtAltText    string `json:"ext_alt_text"`
 	VideoInfo     struct {
 		Variants []struct {
 			URL         string `json:"url"`
@@ -123,6 +126,7 @@ func GetHighQualityMediaForTweet(
 	}
 	u.RawQuery = q.Encode()
 
+	waitWhilePaused()
 	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("build TweetDetail request: %w", err)
@@ -211,7 +215,7 @@ func extractBestMediaFromTweet(tr *tweetResult) []Media {
 		for _, m := range ms {
 			switch m.Type {
 			case "photo":
-				u := upgradePhotoURL(m.MediaURLHTTPS)
+				u := mediaurl.Photo(m.MediaURLHTTPS)
 				if u == "" {
 					continue
 				}
@@ -220,8 +224,9 @@ func extractBestMediaFromTweet(tr *tweetResult) []Media {
 				}
 				seen[u] = struct{}{}
 				out = append(out, Media{
-					URL:  u,
-					Type: "image",
+					URL:     u,
+					Type:    "image",
+					AltText: strings.TrimSpace(m.ExtAltText),
 				})
 			case "video", "animated_gif":
 				u := bestVideoVariantURL(m.VideoInfo.Variants)
@@ -233,8 +238,10 @@ func extractBestMediaFromTweet(tr *tweetResult) []Media {
 				}
 				seen[u] = struct{}{}
 				out = append(out, Media{
-					URL:  u,
-					Type: "video",
+					URL:     u,
+					Type:    "video",
+					Poster:  m.MediaURLHTTPS,
+					AltText: strings.TrimSpace(m.ExtAltText),
 				})
 			default:
 				continue
@@ -244,27 +251,13 @@ func extractBestMediaFromTweet(tr *tweetResult) []Media {
 
 	merge(legacy.ExtendedEntities.Media)
 	merge(legacy.Entities.Media)
+	for i := range out {
+		out[i].Text = strings.TrimSpace(legacy.FullText)
+	}
 
 	return out
 }
 
-func upgradePhotoURL(raw string) string {
-	if raw == "" {
-		return ""
-	}
-	u, err := url.Parse(raw)
-	if err != nil {
-		return raw
-	}
-	q := u.Query()
-	if _, ok := q["name"]; ok {
-		q.Set("name", "orig")
-		u.RawQuery = q.Encode()
-		return u.String()
-	}
-	return raw
-}
-
 func bestVideoVariantURL(vs []struct {
 	URL         string `json:"url"`
 	Bitrate     *int   `json:"bitrate,omitempty"`
@@ -0,0 +1,128 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/errs"
+	"github.com/ghostlawless/xdl/internal/log"
+)
+
+const TweetBatchSize = 50
+
+type TweetState string
+
+const (
+	TweetLive        TweetState = "live"
+	TweetDeleted     TweetState = "deleted"
+	TweetProtected   TweetState = "protected"
+	TweetUnavailable TweetState = "unavailable"
+)
+
+type tweetResultsResponse struct {
+	Data struct {
+		TweetResult []struct {
+			Result *struct {
+				Typename string `json:"__typename"`
+				RestID   string `json:"rest_id"`
+				Reason   string `json:"reason"`
+				Tweet    *struct {
+					RestID string `json:"rest_id"`
+				} `json:"tweet"`
+			} `json:"result"`
+		} `json:"tweetResult"`
+	} `json:"data"`
+}
+
+func FetchTweetsByIDs(cl *http.Client, cf *config.EssentialsConfig, ids []string) ([]Media, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	b, st, err := queryTweetResults(cl, cf, ids)
+	if err != nil {
+		return nil, err
+	}
+	ms, err := fold(b)
+	if err != nil {
+		return nil, &errs.APIError{Op: "TweetResultsByRestIds", Status: st, Message: err.Error(), Kind: errs.ErrShapeChanged}
+	}
+	log.LogInfo("tweets", fmt.Sprintf("hydrated %d ids: %d media", len(ids), len(ms)))
+	return ms, nil
+}
+
+func FetchTweetStates(cl *http.Client, cf *config.EssentialsConfig, ids []string) (map[string]TweetState, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	b, st, err := queryTweetResults(cl, cf, ids)
+	if err != nil {
+		return nil, err
+	}
+	var r tweetResultsResponse
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, &errs.APIError{Op: "TweetResultsByRestIds", Status: st, Message: err.Error(), Kind: errs.ErrShapeChanged}
+	}
+	out := make(map[string]TweetState, len(ids))
+	for i, e := range r.Data.TweetResult {
+		if e.Result == nil {
+			if len(r.Data.TweetResult) == len(ids) {
+				out[ids[i]] = TweetDeleted
+			}
+			continue
+		}
+		id := e.Result.RestID
+		if id == "" && e.Result.Tweet != nil {
+			id = e.Result.Tweet.RestID
+		}
+		if id == "" && len(r.Data.TweetResult) == len(ids) {
+			id = ids[i]
+		}
+		if id == "" {
+			continue
+		}
+		out[id] = tweetState(e.Result.Typename, e.Result.Reason)
+	}
+	for _, id := range ids {
+		if _, ok := out[id]; !ok {
+			out[id] = TweetDeleted
+		}
+	}
+	log.LogInfo("tweets", fmt.Sprintf("checked %d ids", len(ids)))
+	return out, nil
+}
+
+func tweetState(typename, reason string) TweetState {
+	switch typename {
+	case "Tweet", "TweetWithVisibilityResults":
+		return TweetLive
+	case "TweetTombstone":
+		return TweetDeleted
+	}
+	if strings.EqualFold(reason, "Protected") {
+		return TweetProtected
+	}
+	return TweetUnavailable
+}
+
+func queryTweetResults(cl *http.Client, cf *config.EssentialsConfig, ids []string) ([]byte, int, error) {
+	if cl == nil || cf == nil {
+		return nil, 0, errors.New("nil client or config")
+	}
+	vars := map[string]any{
+		"tweetIds":               ids,
+		"includePromotedContent": false,
+		"withBirdwatchNotes":     false,
+		"withVoice":              true,
+		"withCommunity":          true,
+	}
+	ref := strings.TrimRight(cf.X.Network, "/") + "/"
+	tag := ids[0]
+	if len(ids) > 1 {
+		tag += fmt.Sprintf("+%d", len(ids)-1)
+	}
+	return queryGraphQL(cl, cf, "", "tweet_results_by_rest_ids", "TweetResultsByRestIds", vars, ref, tag)
+}
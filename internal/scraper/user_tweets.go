@@ -38,7 +38,30 @@ func BuildUserTweetsParams(userID string, count int) (url.Values, error) {
 		return nil, fmt.Errorf("encode variables: %w", err)
 	}
 
-	features := map[string]bool{
+	featuresJSON, err := config.PatchedFeatureJSON("user_tweets", userTweetsFeatures())
+	if err != nil {
+		return nil, fmt.Errorf("encode features: %w", err)
+	}
+
+	fieldToggles := map[string]bool{
+		"withArticlePlainText": false,
+	}
+
+	fieldTogglesJSON, err := json.Marshal(fieldToggles)
+	if err != nil {
+		return nil, fmt.Errorf("encode fieldToggles: %w", err)
+	}
+
+	params := url.Values{}
+	params.Set("variables", string(varsJSON))
+	params.Set("features", string(featuresJSON))
+	params.Set("fieldToggles", string(fieldTogglesJSON))
+
+	return params, nil
+}
+
+func userTweetsFeatures() map[string]bool {
+	return map[string]bool{
 		"rweb_video_screen_enabled":                                               false,
 		"profile_label_improvements_pcf_label_in_post_enabled":                    true,
 		"responsive_web_profile_redirect_enabled":                                 false,
@@ -74,27 +97,6 @@ func BuildUserTweetsParams(userID string, count int) (url.Values, error) {
 		"responsive_web_grok_community_note_auto_translation_is_enabled":          false,
 		"responsive_web_enhance_cards_enabled":                                    false,
 	}
-
-	featuresJSON, err := json.Marshal(features)
-	if err != nil {
-		return nil, fmt.Errorf("encode features: %w", err)
-	}
-
-	fieldToggles := map[string]bool{
-		"withArticlePlainText": false,
-	}
-
-	fieldTogglesJSON, err := json.Marshal(fieldToggles)
-	if err != nil {
-		return nil, fmt.Errorf("encode fieldToggles: %w", err)
-	}
-
-	params := url.Values{}
-	params.Set("variables", string(varsJSON))
-	params.Set("features", string(featuresJSON))
-	params.Set("fieldToggles", string(fieldTogglesJSON))
-
-	return params, nil
 }
 
 func FetchUserTweetsPage(
@@ -116,6 +118,9 @@ func FetchUserTweetsPage(
 		return nil, err
 	}
 
+	if count <= 0 {
+		count = conf.Runtime.PageSize
+	}
 	params, err := BuildUserTweetsParams(userID, count)
 	if err != nil {
 		return nil, err
@@ -134,6 +139,14 @@ func FetchUserTweetsPage(
 	if err != nil {
 		return nil, err
 	}
+	for i := 0; i < maxFeatureRetries && negotiateFeatures("user_tweets", resp.StatusCode, resp.Body); i++ {
+		if opt.Params, err = BuildUserTweetsParams(userID, count); err != nil {
+			return nil, err
+		}
+		if resp, err = httpx.DoRequest(ctx, client, opt); err != nil {
+			return nil, err
+		}
+	}
 
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("UserTweets HTTP %d: %s", resp.StatusCode, resp.Status)
@@ -0,0 +1,271 @@
+package trace
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghostlawless/xdl/internal/log"
+)
+
+const (
+	maxBatch      = 256
+	flushInterval = 5 * time.Second
+	statusError   = 2
+)
+
+type Span struct {
+	traceID string
+	spanID  string
+	parent  string
+	name    string
+	start   time.Time
+
+	mu    sync.Mutex
+	attrs []attr
+	err   string
+	end   time.Time
+}
+
+type attr struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+type exporter struct {
+	url     string
+	service string
+	client  *http.Client
+
+	mu      sync.Mutex
+	pending []*Span
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+var (
+	mu   sync.RWMutex
+	exp  *exporter
+	root *Span
+)
+
+func Init(endpoint, service string) error {
+	u := strings.TrimSpace(endpoint)
+	if u == "" {
+		return nil
+	}
+	if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		return fmt.Errorf("OTLP endpoint must start with http:// or https://: %q", endpoint)
+	}
+	if !strings.HasSuffix(u, "/v1/traces") {
+		u = strings.TrimRight(u, "/") + "/v1/traces"
+	}
+	e := &exporter{
+		url:     u,
+		service: service,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	mu.Lock()
+	exp = e
+	root = newSpan("run", nil)
+	mu.Unlock()
+	go e.loop()
+	return nil
+}
+
+func Shutdown() {
+	mu.Lock()
+	e, r := exp, root
+	exp, root = nil, nil
+	mu.Unlock()
+	if e == nil {
+		return
+	}
+	r.endTo(e)
+	close(e.stop)
+	<-e.done
+}
+
+func Start(name string, parent *Span) *Span {
+	mu.RLock()
+	defer mu.RUnlock()
+	if exp == nil {
+		return nil
+	}
+	if parent == nil {
+		parent = root
+	}
+	return newSpan(name, parent)
+}
+
+func newSpan(name string, parent *Span) *Span {
+	s := &Span{name: name, spanID: randHex(8), start: time.Now()}
+	if parent != nil {
+		s.traceID, s.parent = parent.traceID, parent.spanID
+	} else {
+		s.traceID = randHex(16)
+	}
+	return s
+}
+
+func (s *Span) Set(key string, v any) {
+	if s == nil {
+		return
+	}
+	var val map[string]any
+	switch t := v.(type) {
+	case string:
+		val = map[string]any{"stringValue": t}
+	case bool:
+		val = map[string]any{"boolValue": t}
+	case int:
+		val = map[string]any{"intValue": strconv.Itoa(t)}
+	case int64:
+		val = map[string]any{"intValue": strconv.FormatInt(t, 10)}
+	case float64:
+		val = map[string]any{"doubleValue": t}
+	default:
+		val = map[string]any{"stringValue": fmt.Sprint(t)}
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attr{Key: key, Value: val})
+	s.mu.Unlock()
+}
+
+func (s *Span) Fail(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err.Error()
+	s.mu.Unlock()
+}
+
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	mu.RLock()
+	e := exp
+	mu.RUnlock()
+	s.endTo(e)
+}
+
+func (s *Span) endTo(e *exporter) {
+	if s == nil || e == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mu.Unlock()
+	e.add(s)
+}
+
+func (e *exporter) add(s *Span) {
+	e.mu.Lock()
+	e.pending = append(e.pending, s)
+	full := len(e.pending) >= maxBatch
+	e.mu.Unlock()
+	if full {
+		go e.flush()
+	}
+}
+
+func (e *exporter) loop() {
+	defer close(e.done)
+	t := time.NewTicker(flushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			e.flush()
+		case <-e.stop:
+			e.flush()
+			return
+		}
+	}
+}
+
+func (e *exporter) flush() {
+	e.mu.Lock()
+	batch := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+	b, err := json.Marshal(e.payload(batch))
+	if err != nil {
+		return
+	}
+	rq, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(b))
+	if err != nil {
+		return
+	}
+	rq.Header.Set("Content-Type", "application/json")
+	res, err := e.client.Do(rq)
+	if err != nil {
+		log.LogError("trace", "export failed: "+err.Error())
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode >= 300 {
+		log.LogError("trace", fmt.Sprintf("export failed: status %d", res.StatusCode))
+	}
+}
+
+func (e *exporter) payload(batch []*Span) map[string]any {
+	spans := make([]map[string]any, 0, len(batch))
+	for _, s := range batch {
+		s.mu.Lock()
+		sp := map[string]any{
+			"traceId":           s.traceID,
+			"spanId":            s.spanID,
+			"name":              s.name,
+			"kind":              1,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if len(s.attrs) > 0 {
+			sp["attributes"] = s.attrs
+		}
+		if s.parent != "" {
+			sp["parentSpanId"] = s.parent
+		}
+		if s.err != "" {
+			sp["status"] = map[string]any{"code": statusError, "message": s.err}
+		}
+		s.mu.Unlock()
+		spans = append(spans, sp)
+	}
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": []attr{
+				{Key: "service.name", Value: map[string]any{"stringValue": e.service}},
+			}},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "xdl"},
+				"spans": spans,
+			}},
+		}},
+	}
+}
+
+func randHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
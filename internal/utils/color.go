@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	xlog "github.com/ghostlawless/xdl/internal/log"
+)
+
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+var (
+	colorMu   sync.RWMutex
+	colorMode = ColorAuto
+)
+
+// SetColor picks when the xdl> and xdl! prefixes are colored. auto colors
+// them only on a terminal and only if NO_COLOR is unset.
+func SetColor(mode string) error {
+	switch mode {
+	case ColorAuto, ColorAlways, ColorNever:
+	default:
+		return fmt.Errorf("invalid color mode %q (use auto, always or never)", mode)
+	}
+	colorMu.Lock()
+	colorMode = mode
+	colorMu.Unlock()
+	xlog.SetConsoleColor(colorFor(os.Stderr))
+	return nil
+}
+
+func colorFor(f *os.File) bool {
+	colorMu.RLock()
+	m := colorMode
+	colorMu.RUnlock()
+	switch m {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	return os.Getenv("NO_COLOR") == "" && IsTerminal(f) && ANSIEnabled()
+}
+
+func paint(w *pipeWriter, code, s string) string {
+	if !colorFor(w.f) {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
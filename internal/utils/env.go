@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Getenv returns $name, or the contents of the file named by $name_FILE so
+// secrets can come from Docker or Kubernetes secret mounts.
+func Getenv(name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	f, okf := os.LookupEnv(name + "_FILE")
+	switch {
+	case ok && okf:
+		return "", fmt.Errorf("%s and %s_FILE are both set", name, name)
+	case okf:
+		b, err := os.ReadFile(strings.TrimSpace(f))
+		if err != nil {
+			return "", fmt.Errorf("%s_FILE: %w", name, err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	return strings.TrimSpace(v), nil
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -48,11 +49,28 @@ func SanitizeFilename(name string) string {
 		return "file"
 	}
 	name = filenameReplacer.Replace(filepath.Base(name))
-	name = strings.TrimSpace(name)
+	name = strings.TrimRight(strings.TrimSpace(name), ". ")
 	if name == "" {
 		return "file"
 	}
-	return strings.TrimRight(name, ". ")
+	if reservedName(name) {
+		name = "_" + name
+	}
+	return name
+}
+
+// reservedName reports Windows device names, which cannot be used as a file
+// name even with an extension (con.txt).
+func reservedName(name string) bool {
+	stem := strings.ToUpper(name)
+	if i := strings.IndexByte(stem, '.'); i >= 0 {
+		stem = stem[:i]
+	}
+	switch stem {
+	case "CON", "PRN", "AUX", "NUL":
+		return true
+	}
+	return len(stem) == 4 && (strings.HasPrefix(stem, "COM") || strings.HasPrefix(stem, "LPT")) && stem[3] >= '1' && stem[3] <= '9'
 }
 
 func SaveToFile(path string, data []byte) error {
@@ -131,6 +149,45 @@ func SaveToFile(path string, data []byte) error {
 	return nil
 }
 
+// ReplaceFile moves tmp over dst. When the rename fails it copies instead;
+// if that fails too, dst is left as it was and tmp is kept.
+func ReplaceFile(tmp, dst string) error {
+	if err := os.Rename(tmp, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(tmp)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	part := out.Name()
+	if err := out.Chmod(0o644); err != nil {
+		out.Close()
+		_ = os.Remove(part)
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		_ = os.Remove(part)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(part)
+		return err
+	}
+	if err := os.Rename(part, dst); err != nil {
+		_ = os.Remove(part)
+		return err
+	}
+	in.Close()
+	_ = os.Remove(tmp)
+	return nil
+}
+
 func SaveText(path string, content string) error {
 	return SaveToFile(path, []byte(content))
 }
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplaceFile(t *testing.T) {
+	dir := t.TempDir()
+	tmp, dst := filepath.Join(dir, "new"), filepath.Join(dir, "dst")
+	if err := os.WriteFile(tmp, []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReplaceFile(tmp, dst); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(dst); string(b) != "new" {
+		t.Errorf("dst = %q", b)
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("tmp still there: %v", err)
+	}
+
+	// A failed replace keeps both the target and the new data.
+	busy := filepath.Join(dir, "busy")
+	if err := os.MkdirAll(filepath.Join(busy, "keep"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tmp, []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReplaceFile(tmp, busy); err == nil {
+		t.Fatal("replacing a directory succeeded")
+	}
+	if b, _ := os.ReadFile(tmp); string(b) != "new" {
+		t.Errorf("tmp = %q after a failed replace", b)
+	}
+	if _, err := os.Stat(filepath.Join(busy, "keep")); err != nil {
+		t.Errorf("target damaged: %v", err)
+	}
+	if m, _ := filepath.Glob(busy + ".tmp-*"); len(m) > 0 {
+		t.Errorf("left %v behind", m)
+	}
+}
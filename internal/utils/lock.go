@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var LockTimeout = 10 * time.Second
+
+var ErrLocked = errors.New("locked by another process")
+
+type FileLock struct {
+	f *os.File
+}
+
+func LockShared(path string) (*FileLock, error)    { return lockPath(path, false) }
+func LockExclusive(path string) (*FileLock, error) { return lockPath(path, true) }
+
+// LockOwner takes an exclusive lock on path+".lock", waiting up to wait, and
+// records this process in the file so a blocked caller can say who holds it.
+func LockOwner(path string, wait time.Duration) (*FileLock, error) {
+	lk, err := lockWait(path, true, wait)
+	if err != nil {
+		return nil, err
+	}
+	if err := lk.f.Truncate(0); err == nil {
+		_, _ = lk.f.WriteAt([]byte(fmt.Sprintf("pid %d since %s\n", os.Getpid(), time.Now().Format("2006-01-02 15:04:05"))), 0)
+	}
+	return lk, nil
+}
+
+// LockHolder describes the process recorded by LockOwner, or "" if unknown.
+func LockHolder(path string) string {
+	b, err := os.ReadFile(path + ".lock")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func lockPath(path string, exclusive bool) (*FileLock, error) {
+	return lockWait(path, exclusive, LockTimeout)
+}
+
+func lockWait(path string, exclusive bool, wait time.Duration) (*FileLock, error) {
+	lp := path + ".lock"
+	if exclusive {
+		if err := EnsureDir(filepath.Dir(lp)); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.OpenFile(lp, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	end := time.Now().Add(wait)
+	for {
+		ok, err := tryLock(f, exclusive)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("lock %s: %w", lp, err)
+		}
+		if ok {
+			return &FileLock{f: f}, nil
+		}
+		if time.Now().After(end) {
+			f.Close()
+			return nil, fmt.Errorf("lock %s: still held by another xdl after %s: %w", lp, wait, ErrLocked)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (l *FileLock) Unlock() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := unlock(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	return err
+}
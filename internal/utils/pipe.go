@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// pipeWriter swallows writes once its stream hits a broken pipe. Each stream
+// keeps its own state, so a closed stderr does not silence stdout.
+type pipeWriter struct {
+	f      *os.File
+	broken atomic.Bool
+
+	mu       sync.Mutex
+	onBroken func()
+}
+
+var (
+	Stdout = &pipeWriter{f: os.Stdout}
+	Stderr = &pipeWriter{f: os.Stderr}
+)
+
+func (w *pipeWriter) Write(p []byte) (int, error) {
+	if w.broken.Load() {
+		return len(p), nil
+	}
+	n, err := w.f.Write(p)
+	if err != nil && isBrokenPipe(err) {
+		if !w.broken.Swap(true) {
+			w.mu.Lock()
+			fn := w.onBroken
+			w.mu.Unlock()
+			if fn != nil {
+				fn()
+			}
+		}
+		return len(p), nil
+	}
+	return n, err
+}
+
+func (w *pipeWriter) Closed() bool { return w.broken.Load() }
+
+func StdoutClosed() bool { return Stdout.Closed() }
+
+func (w *pipeWriter) OnBroken(fn func()) {
+	w.mu.Lock()
+	w.onBroken = fn
+	w.mu.Unlock()
+}
+
+// OnBrokenPipe sets what happens when stdout is closed by its reader.
+func OnBrokenPipe(fn func()) { Stdout.OnBroken(fn) }
+
+func IgnoreSIGPIPE() {
+	signal.Ignore(syscall.SIGPIPE)
+}
+
+func isBrokenPipe(err error) bool {
+	if errors.Is(err, syscall.EPIPE) || errors.Is(err, os.ErrClosed) {
+		return true
+	}
+	m := strings.ToLower(err.Error())
+	return strings.Contains(m, "broken pipe") || strings.Contains(m, "pipe is being closed")
+}
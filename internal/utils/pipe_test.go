@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPipeWriterStreamsAreIndependent(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = r.Close()
+	errw := &pipeWriter{f: w}
+	out := &pipeWriter{f: os.Stdout}
+	var fired int
+	out.OnBroken(func() { fired++ })
+
+	if n, err := errw.Write([]byte("x")); err != nil || n != 1 {
+		t.Fatalf("Write to a broken pipe = %d, %v; want it swallowed", n, err)
+	}
+	if !errw.Closed() {
+		t.Error("broken stream not marked closed")
+	}
+	if out.Closed() || fired != 0 {
+		t.Errorf("other stream closed = %v, handler fired %d times; want untouched", out.Closed(), fired)
+	}
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+var errNotTerminal = errors.New("not a terminal")
+
+var (
+	ansiOnce sync.Once
+	ansiOK   bool
+)
+
+func IsTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	return isTerminal(f.Fd())
+}
+
+func ANSIEnabled() bool {
+	ansiOnce.Do(func() {
+		if !IsTerminal(os.Stdout) {
+			return
+		}
+		ansiOK = enableVirtualTerminal(os.Stdout.Fd())
+	})
+	return ansiOK
+}
+
+func ClearLine() string {
+	if ANSIEnabled() {
+		return "\r\x1b[K"
+	}
+	return "\r"
+}
+
+func MakeRaw(f *os.File) (func(), error) {
+	if f == nil || !IsTerminal(f) {
+		return func() {}, errNotTerminal
+	}
+	return makeRaw(f.Fd())
+}
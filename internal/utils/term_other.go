@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly && !windows
+
+package utils
+
+func isTerminal(uintptr) bool { return false }
+
+func enableVirtualTerminal(uintptr) bool { return false }
+
+func makeRaw(uintptr) (func(), error) { return func() {}, errNotTerminal }
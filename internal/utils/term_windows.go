@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	enableProcessedInput            = 0x0001
+	enableLineInput                 = 0x0002
+	enableEchoInput                 = 0x0004
+	enableVirtualTerminalProcessing = 0x0004
+)
+
+var (
+	kernel32           = syscall.NewLazyDLL("kernel32.dll")
+	procGetConsoleMode = kernel32.NewProc("GetConsoleMode")
+	procSetConsoleMode = kernel32.NewProc("SetConsoleMode")
+)
+
+func getConsoleMode(fd uintptr) (uint32, error) {
+	var m uint32
+	r, _, e := procGetConsoleMode.Call(fd, uintptr(unsafe.Pointer(&m)))
+	if r == 0 {
+		return 0, e
+	}
+	return m, nil
+}
+
+func setConsoleMode(fd uintptr, m uint32) error {
+	r, _, e := procSetConsoleMode.Call(fd, uintptr(m))
+	if r == 0 {
+		return e
+	}
+	return nil
+}
+
+func isTerminal(fd uintptr) bool {
+	_, err := getConsoleMode(fd)
+	return err == nil
+}
+
+func enableVirtualTerminal(fd uintptr) bool {
+	m, err := getConsoleMode(fd)
+	if err != nil {
+		return false
+	}
+	if m&enableVirtualTerminalProcessing != 0 {
+		return true
+	}
+	return setConsoleMode(fd, m|enableVirtualTerminalProcessing) == nil
+}
+
+func makeRaw(fd uintptr) (func(), error) {
+	old, err := getConsoleMode(fd)
+	if err != nil {
+		return func() {}, err
+	}
+	raw := old &^ (enableLineInput | enableEchoInput | enableProcessedInput)
+	if err := setConsoleMode(fd, raw); err != nil {
+		return func() {}, err
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() { _ = setConsoleMode(fd, old) })
+	}, nil
+}
@@ -3,8 +3,13 @@ package utils
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
+
+	xlog "github.com/ghostlawless/xdl/internal/log"
 )
 
 const (
@@ -12,24 +17,64 @@ const (
 	prefixAlert  = "xdl!"
 )
 
-func printTo(writer *os.File, prefix string, format string, args ...any) {
-	fmt.Fprintf(writer, "%s %s\n", prefix, fmt.Sprintf(format, args...))
+var (
+	guardMu sync.RWMutex
+	guard   func(func())
+	logged  atomic.Bool
+)
+
+// SetLogged sends PrintInfo, PrintWarn and the others to the log instead of
+// the terminal, so -headless output stays one JSON record per line.
+func SetLogged(v bool) { logged.Store(v) }
+
+func SetPrintGuard(fn func(write func())) {
+	guardMu.Lock()
+	guard = fn
+	guardMu.Unlock()
+}
+
+func printTo(writer *pipeWriter, prefix, code string, format string, args ...any) {
+	line := fmt.Sprintf("%s %s\n", paint(writer, code, prefix), fmt.Sprintf(format, args...))
+	guardMu.RLock()
+	g := guard
+	guardMu.RUnlock()
+	if g != nil {
+		g(func() { _, _ = io.WriteString(writer, line) })
+		return
+	}
+	_, _ = io.WriteString(writer, line)
 }
 
 func PrintInfo(format string, args ...any) {
-	printTo(os.Stdout, prefixNormal, format, args...)
+	if logged.Load() {
+		xlog.LogInfo("main", fmt.Sprintf(format, args...))
+		return
+	}
+	printTo(Stdout, prefixNormal, "36", format, args...)
 }
 
 func PrintSuccess(format string, args ...any) {
-	printTo(os.Stdout, prefixNormal, format, args...)
+	if logged.Load() {
+		xlog.LogInfo("main", fmt.Sprintf(format, args...))
+		return
+	}
+	printTo(Stdout, prefixNormal, "32", format, args...)
 }
 
 func PrintWarn(format string, args ...any) {
-	printTo(os.Stderr, prefixAlert, format, args...)
+	if logged.Load() {
+		xlog.LogWarn("main", fmt.Sprintf(format, args...))
+		return
+	}
+	printTo(Stderr, prefixAlert, "33", format, args...)
 }
 
 func PrintError(format string, args ...any) {
-	printTo(os.Stderr, prefixAlert, format, args...)
+	if logged.Load() {
+		xlog.LogError("main", fmt.Sprintf(format, args...))
+		return
+	}
+	printTo(Stderr, prefixAlert, "31", format, args...)
 }
 
 func PrintBanner() {
@@ -45,11 +90,11 @@ func PrintBanner() {
 
 xdl > x Downloader
 `
-	fmt.Fprint(os.Stdout, banner+"\n")
+	fmt.Fprint(Stdout, banner+"\n")
 }
 
 func PromptYesNoDefaultYes(question string) bool {
-	fmt.Fprint(os.Stdout, question)
+	fmt.Fprint(Stdout, question)
 	reader := bufio.NewReader(os.Stdin)
 	line, _ := reader.ReadString('\n')
 	line = strings.TrimSpace(strings.ToLower(line))
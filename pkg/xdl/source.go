@@ -0,0 +1,46 @@
+package xdl
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/runtime"
+	"github.com/ghostlawless/xdl/internal/scraper"
+)
+
+// PageFunc receives one page of media, newest first. Returning an error
+// stops the listing and is passed back to the caller.
+type PageFunc func(page int, media []Media) error
+
+// Source is a media host. ResolveUser maps a handle to the host's stable
+// account ID; ListMedia walks that account's media page by page. The
+// downloader, folder layout and progress events are shared by every source.
+type Source interface {
+	Name() string
+	ResolveUser(ctx context.Context, user string) (string, error)
+	ListMedia(ctx context.Context, id, user string, fn PageFunc) error
+}
+
+type xSource struct {
+	cf  *config.EssentialsConfig
+	api *http.Client
+}
+
+func (xSource) Name() string { return "x" }
+
+func (s xSource) ResolveUser(_ context.Context, user string) (string, error) {
+	return scraper.FetchUserID(s.api, s.cf, user)
+}
+
+func (s xSource) ListMedia(_ context.Context, id, user string, fn PageFunc) error {
+	lim := runtime.NewLimiterWith([]byte(user), []byte(strings.TrimSpace(s.cf.Runtime.LimiterSecret)))
+	return scraper.WalkUserMediaPages(s.api, s.cf, id, user, false, lim, func(pg int, _ string, ms []scraper.Media) error {
+		out := make([]Media, len(ms))
+		for i, m := range ms {
+			out[i] = Media(m)
+		}
+		return fn(pg, out)
+	})
+}
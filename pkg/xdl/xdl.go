@@ -0,0 +1,262 @@
+// Package xdl lets Go programs scan and download X/Twitter media without
+// shelling out to the xdl CLI.
+package xdl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghostlawless/xdl/internal/auth"
+	"github.com/ghostlawless/xdl/internal/config"
+	"github.com/ghostlawless/xdl/internal/downloader"
+	"github.com/ghostlawless/xdl/internal/errs"
+	"github.com/ghostlawless/xdl/internal/httpx"
+	"github.com/ghostlawless/xdl/internal/paths"
+	"github.com/ghostlawless/xdl/internal/scraper"
+)
+
+var (
+	ErrUserNotFound     = errs.ErrUserNotFound
+	ErrUserSuspended    = errs.ErrUserSuspended
+	ErrProtectedAccount = errs.ErrProtectedAccount
+	ErrRateLimited      = errs.ErrRateLimited
+	ErrAuthExpired      = errs.ErrAuthExpired
+	ErrShapeChanged     = errs.ErrShapeChanged
+	ErrAborted          = downloader.ErrAborted
+)
+
+// APIError carries the HTTP status, API error code and rate-limit reset of a
+// failed request. Use errors.Is with the Err* values to classify it.
+type APIError = errs.APIError
+
+// Sink receives downloaded files. Paths are relative to the run folder, e.g.
+// images/abc.jpg; Path maps them back to a location for logs and the archive.
+type (
+	Sink       = downloader.Sink
+	SinkWriter = downloader.SinkWriter
+)
+
+// Geo is the location attached to a tweet. Exact is false when only the center
+// of a tagged place is known.
+type Geo = scraper.Geo
+
+// Poll and PollChoice describe the poll attached to a tweet.
+type (
+	Poll       = scraper.Poll
+	PollChoice = scraper.PollChoice
+)
+
+type Media struct {
+	URL     string `json:"url"`
+	Type    string `json:"type"`
+	TweetID string `json:"tweet_id,omitempty"`
+	// Poster is the preview image X shows for a video.
+	Poster string `json:"poster,omitempty"`
+	// AltText is the description the author attached to the media, if any.
+	AltText string `json:"alt_text,omitempty"`
+	// Text is the full text of the tweet the media belongs to.
+	Text string `json:"text,omitempty"`
+	// Geo is set when the tweet carries coordinates or a place.
+	Geo *Geo `json:"geo,omitempty"`
+	// Card names the card a preview image came from, e.g. summary_large_image.
+	Card string `json:"card,omitempty"`
+	// Poll holds the results when the tweet has a poll.
+	Poll *Poll `json:"poll,omitempty"`
+	// Pinned is set for media from the account's pinned tweet.
+	Pinned bool `json:"pinned,omitempty"`
+	// Author and AuthorID name the account that posted the tweet, which
+	// differs from the scanned user for retweets and quotes.
+	Author   string `json:"author,omitempty"`
+	AuthorID string `json:"author_id,omitempty"`
+}
+
+type EventKind int
+
+const (
+	EventScan EventKind = iota
+	EventDownloaded
+	EventSkipped
+	EventFailed
+	EventBytes
+)
+
+// Event reports scan and download progress. Scan events fill Page and Media;
+// download events fill URL, Size, Done and Total.
+type Event struct {
+	Kind  EventKind
+	User  string
+	Page  int
+	Media int
+	URL   string
+	Size  int64
+	Done  int64
+	Total int64
+}
+
+type Options struct {
+	// ConfigPaths lists essentials.json candidates; the built-in defaults are
+	// used when none of them exist.
+	ConfigPaths []string
+	// CookieFile overrides the cookie lookup (cookies.json next to the
+	// config, then the account pool, then a guest session).
+	CookieFile string
+	// OutDir is the download root; defaults to xDownloads.
+	OutDir    string
+	PageSize  int
+	MaxPages  int
+	MaxTweets int
+	// Source lists media for Scan; defaults to the X media timeline.
+	Source Source
+	// Events receives progress; sends never block, so a slow reader
+	// misses events rather than stalling the run.
+	Events chan<- Event
+}
+
+type DownloadOptions struct {
+	// User names the per-user folder under OutDir when Dir is empty.
+	User   string
+	Dir    string
+	DryRun bool
+	// Sink replaces the local folder as the destination for files.
+	Sink Sink
+}
+
+type Result struct {
+	Downloaded int
+	Skipped    int
+	Failed     int
+	Bytes      int64
+}
+
+type Client struct {
+	cf     *config.EssentialsConfig
+	api    *http.Client
+	dl     *http.Client
+	layout *paths.Layout
+	src    Source
+	events chan<- Event
+}
+
+func New(opt Options) (*Client, error) {
+	ps := opt.ConfigPaths
+	if len(ps) == 0 {
+		ps = []string{filepath.Join(".", "config", "essentials.json"), filepath.Join(".", "essentials.json")}
+	}
+	cf, err := config.LoadEssentialsWithFallback(ps)
+	if err != nil {
+		return nil, err
+	}
+	if opt.PageSize > 0 {
+		cf.Runtime.PageSize = opt.PageSize
+	}
+	if opt.MaxPages > 0 {
+		cf.Runtime.MaxPages = opt.MaxPages
+	}
+	if opt.MaxTweets > 0 {
+		cf.Runtime.MaxTweets = opt.MaxTweets
+	}
+
+	pv := []auth.Provider{auth.Static{}, auth.CookieFile{}, &auth.Pool{Dirs: auth.DefaultPoolDirs()}, auth.Guest{}}
+	if k := strings.TrimSpace(opt.CookieFile); k != "" {
+		pv = []auth.Provider{auth.CookieFile{Path: k}}
+	}
+	if _, err := auth.Resolve(cf, pv...); err != nil {
+		return nil, err
+	}
+
+	out := opt.OutDir
+	if strings.TrimSpace(out) == "" {
+		out = paths.DefaultRoot
+	}
+	c := &Client{
+		cf:     cf,
+		api:    httpx.NewAPIClient(cf.HTTPTimeout(), cf.HeaderOrder),
+		dl:     httpx.NewDownloadClient(),
+		layout: paths.New(out),
+		src:    opt.Source,
+		events: opt.Events,
+	}
+	if c.src == nil {
+		c.src = xSource{cf: cf, api: c.api}
+	}
+	return c, nil
+}
+
+// Scan lists a user's media through the client's Source, newest first.
+// Cancelling ctx stops at the next page boundary.
+func (c *Client) Scan(ctx context.Context, user string) ([]Media, error) {
+	user = strings.TrimPrefix(strings.TrimSpace(user), "@")
+	if user == "" {
+		return nil, errors.New("empty user")
+	}
+	id, err := c.src.ResolveUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Media
+	err = c.src.ListMedia(ctx, id, user, func(pg int, ms []Media) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		out = append(out, ms...)
+		c.emit(Event{Kind: EventScan, User: user, Page: pg, Media: len(out)})
+		return nil
+	})
+	return out, err
+}
+
+// Download fetches links into the user's folder (or opt.Dir), skipping files
+// that are already there. Cancelling ctx returns ErrAborted.
+func (c *Client) Download(ctx context.Context, links []Media, opt DownloadOptions) (Result, error) {
+	dir := opt.Dir
+	if dir == "" {
+		d, err := c.layout.RunDir(opt.User, true)
+		if err != nil {
+			return Result{}, err
+		}
+		dir = d
+	}
+	ms := make([]scraper.Media, len(links))
+	for i, m := range links {
+		ms[i] = scraper.Media(m)
+	}
+	sum, err := downloader.DownloadAllCycles(c.dl, c.cf, ms, downloader.Options{
+		RunDir:      dir,
+		User:        opt.User,
+		DryRun:      opt.DryRun,
+		Attempts:    3,
+		Sink:        opt.Sink,
+		Concurrency: c.cf.Concurrency(),
+		ShouldQuit:  func() bool { return ctx.Err() != nil },
+		Progress: func(ev downloader.ProgressEvent) {
+			c.emit(Event{Kind: eventKind(ev.Kind), User: ev.User, URL: ev.URL, Size: ev.Size, Done: ev.Done, Total: ev.Total})
+		},
+	})
+	return Result{Downloaded: sum.Downloaded, Skipped: sum.Skipped, Failed: sum.Failed, Bytes: sum.TotalBytes}, err
+}
+
+func (c *Client) emit(ev Event) {
+	if c.events == nil {
+		return
+	}
+	select {
+	case c.events <- ev:
+	default:
+	}
+}
+
+func eventKind(k downloader.ProgressKind) EventKind {
+	switch k {
+	case downloader.ProgressKindDownloaded:
+		return EventDownloaded
+	case downloader.ProgressKindSkipped:
+		return EventSkipped
+	case downloader.ProgressKindFailed:
+		return EventFailed
+	}
+	return EventBytes
+}